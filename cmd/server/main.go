@@ -1,25 +1,50 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"go-auth-api/internal/auth"
+	"go-auth-api/internal/chirpstack"
 	"go-auth-api/internal/config"
 	"go-auth-api/internal/database"
 	"go-auth-api/internal/handlers"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/lnode"
+	"go-auth-api/internal/mailer"
 	"go-auth-api/internal/middleware"
+	"go-auth-api/internal/models"
 	"go-auth-api/internal/repository"
 	"go-auth-api/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout is how long requests in flight get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatal("Failed to load config:", err)
 	}
+	if _, ok := chirpstack.LookupRegion(cfg.DefaultRegion); !ok {
+		log.Fatalf("Unknown DEFAULT_REGION %q", cfg.DefaultRegion)
+	}
+	if !chirpstack.IsValidEncoding(cfg.EventEncoding) {
+		log.Fatalf("Unknown CHIRPSTACK_INTEGRATION_ENCODING %q, use JSON or PROTOBUF", cfg.EventEncoding)
+	}
+	if !chirpstack.IsValidEncoding(cfg.MQTTEncoding) {
+		log.Fatalf("Unknown CHIRPSTACK_MQTT_ENCODING %q, use JSON or PROTOBUF", cfg.MQTTEncoding)
+	}
 
 	// Connect to database
 	db, err := database.Connect(cfg)
@@ -36,24 +61,158 @@ func main() {
 	defer dbx.Close()
 
 	// Initialize services
-	jwtService := auth.NewJWTService(cfg.JWTSecret)
+	jwtService, err := newJWTService(cfg)
+	if err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
 	userRepo := repository.NewUserRepository(db)
-	chirpStackService := service.NewChirpStackService(cfg, userRepo)
-	userService := service.NewUserService(userRepo, jwtService, chirpStackService)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	revocationStore := service.NewTokenRevocationStore(repository.NewTokenRevocationRepository(db), cfg.AccessTokenTTL)
+	revocationStore.Start(cfg.RevocationSync)
+	tokenService := service.NewTokenService(jwtService, refreshTokenRepo, userRepo, revocationStore, cfg.RefreshTokenTTL)
+	chirpStackClient := newChirpStackClient(cfg)
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize mailer:", err)
+	}
+	codecRepo := repository.NewCodecRepository(db)
+	orgRepo := repository.NewOrganizationRepository(db)
+	orgService := service.NewOrganizationService(cfg, orgRepo, userRepo, chirpStackClient, codecRepo, mail)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	passwordHasher := auth.NewPasswordHasher(cfg.BcryptWorkers, 2*time.Second)
+	accountService := service.NewAccountService(cfg, userRepo, userTokenRepo, tokenService, orgService, passwordHasher, mail)
+	loginGuard := service.NewLoginGuard(repository.NewSecurityRepository(db), userRepo, cfg.LoginLockAfter, cfg.LoginLockDuration)
+	mfaService := service.NewMFAService(userRepo, repository.NewMFARepository(db), userTokenRepo, tokenService, loginGuard, passwordHasher, cfg.MFAIssuer)
+	userService := service.NewUserService(userRepo, tokenService, accountService, mfaService, loginGuard, passwordHasher)
 	userHandler := handlers.NewUserHandler(userService)
+	authHandler := handlers.NewAuthHandler(tokenService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	securityHandler := handlers.NewSecurityHandler(loginGuard)
+	jwksHandler := handlers.NewJWKSHandler(jwtService)
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(db), userRepo)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	orgHandler := handlers.NewOrganizationHandler(orgService)
+	regionHandler := handlers.NewRegionHandler(cfg.DefaultRegion)
 
 	// Initialize device management
 	deviceRepo := repository.NewDeviceRepository(dbx)
-	deviceService := service.NewDeviceService(deviceRepo, userRepo, chirpStackService)
+	deviceService := service.NewDeviceService(deviceRepo, userRepo, orgService, chirpStackClient, codecRepo)
 	deviceHandler := handlers.NewDeviceHandler(deviceService)
 
+	// Versioned payload codecs, the default one starts from the built-in script
+	codecService := service.NewCodecService(codecRepo, chirpStackClient)
+	if err := codecService.EnsureDefaultCodec(); err != nil {
+		log.Printf("Warning: Failed to create the default codec: %v", err)
+	}
+	codecHandler := handlers.NewCodecHandler(codecService)
+
+	// Background jobs provision organizations and devices in ChirpStack
+	jobRepo := repository.NewJobRepository(db)
+	jobQueue := service.NewJobQueue(jobRepo, orgService)
+	jobQueue.Register(models.JobProvisionOrganization, orgService.RunProvisioningJob)
+	jobQueue.Register(models.JobProvisionDevice, deviceService.RunProvisioningJob)
+	codecRollouts := service.NewCodecRolloutRunner(codecRepo, chirpStackClient)
+	jobQueue.Register(models.JobCodecRollout, codecRollouts.RunRolloutJob)
+	jobQueue.Register(models.JobCodecRollback, codecRollouts.RunRollbackJob)
+	jobQueue.Start(cfg.JobWorkers, cfg.JobPollInterval)
+	jobHandler := handlers.NewJobHandler(jobQueue)
+
+	// Events of the ChirpStack HTTP integrations. Organizations provisioned
+	// before the integration, or for another endpoint, get it registered.
+	telemetryRepo := repository.NewTelemetryRepository(db)
+	commandRepo := repository.NewCommandRepository(db)
+	integrationService := service.NewIntegrationService(orgRepo, deviceRepo, telemetryRepo, commandRepo)
+	integrationHandler := handlers.NewIntegrationHandler(integrationService)
+	if queued, err := orgService.EnqueueIntegrationUpdates(); err != nil {
+		log.Printf("Warning: Failed to queue ChirpStack integration updates: %v", err)
+	} else if queued > 0 {
+		log.Printf("Queued ChirpStack integration updates for %d organizations", queued)
+	}
+
+	// Sites running ChirpStack with only the MQTT integration publish the
+	// same events to a broker
+	var mqttSubscriber *service.MQTTSubscriber
+	if cfg.MQTTBroker != "" {
+		mqttSubscriber = service.NewMQTTSubscriber(service.MQTTOptions{
+			Broker:   cfg.MQTTBroker,
+			ClientID: cfg.MQTTClientID,
+			Username: cfg.MQTTUsername,
+			Password: cfg.MQTTPassword,
+			Encoding: cfg.MQTTEncoding,
+		}, orgRepo, integrationService)
+		mqttSubscriber.Start()
+		log.Printf("Subscribing to ChirpStack events on %s", cfg.MQTTBroker)
+	}
+
+	// Lamp measurements of the uplinks, partitioned by month
+	telemetryService := service.NewTelemetryService(deviceService, telemetryRepo)
+	telemetryService.Start()
+	telemetryHandler := handlers.NewTelemetryHandler(telemetryService)
+
+	// Downlink commands to the lamps, tracked through the ack events. The
+	// port and opcodes are not documented by the firmware, so they can be set
+	// once confirmed on a lamp.
+	commandSet := lnode.DefaultCommandSet
+	defaults := false
+	if cfg.CommandFPort != nil {
+		commandSet.FPort = *cfg.CommandFPort
+	} else {
+		defaults = true
+	}
+	for _, setting := range []struct {
+		value  *uint8
+		opcode *lnode.Opcode
+	}{
+		{cfg.CommandDimming, &commandSet.Dimming},
+		{cfg.CommandSwitch, &commandSet.Switch},
+		{cfg.CommandStatus, &commandSet.StatusRequest},
+		{cfg.CommandGPS, &commandSet.GPSRequest},
+	} {
+		if setting.value != nil {
+			*setting.opcode = lnode.Opcode(*setting.value)
+		} else {
+			defaults = true
+		}
+	}
+	if err := commandSet.Validate(); err != nil {
+		log.Fatal("Invalid lamp command settings:", err)
+	}
+	if defaults {
+		log.Println("Warning: Lamp commands use unverified default port or opcodes, set LNODE_COMMAND_FPORT and LNODE_OPCODE_* once confirmed")
+	}
+	commandService := service.NewCommandService(deviceService, commandRepo, chirpStackClient, commandSet)
+	commandHandler := handlers.NewCommandHandler(commandService)
+
+	// Reconciliation between the devices table and ChirpStack
+	reconciler := service.NewReconciler(orgRepo, deviceRepo, jobRepo, chirpStackClient)
+	if chirpStackClient != nil && cfg.ReconcileInterval > 0 {
+		reconciler.Start(cfg.ReconcileInterval, cfg.ReconcileRepair)
+	}
+	reconcileHandler := handlers.NewReconcileHandler(reconciler)
+
+	// Health reports the ChirpStack circuit breaker when the integration is on
+	var chirpStackHealth interfaces.ChirpStackHealthChecker
+	if checker, ok := chirpStackClient.(interfaces.ChirpStackHealthChecker); ok {
+		chirpStackHealth = checker
+	}
+	healthHandler := handlers.NewHealthHandler(chirpStackHealth)
+
 	// Setup Gin router
 	r := gin.Default()
+	// Login throttling keys on the client address, so X-Forwarded-For is only
+	// honoured from configured proxies
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	authMiddleware := middleware.AuthMiddleware(jwtService, revocationStore, apiKeyService)
+	requireMFA := middleware.RequireMFA(mfaService)
+
+	// Public keys for offline token verification
+	r.GET("/.well-known/jwks.json", jwksHandler.JWKS)
 
 	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	r.GET("/health", healthHandler.Health)
 
 	// API routes
 	api := r.Group("/api/v1")
@@ -63,57 +222,244 @@ func main() {
 		{
 			auth.POST("/register", userHandler.Register)
 			auth.POST("/login", userHandler.Login)
+			auth.POST("/login/2fa", mfaHandler.CompleteLogin)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", middleware.OptionalAuthMiddleware(jwtService, revocationStore), authHandler.Logout)
+			auth.POST("/logout-all", authMiddleware, middleware.RejectAPIKeys(), authHandler.LogoutAll)
+			auth.POST("/verify-email", accountHandler.VerifyEmail)
+			auth.POST("/resend-verification", authMiddleware, middleware.RejectAPIKeys(), accountHandler.ResendVerification)
+			auth.POST("/forgot-password", accountHandler.ForgotPassword)
+			auth.POST("/reset-password", accountHandler.ResetPassword)
+
+			// Two-factor authentication
+			twoFactor := auth.Group("/2fa")
+			twoFactor.Use(authMiddleware, middleware.RejectAPIKeys())
+			{
+				twoFactor.POST("/enroll", mfaHandler.Enroll)
+				twoFactor.POST("/confirm", mfaHandler.Confirm)
+				twoFactor.POST("/disable", mfaHandler.Disable)
+				twoFactor.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+			}
 		}
 
 		// Protected routes
 		protected := api.Group("/user")
-		protected.Use(middleware.AuthMiddleware(jwtService))
+		protected.Use(authMiddleware, middleware.RejectAPIKeys())
 		{
 			protected.GET("/profile", userHandler.Profile)
 		}
 
 		// User management routes (protected)
 		users := api.Group("/users")
-		users.Use(middleware.AuthMiddleware(jwtService))
+		users.Use(authMiddleware, middleware.RejectAPIKeys(), requireMFA)
 		{
-			users.GET("", userHandler.GetAllUsers)        // GET /api/v1/users
-			users.GET("/search", userHandler.SearchUsers) // GET /api/v1/users/search
-			users.GET("/:id", userHandler.GetUserByID)    // GET /api/v1/users/:id
-			users.PUT("/:id", userHandler.UpdateUser)     // PUT /api/v1/users/:id
-			users.DELETE("/:id", userHandler.DeleteUser)  // DELETE /api/v1/users/:id
+			// Admin only
+			users.GET("", middleware.RequireRole(models.RoleAdmin), userHandler.GetAllUsers)                                   // GET /api/v1/users
+			users.GET("/search", middleware.RequireRole(models.RoleAdmin), userHandler.SearchUsers)                            // GET /api/v1/users/search
+			users.PUT("/:id/role", middleware.RequireRole(models.RoleAdmin), userHandler.UpdateUserRole)                       // PUT /api/v1/users/:id/role
+			users.POST("/:id/suspend", middleware.RequireRole(models.RoleAdmin), userHandler.SuspendUser)                      // POST /api/v1/users/:id/suspend
+			users.POST("/:id/reactivate", middleware.RequireRole(models.RoleAdmin), userHandler.ReactivateUser)                // POST /api/v1/users/:id/reactivate
+			users.DELETE("/:id/2fa", middleware.RequireRole(models.RoleAdmin), mfaHandler.ResetUserMFA)                        // DELETE /api/v1/users/:id/2fa
+			users.POST("/:id/unlock", middleware.RequireRole(models.RoleAdmin), securityHandler.UnlockUser)                    // POST /api/v1/users/:id/unlock
+			users.GET("/:id/security-events", middleware.RequireRole(models.RoleAdmin), securityHandler.GetUserSecurityEvents) // GET /api/v1/users/:id/security-events
+
+			// Own account or admin
+			self := users.Group("")
+			self.Use(middleware.RequireSelfOrRole("id", models.RoleAdmin))
+			{
+				self.GET("/:id", userHandler.GetUserByID)                    // GET /api/v1/users/:id
+				self.PUT("/:id", userHandler.UpdateUser)                     // PUT /api/v1/users/:id
+				self.DELETE("/:id", userHandler.DeleteUser)                  // DELETE /api/v1/users/:id
+				self.DELETE("/:id/sessions", authHandler.RevokeUserSessions) // DELETE /api/v1/users/:id/sessions
+			}
+		}
+
+		// Security audit log (admin only)
+		api.GET("/security-events", authMiddleware, middleware.RejectAPIKeys(), middleware.RequireRole(models.RoleAdmin), requireMFA, securityHandler.GetSecurityEvents)
+
+		// Role policies (admin only)
+		roles := api.Group("/roles")
+		roles.Use(authMiddleware, middleware.RejectAPIKeys(), middleware.RequireRole(models.RoleAdmin), requireMFA)
+		{
+			roles.GET("/policies", mfaHandler.GetRolePolicies)      // GET /api/v1/roles/policies
+			roles.PUT("/:role/policy", mfaHandler.UpdateRolePolicy) // PUT /api/v1/roles/:role/policy
+		}
+
+		// API key management (interactive login only)
+		apiKeys := api.Group("/api-keys")
+		apiKeys.Use(authMiddleware, middleware.RejectAPIKeys(), requireMFA)
+		{
+			apiKeys.POST("", apiKeyHandler.CreateAPIKey)       // POST /api/v1/api-keys
+			apiKeys.GET("", apiKeyHandler.GetAPIKeys)          // GET /api/v1/api-keys
+			apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey) // DELETE /api/v1/api-keys/:id
+		}
+
+		// Organizations, their members and invitations (interactive login only)
+		orgs := api.Group("/organizations")
+		orgs.Use(authMiddleware, middleware.RejectAPIKeys(), requireMFA)
+		{
+			orgs.POST("", orgHandler.CreateOrganization)                               // POST /api/v1/organizations
+			orgs.GET("", orgHandler.GetOrganizations)                                  // GET /api/v1/organizations
+			orgs.POST("/invitations/accept", orgHandler.AcceptInvitation)              // POST /api/v1/organizations/invitations/accept
+			orgs.GET("/:id", orgHandler.GetOrganization)                               // GET /api/v1/organizations/:id
+			orgs.PUT("/:id", orgHandler.UpdateOrganization)                            // PUT /api/v1/organizations/:id
+			orgs.GET("/:id/jobs", jobHandler.GetOrganizationJobs)                      // GET /api/v1/organizations/:id/jobs
+			orgs.GET("/:id/members", orgHandler.GetMembers)                            // GET /api/v1/organizations/:id/members
+			orgs.PUT("/:id/members/:userId", orgHandler.UpdateMemberRole)              // PUT /api/v1/organizations/:id/members/:userId
+			orgs.DELETE("/:id/members/:userId", orgHandler.RemoveMember)               // DELETE /api/v1/organizations/:id/members/:userId
+			orgs.POST("/:id/invitations", orgHandler.CreateInvitation)                 // POST /api/v1/organizations/:id/invitations
+			orgs.GET("/:id/invitations", orgHandler.GetInvitations)                    // GET /api/v1/organizations/:id/invitations
+			orgs.DELETE("/:id/invitations/:invitationId", orgHandler.RevokeInvitation) // DELETE /api/v1/organizations/:id/invitations/:invitationId
+		}
+
+		// ChirpStack integration events, authenticated with the organization's secret
+		api.POST("/integrations/chirpstack", integrationHandler.HandleChirpStackEvent) // POST /api/v1/integrations/chirpstack?event=up
+
+		// LoRaWAN regions of organizations and device profiles
+		api.GET("/regions", authMiddleware, regionHandler.GetRegions)
+
+		// Background job status, dead letters are managed by admins
+		jobs := api.Group("/jobs")
+		jobs.Use(authMiddleware, requireMFA)
+		{
+			jobs.GET("/:id", middleware.RequireScope(models.ScopeDevicesRead), jobHandler.GetJob)                              // GET /api/v1/jobs/:id
+			jobs.GET("", middleware.RejectAPIKeys(), middleware.RequireRole(models.RoleAdmin), jobHandler.GetJobs)             // GET /api/v1/jobs
+			jobs.POST("/:id/retry", middleware.RejectAPIKeys(), middleware.RequireRole(models.RoleAdmin), jobHandler.RetryJob) // POST /api/v1/jobs/:id/retry
+		}
+
+		// Reconciliation of the devices table with ChirpStack (admin only)
+		reconcile := api.Group("/reconcile")
+		reconcile.Use(authMiddleware, middleware.RejectAPIKeys(), middleware.RequireRole(models.RoleAdmin), requireMFA)
+		{
+			reconcile.POST("", reconcileHandler.Reconcile) // POST /api/v1/reconcile
+			reconcile.GET("", reconcileHandler.LastReport) // GET /api/v1/reconcile
+		}
+
+		// Payload codecs and their rollouts to ChirpStack (admin and operator)
+		codecs := api.Group("/codecs")
+		codecs.Use(authMiddleware, middleware.RequireRole(models.RoleAdmin, models.RoleOperator), middleware.RequireScope(models.ScopeInventoryAdmin), requireMFA)
+		{
+			codecs.POST("", codecHandler.CreateCodec)                                       // POST /api/v1/codecs
+			codecs.GET("", codecHandler.GetCodecs)                                          // GET /api/v1/codecs
+			codecs.GET("/rollouts/:id", codecHandler.GetRollout)                            // GET /api/v1/codecs/rollouts/:id
+			codecs.POST("/rollouts/:id/rollback", codecHandler.RollbackRollout)             // POST /api/v1/codecs/rollouts/:id/rollback
+			codecs.GET("/:id/versions", codecHandler.GetCodecVersions)                      // GET /api/v1/codecs/:id/versions
+			codecs.POST("/:id/versions", codecHandler.CreateCodecVersion)                   // POST /api/v1/codecs/:id/versions
+			codecs.GET("/:id/versions/:version", codecHandler.GetCodecVersion)              // GET /api/v1/codecs/:id/versions/:version
+			codecs.POST("/:id/versions/:version/publish", codecHandler.PublishCodecVersion) // POST /api/v1/codecs/:id/versions/:version/publish
+			codecs.GET("/:id/rollouts", codecHandler.GetCodecRollouts)                      // GET /api/v1/codecs/:id/rollouts
 		}
 
 		// Device management routes (protected)
 		devices := api.Group("/devices")
-		devices.Use(middleware.AuthMiddleware(jwtService))
+		devices.Use(authMiddleware, requireMFA)
 		{
-			// Device version management
-			devices.POST("/versions", deviceHandler.CreateDeviceVersion)
-			devices.GET("/versions", deviceHandler.GetDeviceVersions)
-			devices.GET("/versions/:id", deviceHandler.GetDeviceVersionByID)
-			devices.PUT("/versions/:id", deviceHandler.UpdateDeviceVersion)
-			devices.DELETE("/versions/:id", deviceHandler.DeleteDeviceVersion)
-
-			// Allowed device management
-			devices.POST("/allowed", deviceHandler.CreateAllowedDevice)
-			devices.GET("/allowed", deviceHandler.GetAllowedDevices)
-			devices.GET("/allowed/:devEUI", deviceHandler.GetAllowedDeviceByDevEUI)
-			devices.PUT("/allowed/:devEUI", deviceHandler.UpdateAllowedDevice)
-			devices.DELETE("/allowed/:devEUI", deviceHandler.DeleteAllowedDevice)
+			read := middleware.RequireScope(models.ScopeDevicesRead)
+			write := middleware.RequireScope(models.ScopeDevicesWrite)
+
+			// Device versions can be browsed by everyone
+			devices.GET("/versions", read, deviceHandler.GetDeviceVersions)
+			devices.GET("/versions/:id", read, deviceHandler.GetDeviceVersionByID)
+
+			// Device inventory management (admin and operator)
+			inventory := devices.Group("")
+			inventory.Use(middleware.RequireRole(models.RoleAdmin, models.RoleOperator), middleware.RequireScope(models.ScopeInventoryAdmin))
+			{
+				// Device version management
+				inventory.POST("/versions", deviceHandler.CreateDeviceVersion)
+				inventory.PUT("/versions/:id", deviceHandler.UpdateDeviceVersion)
+				inventory.DELETE("/versions/:id", deviceHandler.DeleteDeviceVersion)
+
+				// Allowed device management
+				inventory.POST("/allowed", deviceHandler.CreateAllowedDevice)
+				inventory.GET("/allowed", deviceHandler.GetAllowedDevices)
+				inventory.GET("/allowed/:devEUI", deviceHandler.GetAllowedDeviceByDevEUI)
+				inventory.PUT("/allowed/:devEUI", deviceHandler.UpdateAllowedDevice)
+				inventory.DELETE("/allowed/:devEUI", deviceHandler.DeleteAllowedDevice)
+			}
 
 			// User device management
-			devices.POST("", deviceHandler.CreateDevice)       // Create device for authenticated user
-			devices.GET("/my", deviceHandler.GetMyDevices)     // Get devices for authenticated user
-			devices.GET("/all", deviceHandler.GetAllDevices)   // Get all devices (admin)
-			devices.GET("/:id", deviceHandler.GetDeviceByID)   // Get device by ID
-			devices.PUT("/:id", deviceHandler.UpdateDevice)    // Update device
-			devices.DELETE("/:id", deviceHandler.DeleteDevice) // Delete device
+			devices.POST("", write, deviceHandler.CreateDevice)                                              // Create device in one of the user's organizations
+			devices.GET("/my", read, deviceHandler.GetMyDevices)                                             // Get devices of the user's organizations
+			devices.GET("/all", read, middleware.RequireRole(models.RoleAdmin), deviceHandler.GetAllDevices) // Get all devices (admin)
+			devices.GET("/:id", read, deviceHandler.GetDeviceByID)                                           // Get device by ID
+			devices.GET("/:id/telemetry", read, telemetryHandler.GetDeviceTelemetry)                         // Get telemetry of a device
+			devices.POST("/:id/commands", write, commandHandler.SendCommand)                                 // Send a command to a device
+			devices.GET("/:id/commands", read, commandHandler.GetDeviceCommands)                             // Get commands sent to a device
+			devices.PUT("/:id", write, deviceHandler.UpdateDevice)                                           // Update device
+			devices.DELETE("/:id", write, deviceHandler.DeleteDevice)                                        // Delete device
 		}
 	}
 
 	// Start server
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := r.Run(":" + cfg.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// On SIGINT or SIGTERM finish the requests in flight, then disconnect from
+	// the MQTT broker so events queued for us stay in the session instead of
+	// being delivered to a connection that is going away
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Warning: Server shutdown did not complete: %v", err)
 	}
+	if mqttSubscriber != nil {
+		mqttSubscriber.Stop()
+	}
+}
+
+// newJWTService signs tokens with the configured asymmetric key, or falls back
+// to the shared HMAC secret when no signing key file is set.
+func newJWTService(cfg *config.Config) (*auth.JWTService, error) {
+	if cfg.JWTSigningKeyFile == "" {
+		if cfg.JWTSecret == "your-secret-key" {
+			log.Println("Warning: JWT_SECRET is the default value, configure JWT_SIGNING_KEY_FILE or a strong JWT_SECRET")
+		}
+		return auth.NewJWTService(cfg.JWTSecret, cfg.AccessTokenTTL), nil
+	}
+
+	signingKey, err := auth.LoadSigningKey(cfg.JWTSigningKeyID, cfg.JWTSigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationKeys, err := auth.LoadVerificationKeys(cfg.JWTVerifyKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Signing access tokens with %s key %q", signingKey.Method.Alg(), signingKey.ID)
+	return auth.NewJWTServiceWithKeys(signingKey, verificationKeys, cfg.AccessTokenTTL), nil
+}
+
+// newChirpStackClient returns nil when the ChirpStack integration is disabled
+// or has no API token, which the services treat as "don't provision".
+func newChirpStackClient(cfg *config.Config) interfaces.ChirpStackClient {
+	if !cfg.ChirpStackEnabled || cfg.ChirpStackToken == "" {
+		return nil
+	}
+
+	// CHIRPSTACK_MAX_RETRIES=0 turns retries off, Options treats 0 as "default"
+	retries := cfg.ChirpStackRetries
+	if retries == 0 {
+		retries = -1
+	}
+
+	baseURL := fmt.Sprintf("http://%s:%s/api", cfg.ChirpStackHost, cfg.ChirpStackPort)
+	return chirpstack.NewClient(baseURL, cfg.ChirpStackToken, chirpstack.Options{
+		Timeout:          cfg.ChirpStackTimeout,
+		MaxRetries:       retries,
+		BreakerThreshold: cfg.BreakerThreshold,
+		BreakerCooldown:  cfg.BreakerCooldown,
+	})
 }
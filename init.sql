@@ -1,2 +1,22 @@
 -- Load initial schema
 \i /docker-entrypoint-initdb.d/migrations/001_initial_schema.sql
+\i /docker-entrypoint-initdb.d/migrations/002_user_roles.sql
+\i /docker-entrypoint-initdb.d/migrations/003_refresh_tokens.sql
+\i /docker-entrypoint-initdb.d/migrations/004_token_revocations.sql
+\i /docker-entrypoint-initdb.d/migrations/005_api_keys.sql
+\i /docker-entrypoint-initdb.d/migrations/006_email_verification.sql
+\i /docker-entrypoint-initdb.d/migrations/007_two_factor.sql
+\i /docker-entrypoint-initdb.d/migrations/008_login_protection.sql
+\i /docker-entrypoint-initdb.d/migrations/009_organizations.sql
+\i /docker-entrypoint-initdb.d/migrations/010_provisioning_saga.sql
+\i /docker-entrypoint-initdb.d/migrations/011_job_queue.sql
+\i /docker-entrypoint-initdb.d/migrations/012_otaa.sql
+\i /docker-entrypoint-initdb.d/migrations/013_device_profile_templates.sql
+\i /docker-entrypoint-initdb.d/migrations/014_regions.sql
+\i /docker-entrypoint-initdb.d/migrations/015_codecs.sql
+\i /docker-entrypoint-initdb.d/migrations/016_chirpstack_integration.sql
+\i /docker-entrypoint-initdb.d/migrations/017_integration_encoding.sql
+\i /docker-entrypoint-initdb.d/migrations/018_mqtt_integration.sql
+\i /docker-entrypoint-initdb.d/migrations/019_telemetry.sql
+\i /docker-entrypoint-initdb.d/migrations/020_device_commands.sql
+\i /docker-entrypoint-initdb.d/migrations/021_command_queue_items.sql
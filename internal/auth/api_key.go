@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKeyPrefix marks a bearer credential as an API key rather than a JWT
+const APIKeyPrefix = "lnk_"
+
+const apiKeyLookupLength = 8
+
+// GenerateAPIKey returns a new API key of the form lnk_<lookup>_<secret>,
+// the lookup prefix used to find it and the hash that is stored.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	lookup := make([]byte, apiKeyLookupLength/2)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(lookup); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	prefix = hex.EncodeToString(lookup)
+	key = APIKeyPrefix + prefix + "_" + hex.EncodeToString(secret)
+	return key, prefix, HashToken(key), nil
+}
+
+// IsAPIKey reports whether a credential looks like an API key
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// ParseAPIKey extracts the lookup prefix from an API key
+func ParseAPIKey(key string) (prefix string, ok bool) {
+	rest := strings.TrimPrefix(key, APIKeyPrefix)
+	if len(rest) <= apiKeyLookupLength+1 || rest[apiKeyLookupLength] != '_' {
+		return "", false
+	}
+	return rest[:apiKeyLookupLength], true
+}
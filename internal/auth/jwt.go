@@ -2,48 +2,124 @@ package auth
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// Token timestamps carry microseconds, the precision Postgres stores the
+// revocation cutoffs with. With whole seconds a token issued right after
+// all tokens of a user were revoked would fall on the cutoff.
+func init() {
+	jwt.TimePrecision = time.Microsecond
+}
+
 type JWTClaims struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
+	Role   string    `json:"role"`
+	// Authentication methods used to log in, e.g. ["pwd", "otp"]
+	AMR []string `json:"amr,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// JWTService issues and validates access tokens. By default tokens are signed
+// with a shared HMAC secret (HS256). When a signing key is configured tokens
+// are signed with it (RS256 or EdDSA) and carry its ID in the "kid" header,
+// and only tokens signed by one of the verification keys are accepted.
 type JWTService struct {
-	secretKey string
+	secretKey        string
+	accessTokenTTL   time.Duration
+	signingKey       *SigningKey
+	verificationKeys map[string]*VerificationKey
+}
+
+func NewJWTService(secretKey string, accessTokenTTL time.Duration) *JWTService {
+	return &JWTService{secretKey: secretKey, accessTokenTTL: accessTokenTTL}
+}
+
+// NewJWTServiceWithKeys creates a JWTService that signs with signingKey and
+// accepts tokens signed by signingKey or any of verificationKeys. Keeping the
+// previous signing key in verificationKeys allows rotating keys without
+// invalidating tokens that are already out.
+func NewJWTServiceWithKeys(signingKey *SigningKey, verificationKeys []*VerificationKey, accessTokenTTL time.Duration) *JWTService {
+	keys := map[string]*VerificationKey{signingKey.ID: signingKey.Public()}
+	for _, key := range verificationKeys {
+		keys[key.ID] = key
+	}
+
+	return &JWTService{
+		accessTokenTTL:   accessTokenTTL,
+		signingKey:       signingKey,
+		verificationKeys: keys,
+	}
+}
+
+// AccessTokenTTL returns how long generated access tokens stay valid
+func (j *JWTService) AccessTokenTTL() time.Duration {
+	return j.accessTokenTTL
 }
 
-func NewJWTService(secretKey string) *JWTService {
-	return &JWTService{secretKey: secretKey}
+// IsAsymmetric reports whether tokens are signed with an asymmetric key
+func (j *JWTService) IsAsymmetric() bool {
+	return j.signingKey != nil
 }
 
-func (j *JWTService) GenerateToken(userID uuid.UUID, email string) (string, error) {
+// GenerateToken issues an access token. amr lists the authentication methods
+// the user logged in with.
+func (j *JWTService) GenerateToken(userID uuid.UUID, email, role string, amr ...string) (string, error) {
 	claims := JWTClaims{
 		UserID: userID,
 		Email:  email,
+		Role:   role,
+		AMR:    amr,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+	return j.sign(claims)
 }
 
-func (j *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+func (j *JWTService) sign(claims jwt.Claims) (string, error) {
+	if j.signingKey == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(j.secretKey))
+	}
+
+	token := jwt.NewWithClaims(j.signingKey.Method, claims)
+	token.Header["kid"] = j.signingKey.ID
+	return token.SignedString(j.signingKey.PrivateKey)
+}
+
+func (j *JWTService) keyFunc(token *jwt.Token) (interface{}, error) {
+	if j.signingKey == nil {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(j.secretKey), nil
-	})
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := j.verificationKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.PublicKey, nil
+}
+
+func (j *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, j.keyFunc)
 
 	if err != nil {
 		return nil, err
@@ -55,3 +131,14 @@ func (j *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
 
 	return nil, fmt.Errorf("invalid token")
 }
+
+// JWKS returns the public verification keys. It is empty when tokens are
+// signed with a shared secret.
+func (j *JWTService) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range j.verificationKeys {
+		jwks.Keys = append(jwks.Keys, key.JWK())
+	}
+	sort.Slice(jwks.Keys, func(a, b int) bool { return jwks.Keys[a].KeyID < jwks.Keys[b].KeyID })
+	return jwks
+}
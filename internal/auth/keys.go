@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is an asymmetric key used to sign access tokens. Its ID is sent
+// in the "kid" header so verifiers can pick the matching public key.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+}
+
+// VerificationKey is a public key accepted when validating access tokens
+type VerificationKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	PublicKey crypto.PublicKey
+}
+
+// JWK is a single entry of a JSON Web Key Set (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Public returns the verification key matching a signing key
+func (k *SigningKey) Public() *VerificationKey {
+	return &VerificationKey{ID: k.ID, Method: k.Method, PublicKey: k.PrivateKey.Public()}
+}
+
+// JWK returns the public JWK representation of the key
+func (k *VerificationKey) JWK() JWK {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Method.Alg()}
+
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	return jwk
+}
+
+// LoadSigningKey reads a PEM encoded RSA or Ed25519 private key. RSA keys
+// sign with RS256 and Ed25519 keys with EdDSA.
+func LoadSigningKey(id, path string) (*SigningKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, PrivateKey: k}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, PrivateKey: k}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported private key algorithm in %s", path)
+}
+
+// LoadVerificationKey reads a PEM encoded RSA or Ed25519 public key. A private
+// key file is accepted too, in which case its public half is used.
+func LoadVerificationKey(id, path string) (*VerificationKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.Type != "PUBLIC KEY" {
+		signingKey, err := LoadSigningKey(id, path)
+		if err != nil {
+			return nil, err
+		}
+		return signingKey.Public(), nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &VerificationKey{ID: id, Method: jwt.SigningMethodRS256, PublicKey: k}, nil
+	case ed25519.PublicKey:
+		return &VerificationKey{ID: id, Method: jwt.SigningMethodEdDSA, PublicKey: k}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported public key algorithm in %s", path)
+}
+
+// LoadVerificationKeys loads a comma separated list of kid=path entries
+func LoadVerificationKeys(spec string) ([]*VerificationKey, error) {
+	var keys []*VerificationKey
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, path, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || path == "" {
+			return nil, fmt.Errorf("invalid verification key entry %q, expected kid=path", entry)
+		}
+
+		key, err := LoadVerificationKey(id, path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	return block, nil
+}
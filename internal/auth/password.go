@@ -1,9 +1,15 @@
 package auth
 
 import (
+	"errors"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrHasherBusy is returned when no bcrypt worker became free in time
+var ErrHasherBusy = errors.New("server is busy, try again later")
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
@@ -13,3 +19,54 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// PasswordHasher runs bcrypt on a bounded number of workers so a flood of
+// login requests cannot use up all CPUs. Requests wait up to maxWait for a
+// free worker and fail with ErrHasherBusy after that.
+type PasswordHasher struct {
+	workers chan struct{}
+	maxWait time.Duration
+}
+
+func NewPasswordHasher(workers int, maxWait time.Duration) *PasswordHasher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &PasswordHasher{workers: make(chan struct{}, workers), maxWait: maxWait}
+}
+
+func (h *PasswordHasher) acquire() error {
+	timer := time.NewTimer(h.maxWait)
+	defer timer.Stop()
+
+	select {
+	case h.workers <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrHasherBusy
+	}
+}
+
+func (h *PasswordHasher) release() {
+	<-h.workers
+}
+
+// Hash is HashPassword on a pool worker
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if err := h.acquire(); err != nil {
+		return "", err
+	}
+	defer h.release()
+
+	return HashPassword(password)
+}
+
+// Check is CheckPasswordHash on a pool worker
+func (h *PasswordHasher) Check(password, hash string) (bool, error) {
+	if err := h.acquire(); err != nil {
+		return false, err
+	}
+	defer h.release()
+
+	return CheckPasswordHash(password, hash), nil
+}
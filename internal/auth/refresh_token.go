@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// GenerateRefreshToken returns a new opaque refresh token together with the
+// hash that is stored in the database. The plain token is only ever handed
+// to the client.
+func GenerateRefreshToken() (token string, hash string, err error) {
+	token, hash, err = GenerateOpaqueToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return token, hash, nil
+}
+
+// GenerateOpaqueToken returns a random URL-safe token and its hash. It is used
+// for the single-use tokens sent by email.
+func GenerateOpaqueToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash of an opaque token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,105 @@
+package chirpstack
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// BreakerStatus is a snapshot of the circuit breaker for health reporting
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// breaker opens after threshold consecutive failures and rejects calls until
+// cooldown has passed. Then a single probe call is let through: success
+// closes the circuit, failure opens it for another cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lastError string
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, state: CircuitClosed}
+}
+
+// allow reports whether a call may go out now
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		// Only one probe at a time
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastError = err.Error()
+	b.probing = false
+
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		retryAt := openedAt.Add(b.cooldown)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
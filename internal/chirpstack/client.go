@@ -0,0 +1,422 @@
+// Package chirpstack is a client for the ChirpStack v4 REST API
+package chirpstack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go-auth-api/internal/models"
+)
+
+// Client talks to the ChirpStack REST API with an API token. Failed calls
+// are retried with backoff where that is safe, and a circuit breaker fails
+// calls fast while ChirpStack is down.
+type Client struct {
+	baseURL    string
+	token      string
+	opts       Options
+	httpClient *http.Client
+	breaker    *breaker
+}
+
+// NewClient creates a client for the API at baseURL, e.g. http://host:8090/api
+func NewClient(baseURL, token string, opts Options) *Client {
+	opts = opts.withDefaults()
+	return &Client{
+		baseURL: baseURL,
+		token:   token,
+		opts:    opts,
+		httpClient: &http.Client{
+			Timeout: opts.Timeout,
+		},
+		breaker: newBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+	}
+}
+
+// CircuitStatus returns the state of the circuit breaker
+func (c *Client) CircuitStatus() BreakerStatus {
+	return c.breaker.status()
+}
+
+type idResponse struct {
+	ID string `json:"id"`
+}
+
+// Tenants
+
+func (c *Client) CreateTenant(tenant models.ChirpStackTenant) (string, error) {
+	var response idResponse
+	if err := c.do(http.MethodPost, "/tenants", models.CreateTenantRequest{Tenant: tenant}, &response); err != nil {
+		return "", fmt.Errorf("failed to create tenant: %w", err)
+	}
+	return response.ID, nil
+}
+
+func (c *Client) GetTenant(id string) (*models.ChirpStackTenant, error) {
+	var response models.CreateTenantRequest
+	if err := c.do(http.MethodGet, "/tenants/"+url.PathEscape(id), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return &response.Tenant, nil
+}
+
+// FindTenant returns the ID of the tenant named name, or ErrNotFound
+func (c *Client) FindTenant(name string) (string, error) {
+	id, err := c.findByName("/tenants", url.Values{}, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to find tenant: %w", err)
+	}
+	return id, nil
+}
+
+func (c *Client) DeleteTenant(id string) error {
+	if err := c.do(http.MethodDelete, "/tenants/"+url.PathEscape(id), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete tenant: %w", err)
+	}
+	return nil
+}
+
+// Applications
+
+func (c *Client) CreateApplication(app models.ChirpStackApplication) (string, error) {
+	var response idResponse
+	if err := c.do(http.MethodPost, "/applications", models.CreateApplicationRequest{Application: app}, &response); err != nil {
+		return "", fmt.Errorf("failed to create application: %w", err)
+	}
+	return response.ID, nil
+}
+
+func (c *Client) GetApplication(id string) (*models.ChirpStackApplication, error) {
+	var response models.CreateApplicationRequest
+	if err := c.do(http.MethodGet, "/applications/"+url.PathEscape(id), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get application: %w", err)
+	}
+	return &response.Application, nil
+}
+
+// FindApplication returns the ID of the application named name in a tenant,
+// or ErrNotFound
+func (c *Client) FindApplication(tenantID, name string) (string, error) {
+	id, err := c.findByName("/applications", url.Values{"tenantId": {tenantID}}, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to find application: %w", err)
+	}
+	return id, nil
+}
+
+func (c *Client) DeleteApplication(id string) error {
+	if err := c.do(http.MethodDelete, "/applications/"+url.PathEscape(id), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete application: %w", err)
+	}
+	return nil
+}
+
+// HTTP integrations
+
+// CreateHTTPIntegration adds the HTTP integration of an application. An
+// application has at most one, creating a second one is a conflict.
+func (c *Client) CreateHTTPIntegration(integration models.ChirpStackHTTPIntegration) error {
+	if err := c.do(http.MethodPost, httpIntegrationPath(integration.ApplicationID), httpIntegrationRequest{integration}, nil); err != nil {
+		return fmt.Errorf("failed to create HTTP integration: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) UpdateHTTPIntegration(integration models.ChirpStackHTTPIntegration) error {
+	if err := c.do(http.MethodPut, httpIntegrationPath(integration.ApplicationID), httpIntegrationRequest{integration}, nil); err != nil {
+		return fmt.Errorf("failed to update HTTP integration: %w", err)
+	}
+	return nil
+}
+
+type httpIntegrationRequest struct {
+	Integration models.ChirpStackHTTPIntegration `json:"integration"`
+}
+
+func httpIntegrationPath(applicationID string) string {
+	return "/applications/" + url.PathEscape(applicationID) + "/integrations/http"
+}
+
+// Device profiles
+
+func (c *Client) CreateDeviceProfile(profile models.ChirpStackDeviceProfile) (string, error) {
+	var response idResponse
+	if err := c.do(http.MethodPost, "/device-profiles", models.CreateDeviceProfileRequest{DeviceProfile: profile}, &response); err != nil {
+		return "", fmt.Errorf("failed to create device profile: %w", err)
+	}
+	return response.ID, nil
+}
+
+func (c *Client) GetDeviceProfile(id string) (*models.ChirpStackDeviceProfile, error) {
+	var response models.CreateDeviceProfileRequest
+	if err := c.do(http.MethodGet, "/device-profiles/"+url.PathEscape(id), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get device profile: %w", err)
+	}
+	return &response.DeviceProfile, nil
+}
+
+func (c *Client) UpdateDeviceProfile(id string, profile models.ChirpStackDeviceProfile) error {
+	if err := c.do(http.MethodPut, "/device-profiles/"+url.PathEscape(id), models.CreateDeviceProfileRequest{DeviceProfile: profile}, nil); err != nil {
+		return fmt.Errorf("failed to update device profile: %w", err)
+	}
+	return nil
+}
+
+// FindDeviceProfile returns the ID of the device profile named name in a
+// tenant, or ErrNotFound
+func (c *Client) FindDeviceProfile(tenantID, name string) (string, error) {
+	id, err := c.findByName("/device-profiles", url.Values{"tenantId": {tenantID}}, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to find device profile: %w", err)
+	}
+	return id, nil
+}
+
+func (c *Client) DeleteDeviceProfile(id string) error {
+	if err := c.do(http.MethodDelete, "/device-profiles/"+url.PathEscape(id), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete device profile: %w", err)
+	}
+	return nil
+}
+
+// Devices
+
+func (c *Client) CreateDevice(device models.ChirpStackDeviceInfo) error {
+	if err := c.do(http.MethodPost, "/devices", models.ChirpStackCreateDeviceRequest{Device: device}, nil); err != nil {
+		return fmt.Errorf("failed to create device: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) GetDevice(devEUI string) (*models.ChirpStackDeviceInfo, error) {
+	var response models.ChirpStackCreateDeviceRequest
+	if err := c.do(http.MethodGet, devicePath(devEUI, ""), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	return &response.Device, nil
+}
+
+func (c *Client) UpdateDevice(device models.ChirpStackDeviceInfo) error {
+	if err := c.do(http.MethodPut, devicePath(device.DevEUI, ""), models.ChirpStackCreateDeviceRequest{Device: device}, nil); err != nil {
+		return fmt.Errorf("failed to update device: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) DeleteDevice(devEUI string) error {
+	if err := c.do(http.MethodDelete, devicePath(devEUI, ""), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	return nil
+}
+
+// ListDevices returns a page of the devices in an application and the total count
+func (c *Client) ListDevices(applicationID string, limit, offset int) ([]models.ChirpStackDeviceListItem, int, error) {
+	query := url.Values{}
+	query.Set("applicationId", applicationID)
+	query.Set("limit", fmt.Sprint(limit))
+	query.Set("offset", fmt.Sprint(offset))
+
+	var response struct {
+		TotalCount int                               `json:"totalCount"`
+		Result     []models.ChirpStackDeviceListItem `json:"result"`
+	}
+	if err := c.do(http.MethodGet, "/devices?"+query.Encode(), nil, &response); err != nil {
+		return nil, 0, fmt.Errorf("failed to list devices: %w", err)
+	}
+	return response.Result, response.TotalCount, nil
+}
+
+// ActivateDevice activates an ABP device with its session keys
+func (c *Client) ActivateDevice(devEUI string, activation models.ChirpStackDeviceActivation) error {
+	req := models.ChirpStackActivateDeviceRequest{DeviceActivation: activation}
+	if err := c.do(http.MethodPost, devicePath(devEUI, "/activate"), req, nil); err != nil {
+		return fmt.Errorf("failed to activate device: %w", err)
+	}
+	return nil
+}
+
+// GetDeviceActivation returns the ABP session of a device, nil when it is
+// not activated
+func (c *Client) GetDeviceActivation(devEUI string) (*models.ChirpStackDeviceActivation, error) {
+	var response struct {
+		DeviceActivation *models.ChirpStackDeviceActivation `json:"deviceActivation"`
+	}
+	if err := c.do(http.MethodGet, devicePath(devEUI, "/activation"), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get device activation: %w", err)
+	}
+	return response.DeviceActivation, nil
+}
+
+// Device keys
+
+func (c *Client) CreateDeviceKeys(devEUI string, keys models.ChirpStackDeviceKeys) error {
+	req := struct {
+		DeviceKeys models.ChirpStackDeviceKeys `json:"deviceKeys"`
+	}{keys}
+	if err := c.do(http.MethodPost, devicePath(devEUI, "/keys"), req, nil); err != nil {
+		return fmt.Errorf("failed to create device keys: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) GetDeviceKeys(devEUI string) (*models.ChirpStackDeviceKeys, error) {
+	var response struct {
+		DeviceKeys models.ChirpStackDeviceKeys `json:"deviceKeys"`
+	}
+	if err := c.do(http.MethodGet, devicePath(devEUI, "/keys"), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get device keys: %w", err)
+	}
+	return &response.DeviceKeys, nil
+}
+
+func (c *Client) DeleteDeviceKeys(devEUI string) error {
+	if err := c.do(http.MethodDelete, devicePath(devEUI, "/keys"), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete device keys: %w", err)
+	}
+	return nil
+}
+
+// Device queue
+
+// EnqueueDownlink adds a downlink to the device queue and returns its ID
+func (c *Client) EnqueueDownlink(item models.ChirpStackQueueItem) (string, error) {
+	req := struct {
+		QueueItem models.ChirpStackQueueItem `json:"queueItem"`
+	}{item}
+	var response idResponse
+	if err := c.do(http.MethodPost, devicePath(item.DevEUI, "/queue"), req, &response); err != nil {
+		return "", fmt.Errorf("failed to enqueue downlink: %w", err)
+	}
+	return response.ID, nil
+}
+
+func (c *Client) GetDeviceQueue(devEUI string) ([]models.ChirpStackQueueItem, error) {
+	var response struct {
+		Result []models.ChirpStackQueueItem `json:"result"`
+	}
+	if err := c.do(http.MethodGet, devicePath(devEUI, "/queue"), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get device queue: %w", err)
+	}
+	return response.Result, nil
+}
+
+func (c *Client) FlushDeviceQueue(devEUI string) error {
+	if err := c.do(http.MethodDelete, devicePath(devEUI, "/queue"), nil, nil); err != nil {
+		return fmt.Errorf("failed to flush device queue: %w", err)
+	}
+	return nil
+}
+
+// findByName searches a list endpoint for a resource named exactly name.
+// ChirpStack searches by substring, so the results are matched again here.
+func (c *Client) findByName(path string, query url.Values, name string) (string, error) {
+	query.Set("search", name)
+	query.Set("limit", "100")
+
+	var response struct {
+		Result []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"result"`
+	}
+	if err := c.do(http.MethodGet, path+"?"+query.Encode(), nil, &response); err != nil {
+		return "", err
+	}
+
+	for _, item := range response.Result {
+		if item.Name == name {
+			return item.ID, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+func devicePath(devEUI, suffix string) string {
+	return "/devices/" + url.PathEscape(devEUI) + suffix
+}
+
+// do sends a JSON request and decodes the response into out when it is not
+// nil. Non-2xx responses return an *APIError, transport failures and an open
+// circuit wrap ErrUnavailable.
+func (c *Client) do(method, path string, in, out interface{}) error {
+	var payload []byte
+	if in != nil {
+		jsonData, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		payload = jsonData
+	}
+
+	for attempt := 0; ; attempt++ {
+		if !c.breaker.allow() {
+			return ErrCircuitOpen
+		}
+
+		statusCode, retryAfter, err := c.attempt(method, path, payload, out)
+		if isOutage(statusCode) && err != nil {
+			c.breaker.failure(err)
+		} else {
+			c.breaker.success()
+		}
+
+		if err == nil || attempt >= c.opts.MaxRetries || !shouldRetry(method, statusCode) {
+			return err
+		}
+
+		delay := c.opts.backoff(attempt)
+		if d, ok := ParseRetryAfter(retryAfter, time.Now()); ok {
+			delay = d
+		}
+		if delay > c.opts.MaxDelay {
+			delay = c.opts.MaxDelay
+		}
+		time.Sleep(delay)
+	}
+}
+
+// attempt makes a single HTTP call. It returns the status code (0 when no
+// response arrived) and the Retry-After header.
+func (c *Client) attempt(method, path string, payload []byte, out interface{}) (int, string, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: failed to read response: %v", ErrUnavailable, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, resp.Header.Get("Retry-After"), newAPIError(resp.StatusCode, responseBody)
+	}
+
+	if out != nil && len(responseBody) > 0 {
+		if err := json.Unmarshal(responseBody, out); err != nil {
+			return resp.StatusCode, "", fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+
+	return resp.StatusCode, "", nil
+}
@@ -0,0 +1,190 @@
+package chirpstack
+
+import (
+	"fmt"
+	"strings"
+
+	"go-auth-api/internal/lnode"
+	"go-auth-api/internal/models"
+)
+
+// DefaultTenant is the tenant created for each organization
+func DefaultTenant(name string) models.ChirpStackTenant {
+	return models.ChirpStackTenant{
+		CanHaveGateways:     true,
+		Description:         name,
+		MaxDeviceCount:      10000,
+		MaxGatewayCount:     10000,
+		Name:                name,
+		PrivateGatewaysDown: true,
+		PrivateGatewaysUp:   true,
+		Tags:                make(map[string]string),
+	}
+}
+
+// TenantName is the name of an organization's tenant. It includes the
+// organization ID so that provisioning can find a tenant whose creation it
+// did not see complete.
+func TenantName(orgID, orgName string) string {
+	return fmt.Sprintf("%s (%s)", orgName, orgID)
+}
+
+// DefaultApplicationName is the name of the application in every tenant
+const DefaultApplicationName = "Lnode"
+
+// DefaultApplication is the application devices are added to in a tenant
+func DefaultApplication(tenantID, name string) models.ChirpStackApplication {
+	return models.ChirpStackApplication{
+		TenantID:    tenantID,
+		Name:        name,
+		Description: fmt.Sprintf("Application for %s", name),
+		Tags:        make(map[string]string),
+	}
+}
+
+// DefaultHTTPIntegration makes ChirpStack post the events of an application
+// to url in encoding, authenticated with secret as bearer token
+func DefaultHTTPIntegration(applicationID, url, encoding, secret string) models.ChirpStackHTTPIntegration {
+	return models.ChirpStackHTTPIntegration{
+		ApplicationID:    applicationID,
+		Headers:          map[string]string{"Authorization": "Bearer " + secret},
+		Encoding:         encoding,
+		EventEndpointURL: url,
+	}
+}
+
+// DefaultMACVersion is the LoRaWAN version of the default device profiles
+const DefaultMACVersion = "LORAWAN_1_0_3"
+
+// DefaultDeviceProfile is the ABP class C profile for Lnode devices in a
+// region, with the JavaScript codec that decodes their uplinks
+func DefaultDeviceProfile(tenantID, region string) models.ChirpStackDeviceProfile {
+	return DeviceProfileFromTemplate(tenantID, "RAK_ABP", DefaultProfileTemplate(), region, false)
+}
+
+// DefaultOTAADeviceProfile is the same profile for Lnode devices that join
+// over OTAA
+func DefaultOTAADeviceProfile(tenantID, region string) models.ChirpStackDeviceProfile {
+	return DeviceProfileFromTemplate(tenantID, "RAK_OTAA", DefaultProfileTemplate(), region, true)
+}
+
+// DefaultProfileTemplate is the template of the Lnode profiles, used for
+// device versions that don't have their own. It has no region, the profiles
+// are created in the organization's. The codec is generated from the lnode
+// frame spec.
+func DefaultProfileTemplate() models.DeviceProfileTemplate {
+	measurements := map[string]models.DeviceProfileMeasurement{
+		"Dimming":       {Name: "", Kind: "UNKNOWN"},
+		"Energy":        {Name: "", Kind: "UNKNOWN"},
+		"PF":            {Name: "", Kind: "UNKNOWN"},
+		"Power":         {Name: "", Kind: "UNKNOWN"},
+		"Status_lamp":   {Name: "", Kind: "UNKNOWN"},
+		"Tilt":          {Name: "", Kind: "UNKNOWN"},
+		"alt":           {Name: "", Kind: "UNKNOWN"},
+		"current":       {Name: "", Kind: "UNKNOWN"},
+		"header_device": {Name: "", Kind: "UNKNOWN"},
+		"lat":           {Name: "", Kind: "UNKNOWN"},
+		"lng":           {Name: "", Kind: "UNKNOWN"},
+		"status_code":   {Name: "", Kind: "UNKNOWN"},
+		"timestamp":     {Name: "", Kind: "UNKNOWN"},
+		"voltage":       {Name: "", Kind: "UNKNOWN"},
+	}
+
+	return models.DeviceProfileTemplate{
+		MacVersion:        DefaultMACVersion,
+		RegParamsRevision: "A",
+		DeviceClass:       models.DeviceClassC,
+		UplinkInterval:    3600,
+		AbpRx1Delay:       1,
+		CodecRuntime:      "JS",
+		CodecScript:       lnode.JavaScript(),
+		Measurements:      measurements,
+	}
+}
+
+// DeviceProfileFromTemplate builds the device profile named name in a tenant
+// from a template, for OTAA or ABP devices. Templates without a region are
+// built for region. RX settings the template leaves unset are taken from the
+// region table.
+func DeviceProfileFromTemplate(tenantID, name string, t models.DeviceProfileTemplate, region string, otaa bool) models.ChirpStackDeviceProfile {
+	if t.Region == "" {
+		t.Region = region
+	}
+	params, ok := LookupRegion(t.Region)
+	if !ok {
+		params = models.Region{Name: t.Region, RegionConfigID: strings.ToLower(t.Region)}
+	}
+
+	regionConfigID := t.RegionConfigID
+	if regionConfigID == "" {
+		regionConfigID = params.RegionConfigID
+	}
+	if t.AbpRx2Freq == 0 {
+		t.AbpRx2Freq = params.Rx2Frequency
+	}
+	if t.AbpRx2Dr == 0 {
+		t.AbpRx2Dr = params.Rx2Dr
+	}
+	if t.AbpRx1Delay == 0 {
+		t.AbpRx1Delay = 1
+	}
+	measurements := t.Measurements
+	if measurements == nil {
+		measurements = make(map[string]models.DeviceProfileMeasurement)
+	}
+
+	return models.ChirpStackDeviceProfile{
+		TenantID:                         tenantID,
+		Name:                             name,
+		Description:                      "",
+		Region:                           t.Region,
+		MacVersion:                       t.MacVersion,
+		RegParamsRevision:                t.RegParamsRevision,
+		AdrAlgorithmID:                   "default",
+		PayloadCodecRuntime:              t.CodecRuntime,
+		PayloadCodecScript:               t.CodecScript,
+		FlushQueueOnActivate:             true,
+		UplinkInterval:                   t.UplinkInterval,
+		DeviceStatusReqInterval:          1,
+		SupportsOtaa:                     otaa,
+		SupportsClassB:                   t.DeviceClass == models.DeviceClassB,
+		SupportsClassC:                   t.DeviceClass == models.DeviceClassC,
+		ClassBTimeout:                    0,
+		ClassBPingSlotNbK:                0,
+		ClassBPingSlotDr:                 0,
+		ClassBPingSlotFreq:               0,
+		ClassCTimeout:                    0,
+		AbpRx1Delay:                      t.AbpRx1Delay,
+		AbpRx1DrOffset:                   0,
+		AbpRx2Dr:                         t.AbpRx2Dr,
+		AbpRx2Freq:                       t.AbpRx2Freq,
+		Tags:                             make(map[string]string),
+		Measurements:                     measurements,
+		AutoDetectMeasurements:           true,
+		RegionConfigID:                   regionConfigID,
+		IsRelay:                          false,
+		IsRelayEd:                        false,
+		RelayEdRelayOnly:                 false,
+		RelayEnabled:                     false,
+		RelayCadPeriodicity:              "SEC_1",
+		RelayDefaultChannelIndex:         0,
+		RelaySecondChannelFreq:           0,
+		RelaySecondChannelDr:             0,
+		RelaySecondChannelAckOffset:      "KHZ_0",
+		RelayEdActivationMode:            "DISABLE_RELAY_MODE",
+		RelayEdSmartEnableLevel:          0,
+		RelayEdBackOff:                   0,
+		RelayEdUplinkLimitBucketSize:     0,
+		RelayEdUplinkLimitReloadRate:     0,
+		RelayJoinReqLimitReloadRate:      0,
+		RelayNotifyLimitReloadRate:       0,
+		RelayGlobalUplinkLimitReloadRate: 0,
+		RelayOverallLimitReloadRate:      0,
+		RelayJoinReqLimitBucketSize:      0,
+		RelayNotifyLimitBucketSize:       0,
+		RelayGlobalUplinkLimitBucketSize: 0,
+		RelayOverallLimitBucketSize:      0,
+		AllowRoaming:                     false,
+		Rx1Delay:                         0,
+	}
+}
@@ -0,0 +1,62 @@
+package chirpstack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by the client, matched with errors.Is
+var (
+	ErrNotFound     = errors.New("chirpstack: not found")
+	ErrConflict     = errors.New("chirpstack: already exists")
+	ErrUnauthorized = errors.New("chirpstack: unauthorized")
+	ErrBadRequest   = errors.New("chirpstack: invalid request")
+	ErrUnavailable  = errors.New("chirpstack: unavailable")
+
+	// ErrCircuitOpen is returned without calling ChirpStack while the circuit
+	// breaker is open
+	ErrCircuitOpen = fmt.Errorf("%w: circuit breaker is open", ErrUnavailable)
+)
+
+// APIError is a non-2xx response from the ChirpStack REST API. It unwraps to
+// one of the sentinel errors above when the status code has a meaning the
+// callers act on.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ChirpStack API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.StatusCode >= 500, e.StatusCode == http.StatusTooManyRequests:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// newAPIError builds an APIError from a response body. ChirpStack returns
+// gRPC gateway errors with a message field, other bodies are used as is.
+func newAPIError(statusCode int, body []byte) *APIError {
+	var gatewayErr struct {
+		Message string `json:"message"`
+	}
+	message := string(body)
+	if err := json.Unmarshal(body, &gatewayErr); err == nil && gatewayErr.Message != "" {
+		message = gatewayErr.Message
+	}
+	return &APIError{StatusCode: statusCode, Message: message}
+}
@@ -0,0 +1,65 @@
+package chirpstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+
+	"go-auth-api/internal/models"
+)
+
+// Encodings of integration events, as configured on the HTTP integration
+const (
+	EncodingJSON     = "JSON"
+	EncodingProtobuf = "PROTOBUF"
+)
+
+// IsValidEncoding reports whether encoding is one of the integration encodings
+func IsValidEncoding(encoding string) bool {
+	return encoding == EncodingJSON || encoding == EncodingProtobuf
+}
+
+// EventEncoding returns the encoding of an event body from its content type.
+// ChirpStack posts JSON events as application/json and protobuf events as
+// application/octet-stream; a missing content type is taken as JSON.
+func EventEncoding(contentType string) (string, error) {
+	if contentType == "" {
+		return EncodingJSON, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", models.ErrUnsupportedEncoding, contentType)
+	}
+	switch mediaType {
+	case "application/json":
+		return EncodingJSON, nil
+	case "application/octet-stream", "application/protobuf", "application/x-protobuf":
+		return EncodingProtobuf, nil
+	default:
+		return "", fmt.Errorf("%w: %s", models.ErrUnsupportedEncoding, mediaType)
+	}
+}
+
+// DecodeEvent decodes an integration event of eventType. Both encodings give
+// the same event, enums of protobuf events are set to the names used in JSON.
+func DecodeEvent(eventType, encoding string, body []byte) (*models.IntegrationEvent, error) {
+	if !models.IsValidIntegrationEvent(eventType) {
+		return nil, fmt.Errorf("%w: unknown event type %q", models.ErrInvalidEvent, eventType)
+	}
+
+	event := &models.IntegrationEvent{}
+	switch encoding {
+	case EncodingJSON:
+		if err := json.Unmarshal(body, event); err != nil {
+			return nil, fmt.Errorf("%w: %v", models.ErrInvalidEvent, err)
+		}
+	case EncodingProtobuf:
+		if err := decodeProtobufEvent(eventType, body, event); err != nil {
+			return nil, fmt.Errorf("%w: %v", models.ErrInvalidEvent, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", models.ErrUnsupportedEncoding, encoding)
+	}
+	return event, nil
+}
@@ -0,0 +1,44 @@
+package chirpstack
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go-auth-api/internal/models"
+
+	"github.com/chirpstack/chirpstack/api/go/v4/integration"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// eventMessages returns the message of the ChirpStack v4 integration API an
+// event type is encoded as
+var eventMessages = map[string]func() proto.Message{
+	models.EventUp:       func() proto.Message { return &integration.UplinkEvent{} },
+	models.EventJoin:     func() proto.Message { return &integration.JoinEvent{} },
+	models.EventAck:      func() proto.Message { return &integration.AckEvent{} },
+	models.EventTxAck:    func() proto.Message { return &integration.TxAckEvent{} },
+	models.EventStatus:   func() proto.Message { return &integration.StatusEvent{} },
+	models.EventLog:      func() proto.Message { return &integration.LogEvent{} },
+	models.EventLocation: func() proto.Message { return &integration.LocationEvent{} },
+}
+
+// decodeProtobufEvent reads a protobuf event into e. The message is turned
+// into the JSON ChirpStack posts for the same event, so both encodings are
+// read by the same struct tags: enums by name, zero values left out.
+func decodeProtobufEvent(eventType string, b []byte, e *models.IntegrationEvent) error {
+	newMessage, ok := eventMessages[eventType]
+	if !ok {
+		return fmt.Errorf("unknown event type %q", eventType)
+	}
+
+	msg := newMessage()
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return err
+	}
+	body, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, e)
+}
@@ -0,0 +1,67 @@
+package chirpstack
+
+import (
+	"fmt"
+	"sort"
+
+	"go-auth-api/internal/models"
+)
+
+// regions are the LoRaWAN regions ChirpStack profiles can be created for,
+// from the LoRaWAN Regional Parameters. The config IDs are those of the
+// region configurations shipped with ChirpStack; US915, AU915 and CN470 use
+// their first sub-band.
+var regions = map[string]models.Region{
+	"EU868":   {Name: "EU868", RegionConfigID: "eu868", MinFrequency: 863000000, MaxFrequency: 870000000, Rx2Frequency: 869525000, Rx2Dr: 0, MinDownlinkDr: 0, MaxDownlinkDr: 7},
+	"US915":   {Name: "US915", RegionConfigID: "us915_0", MinFrequency: 902000000, MaxFrequency: 928000000, Rx2Frequency: 923300000, Rx2Dr: 8, MinDownlinkDr: 8, MaxDownlinkDr: 13},
+	"AU915":   {Name: "AU915", RegionConfigID: "au915_0", MinFrequency: 915000000, MaxFrequency: 928000000, Rx2Frequency: 923300000, Rx2Dr: 8, MinDownlinkDr: 8, MaxDownlinkDr: 13},
+	"AS923":   {Name: "AS923", RegionConfigID: "as923", MinFrequency: 915000000, MaxFrequency: 928000000, Rx2Frequency: 923200000, Rx2Dr: 2, MinDownlinkDr: 0, MaxDownlinkDr: 7},
+	"AS923_2": {Name: "AS923_2", RegionConfigID: "as923_2", MinFrequency: 915000000, MaxFrequency: 928000000, Rx2Frequency: 921400000, Rx2Dr: 2, MinDownlinkDr: 0, MaxDownlinkDr: 7},
+	"AS923_3": {Name: "AS923_3", RegionConfigID: "as923_3", MinFrequency: 915000000, MaxFrequency: 928000000, Rx2Frequency: 916600000, Rx2Dr: 2, MinDownlinkDr: 0, MaxDownlinkDr: 7},
+	"AS923_4": {Name: "AS923_4", RegionConfigID: "as923_4", MinFrequency: 917000000, MaxFrequency: 920000000, Rx2Frequency: 917300000, Rx2Dr: 2, MinDownlinkDr: 0, MaxDownlinkDr: 7},
+	"KR920":   {Name: "KR920", RegionConfigID: "kr920", MinFrequency: 920900000, MaxFrequency: 923300000, Rx2Frequency: 921900000, Rx2Dr: 0, MinDownlinkDr: 0, MaxDownlinkDr: 5},
+	"IN865":   {Name: "IN865", RegionConfigID: "in865", MinFrequency: 865000000, MaxFrequency: 867000000, Rx2Frequency: 866550000, Rx2Dr: 2, MinDownlinkDr: 0, MaxDownlinkDr: 7},
+	"CN470":   {Name: "CN470", RegionConfigID: "cn470_10", MinFrequency: 470000000, MaxFrequency: 510000000, Rx2Frequency: 505300000, Rx2Dr: 0, MinDownlinkDr: 0, MaxDownlinkDr: 5},
+	"EU433":   {Name: "EU433", RegionConfigID: "eu433", MinFrequency: 433175000, MaxFrequency: 434665000, Rx2Frequency: 434665000, Rx2Dr: 0, MinDownlinkDr: 0, MaxDownlinkDr: 7},
+	"RU864":   {Name: "RU864", RegionConfigID: "ru864", MinFrequency: 864000000, MaxFrequency: 870000000, Rx2Frequency: 869100000, Rx2Dr: 0, MinDownlinkDr: 0, MaxDownlinkDr: 7},
+}
+
+// LookupRegion returns the parameters of a region by its ChirpStack name
+func LookupRegion(name string) (models.Region, bool) {
+	region, ok := regions[name]
+	return region, ok
+}
+
+// Regions lists the supported regions by name
+func Regions() []models.Region {
+	list := make([]models.Region, 0, len(regions))
+	for _, region := range regions {
+		list = append(list, region)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ValidateProfileTemplate checks the region of a template and its RX2
+// settings against the region's parameters. A template without a region is
+// created in the region of each organization, so it can't override RX2.
+func ValidateProfileTemplate(t models.DeviceProfileTemplate) error {
+	if t.Region == "" {
+		if t.RegionConfigID != "" || t.AbpRx2Freq != 0 || t.AbpRx2Dr != 0 {
+			return fmt.Errorf("region_config_id, abp_rx2_freq and abp_rx2_dr need a region")
+		}
+		return nil
+	}
+
+	params, ok := LookupRegion(t.Region)
+	if !ok {
+		return fmt.Errorf("unknown region %q", t.Region)
+	}
+	if t.AbpRx2Freq != 0 && (t.AbpRx2Freq < params.MinFrequency || t.AbpRx2Freq > params.MaxFrequency) {
+		return fmt.Errorf("abp_rx2_freq %d is outside the %s band (%d-%d Hz)", t.AbpRx2Freq, params.Name, params.MinFrequency, params.MaxFrequency)
+	}
+	if t.AbpRx2Dr != 0 && (t.AbpRx2Dr < params.MinDownlinkDr || t.AbpRx2Dr > params.MaxDownlinkDr) {
+		return fmt.Errorf("abp_rx2_dr %d is not a %s downlink data rate (DR%d-DR%d)", t.AbpRx2Dr, params.Name, params.MinDownlinkDr, params.MaxDownlinkDr)
+	}
+	return nil
+}
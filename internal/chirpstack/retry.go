@@ -0,0 +1,115 @@
+package chirpstack
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Options tune the client's timeouts, retries and circuit breaker. Zero
+// values fall back to the defaults below.
+type Options struct {
+	// Timeout of a single HTTP attempt
+	Timeout time.Duration
+	// MaxRetries is the number of retries after the first attempt, a negative
+	// value disables retries
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the exponential backoff between attempts
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// BreakerThreshold consecutive failures open the circuit for BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+const (
+	defaultTimeout          = 10 * time.Second
+	defaultMaxRetries       = 3
+	defaultBaseDelay        = 200 * time.Millisecond
+	defaultMaxDelay         = 10 * time.Second
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	} else if o.MaxRetries == 0 {
+		o.MaxRetries = defaultMaxRetries
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = defaultBaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = defaultMaxDelay
+	}
+	if o.BreakerThreshold <= 0 {
+		o.BreakerThreshold = defaultBreakerThreshold
+	}
+	if o.BreakerCooldown <= 0 {
+		o.BreakerCooldown = defaultBreakerCooldown
+	}
+	return o
+}
+
+// backoff returns the delay before retry number attempt (starting at 0):
+// exponential growth capped at MaxDelay, with full jitter so clients that
+// failed together don't retry together
+func (o Options) backoff(attempt int) time.Duration {
+	delay := o.BaseDelay << uint(attempt)
+	if delay <= 0 || delay > o.MaxDelay {
+		delay = o.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// ParseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// isIdempotent reports whether repeating the request is safe. POST creates
+// resources in ChirpStack and is only retried when the server says it
+// didn't process the request.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// shouldRetry decides whether an attempt that ended with statusCode (0 for
+// transport errors) is retried
+func shouldRetry(method string, statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case 0, http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+// isOutage reports whether a result counts against the circuit breaker.
+// Client errors mean ChirpStack is up and answering.
+func isOutage(statusCode int) bool {
+	return statusCode == 0 || statusCode >= 500 || statusCode == http.StatusTooManyRequests
+}
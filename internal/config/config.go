@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,11 +17,54 @@ type Config struct {
 	DBPassword        string
 	DBName            string
 	JWTSecret         string
+	JWTSigningKeyFile string
+	JWTSigningKeyID   string
+	JWTVerifyKeys     string
+	AccessTokenTTL    time.Duration
+	RefreshTokenTTL   time.Duration
+	RevocationSync    time.Duration
 	Port              string
 	ChirpStackHost    string
 	ChirpStackPort    string
 	ChirpStackToken   string
 	ChirpStackEnabled bool
+	ChirpStackTimeout time.Duration
+	ChirpStackRetries int
+	BreakerThreshold  int
+	BreakerCooldown   time.Duration
+	AppBaseURL        string
+	IntegrationURL    string
+	EventEncoding     string
+	MQTTBroker        string
+	MQTTClientID      string
+	MQTTUsername      string
+	MQTTPassword      string
+	MQTTEncoding      string
+	MailerDriver      string
+	MailFrom          string
+	MailLogFile       string
+	SMTPHost          string
+	SMTPPort          string
+	SMTPUsername      string
+	SMTPPassword      string
+	EmailVerifyTTL    time.Duration
+	PasswordResetTTL  time.Duration
+	MFAIssuer         string
+	BcryptWorkers     int
+	LoginLockAfter    int
+	LoginLockDuration time.Duration
+	JobWorkers        int
+	JobPollInterval   time.Duration
+	ReconcileInterval time.Duration
+	ReconcileRepair   bool
+	DefaultRegion     string
+	TrustedProxies    []string
+	// Lamp command port and opcodes, nil when not set
+	CommandFPort   *int
+	CommandDimming *uint8
+	CommandSwitch  *uint8
+	CommandStatus  *uint8
+	CommandGPS     *uint8
 }
 
 func Load() (*Config, error) {
@@ -32,6 +78,101 @@ func Load() (*Config, error) {
 
 	chirpStackEnabled := getEnv("CHIRPSTACK_ENABLED", "true") == "true"
 
+	accessTokenTTL, err := time.ParseDuration(getEnv("ACCESS_TOKEN_TTL", "15m"))
+	if err != nil {
+		accessTokenTTL = 15 * time.Minute
+	}
+
+	refreshTokenTTL, err := time.ParseDuration(getEnv("REFRESH_TOKEN_TTL", "720h"))
+	if err != nil {
+		refreshTokenTTL = 30 * 24 * time.Hour
+	}
+
+	revocationSyncInterval, err := time.ParseDuration(getEnv("REVOCATION_SYNC_INTERVAL", "30s"))
+	if err != nil {
+		revocationSyncInterval = 30 * time.Second
+	}
+
+	chirpStackTimeout, err := time.ParseDuration(getEnv("CHIRPSTACK_TIMEOUT", "10s"))
+	if err != nil {
+		chirpStackTimeout = 10 * time.Second
+	}
+
+	chirpStackRetries, err := strconv.Atoi(getEnv("CHIRPSTACK_MAX_RETRIES", "3"))
+	if err != nil {
+		chirpStackRetries = 3
+	}
+
+	breakerThreshold, err := strconv.Atoi(getEnv("CHIRPSTACK_BREAKER_THRESHOLD", "5"))
+	if err != nil || breakerThreshold < 1 {
+		breakerThreshold = 5
+	}
+
+	breakerCooldown, err := time.ParseDuration(getEnv("CHIRPSTACK_BREAKER_COOLDOWN", "30s"))
+	if err != nil {
+		breakerCooldown = 30 * time.Second
+	}
+
+	emailVerifyTTL, err := time.ParseDuration(getEnv("EMAIL_VERIFICATION_TTL", "48h"))
+	if err != nil {
+		emailVerifyTTL = 48 * time.Hour
+	}
+
+	passwordResetTTL, err := time.ParseDuration(getEnv("PASSWORD_RESET_TTL", "1h"))
+	if err != nil {
+		passwordResetTTL = time.Hour
+	}
+
+	bcryptWorkers, err := strconv.Atoi(getEnv("BCRYPT_WORKERS", strconv.Itoa(runtime.NumCPU())))
+	if err != nil || bcryptWorkers < 1 {
+		bcryptWorkers = runtime.NumCPU()
+	}
+
+	loginLockAfter, err := strconv.Atoi(getEnv("LOGIN_LOCK_THRESHOLD", "10"))
+	if err != nil || loginLockAfter < 1 {
+		loginLockAfter = 10
+	}
+
+	loginLockDuration, err := time.ParseDuration(getEnv("LOGIN_LOCK_DURATION", "15m"))
+	if err != nil {
+		loginLockDuration = 15 * time.Minute
+	}
+
+	jobWorkers, err := strconv.Atoi(getEnv("JOB_WORKERS", "2"))
+	if err != nil || jobWorkers < 0 {
+		jobWorkers = 2
+	}
+
+	jobPollInterval, err := time.ParseDuration(getEnv("JOB_POLL_INTERVAL", "2s"))
+	if err != nil || jobPollInterval <= 0 {
+		jobPollInterval = 2 * time.Second
+	}
+
+	// RECONCILE_INTERVAL=0 turns periodic reconciliation off
+	reconcileInterval, err := time.ParseDuration(getEnv("RECONCILE_INTERVAL", "1h"))
+	if err != nil || reconcileInterval < 0 {
+		reconcileInterval = time.Hour
+	}
+
+	var commandFPort *int
+	if port, err := strconv.Atoi(getEnv("LNODE_COMMAND_FPORT", "")); err == nil {
+		commandFPort = &port
+	}
+
+	// Client addresses are only taken from X-Forwarded-For when the request
+	// comes from one of these proxies, by default from none
+	var trustedProxies []string
+	for _, proxy := range strings.Split(getEnv("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+
+	// ChirpStack posts integration events here, an empty
+	// CHIRPSTACK_INTEGRATION_URL defaults to the endpoint of this server
+	appBaseURL := getEnv("APP_BASE_URL", "http://localhost:8080")
+	integrationURL := getEnv("CHIRPSTACK_INTEGRATION_URL", strings.TrimRight(appBaseURL, "/")+"/api/v1/integrations/chirpstack")
+
 	return &Config{
 		DBHost:            getEnv("DB_HOST", "localhost"),
 		DBPort:            dbPort,
@@ -39,11 +180,53 @@ func Load() (*Config, error) {
 		DBPassword:        getEnv("DB_PASSWORD", "password123"),
 		DBName:            getEnv("DB_NAME", "auth_db"),
 		JWTSecret:         getEnv("JWT_SECRET", "your-secret-key"),
+		JWTSigningKeyFile: getEnv("JWT_SIGNING_KEY_FILE", ""),
+		JWTSigningKeyID:   getEnv("JWT_SIGNING_KEY_ID", "default"),
+		JWTVerifyKeys:     getEnv("JWT_VERIFICATION_KEYS", ""),
+		AccessTokenTTL:    accessTokenTTL,
+		RefreshTokenTTL:   refreshTokenTTL,
+		RevocationSync:    revocationSyncInterval,
 		Port:              getEnv("PORT", "8080"),
 		ChirpStackHost:    getEnv("CHIRPSTACK_HOST", "192.168.0.21"),
 		ChirpStackPort:    getEnv("CHIRPSTACK_PORT", "8090"),
 		ChirpStackToken:   getEnv("CHIRPSTACK_TOKEN", ""),
 		ChirpStackEnabled: chirpStackEnabled,
+		ChirpStackTimeout: chirpStackTimeout,
+		ChirpStackRetries: chirpStackRetries,
+		BreakerThreshold:  breakerThreshold,
+		BreakerCooldown:   breakerCooldown,
+		AppBaseURL:        appBaseURL,
+		IntegrationURL:    integrationURL,
+		EventEncoding:     strings.ToUpper(getEnv("CHIRPSTACK_INTEGRATION_ENCODING", "JSON")),
+		MQTTBroker:        getEnv("CHIRPSTACK_MQTT_BROKER", ""),
+		MQTTClientID:      getEnv("CHIRPSTACK_MQTT_CLIENT_ID", "go-auth-api"),
+		MQTTUsername:      getEnv("CHIRPSTACK_MQTT_USERNAME", ""),
+		MQTTPassword:      getEnv("CHIRPSTACK_MQTT_PASSWORD", ""),
+		MQTTEncoding:      strings.ToUpper(getEnv("CHIRPSTACK_MQTT_ENCODING", "JSON")),
+		MailerDriver:      getEnv("MAILER_DRIVER", "log"),
+		MailFrom:          getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailLogFile:       getEnv("MAIL_LOG_FILE", ""),
+		SMTPHost:          getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:          getEnv("SMTP_PORT", "587"),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		EmailVerifyTTL:    emailVerifyTTL,
+		PasswordResetTTL:  passwordResetTTL,
+		MFAIssuer:         getEnv("MFA_ISSUER", "Go Auth API"),
+		BcryptWorkers:     bcryptWorkers,
+		LoginLockAfter:    loginLockAfter,
+		LoginLockDuration: loginLockDuration,
+		JobWorkers:        jobWorkers,
+		JobPollInterval:   jobPollInterval,
+		ReconcileInterval: reconcileInterval,
+		ReconcileRepair:   getEnv("RECONCILE_REPAIR", "false") == "true",
+		DefaultRegion:     getEnv("DEFAULT_REGION", "AS923_2"),
+		TrustedProxies:    trustedProxies,
+		CommandFPort:      commandFPort,
+		CommandDimming:    getEnvUint8("LNODE_OPCODE_DIMMING"),
+		CommandSwitch:     getEnvUint8("LNODE_OPCODE_SWITCH"),
+		CommandStatus:     getEnvUint8("LNODE_OPCODE_STATUS"),
+		CommandGPS:        getEnvUint8("LNODE_OPCODE_GPS"),
 	}, nil
 }
 
@@ -53,3 +236,14 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvUint8 reads a byte written in decimal or in hex with a 0x prefix
+// getEnvUint8 returns a byte given in decimal or 0x hex, nil when unset
+func getEnvUint8(key string) *uint8 {
+	value, err := strconv.ParseUint(getEnv(key, ""), 0, 8)
+	if err != nil {
+		return nil
+	}
+	b := uint8(value)
+	return &b
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AccountHandler struct {
+	accountService interfaces.AccountServiceInterface
+}
+
+func NewAccountHandler(accountService interfaces.AccountServiceInterface) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// VerifyEmail handles POST /auth/verify-email
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.accountService.VerifyEmail(req.Token)
+	if errors.Is(err, models.ErrInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully", "user": user})
+}
+
+// ResendVerification handles POST /auth/resend-verification
+func (h *AccountHandler) ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	err := h.accountService.ResendVerification(userID.(uuid.UUID))
+	if errors.Is(err, models.ErrEmailAlreadyVerified) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword handles POST /auth/forgot-password
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same response whether or not the address belongs to an account. Errors
+	// can only occur for existing accounts, so they are logged instead of
+	// returned.
+	if err := h.accountService.RequestPasswordReset(req.Email); err != nil {
+		fmt.Printf("Warning: Failed to send password reset email: %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the address belongs to an account, a password reset email has been sent"})
+}
+
+// ResetPassword handles POST /auth/reset-password
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.accountService.ResetPassword(req.Token, req.Password)
+	if errors.Is(err, models.ErrInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, auth.ErrHasherBusy) {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
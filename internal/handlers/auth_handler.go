@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AuthHandler struct {
+	tokenService interfaces.TokenServiceInterface
+}
+
+func NewAuthHandler(tokenService interfaces.TokenServiceInterface) *AuthHandler {
+	return &AuthHandler{tokenService: tokenService}
+}
+
+// Refresh handles POST /auth/refresh
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.tokenService.Refresh(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout handles POST /auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.tokenService.Logout(req.RefreshToken); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Also revoke the access token the request was made with, if any
+	if userID, exists := c.Get("user_id"); exists {
+		if err := h.tokenService.RevokeAccessToken(c.GetString("token_id"), userID.(uuid.UUID), c.GetTime("token_expires_at")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll handles POST /auth/logout-all
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.tokenService.LogoutAll(userID.(uuid.UUID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions"})
+}
+
+// RevokeUserSessions handles DELETE /users/:id/sessions
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	if err := h.tokenService.LogoutAll(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User sessions revoked successfully"})
+}
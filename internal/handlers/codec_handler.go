@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CodecHandler struct {
+	codecService interfaces.CodecServiceInterface
+}
+
+func NewCodecHandler(codecService interfaces.CodecServiceInterface) *CodecHandler {
+	return &CodecHandler{codecService: codecService}
+}
+
+// codecErrorStatus maps codec errors to HTTP status codes
+func codecErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrCodecNotFound), errors.Is(err, models.ErrCodecVersionNotFound), errors.Is(err, models.ErrRolloutNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrCodecExists), errors.Is(err, models.ErrCodecVersionNotDraft),
+		errors.Is(err, models.ErrRolloutRunning), errors.Is(err, models.ErrRolloutNotFinished),
+		errors.Is(err, models.ErrRolloutNotLatest):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrInvalidCodecScript):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrChirpStackDisabled):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// codecVersionParams parses the codec ID and version number from the path
+func codecVersionParams(c *gin.Context) (uuid.UUID, int, bool) {
+	codecID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return uuid.Nil, 0, false
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid codec version"})
+		return uuid.Nil, 0, false
+	}
+
+	return codecID, version, true
+}
+
+// CreateCodec handles POST /codecs
+func (h *CodecHandler) CreateCodec(c *gin.Context) {
+	var req models.CreateCodecRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codec, err := h.codecService.CreateCodec(&req)
+	if err != nil {
+		c.JSON(codecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, codec)
+}
+
+// GetCodecs handles GET /codecs
+func (h *CodecHandler) GetCodecs(c *gin.Context) {
+	codecs, err := h.codecService.GetCodecs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"codecs": codecs})
+}
+
+// GetCodecVersions handles GET /codecs/:id/versions
+func (h *CodecHandler) GetCodecVersions(c *gin.Context) {
+	codecID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	response, err := h.codecService.GetCodecVersions(codecID)
+	if err != nil {
+		c.JSON(codecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateCodecVersion handles POST /codecs/:id/versions
+func (h *CodecHandler) CreateCodecVersion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	codecID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req models.CreateCodecVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, err := h.codecService.CreateCodecVersion(codecID, userID.(uuid.UUID), &req)
+	if err != nil {
+		c.JSON(codecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, version)
+}
+
+// GetCodecVersion handles GET /codecs/:id/versions/:version
+func (h *CodecHandler) GetCodecVersion(c *gin.Context) {
+	codecID, number, ok := codecVersionParams(c)
+	if !ok {
+		return
+	}
+
+	version, err := h.codecService.GetCodecVersion(codecID, number)
+	if err != nil {
+		c.JSON(codecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, version)
+}
+
+// PublishCodecVersion handles POST /codecs/:id/versions/:version/publish. The
+// rollout runs in the background and is polled with GET /codecs/rollouts/:id.
+func (h *CodecHandler) PublishCodecVersion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	codecID, number, ok := codecVersionParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.PublishCodecVersionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	rollout, err := h.codecService.PublishCodecVersion(codecID, number, userID.(uuid.UUID), &req)
+	if err != nil {
+		c.JSON(codecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, rollout)
+}
+
+// GetCodecRollouts handles GET /codecs/:id/rollouts
+func (h *CodecHandler) GetCodecRollouts(c *gin.Context) {
+	codecID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	rollouts, err := h.codecService.GetCodecRollouts(codecID)
+	if err != nil {
+		c.JSON(codecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rollouts": rollouts})
+}
+
+// GetRollout handles GET /codecs/rollouts/:id
+func (h *CodecHandler) GetRollout(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	rollout, err := h.codecService.GetRollout(id)
+	if err != nil {
+		c.JSON(codecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rollout)
+}
+
+// RollbackRollout handles POST /codecs/rollouts/:id/rollback
+func (h *CodecHandler) RollbackRollout(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	rollout, err := h.codecService.RollbackRollout(id)
+	if err != nil {
+		c.JSON(codecErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, rollout)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CommandHandler struct {
+	commandService interfaces.CommandServiceInterface
+}
+
+func NewCommandHandler(commandService interfaces.CommandServiceInterface) *CommandHandler {
+	return &CommandHandler{commandService: commandService}
+}
+
+// commandErrorStatus maps command errors to HTTP status codes
+func commandErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidCommand):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrDeviceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrOrgPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrDeviceNotProvisioned):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrChirpStackDisabled), errors.Is(err, chirpstack.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// SendCommand handles POST /devices/:id/commands
+func (h *CommandHandler) SendCommand(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req models.SendCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	command, err := h.commandService.SendCommand(id, userID.(uuid.UUID), c.GetString("user_role"), &req)
+	if err != nil {
+		c.JSON(commandErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, command)
+}
+
+// GetDeviceCommands handles GET /devices/:id/commands
+func (h *CommandHandler) GetDeviceCommands(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	response, err := h.commandService.GetDeviceCommands(id, userID.(uuid.UUID), c.GetString("user_role"), page, pageSize)
+	if err != nil {
+		c.JSON(commandErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -28,6 +29,10 @@ func (h *DeviceHandler) CreateDeviceVersion(c *gin.Context) {
 	}
 
 	version, err := h.deviceService.CreateDeviceVersion(&req)
+	if errors.Is(err, models.ErrInvalidProfileTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -88,6 +93,10 @@ func (h *DeviceHandler) UpdateDeviceVersion(c *gin.Context) {
 	}
 
 	err = h.deviceService.UpdateDeviceVersion(id, &req)
+	if errors.Is(err, models.ErrInvalidProfileTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -122,6 +131,10 @@ func (h *DeviceHandler) CreateAllowedDevice(c *gin.Context) {
 	}
 
 	device, err := h.deviceService.CreateAllowedDevice(&req)
+	if errors.Is(err, models.ErrInvalidActivation) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -180,6 +193,10 @@ func (h *DeviceHandler) UpdateAllowedDevice(c *gin.Context) {
 	}
 
 	err := h.deviceService.UpdateAllowedDevice(devEUI, &req)
+	if errors.Is(err, models.ErrInvalidActivation) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -218,7 +235,19 @@ func (h *DeviceHandler) CreateDevice(c *gin.Context) {
 		return
 	}
 
-	device, err := h.deviceService.CreateDevice(userID.(uuid.UUID), &req)
+	device, err := h.deviceService.CreateDevice(userID.(uuid.UUID), c.GetString("user_role"), &req)
+	if errors.Is(err, models.ErrRegionMismatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrEmailNotVerified) || errors.Is(err, models.ErrOrgPermissionDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrOrganizationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -228,6 +257,12 @@ func (h *DeviceHandler) CreateDevice(c *gin.Context) {
 }
 
 func (h *DeviceHandler) GetDeviceByID(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -235,7 +270,7 @@ func (h *DeviceHandler) GetDeviceByID(c *gin.Context) {
 		return
 	}
 
-	device, err := h.deviceService.GetDeviceByID(id)
+	device, err := h.deviceService.GetDeviceByID(id, userID.(uuid.UUID), c.GetString("user_role"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -291,6 +326,12 @@ func (h *DeviceHandler) GetAllDevices(c *gin.Context) {
 }
 
 func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -304,7 +345,15 @@ func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
 		return
 	}
 
-	err = h.deviceService.UpdateDevice(id, &req)
+	err = h.deviceService.UpdateDevice(id, userID.(uuid.UUID), c.GetString("user_role"), &req)
+	if errors.Is(err, models.ErrDeviceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrOrgPermissionDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -314,6 +363,12 @@ func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
 }
 
 func (h *DeviceHandler) DeleteDevice(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -321,7 +376,15 @@ func (h *DeviceHandler) DeleteDevice(c *gin.Context) {
 		return
 	}
 
-	err = h.deviceService.DeleteDevice(id)
+	err = h.deviceService.DeleteDevice(id, userID.(uuid.UUID), c.GetString("user_role"))
+	if errors.Is(err, models.ErrDeviceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrOrgPermissionDenied) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	chirpStack interfaces.ChirpStackHealthChecker
+}
+
+// NewHealthHandler creates the handler. chirpStack is nil when the ChirpStack
+// integration is disabled.
+func NewHealthHandler(chirpStack interfaces.ChirpStackHealthChecker) *HealthHandler {
+	return &HealthHandler{chirpStack: chirpStack}
+}
+
+// Health handles GET /health. The API itself is up whenever it answers, so
+// the status code stays 200 and an open ChirpStack circuit only marks the
+// service as degraded.
+func (h *HealthHandler) Health(c *gin.Context) {
+	if h.chirpStack == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":     "ok",
+			"chirpstack": gin.H{"enabled": false},
+		})
+		return
+	}
+
+	circuit := h.chirpStack.CircuitStatus()
+	status := "ok"
+	if circuit.State != chirpstack.CircuitClosed {
+		status = "degraded"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": status,
+		"chirpstack": gin.H{
+			"enabled": true,
+			"circuit": circuit,
+		},
+	})
+}
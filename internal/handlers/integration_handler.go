@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxEventSize limits the body of integration events. The endpoint is public
+// and the body is decoded before the secret is checked. ChirpStack events are
+// a few kilobytes.
+const maxEventSize = 1 << 20
+
+type IntegrationHandler struct {
+	integrationService interfaces.IntegrationServiceInterface
+}
+
+func NewIntegrationHandler(integrationService interfaces.IntegrationServiceInterface) *IntegrationHandler {
+	return &IntegrationHandler{integrationService: integrationService}
+}
+
+func integrationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrIntegrationUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, models.ErrInvalidEvent):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, models.ErrDeviceNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// HandleChirpStackEvent handles POST /integrations/chirpstack?event=up, the
+// endpoint of the HTTP integration of every organization's application.
+// ChirpStack authenticates with the organization's secret as bearer token
+// and posts JSON or protobuf events.
+func (h *IntegrationHandler) HandleChirpStackEvent(c *gin.Context) {
+	eventType := c.Query("event")
+	if !models.IsValidIntegrationEvent(eventType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event type"})
+		return
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxEventSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	err = h.integrationService.HandleWebhook(eventType, strings.TrimPrefix(authHeader, "Bearer "), c.ContentType(), body)
+	if err != nil {
+		c.JSON(integrationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type JobHandler struct {
+	jobService interfaces.JobServiceInterface
+}
+
+func NewJobHandler(jobService interfaces.JobServiceInterface) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// jobErrorStatus maps job errors to HTTP status codes
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrJobNotDead):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrJobNotFound):
+		return http.StatusNotFound
+	default:
+		return orgErrorStatus(err)
+	}
+}
+
+// GetJob handles GET /jobs/:id
+func (h *JobHandler) GetJob(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	job, err := h.jobService.GetJob(id, userID.(uuid.UUID), c.GetString("user_role"))
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// GetOrganizationJobs handles GET /organizations/:id/jobs
+func (h *JobHandler) GetOrganizationJobs(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	response, err := h.jobService.GetOrganizationJobs(orgID, userID, c.GetString("user_role"), page, pageSize)
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetJobs handles GET /jobs?status=dead
+func (h *JobHandler) GetJobs(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.JobPending, models.JobRunning, models.JobSucceeded, models.JobDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job status"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	response, err := h.jobService.GetJobs(status, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RetryJob handles POST /jobs/:id/retry
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	if err := h.jobService.RetryJob(id); err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job queued for retry"})
+}
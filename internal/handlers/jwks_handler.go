@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-auth-api/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	jwtService *auth.JWTService
+}
+
+func NewJWKSHandler(jwtService *auth.JWTService) *JWKSHandler {
+	return &JWKSHandler{jwtService: jwtService}
+}
+
+// JWKS handles GET /.well-known/jwks.json
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MFAHandler struct {
+	mfaService interfaces.MFAServiceInterface
+}
+
+func NewMFAHandler(mfaService interfaces.MFAServiceInterface) *MFAHandler {
+	return &MFAHandler{mfaService: mfaService}
+}
+
+// mfaErrorStatus maps two-factor errors to HTTP status codes
+func mfaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidMFACode), errors.Is(err, models.ErrInvalidUserToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, models.ErrMFAAlreadyEnabled), errors.Is(err, models.ErrMFANotEnabled):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// CompleteLogin handles POST /auth/login/2fa
+func (h *MFAHandler) CompleteLogin(c *gin.Context) {
+	var req models.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+
+	response, err := h.mfaService.CompleteLogin(&req)
+	var throttled *models.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Enroll handles POST /auth/2fa/enroll
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	response, err := h.mfaService.Enroll(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Confirm handles POST /auth/2fa/confirm
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.mfaService.Confirm(userID.(uuid.UUID), req.Code)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Disable handles POST /auth/2fa/disable
+func (h *MFAHandler) Disable(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+
+	err := h.mfaService.Disable(userID.(uuid.UUID), &req)
+	var throttled *models.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, auth.ErrHasherBusy) {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes handles POST /auth/2fa/recovery-codes
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.mfaService.RegenerateRecoveryCodes(userID.(uuid.UUID), req.Code)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetUserMFA handles DELETE /users/:id/2fa
+func (h *MFAHandler) ResetUserMFA(c *gin.Context) {
+	if err := h.mfaService.ResetUserMFA(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+// GetRolePolicies handles GET /roles/policies
+func (h *MFAHandler) GetRolePolicies(c *gin.Context) {
+	policies, err := h.mfaService.GetRolePolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// UpdateRolePolicy handles PUT /roles/:role/policy
+func (h *MFAHandler) UpdateRolePolicy(c *gin.Context) {
+	var req models.UpdateRolePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.mfaService.SetRolePolicy(c.Param("role"), *req.RequireMFA); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role policy updated successfully"})
+}
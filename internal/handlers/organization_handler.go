@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type OrganizationHandler struct {
+	orgService interfaces.OrganizationServiceInterface
+}
+
+func NewOrganizationHandler(orgService interfaces.OrganizationServiceInterface) *OrganizationHandler {
+	return &OrganizationHandler{orgService: orgService}
+}
+
+// orgErrorStatus maps organization errors to HTTP status codes
+func orgErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrOrganizationNotFound), errors.Is(err, models.ErrMemberNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrOrgPermissionDenied), errors.Is(err, models.ErrEmailNotVerified):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrLastOwner), errors.Is(err, models.ErrAlreadyMember):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrInvalidInvitation), errors.Is(err, models.ErrUnknownRegion):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// orgCaller returns the authenticated user and the organization ID from the
+// path, writing the error response when either is missing
+func orgCaller(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return orgID, userID.(uuid.UUID), true
+}
+
+// CreateOrganization handles POST /organizations
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.orgService.CreateOrganization(userID.(uuid.UUID), &req)
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// GetOrganizations handles GET /organizations
+func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	orgs, err := h.orgService.GetOrganizations(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// GetOrganization handles GET /organizations/:id
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	org, err := h.orgService.GetOrganization(orgID, userID, c.GetString("user_role"))
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// UpdateOrganization handles PUT /organizations/:id
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.orgService.UpdateOrganization(orgID, userID, c.GetString("user_role"), &req)
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// GetMembers handles GET /organizations/:id/members
+func (h *OrganizationHandler) GetMembers(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.orgService.GetMembers(orgID, userID, c.GetString("user_role"))
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// UpdateMemberRole handles PUT /organizations/:id/members/:userId
+func (h *OrganizationHandler) UpdateMemberRole(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var req models.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.orgService.UpdateMemberRole(orgID, userID, c.GetString("user_role"), memberID, &req)
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated successfully"})
+}
+
+// RemoveMember handles DELETE /organizations/:id/members/:userId
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	err = h.orgService.RemoveMember(orgID, userID, c.GetString("user_role"), memberID)
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// CreateInvitation handles POST /organizations/:id/invitations
+func (h *OrganizationHandler) CreateInvitation(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, err := h.orgService.CreateInvitation(orgID, userID, c.GetString("user_role"), &req)
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// GetInvitations handles GET /organizations/:id/invitations
+func (h *OrganizationHandler) GetInvitations(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.orgService.GetInvitations(orgID, userID, c.GetString("user_role"))
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// RevokeInvitation handles DELETE /organizations/:id/invitations/:invitationId
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	orgID, userID, ok := orgCaller(c)
+	if !ok {
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	err = h.orgService.RevokeInvitation(orgID, userID, c.GetString("user_role"), invitationID)
+	if errors.Is(err, models.ErrInvalidInvitation) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// AcceptInvitation handles POST /organizations/invitations/accept
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.orgService.AcceptInvitation(userID.(uuid.UUID), req.Token)
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
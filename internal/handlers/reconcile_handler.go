@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReconcileHandler struct {
+	reconciler interfaces.ReconcilerInterface
+}
+
+func NewReconcileHandler(reconciler interfaces.ReconcilerInterface) *ReconcileHandler {
+	return &ReconcileHandler{reconciler: reconciler}
+}
+
+func reconcileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrReconcileRunning):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrChirpStackDisabled):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, models.ErrOrganizationNotFound), errors.Is(err, models.ErrNoReconcileRun):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Reconcile handles POST /reconcile. An empty body checks all organizations
+// without repairing anything.
+func (h *ReconcileHandler) Reconcile(c *gin.Context) {
+	var req models.ReconcileRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := h.reconciler.Reconcile(&req)
+	if err != nil {
+		c.JSON(reconcileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// LastReport handles GET /reconcile
+func (h *ReconcileHandler) LastReport(c *gin.Context) {
+	report, err := h.reconciler.LastReport()
+	if err != nil {
+		c.JSON(reconcileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RegionHandler struct {
+	defaultRegion string
+}
+
+func NewRegionHandler(defaultRegion string) *RegionHandler {
+	return &RegionHandler{defaultRegion: defaultRegion}
+}
+
+// GetRegions handles GET /regions, listing the LoRaWAN regions organizations
+// and device versions can use
+func (h *RegionHandler) GetRegions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.RegionListResponse{
+		DefaultRegion: h.defaultRegion,
+		Regions:       chirpstack.Regions(),
+	})
+}
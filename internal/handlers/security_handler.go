@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-auth-api/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SecurityHandler struct {
+	loginGuard interfaces.LoginGuardInterface
+}
+
+func NewSecurityHandler(loginGuard interfaces.LoginGuardInterface) *SecurityHandler {
+	return &SecurityHandler{loginGuard: loginGuard}
+}
+
+// UnlockUser handles POST /users/:id/unlock
+func (h *SecurityHandler) UnlockUser(c *gin.Context) {
+	adminID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.loginGuard.UnlockUser(c.Param("id"), adminID.(uuid.UUID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// GetUserSecurityEvents handles GET /users/:id/security-events
+func (h *SecurityHandler) GetUserSecurityEvents(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	h.listEvents(c, &userID)
+}
+
+// GetSecurityEvents handles GET /security-events
+func (h *SecurityHandler) GetSecurityEvents(c *gin.Context) {
+	h.listEvents(c, nil)
+}
+
+func (h *SecurityHandler) listEvents(c *gin.Context, userID *uuid.UUID) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	response, err := h.loginGuard.GetSecurityEvents(userID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
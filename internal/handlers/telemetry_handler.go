@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type TelemetryHandler struct {
+	telemetryService interfaces.TelemetryServiceInterface
+}
+
+func NewTelemetryHandler(telemetryService interfaces.TelemetryServiceInterface) *TelemetryHandler {
+	return &TelemetryHandler{telemetryService: telemetryService}
+}
+
+// telemetryErrorStatus maps telemetry errors to HTTP status codes
+func telemetryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidTelemetryQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrDeviceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrOrgPermissionDenied):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GetDeviceTelemetry handles GET /devices/:id/telemetry?from=&to=&fields=&interval=
+func (h *TelemetryHandler) GetDeviceTelemetry(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
+		return
+	}
+
+	var query models.TelemetryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.telemetryService.GetDeviceTelemetry(id, userID.(uuid.UUID), c.GetString("user_role"), &query)
+	if err != nil {
+		c.JSON(telemetryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 
+	"go-auth-api/internal/auth"
 	"go-auth-api/internal/interfaces"
 	"go-auth-api/internal/models"
 
@@ -41,7 +44,21 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.Request.UserAgent()
+
 	response, err := h.userService.Login(&req)
+	var throttled *models.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, auth.ErrHasherBusy) {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -60,6 +77,7 @@ func (h *UserHandler) Profile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"user_id": userID,
 		"email":   c.GetString("user_email"),
+		"role":    c.GetString("user_role"),
 		"message": "Profile accessed successfully",
 	})
 }
@@ -105,6 +123,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		ID:        user.ID,
 		Email:     user.Email,
 		FullName:  user.FullName,
+		Role:      user.Role,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}
@@ -123,6 +142,11 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	user, err := h.userService.UpdateUser(idStr, &req)
+	if errors.Is(err, auth.ErrHasherBusy) {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -133,6 +157,35 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		ID:        user.ID,
 		Email:     user.Email,
 		FullName:  user.FullName,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+
+	c.JSON(http.StatusOK, publicUser)
+}
+
+// UpdateUserRole handles PUT /users/:id/role
+func (h *UserHandler) UpdateUserRole(c *gin.Context) {
+	idStr := c.Param("id")
+
+	var req models.UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.UpdateUserRole(idStr, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	publicUser := models.PublicUser{
+		ID:        user.ID,
+		Email:     user.Email,
+		FullName:  user.FullName,
+		Role:      user.Role,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}
@@ -140,11 +193,41 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	c.JSON(http.StatusOK, publicUser)
 }
 
+// SuspendUser handles POST /users/:id/suspend
+func (h *UserHandler) SuspendUser(c *gin.Context) {
+	idStr := c.Param("id")
+
+	err := h.userService.SuspendUser(idStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User suspended successfully"})
+}
+
+// ReactivateUser handles POST /users/:id/reactivate
+func (h *UserHandler) ReactivateUser(c *gin.Context) {
+	idStr := c.Param("id")
+
+	err := h.userService.ReactivateUser(idStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User reactivated successfully"})
+}
+
 // DeleteUser handles DELETE /users/:id
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	idStr := c.Param("id")
 
 	err := h.userService.DeleteUser(idStr)
+	if errors.Is(err, models.ErrLastOwner) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
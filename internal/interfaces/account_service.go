@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type AccountServiceInterface interface {
+	ResendVerification(userID uuid.UUID) error
+	VerifyEmail(token string) (*models.User, error)
+	RequestPasswordReset(email string) error
+	ResetPassword(token, password string) error
+}
@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyServiceInterface interface {
+	CreateAPIKey(callerID uuid.UUID, callerRole string, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error)
+	GetAPIKeys(userID uuid.UUID) (*models.APIKeyListResponse, error)
+	RevokeAPIKey(callerID uuid.UUID, callerRole string, id uuid.UUID) error
+}
+
+// APIKeyAuthenticator is used by AuthMiddleware to resolve API keys
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (*models.APIKeyPrincipal, error)
+}
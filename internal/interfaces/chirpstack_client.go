@@ -0,0 +1,49 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/models"
+)
+
+// ChirpStackClient is the ChirpStack API as used by the services. Errors can
+// be matched against the sentinel errors of the chirpstack package.
+type ChirpStackClient interface {
+	CreateTenant(tenant models.ChirpStackTenant) (string, error)
+	GetTenant(id string) (*models.ChirpStackTenant, error)
+	FindTenant(name string) (string, error)
+	DeleteTenant(id string) error
+
+	CreateApplication(app models.ChirpStackApplication) (string, error)
+	GetApplication(id string) (*models.ChirpStackApplication, error)
+	FindApplication(tenantID, name string) (string, error)
+	DeleteApplication(id string) error
+	CreateHTTPIntegration(integration models.ChirpStackHTTPIntegration) error
+	UpdateHTTPIntegration(integration models.ChirpStackHTTPIntegration) error
+
+	CreateDeviceProfile(profile models.ChirpStackDeviceProfile) (string, error)
+	GetDeviceProfile(id string) (*models.ChirpStackDeviceProfile, error)
+	FindDeviceProfile(tenantID, name string) (string, error)
+	UpdateDeviceProfile(id string, profile models.ChirpStackDeviceProfile) error
+	DeleteDeviceProfile(id string) error
+
+	CreateDevice(device models.ChirpStackDeviceInfo) error
+	GetDevice(devEUI string) (*models.ChirpStackDeviceInfo, error)
+	UpdateDevice(device models.ChirpStackDeviceInfo) error
+	DeleteDevice(devEUI string) error
+	ListDevices(applicationID string, limit, offset int) ([]models.ChirpStackDeviceListItem, int, error)
+	ActivateDevice(devEUI string, activation models.ChirpStackDeviceActivation) error
+	GetDeviceActivation(devEUI string) (*models.ChirpStackDeviceActivation, error)
+
+	CreateDeviceKeys(devEUI string, keys models.ChirpStackDeviceKeys) error
+	GetDeviceKeys(devEUI string) (*models.ChirpStackDeviceKeys, error)
+	DeleteDeviceKeys(devEUI string) error
+
+	EnqueueDownlink(item models.ChirpStackQueueItem) (string, error)
+	GetDeviceQueue(devEUI string) ([]models.ChirpStackQueueItem, error)
+	FlushDeviceQueue(devEUI string) error
+}
+
+// ChirpStackHealthChecker reports the state of the client's circuit breaker
+type ChirpStackHealthChecker interface {
+	CircuitStatus() chirpstack.BreakerStatus
+}
@@ -0,0 +1,37 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CodecScripts looks up the published codec scripts that new device profiles
+// are created with
+type CodecScripts interface {
+	GetCodecByID(id uuid.UUID) (*models.Codec, error)
+	GetDefaultCodec() (*models.Codec, error)
+	GetPublishedCodecVersion(codecID uuid.UUID) (*models.CodecVersion, error)
+}
+
+// CodecRolloutStore persists the progress of codec rollouts
+type CodecRolloutStore interface {
+	GetRollout(id uuid.UUID) (*models.CodecRollout, error)
+	GetCodecVersionByID(id uuid.UUID) (*models.CodecVersion, error)
+	UpdateRolloutTarget(target *models.CodecRolloutTarget) error
+	SetRolloutStatus(id uuid.UUID, status string) error
+	SetCodecVersionStatus(id uuid.UUID, status string) error
+}
+
+// CodecServiceInterface manages codecs, their versions and rollouts
+type CodecServiceInterface interface {
+	CreateCodec(req *models.CreateCodecRequest) (*models.Codec, error)
+	GetCodecs() ([]models.Codec, error)
+	GetCodecVersions(codecID uuid.UUID) (*models.CodecVersionListResponse, error)
+	CreateCodecVersion(codecID, userID uuid.UUID, req *models.CreateCodecVersionRequest) (*models.CodecVersion, error)
+	GetCodecVersion(codecID uuid.UUID, version int) (*models.CodecVersion, error)
+	PublishCodecVersion(codecID uuid.UUID, version int, userID uuid.UUID, req *models.PublishCodecVersionRequest) (*models.CodecRollout, error)
+	GetCodecRollouts(codecID uuid.UUID) ([]models.CodecRollout, error)
+	GetRollout(id uuid.UUID) (*models.CodecRollout, error)
+	RollbackRollout(id uuid.UUID) (*models.CodecRollout, error)
+}
@@ -0,0 +1,32 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CommandStore records the commands sent to devices
+type CommandStore interface {
+	CreateCommand(command *models.DeviceCommand) error
+	SetCommandQueueItem(command *models.DeviceCommand, queueItemID string) error
+	DeleteCommand(id uuid.UUID) error
+	GetDeviceCommands(deviceID uuid.UUID, page, pageSize int) ([]models.DeviceCommand, int, error)
+}
+
+// DeviceControl loads a device on behalf of a user, failing for devices the
+// user may see but not control
+type DeviceControl interface {
+	DeviceAccess
+	GetDeviceForUpdate(id, userID uuid.UUID, role string) (*models.Device, error)
+}
+
+// CommandQueue enqueues downlinks in the ChirpStack device queue
+type CommandQueue interface {
+	EnqueueDownlink(item models.ChirpStackQueueItem) (string, error)
+}
+
+type CommandServiceInterface interface {
+	SendCommand(deviceID, userID uuid.UUID, role string, req *models.SendCommandRequest) (*models.DeviceCommand, error)
+	GetDeviceCommands(deviceID, userID uuid.UUID, role string, page, pageSize int) (*models.DeviceCommandListResponse, error)
+}
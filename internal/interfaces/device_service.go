@@ -21,10 +21,10 @@ type DeviceServiceInterface interface {
 	DeleteAllowedDevice(devEUI string) error
 
 	// Device methods
-	CreateDevice(userID uuid.UUID, req *models.CreateDeviceRequest) (*models.Device, error)
-	GetDeviceByID(id uuid.UUID) (*models.Device, error)
+	CreateDevice(userID uuid.UUID, role string, req *models.CreateDeviceRequest) (*models.Device, error)
+	GetDeviceByID(id, userID uuid.UUID, role string) (*models.Device, error)
 	GetDevicesByUserID(userID uuid.UUID, page, pageSize int) (*models.DeviceListResponse, error)
 	GetAllDevices(page, pageSize int) (*models.DeviceListResponse, error)
-	UpdateDevice(id uuid.UUID, req *models.UpdateDeviceRequest) error
-	DeleteDevice(id uuid.UUID) error
+	UpdateDevice(id, userID uuid.UUID, role string, req *models.UpdateDeviceRequest) error
+	DeleteDevice(id, userID uuid.UUID, role string) error
 }
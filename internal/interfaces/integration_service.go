@@ -0,0 +1,45 @@
+package interfaces
+
+import (
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// IntegrationOrganizations finds the organization owning the ChirpStack
+// tenant or application an event comes from
+type IntegrationOrganizations interface {
+	GetOrganizationByTenantID(tenantID string) (*models.Organization, error)
+	GetOrganizationByApplicationID(applicationID string) (*models.Organization, error)
+}
+
+// IntegrationApplications lists the ChirpStack applications whose events are
+// received over MQTT
+type IntegrationApplications interface {
+	GetApplicationIDs() ([]string, error)
+}
+
+// IntegrationDevices maps the DevEUIs of events to devices and records their
+// activity
+type IntegrationDevices interface {
+	GetDeviceByDevEUI(devEUI string) (*models.Device, error)
+	UpdateDeviceLastSeen(id uuid.UUID, at time.Time) error
+}
+
+// IntegrationTelemetry stores the measurements of uplinks
+type IntegrationTelemetry interface {
+	InsertTelemetry(sample *models.TelemetrySample) error
+}
+
+// IntegrationCommands follows the delivery of the commands sent to devices
+type IntegrationCommands interface {
+	UpdateCommandStatus(deviceID uuid.UUID, queueItemID, status string) error
+}
+
+// IntegrationServiceInterface ingests the events of the ChirpStack integrations
+type IntegrationServiceInterface interface {
+	HandleWebhook(eventType, secret, contentType string, body []byte) error
+	HandleApplicationEvent(applicationID, eventType, encoding string, body []byte) error
+}
@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// JobStore persists the job queue
+type JobStore interface {
+	ClaimNext(lease time.Duration) (*models.Job, error)
+	Complete(id uuid.UUID, attempt int) error
+	Reschedule(id uuid.UUID, attempt int, runAt time.Time, lastError string) error
+	Bury(id uuid.UUID, attempt int, lastError string) error
+	Requeue(id uuid.UUID) error
+	GetJobByID(id uuid.UUID) (*models.Job, error)
+	GetOrganizationJobs(orgID uuid.UUID, page, pageSize int) ([]models.Job, int, error)
+	GetJobs(status string, page, pageSize int) ([]models.Job, int, error)
+}
+
+// JobServiceInterface lets clients poll background jobs and admins manage
+// dead letters
+type JobServiceInterface interface {
+	GetJob(id, userID uuid.UUID, role string) (*models.Job, error)
+	GetOrganizationJobs(orgID, userID uuid.UUID, role string, page, pageSize int) (*models.JobListResponse, error)
+	GetJobs(status string, page, pageSize int) (*models.JobListResponse, error)
+	RetryJob(id uuid.UUID) error
+}
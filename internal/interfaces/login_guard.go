@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SecurityStore persists failed login counters and security events
+type SecurityStore interface {
+	RecordFailedLogin(userID uuid.UUID, lockAfter int, lockedUntil time.Time) (int, *time.Time, error)
+	ResetFailedLogins(userID uuid.UUID) error
+	CreateSecurityEvent(event *models.SecurityEvent) error
+	GetSecurityEvents(userID *uuid.UUID, page, pageSize int) ([]models.SecurityEvent, int, error)
+}
+
+// LoginGuardInterface exposes the admin side of brute-force protection
+type LoginGuardInterface interface {
+	UnlockUser(id string, adminID uuid.UUID) error
+	GetSecurityEvents(userID *uuid.UUID, page, pageSize int) (*models.SecurityEventListResponse, error)
+}
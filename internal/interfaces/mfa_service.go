@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type MFAServiceInterface interface {
+	Enroll(userID uuid.UUID) (*models.TOTPEnrollResponse, error)
+	Confirm(userID uuid.UUID, code string) (*models.RecoveryCodesResponse, error)
+	Disable(userID uuid.UUID, req *models.DisableTOTPRequest) error
+	RegenerateRecoveryCodes(userID uuid.UUID, code string) (*models.RecoveryCodesResponse, error)
+	ResetUserMFA(id string) error
+	CompleteLogin(req *models.MFALoginRequest) (*models.AuthResponse, error)
+	GetRolePolicies() ([]models.RolePolicy, error)
+	SetRolePolicy(role string, requireMFA bool) error
+}
+
+// MFAPolicyChecker is used by RequireMFA to look up the role policies
+type MFAPolicyChecker interface {
+	RequiresMFA(role string) bool
+}
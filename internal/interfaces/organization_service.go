@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// OrganizationStore persists organizations, their members and invitations
+type OrganizationStore interface {
+	ProvisioningStore
+	CreateOrganization(org *models.Organization, ownerID uuid.UUID, job *models.Job) error
+	GetOrganizationsForUser(userID uuid.UUID) ([]models.Organization, error)
+	UpdateOrganizationName(id uuid.UUID, name string) error
+	EnqueueIntegrationUpdates(url, encoding string) (int, error)
+
+	GetMemberRole(orgID, userID uuid.UUID) (string, error)
+	GetMembers(orgID uuid.UUID) ([]models.OrganizationMember, error)
+	UpdateMemberRole(orgID, userID uuid.UUID, role string) error
+	RemoveMember(orgID, userID uuid.UUID) error
+
+	CreateInvitation(invitation *models.OrganizationInvitation) error
+	GetPendingInvitations(orgID uuid.UUID) ([]models.OrganizationInvitation, error)
+	DeleteInvitation(orgID, id uuid.UUID) error
+	AcceptInvitation(hash string, userID uuid.UUID, email string) (uuid.UUID, error)
+}
+
+// OrganizationServiceInterface takes the caller's user ID and global role on
+// every organization call, so global admins can manage any organization
+type OrganizationServiceInterface interface {
+	CreateOrganization(userID uuid.UUID, req *models.CreateOrganizationRequest) (*models.Organization, error)
+	GetOrganizations(userID uuid.UUID) ([]models.Organization, error)
+	GetOrganization(id, userID uuid.UUID, role string) (*models.Organization, error)
+	UpdateOrganization(id, userID uuid.UUID, role string, req *models.UpdateOrganizationRequest) (*models.Organization, error)
+
+	GetMembers(id, userID uuid.UUID, role string) ([]models.OrganizationMember, error)
+	UpdateMemberRole(id, userID uuid.UUID, role string, memberID uuid.UUID, req *models.UpdateMemberRoleRequest) error
+	RemoveMember(id, userID uuid.UUID, role string, memberID uuid.UUID) error
+
+	CreateInvitation(id, userID uuid.UUID, role string, req *models.CreateInvitationRequest) (*models.OrganizationInvitation, error)
+	GetInvitations(id, userID uuid.UUID, role string) ([]models.OrganizationInvitation, error)
+	RevokeInvitation(id, userID uuid.UUID, role string, invitationID uuid.UUID) error
+	AcceptInvitation(userID uuid.UUID, token string) (*models.Organization, error)
+}
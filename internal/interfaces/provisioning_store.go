@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ProvisioningStore persists the progress of ChirpStack provisioning and the
+// registered HTTP integration on the organization row
+type ProvisioningStore interface {
+	GetOrganizationByID(id uuid.UUID) (*models.Organization, error)
+	UpdateOrganizationProvisioning(org *models.Organization) error
+	UpdateOrganizationIntegration(id uuid.UUID, url, encoding, secret string) error
+}
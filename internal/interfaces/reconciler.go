@@ -0,0 +1,32 @@
+package interfaces
+
+import (
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ReconcileOrganizations returns the organizations the reconciler checks
+type ReconcileOrganizations interface {
+	GetOrganizationByID(id uuid.UUID) (*models.Organization, error)
+	GetProvisionedOrganizations() ([]models.Organization, error)
+}
+
+// ReconcileDevices is the devices table as read and repaired by the reconciler
+type ReconcileDevices interface {
+	GetOrganizationDevices(orgID uuid.UUID) ([]models.Device, error)
+	GetDeviceByDevEUI(devEUI string) (*models.Device, error)
+	GetAllowedDeviceByDevEUI(devEUI string) (*models.AllowedDevice, error)
+	UpdateDeviceChirpStackStatus(id uuid.UUID, created, activated bool) error
+}
+
+// JobEnqueuer queues background jobs
+type JobEnqueuer interface {
+	Enqueue(job *models.Job) error
+}
+
+// ReconcilerInterface compares the devices table with ChirpStack on demand
+type ReconcilerInterface interface {
+	Reconcile(req *models.ReconcileRequest) (*models.ReconcileReport, error)
+	LastReport() (*models.ReconcileReport, error)
+}
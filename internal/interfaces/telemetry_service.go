@@ -0,0 +1,26 @@
+package interfaces
+
+import (
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// TelemetryStore answers range queries over the telemetry of devices
+type TelemetryStore interface {
+	GetTelemetry(deviceID uuid.UUID, from, to time.Time, fields []string, limit int) ([]models.TelemetryPoint, error)
+	GetTelemetryBuckets(deviceID uuid.UUID, from, to time.Time, interval time.Duration, fields []string) ([]models.TelemetryPoint, error)
+	EnsureTelemetryPartitions(from, to time.Time) error
+}
+
+// DeviceAccess loads a device on behalf of a user, failing for devices the
+// user may not see
+type DeviceAccess interface {
+	GetDeviceByID(id, userID uuid.UUID, role string) (*models.Device, error)
+}
+
+type TelemetryServiceInterface interface {
+	GetDeviceTelemetry(deviceID, userID uuid.UUID, role string, query *models.TelemetryQuery) (*models.TelemetryResponse, error)
+}
@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"time"
+
+	"go-auth-api/internal/auth"
+
+	"github.com/google/uuid"
+)
+
+// TokenRevocationChecker is consulted by AuthMiddleware for every access token
+type TokenRevocationChecker interface {
+	IsRevoked(claims *auth.JWTClaims) bool
+}
+
+// TokenRevocationStorage persists the access token denylist
+type TokenRevocationStorage interface {
+	RevokeAccessToken(jti string, userID uuid.UUID, expiresAt time.Time) error
+	RevokeUserAccessTokens(userID uuid.UUID, revokedBefore time.Time) error
+	GetRevokedAccessTokens() (map[string]time.Time, error)
+	GetUserRevocationsSince(since time.Time) (map[uuid.UUID]time.Time, error)
+	DeleteExpiredRevocations(olderThan time.Time) error
+}
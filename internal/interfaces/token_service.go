@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type TokenServiceInterface interface {
+	Refresh(refreshToken string) (*models.AuthResponse, error)
+	Logout(refreshToken string) error
+	LogoutAll(userID uuid.UUID) error
+	RevokeAccessToken(jti string, userID uuid.UUID, expiresAt time.Time) error
+}
@@ -8,6 +8,9 @@ type UserServiceInterface interface {
 	GetUserByID(id string) (*models.User, error)
 	GetAllUsers(page, pageSize int) (*models.UserListResponse, error)
 	UpdateUser(id string, req *models.UpdateUserRequest) (*models.User, error)
+	UpdateUserRole(id string, role string) (*models.User, error)
+	SuspendUser(id string) error
+	ReactivateUser(id string) error
 	DeleteUser(id string) error
 	SearchUsers(req *models.UserSearchRequest) (*models.UserListResponse, error)
 }
@@ -0,0 +1,79 @@
+package lnode
+
+import "fmt"
+
+// Opcode identifies a downlink command. A command is its opcode followed by
+// the argument, if the command has one.
+type Opcode uint8
+
+// CommandSet is the downlink protocol of the controllers: the port commands
+// are taken on and the opcode of each command. The frame spec only covers
+// uplinks, so the values are configurable.
+type CommandSet struct {
+	FPort int
+	// Dimming sets the dimming level, 0 to 100 percent
+	Dimming Opcode
+	// Switch switches the lamp off (0) or on (1)
+	Switch Opcode
+	// StatusRequest asks for a power frame
+	StatusRequest Opcode
+	// GPSRequest asks for a GPS frame with a fresh fix
+	GPSRequest Opcode
+}
+
+// DefaultCommandSet is used unless the port and opcodes are configured.
+// UNVERIFIED: these values are not taken from the controller firmware
+// documentation and have not been confirmed on a lamp.
+var DefaultCommandSet = CommandSet{
+	FPort:         10,
+	Dimming:       0x10,
+	Switch:        0x11,
+	StatusRequest: 0x20,
+	GPSRequest:    0x21,
+}
+
+// MaxDimming is the highest dimming level, full brightness
+const MaxDimming = 100
+
+// Validate checks that the port is an application port and that the opcodes
+// tell the commands apart
+func (c CommandSet) Validate() error {
+	if c.FPort < 1 || c.FPort > 223 {
+		return fmt.Errorf("command fPort %d is not within 1-223", c.FPort)
+	}
+	opcodes := []Opcode{c.Dimming, c.Switch, c.StatusRequest, c.GPSRequest}
+	seen := map[Opcode]bool{}
+	for _, opcode := range opcodes {
+		if seen[opcode] {
+			return fmt.Errorf("command opcode 0x%02X is used twice", uint8(opcode))
+		}
+		seen[opcode] = true
+	}
+	return nil
+}
+
+// DimmingCommand builds the command setting the dimming level
+func (c CommandSet) DimmingCommand(level int) ([]byte, error) {
+	if level < 0 || level > MaxDimming {
+		return nil, fmt.Errorf("%w: dimming level %d is not within 0-%d", ErrValueRange, level, MaxDimming)
+	}
+	return []byte{byte(c.Dimming), byte(level)}, nil
+}
+
+// SwitchCommand builds the command switching the lamp on or off
+func (c CommandSet) SwitchCommand(on bool) []byte {
+	if on {
+		return []byte{byte(c.Switch), 1}
+	}
+	return []byte{byte(c.Switch), 0}
+}
+
+// StatusRequestCommand builds the command asking the controller for its state
+func (c CommandSet) StatusRequestCommand() []byte {
+	return []byte{byte(c.StatusRequest)}
+}
+
+// GPSRequestCommand builds the command asking the controller for its position
+func (c CommandSet) GPSRequestCommand() []byte {
+	return []byte{byte(c.GPSRequest)}
+}
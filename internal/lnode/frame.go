@@ -0,0 +1,254 @@
+package lnode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Errors returned by Decode and Encode, matched with errors.Is
+var (
+	ErrEmptyFrame    = errors.New("lnode: empty frame")
+	ErrUnknownHeader = errors.New("lnode: unknown frame header")
+	ErrShortFrame    = errors.New("lnode: frame too short")
+	ErrMissingValue  = errors.New("lnode: missing value")
+	ErrValueRange    = errors.New("lnode: value out of range")
+)
+
+// PowerFrame reports the lamp state and the electrical measurements. The
+// measurements are sent in hundredths.
+type PowerFrame struct {
+	Dimming    uint8   `json:"Dimming"`
+	StatusLamp uint8   `json:"Status_lamp"`
+	Energy     float64 `json:"Energy"`
+	Voltage    float64 `json:"voltage"`
+	Current    float64 `json:"current"`
+	PF         float64 `json:"PF"`
+	Power      float64 `json:"Power"`
+	Tilt       float64 `json:"Tilt"`
+}
+
+// GPSFrame reports the position in degrees, sent in millionths
+type GPSFrame struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+	Alt uint8   `json:"alt"`
+}
+
+// TimestampFrame reports the controller's clock in Unix seconds
+type TimestampFrame struct {
+	Timestamp uint32 `json:"timestamp"`
+}
+
+// StatusFrame reports a status code. Codes 50 to 53 carry the controller ID.
+type StatusFrame struct {
+	StatusCode uint16  `json:"status_code"`
+	ID         *uint32 `json:"ID,omitempty"`
+}
+
+// Uplink is a decoded frame. The frame matching Header is set.
+type Uplink struct {
+	Header    Header          `json:"header_device"`
+	Power     *PowerFrame     `json:"power,omitempty"`
+	GPS       *GPSFrame       `json:"gps,omitempty"`
+	Timestamp *TimestampFrame `json:"timestamp,omitempty"`
+	Status    *StatusFrame    `json:"status,omitempty"`
+}
+
+// Decode parses an uplink frame. Bytes after the frame are ignored.
+func Decode(frame []byte) (*Uplink, error) {
+	if len(frame) == 0 {
+		return nil, ErrEmptyFrame
+	}
+	spec, ok := LookupSpec(Header(frame[0]))
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownHeader, frame[0])
+	}
+
+	raw := make(map[string]int64)
+	if err := readFields(raw, spec, spec.Fields, frame, false); err != nil {
+		return nil, err
+	}
+	if spec.Condition != "" && spec.hasOptional(raw[spec.Condition]) {
+		if err := readFields(raw, spec, spec.Optional, frame, true); err != nil {
+			return nil, err
+		}
+	}
+
+	value := func(name string) float64 {
+		f, _ := spec.field(name)
+		return f.scaled(raw[name])
+	}
+
+	uplink := &Uplink{Header: spec.Header}
+	switch spec.Header {
+	case HeaderPower:
+		uplink.Power = &PowerFrame{
+			Dimming:    uint8(raw["Dimming"]),
+			StatusLamp: uint8(raw["Status_lamp"]),
+			Energy:     value("Energy"),
+			Voltage:    value("voltage"),
+			Current:    value("current"),
+			PF:         value("PF"),
+			Power:      value("Power"),
+			Tilt:       value("Tilt"),
+		}
+	case HeaderGPS:
+		uplink.GPS = &GPSFrame{Lat: value("lat"), Lng: value("lng"), Alt: uint8(raw["alt"])}
+	case HeaderTimestamp:
+		uplink.Timestamp = &TimestampFrame{Timestamp: uint32(raw["timestamp"])}
+	case HeaderStatus:
+		uplink.Status = &StatusFrame{StatusCode: uint16(raw["status_code"])}
+		if id, ok := raw["ID"]; ok {
+			v := uint32(id)
+			uplink.Status.ID = &v
+		}
+	}
+	return uplink, nil
+}
+
+func readFields(raw map[string]int64, spec FrameSpec, fields []Field, frame []byte, optional bool) error {
+	if length := spec.Length(optional); len(frame) < length {
+		return fmt.Errorf("%w: header %d frames have %d bytes, got %d", ErrShortFrame, spec.Header, length, len(frame))
+	}
+	for _, f := range fields {
+		raw[f.Name] = f.read(frame)
+	}
+	return nil
+}
+
+// Encode builds the frame of an uplink, the inverse of Decode. Scaled values
+// are rounded to the resolution of the frame.
+func Encode(uplink *Uplink) ([]byte, error) {
+	spec, ok := LookupSpec(uplink.Header)
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownHeader, uplink.Header)
+	}
+	values, err := uplink.values()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := spec.Fields
+	optional := spec.Condition != "" && spec.hasOptional(int64(values[spec.Condition]))
+	if optional {
+		fields = append(append([]Field(nil), spec.Fields...), spec.Optional...)
+	} else {
+		for _, f := range spec.Optional {
+			if _, ok := values[f.Name]; ok {
+				return nil, fmt.Errorf("%w: %s is only sent with %s %v", ErrValueRange, f.Name, spec.Condition, spec.ConditionValues)
+			}
+		}
+	}
+
+	frame := make([]byte, spec.Length(optional))
+	frame[0] = byte(spec.Header)
+	for _, f := range fields {
+		v, ok := values[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingValue, f.Name)
+		}
+		raw, ok := f.raw(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s = %v", ErrValueRange, f.Name, v)
+		}
+		f.write(frame, raw)
+	}
+	return frame, nil
+}
+
+// Object returns the uplink as the object the JavaScript codec decodes it to
+func (u *Uplink) Object() map[string]interface{} {
+	object := map[string]interface{}{"header_device": int(u.Header)}
+
+	spec, ok := LookupSpec(u.Header)
+	values, err := u.values()
+	if !ok || err != nil {
+		return object
+	}
+	for _, f := range append(append([]Field(nil), spec.Fields...), spec.Optional...) {
+		if v, ok := values[f.Name]; ok {
+			if f.Scale == 0 {
+				object[f.Name] = int64(v)
+			} else {
+				object[f.Name] = v
+			}
+		}
+	}
+	return object
+}
+
+// values returns the field values of the uplink's frame by name
+func (u *Uplink) values() (map[string]float64, error) {
+	missing := fmt.Errorf("%w: header %d uplink without its frame", ErrMissingValue, u.Header)
+
+	switch u.Header {
+	case HeaderPower:
+		if u.Power == nil {
+			return nil, missing
+		}
+		p := u.Power
+		return map[string]float64{
+			"Dimming":     float64(p.Dimming),
+			"Status_lamp": float64(p.StatusLamp),
+			"Energy":      p.Energy,
+			"voltage":     p.Voltage,
+			"current":     p.Current,
+			"PF":          p.PF,
+			"Power":       p.Power,
+			"Tilt":        p.Tilt,
+		}, nil
+	case HeaderGPS:
+		if u.GPS == nil {
+			return nil, missing
+		}
+		return map[string]float64{"lat": u.GPS.Lat, "lng": u.GPS.Lng, "alt": float64(u.GPS.Alt)}, nil
+	case HeaderTimestamp:
+		if u.Timestamp == nil {
+			return nil, missing
+		}
+		return map[string]float64{"timestamp": float64(u.Timestamp.Timestamp)}, nil
+	case HeaderStatus:
+		if u.Status == nil {
+			return nil, missing
+		}
+		values := map[string]float64{"status_code": float64(u.Status.StatusCode)}
+		if u.Status.ID != nil {
+			values["ID"] = float64(*u.Status.ID)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnknownHeader, u.Header)
+	}
+}
+
+// field looks up a fixed or optional field by name
+func (s FrameSpec) field(name string) (Field, bool) {
+	for _, f := range append(append([]Field(nil), s.Fields...), s.Optional...) {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// scaled converts a raw value to the value the codec reports
+func (f Field) scaled(raw int64) float64 {
+	if f.Scale == 0 {
+		return float64(raw)
+	}
+	return float64(raw) / f.Scale
+}
+
+// raw converts a reported value back to the value sent, reporting whether
+// it fits the field
+func (f Field) raw(v float64) (int64, bool) {
+	if f.Scale != 0 {
+		v = math.Round(v * f.Scale)
+	}
+	if math.IsNaN(v) || v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+		return 0, false
+	}
+	raw := int64(v)
+	return raw, f.inRange(raw)
+}
@@ -0,0 +1,88 @@
+package lnode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// unknownHeaderWarning is the warning of the JavaScript codec for frames of
+// other devices, kept from the original codec
+const unknownHeaderWarning = "Gói tin không thuộc thiết bị được mong đợi"
+
+// JavaScript returns the ChirpStack codec decoding the frames of Spec. It
+// decodes to the same object as Uplink.Object.
+func JavaScript() string {
+	var b strings.Builder
+
+	b.WriteString(`// Lnode uplink codec, generated from the frame spec of the lnode Go package.
+
+function readUint(bytes, offset, size) {
+  var value = 0;
+  for (var i = size - 1; i >= 0; i--) {
+    value = value * 256 + bytes[offset + i];
+  }
+  return value;
+}
+
+function readInt(bytes, offset, size) {
+  var value = readUint(bytes, offset, size);
+  var limit = Math.pow(2, 8 * size - 1);
+  return value >= limit ? value - 2 * limit : value;
+}
+
+function shortFrame(header, length, bytes) {
+  return {
+    errors: ["header " + header + " frames have " + length + " bytes, got " + bytes.length]
+  };
+}
+
+function decodeUplink(input) {
+  var bytes = input.bytes;
+  if (bytes.length == 0) {
+    return { errors: ["empty frame"] };
+  }
+
+  var header_device = bytes[0];
+  var data = { header_device: header_device };
+`)
+
+	for _, spec := range Spec {
+		fmt.Fprintf(&b, "\n  if (header_device == %d) {\n", spec.Header)
+		writeFields(&b, spec, spec.Fields, false, "    ")
+		if spec.Condition != "" {
+			values := make([]string, len(spec.ConditionValues))
+			for i, v := range spec.ConditionValues {
+				values[i] = strconv.FormatInt(v, 10)
+			}
+			fmt.Fprintf(&b, "    if ([%s].indexOf(data.%s) >= 0) {\n", strings.Join(values, ", "), spec.Condition)
+			writeFields(&b, spec, spec.Optional, true, "      ")
+			b.WriteString("    }\n")
+		}
+		b.WriteString("    return { data: data, warnings: [], errors: [] };\n  }\n")
+	}
+
+	fmt.Fprintf(&b, `
+  return { data: data, warnings: [%q], errors: [] };
+}
+`, unknownHeaderWarning)
+
+	return b.String()
+}
+
+func writeFields(b *strings.Builder, spec FrameSpec, fields []Field, optional bool, indent string) {
+	length := spec.Length(optional)
+	fmt.Fprintf(b, "%sif (bytes.length < %d) {\n%s  return shortFrame(header_device, %d, bytes);\n%s}\n", indent, length, indent, length, indent)
+
+	for _, f := range fields {
+		read := "readUint"
+		if f.Signed {
+			read = "readInt"
+		}
+		expr := fmt.Sprintf("%s(bytes, %d, %d)", read, f.Offset, f.Size)
+		if f.Scale != 0 {
+			expr += " / " + strconv.FormatFloat(f.Scale, 'f', -1, 64)
+		}
+		fmt.Fprintf(b, "%sdata.%s = %s;\n", indent, f.Name, expr)
+	}
+}
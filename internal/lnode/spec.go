@@ -0,0 +1,143 @@
+// Package lnode decodes and encodes the uplink frames of Lnode streetlight
+// controllers. The first byte of a frame is its header, which selects the
+// layout of the rest; multi-byte values are little endian. The JavaScript
+// codec of the device profiles is generated from the same spec.
+package lnode
+
+// Header identifies the kind of an uplink frame
+type Header uint8
+
+const (
+	HeaderPower     Header = 1
+	HeaderGPS       Header = 2
+	HeaderTimestamp Header = 3
+	HeaderStatus    Header = 4
+)
+
+// Field is one value of a frame. Names are the keys of the decoded object in
+// ChirpStack.
+type Field struct {
+	Name   string
+	Offset int
+	// Size in bytes, little endian
+	Size   int
+	Signed bool
+	// Scale divides the raw value, 0 leaves it an integer
+	Scale float64
+}
+
+// FrameSpec is the layout of the frames with one header. Optional fields
+// follow the fixed ones when the field named Condition holds one of
+// ConditionValues.
+type FrameSpec struct {
+	Header          Header
+	Fields          []Field
+	Optional        []Field
+	Condition       string
+	ConditionValues []int64
+}
+
+// Spec lists the frame layouts by header
+var Spec = []FrameSpec{
+	{
+		Header: HeaderPower,
+		Fields: []Field{
+			{Name: "Dimming", Offset: 1, Size: 1},
+			{Name: "Status_lamp", Offset: 2, Size: 1},
+			{Name: "Energy", Offset: 3, Size: 4, Scale: 100},
+			{Name: "voltage", Offset: 7, Size: 2, Scale: 100},
+			{Name: "current", Offset: 9, Size: 2, Scale: 100},
+			{Name: "PF", Offset: 11, Size: 2, Scale: 100},
+			{Name: "Power", Offset: 13, Size: 2, Scale: 100},
+			{Name: "Tilt", Offset: 15, Size: 2, Scale: 100},
+		},
+	},
+	{
+		Header: HeaderGPS,
+		Fields: []Field{
+			{Name: "lat", Offset: 1, Size: 4, Signed: true, Scale: 1000000},
+			{Name: "lng", Offset: 5, Size: 4, Signed: true, Scale: 1000000},
+			{Name: "alt", Offset: 9, Size: 1},
+		},
+	},
+	{
+		Header: HeaderTimestamp,
+		Fields: []Field{
+			{Name: "timestamp", Offset: 1, Size: 4},
+		},
+	},
+	{
+		Header: HeaderStatus,
+		Fields: []Field{
+			{Name: "status_code", Offset: 1, Size: 2},
+		},
+		// Status codes 50 to 53 report the ID of the controller
+		Optional:        []Field{{Name: "ID", Offset: 3, Size: 4}},
+		Condition:       "status_code",
+		ConditionValues: []int64{50, 51, 52, 53},
+	},
+}
+
+// LookupSpec returns the layout of the frames with a header
+func LookupSpec(header Header) (FrameSpec, bool) {
+	for _, spec := range Spec {
+		if spec.Header == header {
+			return spec, true
+		}
+	}
+	return FrameSpec{}, false
+}
+
+// Length returns the size of a frame, with or without the optional fields
+func (s FrameSpec) Length(optional bool) int {
+	fields := s.Fields
+	if optional {
+		fields = append(append([]Field(nil), s.Fields...), s.Optional...)
+	}
+	length := 1
+	for _, f := range fields {
+		if end := f.Offset + f.Size; end > length {
+			length = end
+		}
+	}
+	return length
+}
+
+// hasOptional tells whether a frame with the condition value carries the
+// optional fields
+func (s FrameSpec) hasOptional(value int64) bool {
+	for _, v := range s.ConditionValues {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// read returns the raw value of a field
+func (f Field) read(frame []byte) int64 {
+	var value uint64
+	for i := f.Size - 1; i >= 0; i-- {
+		value = value<<8 | uint64(frame[f.Offset+i])
+	}
+	if f.Signed && value >= 1<<(8*f.Size-1) {
+		return int64(value) - 1<<(8*f.Size)
+	}
+	return int64(value)
+}
+
+// write stores the raw value of a field
+func (f Field) write(frame []byte, value int64) {
+	for i := 0; i < f.Size; i++ {
+		frame[f.Offset+i] = byte(value >> (8 * i))
+	}
+}
+
+// inRange tells whether a raw value fits the field
+func (f Field) inRange(value int64) bool {
+	bits := 8 * f.Size
+	if f.Signed {
+		return value >= -(1<<(bits-1)) && value < 1<<(bits-1)
+	}
+	return value >= 0 && value < 1<<bits
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer writes emails to a file or stdout instead of sending them. It is
+// meant for local development and tests.
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogMailer appends emails to path, or writes them to stdout when path is empty
+func NewLogMailer(path string) (*LogMailer, error) {
+	if path == "" {
+		return NewWriterMailer(os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mail log: %w", err)
+	}
+
+	return NewWriterMailer(f), nil
+}
+
+// NewWriterMailer writes emails to w
+func NewWriterMailer(w io.Writer) *LogMailer {
+	return &LogMailer{w: w}
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().UTC().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"fmt"
+
+	"go-auth-api/internal/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// New returns the mailer selected by MAILER_DRIVER: "smtp" or "log" (the default)
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailerDriver {
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "log", "":
+		return NewLogMailer(cfg.MailLogFile)
+	default:
+		return nil, fmt.Errorf("unknown mailer driver: %s", cfg.MailerDriver)
+	}
+}
@@ -6,10 +6,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go-auth-api/internal/auth"
+	"go-auth-api/internal/interfaces"
 )
 
-func AuthMiddleware(jwtService *auth.JWTService) gin.HandlerFunc {
+// AuthMiddleware accepts a Bearer access token or an API key, given either as
+// "Authorization: Bearer lnk_..." or in the X-API-Key header. API keys are
+// only checked when apiKeys is not nil.
+func AuthMiddleware(jwtService *auth.JWTService, revocations interfaces.TokenRevocationChecker, apiKeys interfaces.APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" && apiKeys != nil {
+			authenticateAPIKey(c, apiKeys, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -27,6 +36,11 @@ func AuthMiddleware(jwtService *auth.JWTService) gin.HandlerFunc {
 		// Extract token
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
+		if auth.IsAPIKey(token) && apiKeys != nil {
+			authenticateAPIKey(c, apiKeys, token)
+			return
+		}
+
 		// Validate token
 		claims, err := jwtService.ValidateToken(token)
 		if err != nil {
@@ -35,10 +49,62 @@ func AuthMiddleware(jwtService *auth.JWTService) gin.HandlerFunc {
 			return
 		}
 
-		// Set user info in context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
+		// Check token denylist
+		if revocations != nil && revocations.IsRevoked(claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		setClaims(c, claims)
+
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware sets the user info in the context when the request
+// carries a valid, non-revoked access token and lets it through regardless.
+func OptionalAuthMiddleware(jwtService *auth.JWTService, revocations interfaces.TokenRevocationChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			claims, err := jwtService.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+			if err == nil && (revocations == nil || !revocations.IsRevoked(claims)) {
+				setClaims(c, claims)
+			}
+		}
 
 		c.Next()
 	}
 }
+
+// setClaims stores the user info from a validated token in the context
+func setClaims(c *gin.Context, claims *auth.JWTClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("user_email", claims.Email)
+	c.Set("user_role", claims.Role)
+	c.Set("token_id", claims.ID)
+	c.Set("auth_methods", claims.AMR)
+	if claims.ExpiresAt != nil {
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+	}
+}
+
+// authenticateAPIKey resolves an API key and sets the same user info as a
+// token would, plus the key's scopes
+func authenticateAPIKey(c *gin.Context, apiKeys interfaces.APIKeyAuthenticator, key string) {
+	principal, err := apiKeys.AuthenticateAPIKey(key)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", principal.UserID)
+	c.Set("user_email", principal.Email)
+	c.Set("user_role", principal.Role)
+	c.Set("api_key_id", principal.KeyID)
+	c.Set("api_key_scopes", principal.Scopes)
+
+	c.Next()
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireMFA rejects access tokens without a second factor when the policy of
+// the user's role requires one. API keys are not interactive and are limited
+// by their scopes instead. It must run after AuthMiddleware.
+func RequireMFA(policies interfaces.MFAPolicyChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := c.Get("api_key_id"); isAPIKey {
+			c.Next()
+			return
+		}
+
+		if !policies.RequiresMFA(c.GetString("user_role")) {
+			c.Next()
+			return
+		}
+
+		for _, method := range c.GetStringSlice("auth_methods") {
+			if method == auth.AMROTP {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication required"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequireRole only lets requests through when the authenticated user has one
+// of the given roles. It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("user_role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// RequireSelfOrRole lets requests through when the URL parameter param is the
+// authenticated user's own ID, or when the user has one of the given roles.
+// It must run after AuthMiddleware.
+func RequireSelfOrRole(param string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("user_role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		userID, exists := c.Get("user_id")
+		if exists {
+			if id, err := uuid.Parse(c.Param(param)); err == nil && id == userID.(uuid.UUID) {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope only lets API key requests through when the key has the given
+// scope. Requests authenticated with an access token are limited by role only.
+// It must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := c.Get("api_key_id"); !isAPIKey {
+			c.Next()
+			return
+		}
+
+		for _, granted := range c.GetStringSlice("api_key_scopes") {
+			if granted == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing scope " + scope})
+		c.Abort()
+	}
+}
+
+// RejectAPIKeys blocks API keys from routes that require an interactive
+// login, such as account and API key management. It must run after AuthMiddleware.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := c.Get("api_key_id"); isAPIKey {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot access this endpoint"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
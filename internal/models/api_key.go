@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key kinds. Personal keys act on behalf of the user who created them,
+// service keys are issued by admins for integration accounts.
+const (
+	APIKeyKindPersonal = "personal"
+	APIKeyKindService  = "service"
+)
+
+// API key scopes
+const (
+	ScopeDevicesRead    = "devices:read"
+	ScopeDevicesWrite   = "devices:write"
+	ScopeInventoryAdmin = "inventory:admin"
+)
+
+// RoleScopes lists the scopes a user of each role may grant to an API key
+var RoleScopes = map[string][]string{
+	RoleAdmin:    {ScopeDevicesRead, ScopeDevicesWrite, ScopeInventoryAdmin},
+	RoleOperator: {ScopeDevicesRead, ScopeDevicesWrite, ScopeInventoryAdmin},
+	RoleCustomer: {ScopeDevicesRead, ScopeDevicesWrite},
+}
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	Name       string     `json:"name" db:"name"`
+	Kind       string     `json:"kind" db:"kind"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// APIKeyPrincipal is the identity a valid API key authenticates as
+type APIKeyPrincipal struct {
+	KeyID  uuid.UUID
+	UserID uuid.UUID
+	Email  string
+	Role   string
+	Scopes []string
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Kind      string     `json:"kind" binding:"omitempty,oneof=personal service"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Owner of a service key, defaults to the creating admin
+	UserID *uuid.UUID `json:"user_id"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey APIKey `json:"api_key"`
+	// The full key, only returned once
+	Key string `json:"key"`
+}
+
+type APIKeyListResponse struct {
+	APIKeys []APIKey `json:"api_keys"`
+	Total   int      `json:"total"`
+}
@@ -114,3 +114,40 @@ type ChirpStackUserData struct {
 	DeviceProfileID   string    `json:"device_profile_id"`
 	CreatedAt         time.Time `json:"created_at"`
 }
+
+// ChirpStack device keys, used by OTAA devices to join
+type ChirpStackDeviceKeys struct {
+	NwkKey string `json:"nwkKey"`
+	AppKey string `json:"appKey"`
+}
+
+// ChirpStackDeviceListItem is a device as returned by the device list
+type ChirpStackDeviceListItem struct {
+	DevEUI            string     `json:"devEui"`
+	Name              string     `json:"name"`
+	Description       string     `json:"description"`
+	DeviceProfileID   string     `json:"deviceProfileId"`
+	DeviceProfileName string     `json:"deviceProfileName"`
+	LastSeenAt        *time.Time `json:"lastSeenAt,omitempty"`
+}
+
+// ChirpStackQueueItem is a downlink in a device queue. Data is sent base64
+// encoded, which encoding/json does for byte slices.
+type ChirpStackQueueItem struct {
+	ID        string `json:"id,omitempty"`
+	DevEUI    string `json:"devEui"`
+	Confirmed bool   `json:"confirmed"`
+	FPort     int    `json:"fPort"`
+	Data      []byte `json:"data"`
+	IsPending bool   `json:"isPending,omitempty"`
+	FCntDown  int    `json:"fCntDown,omitempty"`
+}
+
+// ChirpStackHTTPIntegration makes ChirpStack post the events of an
+// application to EventEndpointURL, sending Headers with every request
+type ChirpStackHTTPIntegration struct {
+	ApplicationID    string            `json:"applicationId"`
+	Headers          map[string]string `json:"headers"`
+	Encoding         string            `json:"encoding"`
+	EventEndpointURL string            `json:"eventEndpointUrl"`
+}
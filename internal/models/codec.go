@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Codec version states
+const (
+	CodecVersionDraft      = "draft"
+	CodecVersionPublished  = "published"
+	CodecVersionRolledBack = "rolled_back"
+)
+
+// Codec rollout states. Failed rollouts updated some of their profiles.
+const (
+	RolloutRunning     = "running"
+	RolloutSucceeded   = "succeeded"
+	RolloutFailed      = "failed"
+	RolloutRollingBack = "rolling_back"
+	RolloutRolledBack  = "rolled_back"
+)
+
+// Codec rollout target states
+const (
+	RolloutTargetPending    = "pending"
+	RolloutTargetUpdated    = "updated"
+	RolloutTargetFailed     = "failed"
+	RolloutTargetRolledBack = "rolled_back"
+)
+
+// Codec is a JavaScript payload codec used by device profiles. Device
+// versions attach a codec, the default codec is used by the organizations'
+// default profiles.
+type Codec struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	IsDefault   bool      `json:"is_default"`
+	// Latest published version, the one new device profiles get
+	PublishedVersion *int      `json:"published_version,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// CodecVersion is one revision of a codec's script
+type CodecVersion struct {
+	ID          uuid.UUID  `json:"id"`
+	CodecID     uuid.UUID  `json:"codec_id"`
+	Version     int        `json:"version"`
+	Script      string     `json:"script"`
+	Changelog   string     `json:"changelog"`
+	Status      string     `json:"status"`
+	AuthorID    *uuid.UUID `json:"author_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// CodecRollout pushes a published codec version to the device profiles of
+// every tenant using the codec
+type CodecRollout struct {
+	ID                uuid.UUID  `json:"id"`
+	CodecID           uuid.UUID  `json:"codec_id"`
+	CodecVersionID    uuid.UUID  `json:"codec_version_id"`
+	Status            string     `json:"status"`
+	RollbackOnFailure bool       `json:"rollback_on_failure"`
+	StartedBy         *uuid.UUID `json:"started_by,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+
+	// Progress, counted from the targets
+	Total      int `json:"total"`
+	Pending    int `json:"pending"`
+	Updated    int `json:"updated"`
+	Failed     int `json:"failed"`
+	RolledBack int `json:"rolled_back"`
+
+	Targets []CodecRolloutTarget `json:"targets,omitempty"`
+
+	// Background job running the rollout or its rollback
+	JobID *uuid.UUID `json:"job_id,omitempty"`
+}
+
+// CodecRolloutTarget is one device profile of a rollout. The codec it
+// replaced is kept to roll it back.
+type CodecRolloutTarget struct {
+	ID              uuid.UUID  `json:"id"`
+	RolloutID       uuid.UUID  `json:"rollout_id"`
+	OrganizationID  *uuid.UUID `json:"organization_id,omitempty"`
+	DeviceProfileID string     `json:"device_profile_id"`
+	Status          string     `json:"status"`
+	PreviousRuntime *string    `json:"-"`
+	PreviousScript  *string    `json:"-"`
+	Error           *string    `json:"error,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// CodecRolloutPayload is the payload of rollout and rollback jobs
+type CodecRolloutPayload struct {
+	RolloutID uuid.UUID `json:"rollout_id"`
+}
+
+type CreateCodecRequest struct {
+	Name        string  `json:"name" binding:"required"`
+	Description *string `json:"description"`
+}
+
+type CreateCodecVersionRequest struct {
+	Script    string `json:"script" binding:"required"`
+	Changelog string `json:"changelog" binding:"required"`
+}
+
+// PublishCodecVersionRequest publishes a draft. With RollbackOnFailure the
+// rollout is undone when any profile can't be updated.
+type PublishCodecVersionRequest struct {
+	RollbackOnFailure bool `json:"rollback_on_failure"`
+}
+
+type CodecVersionListResponse struct {
+	Codec    Codec          `json:"codec"`
+	Versions []CodecVersion `json:"versions"`
+}
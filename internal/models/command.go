@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Command types of the lamp controllers
+const (
+	CommandSetDimming    = "set_dimming"
+	CommandSwitch        = "switch"
+	CommandRequestStatus = "request_status"
+	CommandRequestGPS    = "request_gps"
+)
+
+// Command states. Pending commands are being enqueued, queued ones wait in
+// the ChirpStack device queue, sent ones were transmitted by a gateway and
+// confirmed ones are acknowledged or not by the device.
+const (
+	CommandPending         = "pending"
+	CommandQueued          = "queued"
+	CommandSent            = "sent"
+	CommandAcknowledged    = "acknowledged"
+	CommandNotAcknowledged = "not_acknowledged"
+)
+
+// SendCommandRequest is a command for a lamp. Level is required by
+// set_dimming, on by switch.
+type SendCommandRequest struct {
+	Type      string `json:"type" binding:"required,oneof=set_dimming switch request_status request_gps"`
+	Level     *int   `json:"level,omitempty"`
+	On        *bool  `json:"on,omitempty"`
+	Confirmed bool   `json:"confirmed"`
+}
+
+// DeviceCommand is a downlink sent to a device through the ChirpStack device
+// queue. Data is the encoded command, base64 in JSON.
+type DeviceCommand struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	DeviceID    uuid.UUID  `json:"device_id" db:"device_id"`
+	Type        string     `json:"type" db:"type"`
+	Level       *int       `json:"level,omitempty" db:"level"`
+	On          *bool      `json:"on,omitempty" db:"on"`
+	Data        []byte     `json:"data" db:"data"`
+	FPort       int        `json:"f_port" db:"f_port"`
+	Confirmed   bool       `json:"confirmed" db:"confirmed"`
+	QueueItemID *string    `json:"queue_item_id,omitempty" db:"queue_item_id"`
+	Status      string     `json:"status" db:"status"`
+	SentBy      *uuid.UUID `json:"sent_by,omitempty" db:"sent_by"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type DeviceCommandListResponse struct {
+	Commands   []DeviceCommand `json:"commands"`
+	Total      int             `json:"total"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"page_size"`
+	TotalPages int             `json:"total_pages"`
+}
@@ -8,30 +8,45 @@ import (
 
 // DeviceVersion represents a device version/model
 type DeviceVersion struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Version     string    `json:"version" db:"version"`
-	Description *string   `json:"description,omitempty" db:"description"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
-}
+	ID              uuid.UUID              `json:"id" db:"id"`
+	Name            string                 `json:"name" db:"name"`
+	Version         string                 `json:"version" db:"version"`
+	Description     *string                `json:"description,omitempty" db:"description"`
+	ProfileTemplate *DeviceProfileTemplate `json:"profile_template,omitempty" db:"profile_template"`
+	CodecID         *uuid.UUID             `json:"codec_id,omitempty" db:"codec_id"`
+	CreatedAt       time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+// Activation modes of allowed devices
+const (
+	ActivationABP  = "abp"
+	ActivationOTAA = "otaa"
+)
 
-// AllowedDevice represents pre-configured device keys
+// AllowedDevice represents pre-configured device keys. ABP devices carry
+// their session keys (NwkKey is the NwkSKey, AppKey the AppSKey and AddrKey
+// the DevAddr). OTAA devices carry their root keys and JoinEUI and have no
+// AddrKey.
 type AllowedDevice struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	DevEUI      string    `json:"dev_eui" db:"dev_eui"`
-	NwkKey      string    `json:"nwk_key" db:"nwk_key"`
-	AppKey      string    `json:"app_key" db:"app_key"`
-	AddrKey     string    `json:"addr_key" db:"addr_key"`
-	Description *string   `json:"description,omitempty" db:"description"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
-}
-
-// Device represents a user's IoT device
+	ID             uuid.UUID `json:"id" db:"id"`
+	DevEUI         string    `json:"dev_eui" db:"dev_eui"`
+	ActivationMode string    `json:"activation_mode" db:"activation_mode"`
+	JoinEUI        *string   `json:"join_eui,omitempty" db:"join_eui"`
+	NwkKey         string    `json:"nwk_key" db:"nwk_key"`
+	AppKey         string    `json:"app_key" db:"app_key"`
+	AddrKey        string    `json:"addr_key,omitempty" db:"addr_key"`
+	Description    *string   `json:"description,omitempty" db:"description"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Device represents an IoT device owned by an organization. UserID is the
+// member who added it and is cleared when that user is deleted.
 type Device struct {
 	ID                        uuid.UUID      `json:"id" db:"id"`
-	UserID                    uuid.UUID      `json:"user_id" db:"user_id"`
+	OrganizationID            uuid.UUID      `json:"organization_id" db:"organization_id"`
+	UserID                    *uuid.UUID     `json:"user_id,omitempty" db:"user_id"`
 	VersionID                 uuid.UUID      `json:"version_id" db:"version_id"`
 	Name                      string         `json:"name" db:"name"`
 	DevEUI                    string         `json:"dev_eui" db:"dev_eui"`
@@ -39,47 +54,65 @@ type Device struct {
 	ChirpStackDeviceCreated   bool           `json:"chirpstack_device_created" db:"chirpstack_device_created"`
 	ChirpStackDeviceActivated bool           `json:"chirpstack_device_activated" db:"chirpstack_device_activated"`
 	IsActive                  bool           `json:"is_active" db:"is_active"`
+	LastSeenAt                *time.Time     `json:"last_seen_at,omitempty" db:"last_seen_at"`
 	CreatedAt                 time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt                 time.Time      `json:"updated_at" db:"updated_at"`
 	
 	// Joined fields
 	Version *DeviceVersion `json:"version,omitempty"`
 	User    *User          `json:"user,omitempty"`
+
+	// Background job creating the device in ChirpStack, set on creation
+	ProvisioningJobID *uuid.UUID `json:"provisioning_job_id,omitempty" db:"-"`
 }
 
 // Request/Response models
 type CreateDeviceVersionRequest struct {
-	Name        string  `json:"name" binding:"required"`
-	Version     string  `json:"version" binding:"required"`
-	Description *string `json:"description"`
+	Name            string                 `json:"name" binding:"required"`
+	Version         string                 `json:"version" binding:"required"`
+	Description     *string                `json:"description"`
+	ProfileTemplate *DeviceProfileTemplate `json:"profile_template"`
+	CodecID         *uuid.UUID             `json:"codec_id"`
 }
 
 type UpdateDeviceVersionRequest struct {
-	Name        *string `json:"name"`
-	Version     *string `json:"version"`
-	Description *string `json:"description"`
+	Name            *string                `json:"name"`
+	Version         *string                `json:"version"`
+	Description     *string                `json:"description"`
+	ProfileTemplate *DeviceProfileTemplate `json:"profile_template"`
+	CodecID         *uuid.UUID             `json:"codec_id"`
 }
 
+// CreateAllowedDeviceRequest registers device keys. ActivationMode defaults
+// to ABP, which needs AddrKey, OTAA needs JoinEUI. An OTAA device without
+// NwkKey is a LoRaWAN 1.0 device whose only root key is AppKey.
 type CreateAllowedDeviceRequest struct {
-	DevEUI      string  `json:"dev_eui" binding:"required,len=16"`
-	NwkKey      string  `json:"nwk_key" binding:"required,len=32"`
-	AppKey      string  `json:"app_key" binding:"required,len=32"`
-	AddrKey     string  `json:"addr_key" binding:"required,len=8"`
-	Description *string `json:"description"`
+	DevEUI         string  `json:"dev_eui" binding:"required,len=16"`
+	ActivationMode string  `json:"activation_mode" binding:"omitempty,oneof=abp otaa"`
+	JoinEUI        *string `json:"join_eui" binding:"omitempty,len=16"`
+	NwkKey         string  `json:"nwk_key" binding:"omitempty,len=32"`
+	AppKey         string  `json:"app_key" binding:"required,len=32"`
+	AddrKey        string  `json:"addr_key" binding:"omitempty,len=8"`
+	Description    *string `json:"description"`
 }
 
 type UpdateAllowedDeviceRequest struct {
-	NwkKey      *string `json:"nwk_key"`
-	AppKey      *string `json:"app_key"`
-	AddrKey     *string `json:"addr_key"`
-	Description *string `json:"description"`
+	ActivationMode *string `json:"activation_mode" binding:"omitempty,oneof=abp otaa"`
+	JoinEUI        *string `json:"join_eui" binding:"omitempty,len=16"`
+	NwkKey         *string `json:"nwk_key" binding:"omitempty,len=32"`
+	AppKey         *string `json:"app_key" binding:"omitempty,len=32"`
+	AddrKey        *string `json:"addr_key" binding:"omitempty,len=8"`
+	Description    *string `json:"description"`
 }
 
+// CreateDeviceRequest registers a device in an organization. Without
+// OrganizationID the device goes to the user's first organization.
 type CreateDeviceRequest struct {
-	Name        string    `json:"name" binding:"required"`
-	VersionID   uuid.UUID `json:"version_id" binding:"required"`
-	DevEUI      string    `json:"dev_eui" binding:"required,len=16"`
-	Description *string   `json:"description"`
+	Name           string     `json:"name" binding:"required"`
+	VersionID      uuid.UUID  `json:"version_id" binding:"required"`
+	DevEUI         string     `json:"dev_eui" binding:"required,len=16"`
+	Description    *string    `json:"description"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
 }
 
 type UpdateDeviceRequest struct {
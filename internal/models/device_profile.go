@@ -0,0 +1,66 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Device classes of a profile template
+const (
+	DeviceClassA = "A"
+	DeviceClassB = "B"
+	DeviceClassC = "C"
+)
+
+// DeviceProfileTemplate describes the ChirpStack device profile of a device
+// version. It is created in a tenant when the organization adds its first
+// device of that version, once per activation mode. Templates with a region
+// are for hardware built for that region, the others are created in the
+// organization's region. Unset RX settings take the region's defaults.
+type DeviceProfileTemplate struct {
+	Region            string                              `json:"region,omitempty"`
+	RegionConfigID    string                              `json:"region_config_id,omitempty"`
+	MacVersion        string                              `json:"mac_version" binding:"required"`
+	RegParamsRevision string                              `json:"reg_params_revision" binding:"required"`
+	DeviceClass       string                              `json:"device_class" binding:"required,oneof=A B C"`
+	UplinkInterval    int                                 `json:"uplink_interval" binding:"required,min=1"`
+	AbpRx1Delay       int                                 `json:"abp_rx1_delay,omitempty"`
+	AbpRx2Dr          int                                 `json:"abp_rx2_dr,omitempty"`
+	AbpRx2Freq        int                                 `json:"abp_rx2_freq,omitempty"`
+	CodecRuntime      string                              `json:"codec_runtime" binding:"required,oneof=NONE JS CAYENNE_LPP"`
+	CodecScript       string                              `json:"codec_script,omitempty"`
+	Measurements      map[string]DeviceProfileMeasurement `json:"measurements,omitempty"`
+}
+
+// Value stores the template as JSON
+func (t DeviceProfileTemplate) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan reads a template stored as JSON
+func (t *DeviceProfileTemplate) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into DeviceProfileTemplate", src)
+	}
+	return json.Unmarshal(data, t)
+}
+
+// OrganizationDeviceProfile records the device profile created from a device
+// version's template in an organization's tenant
+type OrganizationDeviceProfile struct {
+	OrganizationID  uuid.UUID `json:"organization_id" db:"organization_id"`
+	DeviceVersionID uuid.UUID `json:"device_version_id" db:"device_version_id"`
+	ActivationMode  string    `json:"activation_mode" db:"activation_mode"`
+	DeviceProfileID string    `json:"device_profile_id" db:"device_profile_id"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import "errors"
+
+// Sentinel errors shared between repositories, services and handlers
+var (
+	ErrDeviceNotFound         = errors.New("device not found")
+	ErrInvalidActivation      = errors.New("invalid activation settings")
+	ErrInvalidProfileTemplate = errors.New("invalid device profile template")
+	ErrRegionMismatch         = errors.New("device version is built for another region")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	ErrInvalidUserToken     = errors.New("invalid or expired token")
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+
+	ErrInvalidMFACode    = errors.New("invalid two-factor authentication code")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+
+	ErrLoginThrottled = errors.New("login throttled")
+
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrgPermissionDenied  = errors.New("insufficient organization role")
+	ErrLastOwner            = errors.New("organization must keep at least one owner")
+	ErrMemberNotFound       = errors.New("organization member not found")
+	ErrAlreadyMember        = errors.New("user is already a member of the organization")
+	ErrInvalidInvitation    = errors.New("invalid or expired invitation")
+	ErrUnknownRegion        = errors.New("unknown LoRaWAN region")
+
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobNotDead  = errors.New("only dead jobs can be retried")
+	// ErrJobPermanent marks job failures that retrying won't fix
+	ErrJobPermanent = errors.New("permanent job failure")
+	// ErrJobLeaseLost is returned when a worker finishes a job that was
+	// handed to another worker after its lease expired
+	ErrJobLeaseLost = errors.New("job lease lost")
+
+	ErrChirpStackDisabled = errors.New("ChirpStack integration is disabled")
+	ErrReconcileRunning   = errors.New("reconciliation is already running")
+	ErrNoReconcileRun     = errors.New("no reconciliation has run yet")
+
+	ErrCodecNotFound        = errors.New("codec not found")
+	ErrCodecExists          = errors.New("a codec with this name already exists")
+	ErrCodecVersionNotFound = errors.New("codec version not found")
+	ErrCodecVersionNotDraft = errors.New("only draft codec versions can be published")
+	ErrInvalidCodecScript   = errors.New("codec script must define a decodeUplink function")
+	ErrRolloutNotFound      = errors.New("codec rollout not found")
+	ErrRolloutRunning       = errors.New("a rollout of this codec is still running")
+	ErrRolloutNotFinished   = errors.New("only finished rollouts can be rolled back")
+	ErrRolloutNotLatest     = errors.New("only the latest rollout of a codec can be rolled back")
+
+	ErrIntegrationUnauthorized = errors.New("invalid integration credentials")
+	ErrInvalidEvent            = errors.New("invalid integration event")
+	ErrUnsupportedEncoding     = errors.New("unsupported integration event encoding")
+
+	ErrInvalidTelemetryQuery = errors.New("invalid telemetry query")
+
+	ErrInvalidCommand       = errors.New("invalid device command")
+	ErrDeviceNotProvisioned = errors.New("device is not provisioned in ChirpStack yet")
+)
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types of the ChirpStack integrations, as given in the event query
+// parameter of the HTTP integration
+const (
+	EventUp       = "up"
+	EventJoin     = "join"
+	EventAck      = "ack"
+	EventTxAck    = "txack"
+	EventStatus   = "status"
+	EventLog      = "log"
+	EventLocation = "location"
+)
+
+// IsValidIntegrationEvent reports whether event is one of the event types
+func IsValidIntegrationEvent(event string) bool {
+	switch event {
+	case EventUp, EventJoin, EventAck, EventTxAck, EventStatus, EventLog, EventLocation:
+		return true
+	}
+	return false
+}
+
+// IntegrationDeviceInfo identifies the device, application and tenant an
+// event belongs to
+type IntegrationDeviceInfo struct {
+	TenantID           string            `json:"tenantId"`
+	TenantName         string            `json:"tenantName,omitempty"`
+	ApplicationID      string            `json:"applicationId"`
+	ApplicationName    string            `json:"applicationName,omitempty"`
+	DeviceProfileID    string            `json:"deviceProfileId,omitempty"`
+	DeviceProfileName  string            `json:"deviceProfileName,omitempty"`
+	DeviceName         string            `json:"deviceName,omitempty"`
+	DevEUI             string            `json:"devEui"`
+	DeviceClassEnabled string            `json:"deviceClassEnabled,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+}
+
+// IntegrationLocation is a position reported by a gateway or resolved for a device
+type IntegrationLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+	Source    string  `json:"source,omitempty"`
+	Accuracy  float32 `json:"accuracy,omitempty"`
+}
+
+// IntegrationRxInfo describes the reception of an uplink by one gateway
+type IntegrationRxInfo struct {
+	GatewayID string               `json:"gatewayId"`
+	UplinkID  uint32               `json:"uplinkId,omitempty"`
+	RSSI      int32                `json:"rssi"`
+	SNR       float32              `json:"snr"`
+	Channel   uint32               `json:"channel,omitempty"`
+	RFChain   uint32               `json:"rfChain,omitempty"`
+	Location  *IntegrationLocation `json:"location,omitempty"`
+	CRCStatus string               `json:"crcStatus,omitempty"`
+}
+
+// IntegrationTxInfo describes the transmission of an uplink or downlink
+type IntegrationTxInfo struct {
+	Frequency  uint32                 `json:"frequency"`
+	Modulation *IntegrationModulation `json:"modulation,omitempty"`
+}
+
+type IntegrationModulation struct {
+	LoRa *IntegrationLoRaModulation `json:"lora,omitempty"`
+}
+
+type IntegrationLoRaModulation struct {
+	Bandwidth       uint32 `json:"bandwidth"`
+	SpreadingFactor uint32 `json:"spreadingFactor"`
+	CodeRate        string `json:"codeRate,omitempty"`
+}
+
+// IntegrationEvent is an event posted by a ChirpStack integration. All event
+// types share the device info, the other fields are only set for the types
+// noted.
+type IntegrationEvent struct {
+	DeduplicationID string                `json:"deduplicationId,omitempty"`
+	Time            *time.Time            `json:"time,omitempty"`
+	DeviceInfo      IntegrationDeviceInfo `json:"deviceInfo"`
+
+	// up and join
+	DevAddr   string              `json:"devAddr,omitempty"`
+	ADR       bool                `json:"adr,omitempty"`
+	DR        uint32              `json:"dr,omitempty"`
+	FCnt      uint32              `json:"fCnt,omitempty"`
+	FPort     uint32              `json:"fPort,omitempty"`
+	Confirmed bool                `json:"confirmed,omitempty"`
+	Data      []byte              `json:"data,omitempty"`
+	Object    json.RawMessage     `json:"object,omitempty"`
+	RxInfo    []IntegrationRxInfo `json:"rxInfo,omitempty"`
+	TxInfo    *IntegrationTxInfo  `json:"txInfo,omitempty"`
+
+	// ack and txack
+	QueueItemID  string `json:"queueItemId,omitempty"`
+	Acknowledged bool   `json:"acknowledged,omitempty"`
+	FCntDown     uint32 `json:"fCntDown,omitempty"`
+	DownlinkID   uint32 `json:"downlinkId,omitempty"`
+	GatewayID    string `json:"gatewayId,omitempty"`
+
+	// status
+	Margin                  int32   `json:"margin,omitempty"`
+	ExternalPowerSource     bool    `json:"externalPowerSource,omitempty"`
+	BatteryLevelUnavailable bool    `json:"batteryLevelUnavailable,omitempty"`
+	BatteryLevel            float32 `json:"batteryLevel,omitempty"`
+
+	// log
+	Level       string            `json:"level,omitempty"`
+	Code        string            `json:"code,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Context     map[string]string `json:"context,omitempty"`
+
+	// location
+	Location *IntegrationLocation `json:"location,omitempty"`
+}
+
+// OccurredAt returns the time of the event, or now when ChirpStack didn't
+// send one
+func (e *IntegrationEvent) OccurredAt() time.Time {
+	if e.Time != nil {
+		return e.Time.UTC()
+	}
+	return time.Now().UTC()
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job types
+const (
+	JobProvisionOrganization = "provision_organization"
+	JobProvisionDevice       = "provision_device"
+	JobCodecRollout          = "codec_rollout"
+	JobCodecRollback         = "codec_rollback"
+)
+
+// Job states. Pending jobs wait for run_at, running jobs are leased by a
+// worker until locked_until and dead jobs ran out of attempts.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// DefaultJobMaxAttempts is used when a job doesn't set its own limit
+const DefaultJobMaxAttempts = 10
+
+// Job is a unit of background work, e.g. provisioning an organization or a
+// device in ChirpStack
+type Job struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	Type           string          `json:"type" db:"type"`
+	Status         string          `json:"status" db:"status"`
+	OrganizationID *uuid.UUID      `json:"organization_id,omitempty" db:"organization_id"`
+	DeviceID       *uuid.UUID      `json:"device_id,omitempty" db:"device_id"`
+	Payload        json.RawMessage `json:"payload,omitempty" db:"payload"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	MaxAttempts    int             `json:"max_attempts" db:"max_attempts"`
+	RunAt          time.Time       `json:"run_at" db:"run_at"`
+	LastError      *string         `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+	CompletedAt    *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+type JobListResponse struct {
+	Jobs       []Job `json:"jobs"`
+	Total      int   `json:"total"`
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalPages int   `json:"total_pages"`
+}
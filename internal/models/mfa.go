@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// RolePolicy holds the security settings that apply to every user of a role
+type RolePolicy struct {
+	Role       string    `json:"role" db:"role"`
+	RequireMFA bool      `json:"require_mfa" db:"require_mfa"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateRolePolicyRequest struct {
+	RequireMFA *bool `json:"require_mfa" binding:"required"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TOTPCodeRequest carries a code from the authenticator app or a recovery code
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	// Client details set by the handler for throttling and auditing
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFALoginRequest completes a login that returned mfa_required
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	// Client details set by the handler for throttling and auditing
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization roles. Owners manage everything including other owners, admins
+// manage members and devices, members manage devices and viewers can only
+// read. Global admins can act on every organization.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+	OrgRoleViewer = "viewer"
+)
+
+var orgRoleRanks = map[string]int{
+	OrgRoleViewer: 1,
+	OrgRoleMember: 2,
+	OrgRoleAdmin:  3,
+	OrgRoleOwner:  4,
+}
+
+// IsValidOrgRole reports whether role is one of the organization member roles
+func IsValidOrgRole(role string) bool {
+	_, ok := orgRoleRanks[role]
+	return ok
+}
+
+// OrgRoleAtLeast reports whether role grants at least the permissions of min
+func OrgRoleAtLeast(role, min string) bool {
+	return IsValidOrgRole(role) && orgRoleRanks[role] >= orgRoleRanks[min]
+}
+
+// Provisioning states of an organization's ChirpStack resources. Pending
+// organizations may hold some of the resources already, a retry continues
+// from there. Failed means provisioning was rejected and rolled back.
+const (
+	ProvisioningPending     = "pending"
+	ProvisioningProvisioned = "provisioned"
+	ProvisioningFailed      = "failed"
+)
+
+// Organization owns a ChirpStack tenant with its application and device
+// profile, and all devices registered by its members
+type Organization struct {
+	ID              uuid.UUID `json:"id" db:"id"`
+	Name            string    `json:"name" db:"name"`
+	Region          string    `json:"region" db:"region"`
+	TenantID        *string   `json:"tenant_id,omitempty" db:"tenant_id"`
+	ApplicationID   *string   `json:"application_id,omitempty" db:"application_id"`
+	DeviceProfileID *string   `json:"device_profile_id,omitempty" db:"device_profile_id"`
+	// Device profile of OTAA devices, DeviceProfileID is used for ABP
+	OTAADeviceProfileID *string `json:"otaa_device_profile_id,omitempty" db:"otaa_device_profile_id"`
+	ProvisioningStatus  string  `json:"provisioning_status" db:"provisioning_status"`
+	ProvisioningError   *string `json:"provisioning_error,omitempty" db:"provisioning_error"`
+	// Endpoint the ChirpStack HTTP integration posts to and the bearer
+	// secret it authenticates with, set once the integration is registered
+	IntegrationURL      *string    `json:"integration_url,omitempty" db:"integration_url"`
+	IntegrationEncoding *string    `json:"integration_encoding,omitempty" db:"integration_encoding"`
+	IntegrationSecret   *string    `json:"-" db:"integration_secret"`
+	CreatedBy           *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Role of the requesting user, set when listing their organizations
+	Role string `json:"role,omitempty" db:"-"`
+	// Background job provisioning the organization, set on creation
+	ProvisioningJobID *uuid.UUID `json:"provisioning_job_id,omitempty" db:"-"`
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID `json:"organization_id" db:"organization_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Email          string    `json:"email" db:"email"`
+	FullName       string    `json:"full_name" db:"full_name"`
+	Role           string    `json:"role" db:"role"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// OrganizationInvitation is a pending invitation for an email address. The
+// token itself is only mailed out, the table keeps a SHA-256 hash.
+type OrganizationInvitation struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id" db:"organization_id"`
+	Email          string     `json:"email" db:"email"`
+	Role           string     `json:"role" db:"role"`
+	TokenHash      string     `json:"-" db:"token_hash"`
+	InvitedBy      *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Organization request/response models
+// CreateOrganizationRequest creates an organization. Region is the LoRaWAN
+// region of its devices and defaults to the deployment's.
+type CreateOrganizationRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Region string `json:"region"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of drift between the devices table and ChirpStack
+const (
+	// DriftMissing is a device flagged as created that ChirpStack doesn't have
+	DriftMissing = "missing_in_chirpstack"
+	// DriftNotActivated is a device flagged as activated without a session in ChirpStack
+	DriftNotActivated = "not_activated"
+	// DriftUnflagged is a device that exists in ChirpStack but isn't flagged as created
+	DriftUnflagged = "not_flagged"
+	// DriftOrphan is a ChirpStack device without a row in the organization
+	DriftOrphan = "orphan"
+	// DriftMisplaced is a ChirpStack device whose row belongs to another organization
+	DriftMisplaced = "misplaced"
+)
+
+// DeviceDrift is one difference found by the reconciler. DeviceID is not set
+// for orphans.
+type DeviceDrift struct {
+	Kind           string     `json:"kind"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	DeviceID       *uuid.UUID `json:"device_id,omitempty"`
+	DevEUI         string     `json:"dev_eui"`
+	Repaired       bool       `json:"repaired"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// ReconcileReport is the result of comparing the devices table with ChirpStack
+type ReconcileReport struct {
+	StartedAt     time.Time     `json:"started_at"`
+	FinishedAt    time.Time     `json:"finished_at"`
+	Repair        bool          `json:"repair"`
+	Organizations int           `json:"organizations"`
+	Devices       int           `json:"devices"`
+	Drift         []DeviceDrift `json:"drift"`
+	// Organizations that couldn't be checked, by ID
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type ReconcileRequest struct {
+	Repair         bool       `json:"repair"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+}
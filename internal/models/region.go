@@ -0,0 +1,20 @@
+package models
+
+// Region holds the regional parameters of a LoRaWAN region that device
+// profiles are checked against. Frequencies are in Hz.
+type Region struct {
+	Name           string `json:"name"`
+	RegionConfigID string `json:"region_config_id"`
+	MinFrequency   int    `json:"min_frequency"`
+	MaxFrequency   int    `json:"max_frequency"`
+	Rx2Frequency   int    `json:"rx2_frequency"`
+	Rx2Dr          int    `json:"rx2_dr"`
+	// Data rates usable on the downlink, RX2 included
+	MinDownlinkDr int `json:"min_downlink_dr"`
+	MaxDownlinkDr int `json:"max_downlink_dr"`
+}
+
+type RegionListResponse struct {
+	DefaultRegion string   `json:"default_region"`
+	Regions       []Region `json:"regions"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Security event types
+const (
+	EventLoginSuccess    = "login_success"
+	EventLoginFailure    = "login_failure"
+	EventAccountLocked   = "account_locked"
+	EventAccountUnlocked = "account_unlocked"
+	EventIPBlocked       = "ip_blocked"
+)
+
+type SecurityEvent struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	EventType string     `json:"event_type" db:"event_type"`
+	Email     string     `json:"email,omitempty" db:"email"`
+	IPAddress string     `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent string     `json:"user_agent,omitempty" db:"user_agent"`
+	Details   string     `json:"details,omitempty" db:"details"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type SecurityEventListResponse struct {
+	Events     []SecurityEvent `json:"events"`
+	Total      int             `json:"total"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"page_size"`
+	TotalPages int             `json:"total_pages"`
+}
+
+// LoginThrottledError is returned when a login attempt is refused before the
+// password is checked, because of earlier failures for the account or the
+// client address
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account is temporarily locked, try again in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrLoginThrottled
+}
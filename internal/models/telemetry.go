@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Telemetry fields, the lamp measurements of the Lnode device profile named
+// as the codec decodes them
+const (
+	TelemetryVoltage    = "voltage"
+	TelemetryCurrent    = "current"
+	TelemetryPower      = "Power"
+	TelemetryEnergy     = "Energy"
+	TelemetryPF         = "PF"
+	TelemetryTilt       = "Tilt"
+	TelemetryDimming    = "Dimming"
+	TelemetryStatusLamp = "Status_lamp"
+)
+
+// TelemetryFields lists the telemetry fields in the order they are stored
+var TelemetryFields = []string{
+	TelemetryVoltage,
+	TelemetryCurrent,
+	TelemetryPower,
+	TelemetryEnergy,
+	TelemetryPF,
+	TelemetryTilt,
+	TelemetryDimming,
+	TelemetryStatusLamp,
+}
+
+// IsValidTelemetryField reports whether field is one of the telemetry fields
+func IsValidTelemetryField(field string) bool {
+	for _, f := range TelemetryFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// TelemetrySample holds the measurements of one uplink of a device. Fields
+// the uplink didn't carry are missing from Values.
+type TelemetrySample struct {
+	DeviceID uuid.UUID
+	Time     time.Time
+	Values   map[string]float64
+}
+
+// TelemetryQuery selects the telemetry of a device. From and To are RFC 3339
+// times, Fields is a comma separated list of telemetry fields and Interval a
+// duration such as 15m to aggregate the samples by.
+type TelemetryQuery struct {
+	From     string `form:"from"`
+	To       string `form:"to"`
+	Fields   string `form:"fields"`
+	Interval string `form:"interval"`
+}
+
+// TelemetryPoint is a sample, with Values set, or a bucket of samples
+// starting at Time, with Count, Min, Max and Avg set
+type TelemetryPoint struct {
+	Time   time.Time          `json:"time"`
+	Values map[string]float64 `json:"values,omitempty"`
+	Count  int                `json:"count,omitempty"`
+	Min    map[string]float64 `json:"min,omitempty"`
+	Max    map[string]float64 `json:"max,omitempty"`
+	Avg    map[string]float64 `json:"avg,omitempty"`
+}
+
+type TelemetryResponse struct {
+	DeviceID uuid.UUID        `json:"device_id"`
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	Interval string           `json:"interval,omitempty"`
+	Fields   []string         `json:"fields"`
+	Points   []TelemetryPoint `json:"points"`
+}
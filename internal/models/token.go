@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is an opaque, server-side revocable token used to obtain new
+// access tokens. Only the hash of the token is persisted.
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	FamilyID   uuid.UUID  `json:"family_id" db:"family_id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	ReplacedBy *uuid.UUID `json:"replaced_by,omitempty" db:"replaced_by"`
+	MFA        bool       `json:"mfa" db:"mfa"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// TokenPair is an access token together with its refresh token
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Purposes of single-use user tokens
+const (
+	UserTokenEmailVerification = "email_verification"
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenMFAChallenge      = "mfa_challenge"
+)
+
+// UserToken is a single-use token sent to a user by email, or handed out
+// between the two steps of a login
+type UserToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Purpose   string     `json:"purpose" db:"purpose"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
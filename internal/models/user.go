@@ -6,16 +6,39 @@ import (
 	"github.com/google/uuid"
 )
 
+// User roles. Admins manage the whole fleet and the user base, operators
+// manage device inventory (versions and allowed device keys) and customers
+// only manage their own account and devices.
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleCustomer = "customer"
+)
+
+// IsValidRole reports whether role is one of the known user roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleOperator, RoleCustomer:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID              uuid.UUID `json:"id" db:"id"`
-	Email           string    `json:"email" db:"email"`
-	PasswordHash    string    `json:"-" db:"password_hash"`
-	FullName        string    `json:"full_name" db:"full_name"`
-	TenantID        *string   `json:"tenant_id,omitempty" db:"tenant_id"`
-	ApplicationID   *string   `json:"application_id,omitempty" db:"application_id"`
-	DeviceProfileID *string   `json:"device_profile_id,omitempty" db:"device_profile_id"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+	ID              uuid.UUID  `json:"id" db:"id"`
+	Email           string     `json:"email" db:"email"`
+	PasswordHash    string     `json:"-" db:"password_hash"`
+	FullName        string     `json:"full_name" db:"full_name"`
+	Role            string     `json:"role" db:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at,omitempty" db:"totp_enabled_at"`
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty" db:"suspended_at"`
+	// Brute-force protection state
+	FailedLogins    int        `json:"failed_login_count,omitempty" db:"failed_login_count"`
+	LastFailedLogin *time.Time `json:"last_failed_login_at,omitempty" db:"last_failed_login_at"`
+	LockedUntil     *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type RegisterRequest struct {
@@ -27,11 +50,35 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// Client details set by the handler for throttling and auditing
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
+// Email verification and password reset requests
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// AuthResponse is returned by a successful login. When the user has two-factor
+// authentication enabled the login returns MFARequired and an MFAToken instead
+// of tokens, to be exchanged with a code at /auth/login/2fa.
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	User         User   `json:"user"`
 }
 
 // User management request/response models
@@ -41,6 +88,10 @@ type UpdateUserRequest struct {
 	Password string `json:"password" binding:"omitempty,min=6"`
 }
 
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin operator customer"`
+}
+
 type UserListResponse struct {
 	Users      []User `json:"users"`
 	Total      int    `json:"total"`
@@ -60,6 +111,7 @@ type PublicUser struct {
 	ID        uuid.UUID `json:"id"`
 	Email     string    `json:"email"`
 	FullName  string    `json:"full_name"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) CreateAPIKey(key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, created_by, name, kind, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(query, key.UserID, key.CreatedBy, key.Name, key.Kind, key.Prefix,
+		key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	err := row.Scan(
+		&key.ID, &key.UserID, &key.CreatedBy, &key.Name, &key.Kind, &key.Prefix, &key.KeyHash,
+		pq.Array(&key.Scopes), &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt,
+	)
+	return key, err
+}
+
+const apiKeyColumns = `id, user_id, created_by, name, kind, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+func (r *APIKeyRepository) GetAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE prefix = $1`
+
+	key, err := scanAPIKey(r.db.QueryRow(query, prefix))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByID(id uuid.UUID) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	key, err := scanAPIKey(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetAPIKeysForUser returns the keys owned or created by a user
+func (r *APIKeyRepository) GetAPIKeysForUser(userID uuid.UUID) ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys
+		WHERE user_id = $1 OR created_by = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(id uuid.UUID) error {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return models.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that a key was used, at most once per minute
+func (r *APIKeyRepository) TouchAPIKey(id uuid.UUID) error {
+	query := `
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')`
+
+	if _, err := r.db.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to update API key usage: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,528 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type CodecRepository struct {
+	db *sql.DB
+}
+
+func NewCodecRepository(db *sql.DB) *CodecRepository {
+	return &CodecRepository{db: db}
+}
+
+const codecColumns = `c.id, c.name, c.description, c.is_default,
+	(SELECT MAX(v.version) FROM codec_versions v WHERE v.codec_id = c.id AND v.status = 'published'),
+	c.created_at, c.updated_at`
+
+const codecVersionColumns = `id, codec_id, version, script, changelog, status, author_id, created_at, published_at`
+
+// rolloutColumns include the progress counted from the targets
+const rolloutColumns = `r.id, r.codec_id, r.codec_version_id, r.status, r.rollback_on_failure, r.started_by,
+	r.created_at, r.updated_at, r.finished_at,
+	COUNT(t.id), COUNT(t.id) FILTER (WHERE t.status = 'pending'), COUNT(t.id) FILTER (WHERE t.status = 'updated'),
+	COUNT(t.id) FILTER (WHERE t.status = 'failed'), COUNT(t.id) FILTER (WHERE t.status = 'rolled_back')`
+
+func scanCodec(row rowScanner) (*models.Codec, error) {
+	codec := &models.Codec{}
+	err := row.Scan(&codec.ID, &codec.Name, &codec.Description, &codec.IsDefault, &codec.PublishedVersion,
+		&codec.CreatedAt, &codec.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+func scanCodecVersion(row rowScanner) (*models.CodecVersion, error) {
+	version := &models.CodecVersion{}
+	err := row.Scan(&version.ID, &version.CodecID, &version.Version, &version.Script, &version.Changelog,
+		&version.Status, &version.AuthorID, &version.CreatedAt, &version.PublishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+func scanRollout(row rowScanner) (*models.CodecRollout, error) {
+	rollout := &models.CodecRollout{}
+	err := row.Scan(&rollout.ID, &rollout.CodecID, &rollout.CodecVersionID, &rollout.Status, &rollout.RollbackOnFailure,
+		&rollout.StartedBy, &rollout.CreatedAt, &rollout.UpdatedAt, &rollout.FinishedAt,
+		&rollout.Total, &rollout.Pending, &rollout.Updated, &rollout.Failed, &rollout.RolledBack)
+	if err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+// CreateCodec adds a codec without versions
+func (r *CodecRepository) CreateCodec(codec *models.Codec) error {
+	query := `
+		INSERT INTO codecs (name, description)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, is_default, created_at, updated_at`
+
+	err := r.db.QueryRow(query, codec.Name, codec.Description).
+		Scan(&codec.ID, &codec.IsDefault, &codec.CreatedAt, &codec.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrCodecExists
+		}
+		return fmt.Errorf("failed to create codec: %w", err)
+	}
+	return nil
+}
+
+// EnsureDefaultCodec creates the default codec with script as its published
+// first version unless there is a default codec already. It reports whether
+// it created one.
+func (r *CodecRepository) EnsureDefaultCodec(codec *models.Codec, script, changelog string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO codecs (name, description, is_default)
+		VALUES ($1, $2, TRUE)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at, updated_at`, codec.Name, codec.Description).
+		Scan(&codec.ID, &codec.CreatedAt, &codec.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create default codec: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO codec_versions (codec_id, version, script, changelog, status, published_at)
+		VALUES ($1, 1, $2, $3, 'published', CURRENT_TIMESTAMP)`, codec.ID, script, changelog)
+	if err != nil {
+		return false, fmt.Errorf("failed to create default codec version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	codec.IsDefault = true
+	return true, nil
+}
+
+func (r *CodecRepository) GetCodecs() ([]models.Codec, error) {
+	rows, err := r.db.Query(`SELECT ` + codecColumns + ` FROM codecs c ORDER BY c.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query codecs: %w", err)
+	}
+	defer rows.Close()
+
+	codecs := []models.Codec{}
+	for rows.Next() {
+		codec, err := scanCodec(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan codec: %w", err)
+		}
+		codecs = append(codecs, *codec)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return codecs, nil
+}
+
+func (r *CodecRepository) GetCodecByID(id uuid.UUID) (*models.Codec, error) {
+	return r.getCodec(`WHERE c.id = $1`, id)
+}
+
+// GetDefaultCodec returns the codec of the organizations' default profiles
+func (r *CodecRepository) GetDefaultCodec() (*models.Codec, error) {
+	return r.getCodec(`WHERE c.is_default`)
+}
+
+func (r *CodecRepository) getCodec(where string, args ...interface{}) (*models.Codec, error) {
+	codec, err := scanCodec(r.db.QueryRow(`SELECT `+codecColumns+` FROM codecs c `+where, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrCodecNotFound
+		}
+		return nil, fmt.Errorf("failed to get codec: %w", err)
+	}
+	return codec, nil
+}
+
+// CreateCodecVersion adds a draft with the next version number of its codec
+func (r *CodecRepository) CreateCodecVersion(version *models.CodecVersion) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The codec row lock hands out version numbers one at a time
+	var id uuid.UUID
+	if err := tx.QueryRow(`SELECT id FROM codecs WHERE id = $1 FOR UPDATE`, version.CodecID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrCodecNotFound
+		}
+		return fmt.Errorf("failed to lock codec: %w", err)
+	}
+
+	query := `
+		INSERT INTO codec_versions (codec_id, version, script, changelog, author_id)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4 FROM codec_versions WHERE codec_id = $1
+		RETURNING version, status, created_at`
+
+	err = tx.QueryRow(query, version.CodecID, version.Script, version.Changelog, version.AuthorID).
+		Scan(&version.Version, &version.Status, &version.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create codec version: %w", err)
+	}
+
+	if err := tx.QueryRow(`SELECT id FROM codec_versions WHERE codec_id = $1 AND version = $2`, version.CodecID, version.Version).Scan(&version.ID); err != nil {
+		return fmt.Errorf("failed to create codec version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetCodecVersions returns the versions of a codec, newest first
+func (r *CodecRepository) GetCodecVersions(codecID uuid.UUID) ([]models.CodecVersion, error) {
+	rows, err := r.db.Query(`SELECT `+codecVersionColumns+` FROM codec_versions WHERE codec_id = $1 ORDER BY version DESC`, codecID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query codec versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.CodecVersion{}
+	for rows.Next() {
+		version, err := scanCodecVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan codec version: %w", err)
+		}
+		versions = append(versions, *version)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return versions, nil
+}
+
+func (r *CodecRepository) GetCodecVersion(codecID uuid.UUID, version int) (*models.CodecVersion, error) {
+	return r.getCodecVersion(`WHERE codec_id = $1 AND version = $2`, codecID, version)
+}
+
+func (r *CodecRepository) GetCodecVersionByID(id uuid.UUID) (*models.CodecVersion, error) {
+	return r.getCodecVersion(`WHERE id = $1`, id)
+}
+
+// GetPublishedCodecVersion returns the latest published version of a codec
+func (r *CodecRepository) GetPublishedCodecVersion(codecID uuid.UUID) (*models.CodecVersion, error) {
+	return r.getCodecVersion(`WHERE codec_id = $1 AND status = 'published' ORDER BY version DESC LIMIT 1`, codecID)
+}
+
+func (r *CodecRepository) getCodecVersion(where string, args ...interface{}) (*models.CodecVersion, error) {
+	version, err := scanCodecVersion(r.db.QueryRow(`SELECT `+codecVersionColumns+` FROM codec_versions `+where, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrCodecVersionNotFound
+		}
+		return nil, fmt.Errorf("failed to get codec version: %w", err)
+	}
+	return version, nil
+}
+
+// SetCodecVersionStatus moves a version between published and rolled_back
+func (r *CodecRepository) SetCodecVersionStatus(id uuid.UUID, status string) error {
+	result, err := r.db.Exec(`UPDATE codec_versions SET status = $1 WHERE id = $2`, status, id)
+	if err != nil {
+		return fmt.Errorf("failed to update codec version: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrCodecVersionNotFound
+	}
+	return nil
+}
+
+// PublishCodecVersion publishes a draft and starts its rollout to the device
+// profiles using the codec: the profiles made from the templates of device
+// versions the codec is attached to and, for the default codec, the default
+// profiles of all organizations. The rollout, its targets and the job running
+// it are written in one transaction.
+func (r *CodecRepository) PublishCodecVersion(codec *models.Codec, version *models.CodecVersion, rollout *models.CodecRollout, job *models.Job) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// One rollout per codec at a time
+	var id uuid.UUID
+	if err := tx.QueryRow(`SELECT id FROM codecs WHERE id = $1 FOR UPDATE`, codec.ID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrCodecNotFound
+		}
+		return fmt.Errorf("failed to lock codec: %w", err)
+	}
+	var running bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM codec_rollouts WHERE codec_id = $1 AND status IN ('running', 'rolling_back'))`, codec.ID).Scan(&running)
+	if err != nil {
+		return fmt.Errorf("failed to check running rollouts: %w", err)
+	}
+	if running {
+		return models.ErrRolloutRunning
+	}
+
+	err = tx.QueryRow(`
+		UPDATE codec_versions SET status = 'published', published_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'draft'
+		RETURNING status, published_at`, version.ID).Scan(&version.Status, &version.PublishedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrCodecVersionNotDraft
+		}
+		return fmt.Errorf("failed to publish codec version: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO codec_rollouts (codec_id, codec_version_id, rollback_on_failure, started_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, created_at, updated_at`,
+		codec.ID, version.ID, rollout.RollbackOnFailure, rollout.StartedBy).
+		Scan(&rollout.ID, &rollout.Status, &rollout.CreatedAt, &rollout.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create rollout: %w", err)
+	}
+	rollout.CodecID = codec.ID
+	rollout.CodecVersionID = version.ID
+
+	profiles := `
+		SELECT p.organization_id, p.device_profile_id
+		FROM organization_device_profiles p
+		JOIN device_versions dv ON dv.id = p.device_version_id
+		WHERE dv.codec_id = $2`
+	if codec.IsDefault {
+		// Device versions without a template or codec of their own use the
+		// default profiles
+		profiles += `
+		UNION
+		SELECT id, device_profile_id FROM organizations WHERE device_profile_id IS NOT NULL
+		UNION
+		SELECT id, otaa_device_profile_id FROM organizations WHERE otaa_device_profile_id IS NOT NULL`
+	}
+	rows, err := tx.Query(`
+		INSERT INTO codec_rollout_targets (rollout_id, organization_id, device_profile_id)
+		SELECT $1, organization_id, device_profile_id FROM (`+profiles+`) AS profiles (organization_id, device_profile_id)
+		ON CONFLICT (rollout_id, device_profile_id) DO NOTHING
+		RETURNING id, organization_id, device_profile_id, status, updated_at`, rollout.ID, codec.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create rollout targets: %w", err)
+	}
+	rollout.Targets = []models.CodecRolloutTarget{}
+	for rows.Next() {
+		target := models.CodecRolloutTarget{RolloutID: rollout.ID}
+		if err := rows.Scan(&target.ID, &target.OrganizationID, &target.DeviceProfileID, &target.Status, &target.UpdatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan rollout target: %w", err)
+		}
+		rollout.Targets = append(rollout.Targets, target)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+	rollout.Total = len(rollout.Targets)
+	rollout.Pending = rollout.Total
+
+	if err := r.insertRolloutJob(tx, job, rollout.ID); err != nil {
+		return err
+	}
+	rollout.JobID = &job.ID
+
+	return tx.Commit()
+}
+
+// StartRollback marks a finished rollout as rolling back, takes its version
+// off the published ones and queues the job restoring the previous codecs.
+// Only the latest rollout of a codec can be rolled back, and not while
+// another one is running: the codecs it restores are those it replaced.
+func (r *CodecRepository) StartRollback(rollout *models.CodecRollout, job *models.Job) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialized with publishing and other rollbacks of the codec
+	var id uuid.UUID
+	if err := tx.QueryRow(`SELECT id FROM codecs WHERE id = $1 FOR UPDATE`, rollout.CodecID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrCodecNotFound
+		}
+		return fmt.Errorf("failed to lock codec: %w", err)
+	}
+	var running bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM codec_rollouts WHERE codec_id = $1 AND status IN ('running', 'rolling_back'))`, rollout.CodecID).Scan(&running)
+	if err != nil {
+		return fmt.Errorf("failed to check running rollouts: %w", err)
+	}
+	if running {
+		return models.ErrRolloutRunning
+	}
+	var latest uuid.UUID
+	err = tx.QueryRow(`SELECT id FROM codec_rollouts WHERE codec_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1`, rollout.CodecID).Scan(&latest)
+	if err != nil {
+		return fmt.Errorf("failed to get latest rollout: %w", err)
+	}
+	if latest != rollout.ID {
+		return models.ErrRolloutNotLatest
+	}
+
+	result, err := tx.Exec(`
+		UPDATE codec_rollouts SET status = 'rolling_back', finished_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ('succeeded', 'failed')`, rollout.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update rollout: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrRolloutNotFinished
+	}
+
+	if _, err := tx.Exec(`UPDATE codec_versions SET status = 'rolled_back' WHERE id = $1`, rollout.CodecVersionID); err != nil {
+		return fmt.Errorf("failed to update codec version: %w", err)
+	}
+
+	if err := r.insertRolloutJob(tx, job, rollout.ID); err != nil {
+		return err
+	}
+	rollout.Status = models.RolloutRollingBack
+	rollout.JobID = &job.ID
+
+	return tx.Commit()
+}
+
+func (r *CodecRepository) insertRolloutJob(tx *sql.Tx, job *models.Job, rolloutID uuid.UUID) error {
+	payload, err := json.Marshal(models.CodecRolloutPayload{RolloutID: rolloutID})
+	if err != nil {
+		return fmt.Errorf("failed to encode job payload: %w", err)
+	}
+	job.Payload = payload
+	return insertJob(tx, job)
+}
+
+// GetRollout returns a rollout with its targets
+func (r *CodecRepository) GetRollout(id uuid.UUID) (*models.CodecRollout, error) {
+	rollout, err := scanRollout(r.db.QueryRow(`
+		SELECT `+rolloutColumns+`
+		FROM codec_rollouts r
+		LEFT JOIN codec_rollout_targets t ON t.rollout_id = r.id
+		WHERE r.id = $1
+		GROUP BY r.id`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrRolloutNotFound
+		}
+		return nil, fmt.Errorf("failed to get rollout: %w", err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, rollout_id, organization_id, device_profile_id, status, previous_runtime, previous_script, error, updated_at
+		FROM codec_rollout_targets
+		WHERE rollout_id = $1
+		ORDER BY device_profile_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollout targets: %w", err)
+	}
+	defer rows.Close()
+
+	rollout.Targets = []models.CodecRolloutTarget{}
+	for rows.Next() {
+		var t models.CodecRolloutTarget
+		err := rows.Scan(&t.ID, &t.RolloutID, &t.OrganizationID, &t.DeviceProfileID, &t.Status,
+			&t.PreviousRuntime, &t.PreviousScript, &t.Error, &t.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rollout target: %w", err)
+		}
+		rollout.Targets = append(rollout.Targets, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return rollout, nil
+}
+
+// GetCodecRollouts returns the rollouts of a codec without their targets,
+// newest first
+func (r *CodecRepository) GetCodecRollouts(codecID uuid.UUID) ([]models.CodecRollout, error) {
+	rows, err := r.db.Query(`
+		SELECT `+rolloutColumns+`
+		FROM codec_rollouts r
+		LEFT JOIN codec_rollout_targets t ON t.rollout_id = r.id
+		WHERE r.codec_id = $1
+		GROUP BY r.id
+		ORDER BY r.created_at DESC`, codecID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollouts: %w", err)
+	}
+	defer rows.Close()
+
+	rollouts := []models.CodecRollout{}
+	for rows.Next() {
+		rollout, err := scanRollout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rollout: %w", err)
+		}
+		rollouts = append(rollouts, *rollout)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return rollouts, nil
+}
+
+// UpdateRolloutTarget stores the progress of one target
+func (r *CodecRepository) UpdateRolloutTarget(target *models.CodecRolloutTarget) error {
+	query := `
+		UPDATE codec_rollout_targets
+		SET status = $1, previous_runtime = $2, previous_script = $3, error = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5`
+
+	_, err := r.db.Exec(query, target.Status, target.PreviousRuntime, target.PreviousScript, target.Error, target.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update rollout target: %w", err)
+	}
+	return nil
+}
+
+// SetRolloutStatus moves a rollout on, finished_at is set once it stops running
+func (r *CodecRepository) SetRolloutStatus(id uuid.UUID, status string) error {
+	query := `
+		UPDATE codec_rollouts
+		SET status = $1, updated_at = CURRENT_TIMESTAMP,
+			finished_at = CASE WHEN $1 IN ('running', 'rolling_back') THEN NULL ELSE CURRENT_TIMESTAMP END
+		WHERE id = $2`
+
+	result, err := r.db.Exec(query, status, id)
+	if err != nil {
+		return fmt.Errorf("failed to update rollout: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrRolloutNotFound
+	}
+	return nil
+}
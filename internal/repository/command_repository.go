@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type CommandRepository struct {
+	db *sql.DB
+}
+
+func NewCommandRepository(db *sql.DB) *CommandRepository {
+	return &CommandRepository{db: db}
+}
+
+const commandColumns = `id, device_id, type, level, "on", data, f_port, confirmed, queue_item_id, status,
+	sent_by, created_at, updated_at`
+
+func scanCommand(row rowScanner) (*models.DeviceCommand, error) {
+	command := &models.DeviceCommand{}
+	err := row.Scan(
+		&command.ID, &command.DeviceID, &command.Type, &command.Level, &command.On, &command.Data, &command.FPort,
+		&command.Confirmed, &command.QueueItemID, &command.Status, &command.SentBy, &command.CreatedAt, &command.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// CreateCommand records a pending command before it is enqueued in ChirpStack,
+// filling in its ID, status and timestamps
+func (r *CommandRepository) CreateCommand(command *models.DeviceCommand) error {
+	query := `
+		INSERT INTO device_commands (device_id, type, level, "on", data, f_port, confirmed, sent_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, status, created_at, updated_at`
+
+	err := r.db.QueryRow(query,
+		command.DeviceID, command.Type, command.Level, command.On, command.Data, command.FPort,
+		command.Confirmed, command.SentBy,
+	).Scan(&command.ID, &command.Status, &command.CreatedAt, &command.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create command: %w", err)
+	}
+	return nil
+}
+
+// SetCommandQueueItem records the ChirpStack queue item of a pending command.
+// A status reported for the queue item in the meantime is applied to it.
+func (r *CommandRepository) SetCommandQueueItem(command *models.DeviceCommand, queueItemID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockQueueItem(tx, queueItemID); err != nil {
+		return err
+	}
+
+	status := models.CommandQueued
+	err = tx.QueryRow(`DELETE FROM device_command_events WHERE device_id = $1 AND queue_item_id = $2 RETURNING status`,
+		command.DeviceID, queueItemID).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get queue item status: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		UPDATE device_commands SET queue_item_id = $2, status = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING status, updated_at`, command.ID, queueItemID, status).Scan(&command.Status, &command.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set command queue item: %w", err)
+	}
+	command.QueueItemID = &queueItemID
+
+	// Statuses of items queued outside of the API are never claimed
+	if _, err := tx.Exec(`DELETE FROM device_command_events WHERE created_at < CURRENT_TIMESTAMP - INTERVAL '1 day'`); err != nil {
+		return fmt.Errorf("failed to delete old queue item statuses: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// DeleteCommand removes a pending command that couldn't be enqueued
+func (r *CommandRepository) DeleteCommand(id uuid.UUID) error {
+	if _, err := r.db.Exec(`DELETE FROM device_commands WHERE id = $1 AND status = 'pending'`, id); err != nil {
+		return fmt.Errorf("failed to delete command: %w", err)
+	}
+	return nil
+}
+
+// GetDeviceCommands returns the commands sent to a device, newest first
+func (r *CommandRepository) GetDeviceCommands(deviceID uuid.UUID, page, pageSize int) ([]models.DeviceCommand, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM device_commands WHERE device_id = $1`, deviceID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count commands: %w", err)
+	}
+
+	query := `SELECT ` + commandColumns + ` FROM device_commands WHERE device_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(query, deviceID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query commands: %w", err)
+	}
+	defer rows.Close()
+
+	commands := []models.DeviceCommand{}
+	for rows.Next() {
+		command, err := scanCommand(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan command: %w", err)
+		}
+		commands = append(commands, *command)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	return commands, total, nil
+}
+
+// UpdateCommandStatus sets the status of the command of a device with the
+// ChirpStack queue item ID. A late txack doesn't take back an ack. The status
+// of a queue item no command has yet is kept for SetCommandQueueItem, as the
+// txack can arrive before the command that was just enqueued is updated.
+func (r *CommandRepository) UpdateCommandStatus(deviceID uuid.UUID, queueItemID, status string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockQueueItem(tx, queueItemID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE device_commands SET status = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE device_id = $1 AND queue_item_id = $2 AND ($3 <> 'sent' OR status = 'queued')`,
+		deviceID, queueItemID, status)
+	if err != nil {
+		return fmt.Errorf("failed to update command status: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		_, err := tx.Exec(`
+			INSERT INTO device_command_events (device_id, queue_item_id, status)
+			SELECT $1::uuid, $2::varchar, $3::varchar
+			WHERE NOT EXISTS (SELECT 1 FROM device_commands WHERE device_id = $1 AND queue_item_id = $2)
+			ON CONFLICT (device_id, queue_item_id) DO UPDATE SET status = EXCLUDED.status
+			WHERE EXCLUDED.status <> 'sent'`, deviceID, queueItemID, status)
+		if err != nil {
+			return fmt.Errorf("failed to record queue item status: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// lockQueueItem serializes the writes for a queue item until the transaction
+// ends, so a status and the command it belongs to can't miss each other
+func lockQueueItem(tx *sql.Tx, queueItemID string) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, queueItemID); err != nil {
+		return fmt.Errorf("failed to lock queue item: %w", err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"go-auth-api/internal/models"
 
@@ -22,17 +23,17 @@ func NewDeviceRepository(db *sqlx.DB) *DeviceRepository {
 // Device Version methods
 func (r *DeviceRepository) CreateDeviceVersion(version *models.DeviceVersion) error {
 	query := `
-		INSERT INTO device_versions (name, version, description)
-		VALUES ($1, $2, $3)
+		INSERT INTO device_versions (name, version, description, profile_template, codec_id)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at, updated_at`
 
-	return r.db.QueryRow(query, version.Name, version.Version, version.Description).
+	return r.db.QueryRow(query, version.Name, version.Version, version.Description, version.ProfileTemplate, version.CodecID).
 		Scan(&version.ID, &version.CreatedAt, &version.UpdatedAt)
 }
 
 func (r *DeviceRepository) GetDeviceVersionByID(id uuid.UUID) (*models.DeviceVersion, error) {
 	version := &models.DeviceVersion{}
-	query := `SELECT id, name, version, description, created_at, updated_at 
+	query := `SELECT id, name, version, description, profile_template, codec_id, created_at, updated_at 
 			  FROM device_versions WHERE id = $1`
 
 	err := r.db.Get(version, query, id)
@@ -57,7 +58,7 @@ func (r *DeviceRepository) GetDeviceVersions(page, pageSize int) ([]models.Devic
 	}
 
 	// Get versions
-	query := `SELECT id, name, version, description, created_at, updated_at 
+	query := `SELECT id, name, version, description, profile_template, codec_id, created_at, updated_at 
 			  FROM device_versions 
 			  ORDER BY created_at DESC 
 			  LIMIT $1 OFFSET $2`
@@ -94,6 +95,18 @@ func (r *DeviceRepository) UpdateDeviceVersion(id uuid.UUID, req *models.UpdateD
 		argIndex++
 	}
 
+	if req.ProfileTemplate != nil {
+		setParts = append(setParts, fmt.Sprintf("profile_template = $%d", argIndex))
+		args = append(args, *req.ProfileTemplate)
+		argIndex++
+	}
+
+	if req.CodecID != nil {
+		setParts = append(setParts, fmt.Sprintf("codec_id = $%d", argIndex))
+		args = append(args, *req.CodecID)
+		argIndex++
+	}
+
 	if len(setParts) == 0 {
 		return fmt.Errorf("no fields to update")
 	}
@@ -140,20 +153,57 @@ func (r *DeviceRepository) DeleteDeviceVersion(id uuid.UUID) error {
 	return nil
 }
 
+// GetOrganizationDeviceProfile returns the ID of the device profile created
+// from a device version's template in an organization's tenant, or "" when
+// there is none yet
+func (r *DeviceRepository) GetOrganizationDeviceProfile(orgID, versionID uuid.UUID, mode string) (string, error) {
+	var profileID string
+	query := `SELECT device_profile_id FROM organization_device_profiles
+			  WHERE organization_id = $1 AND device_version_id = $2 AND activation_mode = $3`
+
+	err := r.db.Get(&profileID, query, orgID, versionID, mode)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return profileID, nil
+}
+
+// AddOrganizationDeviceProfile records a device profile created from a
+// template. When another worker recorded one first, that one is kept and its
+// ID returned, otherwise the returned ID is profile.DeviceProfileID.
+func (r *DeviceRepository) AddOrganizationDeviceProfile(profile *models.OrganizationDeviceProfile) (string, error) {
+	query := `
+		INSERT INTO organization_device_profiles (organization_id, device_version_id, activation_mode, device_profile_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id, device_version_id, activation_mode) DO NOTHING`
+
+	result, err := r.db.Exec(query, profile.OrganizationID, profile.DeviceVersionID, profile.ActivationMode, profile.DeviceProfileID)
+	if err != nil {
+		return "", err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return r.GetOrganizationDeviceProfile(profile.OrganizationID, profile.DeviceVersionID, profile.ActivationMode)
+	}
+	return profile.DeviceProfileID, nil
+}
+
 // Allowed Device methods
 func (r *DeviceRepository) CreateAllowedDevice(device *models.AllowedDevice) error {
 	query := `
-		INSERT INTO allowed_devices (dev_eui, nwk_key, app_key, addr_key, description)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO allowed_devices (dev_eui, activation_mode, join_eui, nwk_key, app_key, addr_key, description)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
 		RETURNING id, created_at, updated_at`
 
-	return r.db.QueryRow(query, device.DevEUI, device.NwkKey, device.AppKey, device.AddrKey, device.Description).
+	return r.db.QueryRow(query, device.DevEUI, device.ActivationMode, device.JoinEUI, device.NwkKey, device.AppKey, device.AddrKey, device.Description).
 		Scan(&device.ID, &device.CreatedAt, &device.UpdatedAt)
 }
 
 func (r *DeviceRepository) GetAllowedDeviceByDevEUI(devEUI string) (*models.AllowedDevice, error) {
 	device := &models.AllowedDevice{}
-	query := `SELECT id, dev_eui, nwk_key, app_key, addr_key, description, created_at, updated_at 
+	query := `SELECT id, dev_eui, activation_mode, join_eui, nwk_key, app_key, COALESCE(addr_key, '') AS addr_key, description, created_at, updated_at 
 			  FROM allowed_devices WHERE dev_eui = $1`
 
 	err := r.db.Get(device, query, devEUI)
@@ -178,7 +228,7 @@ func (r *DeviceRepository) GetAllowedDevices(page, pageSize int) ([]models.Allow
 	}
 
 	// Get devices
-	query := `SELECT id, dev_eui, nwk_key, app_key, addr_key, description, created_at, updated_at 
+	query := `SELECT id, dev_eui, activation_mode, join_eui, nwk_key, app_key, COALESCE(addr_key, '') AS addr_key, description, created_at, updated_at 
 			  FROM allowed_devices 
 			  ORDER BY created_at DESC 
 			  LIMIT $1 OFFSET $2`
@@ -197,6 +247,18 @@ func (r *DeviceRepository) UpdateAllowedDevice(devEUI string, req *models.Update
 	args := []interface{}{}
 	argIndex := 1
 
+	if req.ActivationMode != nil {
+		setParts = append(setParts, fmt.Sprintf("activation_mode = $%d", argIndex))
+		args = append(args, *req.ActivationMode)
+		argIndex++
+	}
+
+	if req.JoinEUI != nil {
+		setParts = append(setParts, fmt.Sprintf("join_eui = $%d", argIndex))
+		args = append(args, *req.JoinEUI)
+		argIndex++
+	}
+
 	if req.NwkKey != nil {
 		setParts = append(setParts, fmt.Sprintf("nwk_key = $%d", argIndex))
 		args = append(args, *req.NwkKey)
@@ -268,21 +330,43 @@ func (r *DeviceRepository) DeleteAllowedDevice(devEUI string) error {
 }
 
 // Device methods
-func (r *DeviceRepository) CreateDevice(device *models.Device) error {
+
+// CreateDevice stores a new device. A non-nil job is enqueued for the device
+// in the same transaction.
+func (r *DeviceRepository) CreateDevice(device *models.Device, job *models.Job) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO devices (user_id, version_id, name, dev_eui, description)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO devices (organization_id, user_id, version_id, name, dev_eui, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at`
 
-	return r.db.QueryRow(query, device.UserID, device.VersionID, device.Name, device.DevEUI, device.Description).
+	err = tx.QueryRow(query, device.OrganizationID, device.UserID, device.VersionID, device.Name, device.DevEUI, device.Description).
 		Scan(&device.ID, &device.CreatedAt, &device.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if job != nil {
+		job.OrganizationID = &device.OrganizationID
+		job.DeviceID = &device.ID
+		if err := insertJob(tx, job); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (r *DeviceRepository) GetDeviceByID(id uuid.UUID) (*models.Device, error) {
 	device := &models.Device{}
 	query := `
-		SELECT d.id, d.user_id, d.version_id, d.name, d.dev_eui, d.description,
-			   d.chirpstack_device_created, d.chirpstack_device_activated, d.is_active,
+		SELECT d.id, d.organization_id, d.user_id, d.version_id, d.name, d.dev_eui, d.description,
+			   d.chirpstack_device_created, d.chirpstack_device_activated, d.is_active, d.last_seen_at,
 			   d.created_at, d.updated_at,
 			   dv.id as "version.id", dv.name as "version.name", dv.version as "version.version",
 			   dv.description as "version.description", dv.created_at as "version.created_at", dv.updated_at as "version.updated_at"
@@ -293,19 +377,22 @@ func (r *DeviceRepository) GetDeviceByID(id uuid.UUID) (*models.Device, error) {
 	err := r.db.Get(device, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("device not found")
+			return nil, models.ErrDeviceNotFound
 		}
 		return nil, err
 	}
 	return device, nil
 }
 
-func (r *DeviceRepository) GetDevicesByUserID(userID uuid.UUID, page, pageSize int) ([]models.Device, int, error) {
+// GetDevicesForMember retrieves the devices of all organizations the user is a member of
+func (r *DeviceRepository) GetDevicesForMember(userID uuid.UUID, page, pageSize int) ([]models.Device, int, error) {
 	offset := (page - 1) * pageSize
 
 	// Get total count
 	var total int
-	countQuery := `SELECT COUNT(*) FROM devices WHERE user_id = $1`
+	countQuery := `
+		SELECT COUNT(*) FROM devices
+		WHERE organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)`
 	err := r.db.Get(&total, countQuery, userID)
 	if err != nil {
 		return nil, 0, err
@@ -313,14 +400,14 @@ func (r *DeviceRepository) GetDevicesByUserID(userID uuid.UUID, page, pageSize i
 
 	// Get devices
 	query := `
-		SELECT d.id, d.user_id, d.version_id, d.name, d.dev_eui, d.description,
-			   d.chirpstack_device_created, d.chirpstack_device_activated, d.is_active,
+		SELECT d.id, d.organization_id, d.user_id, d.version_id, d.name, d.dev_eui, d.description,
+			   d.chirpstack_device_created, d.chirpstack_device_activated, d.is_active, d.last_seen_at,
 			   d.created_at, d.updated_at,
 			   dv.id as "version.id", dv.name as "version.name", dv.version as "version.version",
 			   dv.description as "version.description", dv.created_at as "version.created_at", dv.updated_at as "version.updated_at"
 		FROM devices d
 		LEFT JOIN device_versions dv ON d.version_id = dv.id
-		WHERE d.user_id = $1
+		WHERE d.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)
 		ORDER BY d.created_at DESC
 		LIMIT $2 OFFSET $3`
 
@@ -333,6 +420,43 @@ func (r *DeviceRepository) GetDevicesByUserID(userID uuid.UUID, page, pageSize i
 	return devices, total, nil
 }
 
+// GetOrganizationDevices returns all devices of an organization
+func (r *DeviceRepository) GetOrganizationDevices(orgID uuid.UUID) ([]models.Device, error) {
+	query := `
+		SELECT id, organization_id, user_id, version_id, name, dev_eui, description,
+			   chirpstack_device_created, chirpstack_device_activated, is_active, last_seen_at,
+			   created_at, updated_at
+		FROM devices
+		WHERE organization_id = $1
+		ORDER BY created_at`
+
+	devices := []models.Device{}
+	if err := r.db.Select(&devices, query, orgID); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// GetDeviceByDevEUI looks a device up by its DevEUI, ignoring case
+func (r *DeviceRepository) GetDeviceByDevEUI(devEUI string) (*models.Device, error) {
+	device := &models.Device{}
+	query := `
+		SELECT id, organization_id, user_id, version_id, name, dev_eui, description,
+			   chirpstack_device_created, chirpstack_device_activated, is_active, last_seen_at,
+			   created_at, updated_at
+		FROM devices
+		WHERE UPPER(dev_eui) = UPPER($1)`
+
+	err := r.db.Get(device, query, devEUI)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	return device, nil
+}
+
 func (r *DeviceRepository) GetAllDevices(page, pageSize int) ([]models.Device, int, error) {
 	offset := (page - 1) * pageSize
 
@@ -346,8 +470,8 @@ func (r *DeviceRepository) GetAllDevices(page, pageSize int) ([]models.Device, i
 
 	// Get devices
 	query := `
-		SELECT d.id, d.user_id, d.version_id, d.name, d.dev_eui, d.description,
-			   d.chirpstack_device_created, d.chirpstack_device_activated, d.is_active,
+		SELECT d.id, d.organization_id, d.user_id, d.version_id, d.name, d.dev_eui, d.description,
+			   d.chirpstack_device_created, d.chirpstack_device_activated, d.is_active, d.last_seen_at,
 			   d.created_at, d.updated_at,
 			   dv.id as "version.id", dv.name as "version.name", dv.version as "version.version",
 			   dv.description as "version.description", dv.created_at as "version.created_at", dv.updated_at as "version.updated_at"
@@ -415,7 +539,7 @@ func (r *DeviceRepository) UpdateDevice(id uuid.UUID, req *models.UpdateDeviceRe
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("device not found")
+		return models.ErrDeviceNotFound
 	}
 
 	return nil
@@ -434,7 +558,28 @@ func (r *DeviceRepository) UpdateDeviceChirpStackStatus(id uuid.UUID, created, a
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("device not found")
+		return models.ErrDeviceNotFound
+	}
+
+	return nil
+}
+
+// UpdateDeviceLastSeen records when a device was last heard from. Events
+// arriving out of order don't move the time back.
+func (r *DeviceRepository) UpdateDeviceLastSeen(id uuid.UUID, at time.Time) error {
+	query := `UPDATE devices SET last_seen_at = GREATEST(last_seen_at, $1) WHERE id = $2`
+	result, err := r.db.Exec(query, at, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return models.ErrDeviceNotFound
 	}
 
 	return nil
@@ -453,7 +598,7 @@ func (r *DeviceRepository) DeleteDevice(id uuid.UUID) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("device not found")
+		return models.ErrDeviceNotFound
 	}
 
 	return nil
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type JobRepository struct {
+	db *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+const jobColumns = `id, type, status, organization_id, device_id, payload, attempts, max_attempts,
+	run_at, last_error, created_at, updated_at, completed_at`
+
+// rowQuerier is implemented by *sql.DB, *sql.Tx and *sqlx.Tx, so jobs can be
+// written in the transaction of the row they belong to
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertJob adds a pending job, filling in its ID and timestamps
+func insertJob(q rowQuerier, job *models.Job) error {
+	payload := "{}"
+	if len(job.Payload) > 0 {
+		payload = string(job.Payload)
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = models.DefaultJobMaxAttempts
+	}
+
+	query := `
+		INSERT INTO jobs (type, organization_id, device_id, payload, max_attempts)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, run_at, created_at, updated_at`
+
+	err := q.QueryRow(query, job.Type, job.OrganizationID, job.DeviceID, payload, job.MaxAttempts).
+		Scan(&job.ID, &job.Status, &job.RunAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return nil
+}
+
+func scanJob(row rowScanner) (*models.Job, error) {
+	job := &models.Job{}
+	var payload []byte
+	err := row.Scan(
+		&job.ID, &job.Type, &job.Status, &job.OrganizationID, &job.DeviceID, &payload, &job.Attempts, &job.MaxAttempts,
+		&job.RunAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt, &job.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = payload
+	return job, nil
+}
+
+// Enqueue adds a job outside of any other write
+func (r *JobRepository) Enqueue(job *models.Job) error {
+	return insertJob(r.db, job)
+}
+
+// ClaimNext leases the next due job to the caller until lease has passed and
+// counts the attempt. Running jobs whose lease expired belong to a worker
+// that died and are handed out again. Returns nil when nothing is due.
+func (r *JobRepository) ClaimNext(lease time.Duration) (*models.Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1,
+			locked_until = CURRENT_TIMESTAMP + $1 * INTERVAL '1 second', updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= CURRENT_TIMESTAMP)
+			   OR (status = 'running' AND locked_until < CURRENT_TIMESTAMP)
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(r.db.QueryRow(query, lease.Seconds()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return job, nil
+}
+
+// Complete, Reschedule and Bury finish the attempt a worker claimed. They
+// only touch the job while that attempt still holds it: once the lease has
+// expired and another worker claimed the job, they return ErrJobLeaseLost.
+
+// Complete marks a job succeeded
+func (r *JobRepository) Complete(id uuid.UUID, attempt int) error {
+	query := `
+		UPDATE jobs
+		SET status = 'succeeded', locked_until = NULL, last_error = NULL,
+			completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND attempts = $2`
+	return r.finish(query, id, attempt)
+}
+
+// Reschedule puts a failed job back in the queue to run again at runAt
+func (r *JobRepository) Reschedule(id uuid.UUID, attempt int, runAt time.Time, lastError string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', run_at = $3, locked_until = NULL, last_error = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND attempts = $2`
+	return r.finish(query, id, attempt, runAt, lastError)
+}
+
+// Bury moves a job to the dead letters
+func (r *JobRepository) Bury(id uuid.UUID, attempt int, lastError string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'dead', locked_until = NULL, last_error = $3,
+			completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND attempts = $2`
+	return r.finish(query, id, attempt, lastError)
+}
+
+// finish runs one of the fenced updates above
+func (r *JobRepository) finish(query string, args ...interface{}) error {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrJobLeaseLost
+	}
+	return nil
+}
+
+// Requeue gives a dead job a fresh set of attempts
+func (r *JobRepository) Requeue(id uuid.UUID) error {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', attempts = 0, run_at = CURRENT_TIMESTAMP, completed_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'dead'`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		if _, err := r.GetJobByID(id); err != nil {
+			return err
+		}
+		return models.ErrJobNotDead
+	}
+	return nil
+}
+
+func (r *JobRepository) GetJobByID(id uuid.UUID) (*models.Job, error) {
+	job, err := scanJob(r.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return job, nil
+}
+
+// GetOrganizationJobs returns the jobs of an organization and its devices,
+// newest first
+func (r *JobRepository) GetOrganizationJobs(orgID uuid.UUID, page, pageSize int) ([]models.Job, int, error) {
+	return r.list(`WHERE organization_id = $1`, []interface{}{orgID}, page, pageSize)
+}
+
+// GetJobs returns all jobs, or only those in status when it is not empty
+func (r *JobRepository) GetJobs(status string, page, pageSize int) ([]models.Job, int, error) {
+	if status == "" {
+		return r.list("", nil, page, pageSize)
+	}
+	return r.list(`WHERE status = $1`, []interface{}{status}, page, pageSize)
+}
+
+func (r *JobRepository) list(where string, args []interface{}, page, pageSize int) ([]models.Job, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM jobs `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	n := len(args)
+	query := fmt.Sprintf(`SELECT %s FROM jobs %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, jobColumns, where, n+1, n+2)
+	rows, err := r.db.Query(query, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	return jobs, total, nil
+}
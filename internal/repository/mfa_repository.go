@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// SetPendingTOTPSecret stores a new secret for a user that has not enabled TOTP yet
+func (r *MFARepository) SetPendingTOTPSecret(userID uuid.UUID, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND totp_enabled_at IS NULL`
+
+	result, err := r.db.Exec(query, secret, userID)
+	if err != nil {
+		return fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return models.ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// GetTOTPSecret returns the TOTP secret of a user and the last accepted time step
+func (r *MFARepository) GetTOTPSecret(userID uuid.UUID) (string, int64, error) {
+	var secret sql.NullString
+	var lastStep int64
+	query := "SELECT totp_secret, totp_last_step FROM users WHERE id = $1"
+
+	err := r.db.QueryRow(query, userID).Scan(&secret, &lastStep)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", 0, fmt.Errorf("user not found")
+		}
+		return "", 0, fmt.Errorf("failed to get TOTP secret: %w", err)
+	}
+
+	if !secret.Valid {
+		return "", 0, models.ErrMFANotEnabled
+	}
+
+	return secret.String, lastStep, nil
+}
+
+// AdvanceTOTPStep records step as the last accepted time step. It returns
+// false when a code for this or a later step has already been used.
+func (r *MFARepository) AdvanceTOTPStep(userID uuid.UUID, step int64) (bool, error) {
+	query := "UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1"
+
+	result, err := r.db.Exec(query, step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update TOTP step: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// EnableTOTP turns on TOTP for a user and replaces the recovery codes
+func (r *MFARepository) EnableTOTP(userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET totp_enabled_at = $1, totp_last_step = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND totp_secret IS NOT NULL`
+
+	if _, err := tx.Exec(query, time.Now().UTC(), step, userID); err != nil {
+		return fmt.Errorf("failed to enable TOTP: %w", err)
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableTOTP removes the TOTP secret and recovery codes of a user
+func (r *MFARepository) DisableTOTP(userID uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+
+	if _, err := tx.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to disable TOTP: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes invalidates all recovery codes of a user and stores new ones
+func (r *MFARepository) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, hashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID, hashes []string) error {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range hashes {
+		_, err := tx.Exec("INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It returns false when
+// the code does not exist or has been used before.
+func (r *MFARepository) UseRecoveryCode(userID uuid.UUID, hash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`
+
+	result, err := r.db.Exec(query, time.Now().UTC(), userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// GetRolePolicies returns the policy of every role
+func (r *MFARepository) GetRolePolicies() ([]models.RolePolicy, error) {
+	rows, err := r.db.Query("SELECT role, require_mfa, updated_at FROM role_policies ORDER BY role")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []models.RolePolicy
+	for rows.Next() {
+		var policy models.RolePolicy
+		if err := rows.Scan(&policy.Role, &policy.RequireMFA, &policy.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return policies, nil
+}
+
+// SetRolePolicy creates or updates the policy of a role
+func (r *MFARepository) SetRolePolicy(role string, requireMFA bool) error {
+	query := `
+		INSERT INTO role_policies (role, require_mfa, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (role) DO UPDATE SET require_mfa = EXCLUDED.require_mfa, updated_at = EXCLUDED.updated_at`
+
+	if _, err := r.db.Exec(query, role, requireMFA); err != nil {
+		return fmt.Errorf("failed to update role policy: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,488 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type OrganizationRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepository(db *sql.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// CreateOrganization stores a new organization with ownerID as its first
+// owner. A non-nil job is enqueued for the organization in the same
+// transaction.
+func (r *OrganizationRepository) CreateOrganization(org *models.Organization, ownerID uuid.UUID, job *models.Job) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO organizations (name, region, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, provisioning_status, created_at, updated_at`
+
+	err = tx.QueryRow(query, org.Name, org.Region, ownerID).Scan(&org.ID, &org.ProvisioningStatus, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+	org.CreatedBy = &ownerID
+
+	_, err = tx.Exec(`
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)`,
+		org.ID, ownerID, models.OrgRoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to add organization owner: %w", err)
+	}
+
+	if job != nil {
+		job.OrganizationID = &org.ID
+		if err := insertJob(tx, job); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *OrganizationRepository) GetOrganizationByID(id uuid.UUID) (*models.Organization, error) {
+	return r.getOrganization("id = $1", id)
+}
+
+// GetOrganizationByTenantID returns the organization owning a ChirpStack tenant
+func (r *OrganizationRepository) GetOrganizationByTenantID(tenantID string) (*models.Organization, error) {
+	return r.getOrganization("tenant_id = $1", tenantID)
+}
+
+// GetOrganizationByApplicationID returns the organization owning a ChirpStack application
+func (r *OrganizationRepository) GetOrganizationByApplicationID(applicationID string) (*models.Organization, error) {
+	return r.getOrganization("application_id = $1", applicationID)
+}
+
+func (r *OrganizationRepository) getOrganization(where string, arg interface{}) (*models.Organization, error) {
+	org := &models.Organization{}
+	query := `
+		SELECT id, name, region, tenant_id, application_id, device_profile_id, otaa_device_profile_id, provisioning_status, provisioning_error,
+			integration_url, integration_encoding, integration_secret, created_by, created_at, updated_at
+		FROM organizations
+		WHERE ` + where
+
+	err := r.db.QueryRow(query, arg).Scan(
+		&org.ID, &org.Name, &org.Region, &org.TenantID, &org.ApplicationID, &org.DeviceProfileID, &org.OTAADeviceProfileID, &org.ProvisioningStatus, &org.ProvisioningError,
+		&org.IntegrationURL, &org.IntegrationEncoding, &org.IntegrationSecret, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// GetOrganizationsForUser returns the organizations a user is a member of,
+// oldest membership first, with the user's role in each
+func (r *OrganizationRepository) GetOrganizationsForUser(userID uuid.UUID) ([]models.Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.region, o.tenant_id, o.application_id, o.device_profile_id, o.otaa_device_profile_id, o.provisioning_status, o.provisioning_error,
+			o.integration_url, o.integration_encoding, o.integration_secret, o.created_by, o.created_at, o.updated_at, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY m.created_at, o.created_at`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var org models.Organization
+		err := rows.Scan(
+			&org.ID, &org.Name, &org.Region, &org.TenantID, &org.ApplicationID, &org.DeviceProfileID, &org.OTAADeviceProfileID, &org.ProvisioningStatus, &org.ProvisioningError,
+			&org.IntegrationURL, &org.IntegrationEncoding, &org.IntegrationSecret, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt, &org.Role,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return orgs, nil
+}
+
+// GetProvisionedOrganizations returns every organization whose ChirpStack
+// resources are complete
+func (r *OrganizationRepository) GetProvisionedOrganizations() ([]models.Organization, error) {
+	query := `
+		SELECT id, name, region, tenant_id, application_id, device_profile_id, otaa_device_profile_id, provisioning_status, provisioning_error,
+			integration_url, integration_encoding, integration_secret, created_by, created_at, updated_at
+		FROM organizations
+		WHERE provisioning_status = $1
+		ORDER BY created_at`
+
+	rows, err := r.db.Query(query, models.ProvisioningProvisioned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var org models.Organization
+		err := rows.Scan(
+			&org.ID, &org.Name, &org.Region, &org.TenantID, &org.ApplicationID, &org.DeviceProfileID, &org.OTAADeviceProfileID, &org.ProvisioningStatus, &org.ProvisioningError,
+			&org.IntegrationURL, &org.IntegrationEncoding, &org.IntegrationSecret, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return orgs, nil
+}
+
+// GetApplicationIDs returns the ChirpStack application of every organization
+// that has one
+func (r *OrganizationRepository) GetApplicationIDs() ([]string, error) {
+	rows, err := r.db.Query(`SELECT application_id FROM organizations WHERE application_id IS NOT NULL ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query application IDs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan application ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (r *OrganizationRepository) UpdateOrganizationName(id uuid.UUID, name string) error {
+	query := `UPDATE organizations SET name = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	return r.execExpectingRow(query, models.ErrOrganizationNotFound, name, id)
+}
+
+// UpdateOrganizationProvisioning stores the ChirpStack resources recorded so
+// far for an organization together with the provisioning status
+func (r *OrganizationRepository) UpdateOrganizationProvisioning(org *models.Organization) error {
+	query := `
+		UPDATE organizations
+		SET tenant_id = $1, application_id = $2, device_profile_id = $3, otaa_device_profile_id = $4,
+			provisioning_status = $5, provisioning_error = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7`
+	return r.execExpectingRow(query, models.ErrOrganizationNotFound,
+		org.TenantID, org.ApplicationID, org.DeviceProfileID, org.OTAADeviceProfileID, org.ProvisioningStatus, org.ProvisioningError, org.ID)
+}
+
+// UpdateOrganizationIntegration records the HTTP integration registered for
+// an organization's application
+func (r *OrganizationRepository) UpdateOrganizationIntegration(id uuid.UUID, url, encoding, secret string) error {
+	query := `
+		UPDATE organizations
+		SET integration_url = $1, integration_encoding = $2, integration_secret = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4`
+	return r.execExpectingRow(query, models.ErrOrganizationNotFound, url, encoding, secret, id)
+}
+
+// EnqueueIntegrationUpdates queues a provisioning job for every provisioned
+// organization whose HTTP integration doesn't post to url in encoding yet,
+// unless one is already queued. It returns the number of jobs added.
+func (r *OrganizationRepository) EnqueueIntegrationUpdates(url, encoding string) (int, error) {
+	query := `
+		INSERT INTO jobs (type, organization_id)
+		SELECT $1, o.id FROM organizations o
+		WHERE o.provisioning_status = $2
+		  AND (o.integration_url IS DISTINCT FROM $3 OR o.integration_encoding IS DISTINCT FROM $4)
+		  AND NOT EXISTS (
+			SELECT 1 FROM jobs j
+			WHERE j.organization_id = o.id AND j.type = $1 AND j.status IN ('pending', 'running')
+		  )`
+
+	result, err := r.db.Exec(query, models.JobProvisionOrganization, models.ProvisioningProvisioned, url, encoding)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue integration updates: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
+
+// GetMemberRole returns the role of a user in an organization, or
+// ErrMemberNotFound when the user is not a member
+func (r *OrganizationRepository) GetMemberRole(orgID, userID uuid.UUID) (string, error) {
+	var role string
+	query := "SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2"
+
+	err := r.db.QueryRow(query, orgID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", models.ErrMemberNotFound
+		}
+		return "", fmt.Errorf("failed to get member role: %w", err)
+	}
+
+	return role, nil
+}
+
+func (r *OrganizationRepository) GetMembers(orgID uuid.UUID) ([]models.OrganizationMember, error) {
+	query := `
+		SELECT m.organization_id, m.user_id, u.email, u.full_name, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY m.created_at`
+
+	rows, err := r.db.Query(query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organization members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.OrganizationMember{}
+	for rows.Next() {
+		var member models.OrganizationMember
+		err := rows.Scan(&member.OrganizationID, &member.UserID, &member.Email, &member.FullName, &member.Role, &member.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return members, nil
+}
+
+// UpdateMemberRole changes the role of a member. Demoting the last owner
+// returns ErrLastOwner.
+func (r *OrganizationRepository) UpdateMemberRole(orgID, userID uuid.UUID, role string) error {
+	return r.changeMember(orgID, userID, func(tx *sql.Tx, current string) error {
+		if current == models.OrgRoleOwner && role != models.OrgRoleOwner {
+			if err := ensureAnotherOwner(tx, orgID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`UPDATE organization_members SET role = $1 WHERE organization_id = $2 AND user_id = $3`,
+			role, orgID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to update member role: %w", err)
+		}
+		return nil
+	})
+}
+
+// RemoveMember removes a user from an organization. Removing the last owner
+// returns ErrLastOwner.
+func (r *OrganizationRepository) RemoveMember(orgID, userID uuid.UUID) error {
+	return r.changeMember(orgID, userID, func(tx *sql.Tx, current string) error {
+		if current == models.OrgRoleOwner {
+			if err := ensureAnotherOwner(tx, orgID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`, orgID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+		return nil
+	})
+}
+
+// changeMember runs fn in a transaction that holds a lock on the organization,
+// so concurrent changes can't remove the last owner between check and write
+func (r *OrganizationRepository) changeMember(orgID, userID uuid.UUID, fn func(tx *sql.Tx, current string) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id uuid.UUID
+	if err := tx.QueryRow(`SELECT id FROM organizations WHERE id = $1 FOR UPDATE`, orgID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrOrganizationNotFound
+		}
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+
+	var current string
+	err = tx.QueryRow(`SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2`, orgID, userID).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.ErrMemberNotFound
+		}
+		return fmt.Errorf("failed to get member role: %w", err)
+	}
+
+	if err := fn(tx, current); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ensureAnotherOwner(tx *sql.Tx, orgID uuid.UUID) error {
+	var owners int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = $2`,
+		orgID, models.OrgRoleOwner).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("failed to count owners: %w", err)
+	}
+	if owners <= 1 {
+		return models.ErrLastOwner
+	}
+	return nil
+}
+
+func (r *OrganizationRepository) CreateInvitation(invitation *models.OrganizationInvitation) error {
+	query := `
+		INSERT INTO organization_invitations (organization_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(query, invitation.OrganizationID, invitation.Email, invitation.Role,
+		invitation.TokenHash, invitation.InvitedBy, invitation.ExpiresAt).
+		Scan(&invitation.ID, &invitation.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingInvitations returns the invitations of an organization that have
+// not been accepted yet
+func (r *OrganizationRepository) GetPendingInvitations(orgID uuid.UUID) ([]models.OrganizationInvitation, error) {
+	query := `
+		SELECT id, organization_id, email, role, invited_by, expires_at, accepted_at, created_at
+		FROM organization_invitations
+		WHERE organization_id = $1 AND accepted_at IS NULL
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []models.OrganizationInvitation{}
+	for rows.Next() {
+		var inv models.OrganizationInvitation
+		err := rows.Scan(&inv.ID, &inv.OrganizationID, &inv.Email, &inv.Role, &inv.InvitedBy,
+			&inv.ExpiresAt, &inv.AcceptedAt, &inv.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, inv)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return invitations, nil
+}
+
+func (r *OrganizationRepository) DeleteInvitation(orgID, id uuid.UUID) error {
+	query := `DELETE FROM organization_invitations WHERE id = $1 AND organization_id = $2 AND accepted_at IS NULL`
+	return r.execExpectingRow(query, models.ErrInvalidInvitation, id, orgID)
+}
+
+// AcceptInvitation consumes a pending invitation addressed to email and adds
+// the user to the organization with the invited role
+func (r *OrganizationRepository) AcceptInvitation(hash string, userID uuid.UUID, email string) (uuid.UUID, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var orgID uuid.UUID
+	var role string
+	now := time.Now().UTC()
+	err = tx.QueryRow(`
+		UPDATE organization_invitations SET accepted_at = $3
+		WHERE token_hash = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL AND expires_at > $3
+		RETURNING organization_id, role`,
+		hash, email, now).Scan(&orgID, &role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, models.ErrInvalidInvitation
+		}
+		return uuid.Nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`,
+		orgID, userID, role)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return uuid.Nil, models.ErrAlreadyMember
+	}
+
+	return orgID, tx.Commit()
+}
+
+// execExpectingRow runs an update or delete and returns notFound when no row matched
+func (r *OrganizationRepository) execExpectingRow(query string, notFound error, args ...interface{}) error {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return notFound
+	}
+
+	return nil
+}
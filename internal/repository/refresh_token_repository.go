@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+func (r *RefreshTokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, mfa)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, token.MFA).
+		Scan(&token.ID, &token.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepository) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, mfa, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1`
+
+	err := r.db.QueryRow(query, hash).Scan(
+		&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash,
+		&token.ExpiresAt, &token.RevokedAt, &token.ReplacedBy, &token.MFA, &token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken stores next and revokes current in a single transaction.
+// It returns ErrRefreshTokenReused when current has already been revoked, which
+// happens when two requests race to rotate the same token.
+func (r *RefreshTokenRepository) RotateRefreshToken(currentID uuid.UUID, next *models.RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insertQuery := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, mfa)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	err = tx.QueryRow(insertQuery, next.UserID, next.FamilyID, next.TokenHash, next.ExpiresAt, next.MFA).
+		Scan(&next.ID, &next.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	revokeQuery := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $1
+		WHERE id = $2 AND revoked_at IS NULL`
+
+	result, err := tx.Exec(revokeQuery, next.ID, currentID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return models.ErrRefreshTokenReused
+	}
+
+	return tx.Commit()
+}
+
+// RevokeRefreshTokenFamily revokes every token descending from the same login
+func (r *RefreshTokenRepository) RevokeRefreshTokenFamily(familyID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.db.Exec(query, familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of a user
+func (r *RefreshTokenRepository) RevokeUserRefreshTokens(userID uuid.UUID) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.db.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type SecurityRepository struct {
+	db *sql.DB
+}
+
+func NewSecurityRepository(db *sql.DB) *SecurityRepository {
+	return &SecurityRepository{db: db}
+}
+
+// RecordFailedLogin counts a failed login for a user. When the count reaches
+// lockAfter the account is locked until lockedUntil and the count starts over.
+// It returns the new count and the lock expiry of the account.
+func (r *SecurityRepository) RecordFailedLogin(userID uuid.UUID, lockAfter int, lockedUntil time.Time) (int, *time.Time, error) {
+	var count int
+	var locked *time.Time
+	query := `
+		UPDATE users SET
+			failed_login_count = CASE WHEN failed_login_count + 1 >= $2 THEN 0 ELSE failed_login_count + 1 END,
+			locked_until = CASE WHEN failed_login_count + 1 >= $2 THEN $3 ELSE locked_until END,
+			last_failed_login_at = $4
+		WHERE id = $1
+		RETURNING failed_login_count, locked_until`
+
+	err := r.db.QueryRow(query, userID, lockAfter, lockedUntil, time.Now().UTC()).Scan(&count, &locked)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to record failed login: %w", err)
+	}
+
+	return count, locked, nil
+}
+
+// ResetFailedLogins clears the failure count and any lock of a user
+func (r *SecurityRepository) ResetFailedLogins(userID uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET failed_login_count = 0, last_failed_login_at = NULL, locked_until = NULL
+		WHERE id = $1`
+
+	result, err := r.db.Exec(query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (r *SecurityRepository) CreateSecurityEvent(event *models.SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (user_id, event_type, email, ip_address, user_agent, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(query, event.UserID, event.EventType, nullString(event.Email),
+		nullString(event.IPAddress), nullString(event.UserAgent), nullString(event.Details)).
+		Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+
+	return nil
+}
+
+// GetSecurityEvents returns the most recent events, optionally only for one user
+func (r *SecurityRepository) GetSecurityEvents(userID *uuid.UUID, page, pageSize int) ([]models.SecurityEvent, int, error) {
+	var total int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM security_events WHERE $1::uuid IS NULL OR user_id = $1", userID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count security events: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	query := `
+		SELECT id, user_id, event_type, COALESCE(email, ''), COALESCE(ip_address, ''), COALESCE(user_agent, ''), COALESCE(details, ''), created_at
+		FROM security_events
+		WHERE $1::uuid IS NULL OR user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(query, userID, pageSize, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query security events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.SecurityEvent{}
+	for rows.Next() {
+		var event models.SecurityEvent
+		err := rows.Scan(&event.ID, &event.UserID, &event.EventType, &event.Email,
+			&event.IPAddress, &event.UserAgent, &event.Details, &event.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan security event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	return events, total, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// telemetryColumns maps the telemetry fields to their columns
+var telemetryColumns = map[string]string{
+	models.TelemetryVoltage:    "voltage",
+	models.TelemetryCurrent:    "current",
+	models.TelemetryPower:      "power",
+	models.TelemetryEnergy:     "energy",
+	models.TelemetryPF:         "pf",
+	models.TelemetryTilt:       "tilt",
+	models.TelemetryDimming:    "dimming",
+	models.TelemetryStatusLamp: "status_lamp",
+}
+
+type TelemetryRepository struct {
+	db *sql.DB
+}
+
+func NewTelemetryRepository(db *sql.DB) *TelemetryRepository {
+	return &TelemetryRepository{db: db}
+}
+
+// InsertTelemetry stores a sample. A sample of the device at the same time,
+// from an event delivered twice, is kept.
+func (r *TelemetryRepository) InsertTelemetry(sample *models.TelemetrySample) error {
+	columns := []string{"device_id", "time"}
+	args := []interface{}{sample.DeviceID, sample.Time.UTC()}
+	for _, field := range models.TelemetryFields {
+		if v, ok := sample.Values[field]; ok {
+			columns = append(columns, telemetryColumns[field])
+			args = append(args, v)
+		}
+	}
+
+	placeholders := make([]string, len(args))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	query := `
+		INSERT INTO device_telemetry (` + strings.Join(columns, ", ") + `)
+		VALUES (` + strings.Join(placeholders, ", ") + `)
+		ON CONFLICT (device_id, time) DO NOTHING`
+
+	if _, err := r.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to insert telemetry: %w", err)
+	}
+	return nil
+}
+
+// GetTelemetry returns up to limit samples of a device from from up to to,
+// oldest first
+func (r *TelemetryRepository) GetTelemetry(deviceID uuid.UUID, from, to time.Time, fields []string, limit int) ([]models.TelemetryPoint, error) {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = telemetryColumns[field]
+	}
+
+	query := `
+		SELECT time, ` + strings.Join(columns, ", ") + `
+		FROM device_telemetry
+		WHERE device_id = $1 AND time >= $2 AND time < $3
+		ORDER BY time
+		LIMIT $4`
+
+	rows, err := r.db.Query(query, deviceID, from.UTC(), to.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query telemetry: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.TelemetryPoint{}
+	for rows.Next() {
+		var point models.TelemetryPoint
+		values := make([]sql.NullFloat64, len(fields))
+		dest := []interface{}{&point.Time}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan telemetry: %w", err)
+		}
+		point.Values = telemetryValues(fields, values)
+		points = append(points, point)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return points, nil
+}
+
+// GetTelemetryBuckets aggregates the samples of a device from from up to to
+// into buckets of interval starting at from. Buckets without samples are
+// left out.
+func (r *TelemetryRepository) GetTelemetryBuckets(deviceID uuid.UUID, from, to time.Time, interval time.Duration, fields []string) ([]models.TelemetryPoint, error) {
+	aggregates := make([]string, 0, 3*len(fields))
+	for _, field := range fields {
+		column := telemetryColumns[field]
+		aggregates = append(aggregates, "MIN("+column+")", "MAX("+column+")", "AVG("+column+")")
+	}
+
+	query := `
+		SELECT date_bin($1::interval, time, $2::timestamp) AS bucket, COUNT(*), ` + strings.Join(aggregates, ", ") + `
+		FROM device_telemetry
+		WHERE device_id = $3 AND time >= $2 AND time < $4
+		GROUP BY bucket
+		ORDER BY bucket`
+
+	bucketSize := fmt.Sprintf("%d microseconds", interval.Microseconds())
+	rows, err := r.db.Query(query, bucketSize, from.UTC(), deviceID, to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query telemetry: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.TelemetryPoint{}
+	for rows.Next() {
+		var point models.TelemetryPoint
+		values := make([]sql.NullFloat64, 3*len(fields))
+		dest := []interface{}{&point.Time, &point.Count}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan telemetry: %w", err)
+		}
+
+		min := make([]sql.NullFloat64, len(fields))
+		max := make([]sql.NullFloat64, len(fields))
+		avg := make([]sql.NullFloat64, len(fields))
+		for i := range fields {
+			min[i], max[i], avg[i] = values[3*i], values[3*i+1], values[3*i+2]
+		}
+		point.Min = telemetryValues(fields, min)
+		point.Max = telemetryValues(fields, max)
+		point.Avg = telemetryValues(fields, avg)
+		points = append(points, point)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return points, nil
+}
+
+// EnsureTelemetryPartitions creates the monthly partitions of the telemetry
+// table covering from up to to. Samples of months without a partition are
+// kept in the default partition until their month's partition is created.
+func (r *TelemetryRepository) EnsureTelemetryPartitions(from, to time.Time) error {
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(to) {
+		if err := r.createTelemetryPartition(month); err != nil {
+			return fmt.Errorf("failed to create telemetry partition for %s: %w", month.Format("2006-01"), err)
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+	return nil
+}
+
+// createTelemetryPartition creates the partition of a month unless it exists.
+// Postgres refuses a partition while the default partition holds rows in its
+// range, so the rows are moved into the new table before it is attached. The
+// default partition is locked against inserts until the partition is in place.
+func (r *TelemetryRepository) createTelemetryPartition(month time.Time) error {
+	name := "device_telemetry_" + month.Format("2006_01")
+
+	var exists bool
+	if err := r.db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	start := month.Format("2006-01-02")
+	end := month.AddDate(0, 1, 0).Format("2006-01-02")
+	statements := []string{
+		`LOCK TABLE device_telemetry_default IN EXCLUSIVE MODE`,
+		fmt.Sprintf(`CREATE TABLE %s (LIKE device_telemetry INCLUDING ALL)`, name),
+		fmt.Sprintf(`INSERT INTO %s SELECT * FROM device_telemetry_default WHERE time >= '%s' AND time < '%s'`, name, start, end),
+		fmt.Sprintf(`DELETE FROM device_telemetry_default WHERE time >= '%s' AND time < '%s'`, start, end),
+		fmt.Sprintf(`ALTER TABLE device_telemetry ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`, name, start, end),
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// telemetryValues maps the non-NULL values to their fields
+func telemetryValues(fields []string, values []sql.NullFloat64) map[string]float64 {
+	m := make(map[string]float64, len(fields))
+	for i, field := range fields {
+		if values[i].Valid {
+			m[field] = values[i].Float64
+		}
+	}
+	return m
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TokenRevocationRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRevocationRepository(db *sql.DB) *TokenRevocationRepository {
+	return &TokenRevocationRepository{db: db}
+}
+
+// RevokeAccessToken adds a single access token to the denylist
+func (r *TokenRevocationRepository) RevokeAccessToken(jti string, userID uuid.UUID, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_access_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := r.db.Exec(query, jti, userID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserAccessTokens rejects every access token of a user issued up to revokedBefore
+func (r *TokenRevocationRepository) RevokeUserAccessTokens(userID uuid.UUID, revokedBefore time.Time) error {
+	query := `
+		INSERT INTO user_token_revocations (user_id, revoked_before)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before)`
+
+	if _, err := r.db.Exec(query, userID, revokedBefore); err != nil {
+		return fmt.Errorf("failed to revoke user access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// GetRevokedAccessTokens returns the jti and expiry of every revoked token that has not expired yet
+func (r *TokenRevocationRepository) GetRevokedAccessTokens() (map[string]time.Time, error) {
+	rows, err := r.db.Query("SELECT jti, expires_at FROM revoked_access_tokens WHERE expires_at > CURRENT_TIMESTAMP")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revoked access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make(map[string]time.Time)
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revoked access token: %w", err)
+		}
+		tokens[jti] = expiresAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// GetUserRevocationsSince returns the per-user cutoffs that are newer than since
+func (r *TokenRevocationRepository) GetUserRevocationsSince(since time.Time) (map[uuid.UUID]time.Time, error) {
+	rows, err := r.db.Query("SELECT user_id, revoked_before FROM user_token_revocations WHERE revoked_before > $1", since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user token revocations: %w", err)
+	}
+	defer rows.Close()
+
+	revocations := make(map[uuid.UUID]time.Time)
+	for rows.Next() {
+		var userID uuid.UUID
+		var revokedBefore time.Time
+		if err := rows.Scan(&userID, &revokedBefore); err != nil {
+			return nil, fmt.Errorf("failed to scan user token revocation: %w", err)
+		}
+		revocations[userID] = revokedBefore
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return revocations, nil
+}
+
+// DeleteExpiredRevocations removes denylist entries for tokens that have expired anyway
+func (r *TokenRevocationRepository) DeleteExpiredRevocations(olderThan time.Time) error {
+	if _, err := r.db.Exec("DELETE FROM revoked_access_tokens WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		return fmt.Errorf("failed to delete expired access token revocations: %w", err)
+	}
+
+	if _, err := r.db.Exec("DELETE FROM user_token_revocations WHERE revoked_before < $1", olderThan); err != nil {
+		return fmt.Errorf("failed to delete expired user token revocations: %w", err)
+	}
+
+	return nil
+}
@@ -18,11 +18,11 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 func (r *UserRepository) CreateUser(user *models.User) error {
 	query := `
-		INSERT INTO users (email, password_hash, full_name)
-		VALUES ($1, $2, $3)
+		INSERT INTO users (email, password_hash, full_name, role)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at, updated_at`
 
-	err := r.db.QueryRow(query, user.Email, user.PasswordHash, user.FullName).
+	err := r.db.QueryRow(query, user.Email, user.PasswordHash, user.FullName, user.Role).
 		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
@@ -35,13 +35,14 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, email, password_hash, full_name, tenant_id, application_id, device_profile_id, created_at, updated_at
+		SELECT id, email, password_hash, full_name, role, email_verified_at, totp_enabled_at, suspended_at, failed_login_count, last_failed_login_at, locked_until, created_at, updated_at
 		FROM users
 		WHERE email = $1`
 
 	err := r.db.QueryRow(query, email).Scan(
 		&user.ID, &user.Email, &user.PasswordHash,
-		&user.FullName, &user.TenantID, &user.ApplicationID, &user.DeviceProfileID,
+		&user.FullName, &user.Role, &user.EmailVerifiedAt, &user.TOTPEnabledAt, &user.SuspendedAt,
+		&user.FailedLogins, &user.LastFailedLogin, &user.LockedUntil,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 
@@ -58,13 +59,14 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 func (r *UserRepository) GetUserByID(id string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, email, password_hash, full_name, tenant_id, application_id, device_profile_id, created_at, updated_at
+		SELECT id, email, password_hash, full_name, role, email_verified_at, totp_enabled_at, suspended_at, failed_login_count, last_failed_login_at, locked_until, created_at, updated_at
 		FROM users
 		WHERE id = $1`
 
 	err := r.db.QueryRow(query, id).Scan(
 		&user.ID, &user.Email, &user.PasswordHash,
-		&user.FullName, &user.TenantID, &user.ApplicationID, &user.DeviceProfileID,
+		&user.FullName, &user.Role, &user.EmailVerifiedAt, &user.TOTPEnabledAt, &user.SuspendedAt,
+		&user.FailedLogins, &user.LastFailedLogin, &user.LockedUntil,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 
@@ -91,7 +93,7 @@ func (r *UserRepository) GetAllUsers(page, pageSize int) ([]models.User, int, er
 	// Get users with pagination
 	offset := (page - 1) * pageSize
 	query := `
-		SELECT id, email, password_hash, full_name, tenant_id, application_id, device_profile_id, created_at, updated_at
+		SELECT id, email, password_hash, full_name, role, email_verified_at, totp_enabled_at, suspended_at, failed_login_count, last_failed_login_at, locked_until, created_at, updated_at
 		FROM users
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2`
@@ -107,7 +109,8 @@ func (r *UserRepository) GetAllUsers(page, pageSize int) ([]models.User, int, er
 		var user models.User
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.PasswordHash,
-			&user.FullName, &user.TenantID, &user.ApplicationID, &user.DeviceProfileID,
+			&user.FullName, &user.Role, &user.EmailVerifiedAt, &user.TOTPEnabledAt, &user.SuspendedAt,
+			&user.FailedLogins, &user.LastFailedLogin, &user.LockedUntil,
 			&user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
@@ -166,10 +169,49 @@ func (r *UserRepository) UpdateUser(id string, updates map[string]interface{}) e
 	return nil
 }
 
-// DeleteUser deletes a user by ID
-func (r *UserRepository) DeleteUser(id string) error {
-	query := "DELETE FROM users WHERE id = $1"
-	result, err := r.db.Exec(query, id)
+// DeleteUser deletes a user by ID. The memberships of the user go with it, so
+// a user who is the only owner of an organization is kept and ErrLastOwner is
+// returned. revoke runs once the user is known to go and a failure keeps the
+// user.
+func (r *UserRepository) DeleteUser(id string, revoke func() error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Owned organizations are locked like member changes lock them, so
+	// another owner can't leave between the check and the delete
+	_, err = tx.Exec(`
+		SELECT o.id FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1 AND m.role = $2
+		ORDER BY o.id
+		FOR UPDATE OF o`, id, models.OrgRoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to lock organizations: %w", err)
+	}
+
+	var soleOwner int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM organization_members m
+		WHERE m.user_id = $1 AND m.role = $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM organization_members other
+			WHERE other.organization_id = m.organization_id AND other.role = $2 AND other.user_id <> m.user_id
+		  )`, id, models.OrgRoleOwner).Scan(&soleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to count owned organizations: %w", err)
+	}
+	if soleOwner > 0 {
+		return fmt.Errorf("%w, the user is its only owner", models.ErrLastOwner)
+	}
+
+	if err := revoke(); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM users WHERE id = $1", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -183,7 +225,7 @@ func (r *UserRepository) DeleteUser(id string) error {
 		return fmt.Errorf("user not found")
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 // SearchUsers searches users by email or full name
@@ -203,7 +245,7 @@ func (r *UserRepository) SearchUsers(query string, page, pageSize int) ([]models
 	// Get users with pagination
 	offset := (page - 1) * pageSize
 	searchQuery := `
-		SELECT id, email, password_hash, full_name, tenant_id, application_id, device_profile_id, created_at, updated_at
+		SELECT id, email, password_hash, full_name, role, email_verified_at, totp_enabled_at, suspended_at, failed_login_count, last_failed_login_at, locked_until, created_at, updated_at
 		FROM users
 		WHERE LOWER(email) LIKE $1 OR LOWER(full_name) LIKE $1
 		ORDER BY created_at DESC
@@ -220,7 +262,8 @@ func (r *UserRepository) SearchUsers(query string, page, pageSize int) ([]models
 		var user models.User
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.PasswordHash,
-			&user.FullName, &user.TenantID, &user.ApplicationID, &user.DeviceProfileID,
+			&user.FullName, &user.Role, &user.EmailVerifiedAt, &user.TOTPEnabledAt, &user.SuspendedAt,
+			&user.FailedLogins, &user.LastFailedLogin, &user.LockedUntil,
 			&user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
@@ -236,16 +279,40 @@ func (r *UserRepository) SearchUsers(query string, page, pageSize int) ([]models
 	return users, total, nil
 }
 
-// UpdateUserChirpStackData updates ChirpStack related fields for a user
-func (r *UserRepository) UpdateUserChirpStackData(id string, tenantID, applicationID, deviceProfileID string) error {
+// SetUserSuspended suspends or reactivates a user account
+func (r *UserRepository) SetUserSuspended(id string, suspended bool) error {
 	query := `
 		UPDATE users
-		SET tenant_id = $1, application_id = $2, device_profile_id = $3, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $4`
+		SET suspended_at = CASE WHEN $1 THEN CURRENT_TIMESTAMP ELSE NULL END, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2`
 
-	result, err := r.db.Exec(query, tenantID, applicationID, deviceProfileID, id)
+	result, err := r.db.Exec(query, suspended, id)
+	if err != nil {
+		return fmt.Errorf("failed to update user suspension: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// SetEmailVerified marks the email address of a user as verified
+func (r *UserRepository) SetEmailVerified(id string) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
 	if err != nil {
-		return fmt.Errorf("failed to update user ChirpStack data: %w", err)
+		return fmt.Errorf("failed to update email verification: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
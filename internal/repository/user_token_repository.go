@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type UserTokenRepository struct {
+	db *sql.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+// CreateUserToken stores a new token and invalidates any unused token the
+// user still has for the same purpose, so only the latest email works.
+func (r *UserTokenRepository) CreateUserToken(token *models.UserToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE user_tokens SET used_at = $3
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		token.UserID, token.Purpose, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to invalidate user tokens: %w", err)
+	}
+
+	query := `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err = tx.QueryRow(query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns the
+// user it belongs to. A token can only be consumed once.
+func (r *UserTokenRepository) ConsumeUserToken(hash, purpose string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	now := time.Now().UTC()
+	query := `
+		UPDATE user_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING user_id`
+
+	err := r.db.QueryRow(query, hash, purpose, now).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, models.ErrInvalidUserToken
+		}
+		return uuid.Nil, fmt.Errorf("failed to consume user token: %w", err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/config"
+	"go-auth-api/internal/mailer"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// AccountService handles the email based account flows: address verification
+// and password reset. Both use single-use tokens that are mailed to the user.
+type AccountService struct {
+	userRepo      *repository.UserRepository
+	userTokenRepo *repository.UserTokenRepository
+	tokenService  *TokenService
+	orgService    *OrganizationService
+	hasher        *auth.PasswordHasher
+	mailer        mailer.Mailer
+	baseURL       string
+	verifyTTL     time.Duration
+	resetTTL      time.Duration
+}
+
+func NewAccountService(cfg *config.Config, userRepo *repository.UserRepository, userTokenRepo *repository.UserTokenRepository, tokenService *TokenService, orgService *OrganizationService, hasher *auth.PasswordHasher, m mailer.Mailer) *AccountService {
+	return &AccountService{
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		tokenService:  tokenService,
+		orgService:    orgService,
+		hasher:        hasher,
+		mailer:        m,
+		baseURL:       cfg.AppBaseURL,
+		verifyTTL:     cfg.EmailVerifyTTL,
+		resetTTL:      cfg.PasswordResetTTL,
+	}
+}
+
+// SendVerificationEmail mails a new verification link to the user. Links sent
+// earlier stop working.
+func (s *AccountService) SendVerificationEmail(user *models.User) error {
+	token, err := createUserToken(s.userTokenRepo, user.ID, models.UserTokenEmailVerification, s.verifyTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.",
+			user.FullName, s.link("/verify-email", token), s.verifyTTL),
+	})
+}
+
+// ResendVerification sends a new verification email to an unverified user
+func (s *AccountService) ResendVerification(userID uuid.UUID) error {
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return models.ErrEmailAlreadyVerified
+	}
+
+	return s.SendVerificationEmail(user)
+}
+
+// VerifyEmail confirms the address the token was sent to. Users that don't
+// belong to an organization yet get a personal one with its ChirpStack
+// resources, which are only provisioned once the address is confirmed.
+func (s *AccountService) VerifyEmail(token string) (*models.User, error) {
+	userID, err := s.userTokenRepo.ConsumeUserToken(auth.HashToken(token), models.UserTokenEmailVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.SetEmailVerified(userID.String()); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if s.orgService != nil {
+		if _, err := s.orgService.EnsurePersonalOrganization(user); err != nil {
+			// Log error but don't fail the verification
+			fmt.Printf("Warning: Failed to create organization for user %s: %v\n", user.Email, err)
+		}
+	}
+
+	return user, nil
+}
+
+// RequestPasswordReset mails a password reset link. It does not report whether
+// the address belongs to an account.
+func (s *AccountService) RequestPasswordReset(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || user.SuspendedAt != nil {
+		return nil
+	}
+
+	token, err := createUserToken(s.userTokenRepo, user.ID, models.UserTokenPasswordReset, s.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account. Open the link below to choose a new password:\n\n%s\n\nThe link expires in %s. If you did not request this, you can ignore this email.",
+			user.FullName, s.link("/reset-password", token), s.resetTTL),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and ends all sessions
+func (s *AccountService) ResetPassword(token, password string) error {
+	userID, err := s.userTokenRepo.ConsumeUserToken(auth.HashToken(token), models.UserTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.userRepo.UpdateUser(userID.String(), map[string]interface{}{"password_hash": hashedPassword}); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := s.tokenService.LogoutAll(userID); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	return nil
+}
+
+// createUserToken stores a new single-use token and returns its plain value
+func createUserToken(userTokenRepo *repository.UserTokenRepository, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	plain, hash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+	if err := userTokenRepo.CreateUserToken(token); err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
+
+func (s *AccountService) link(path, token string) string {
+	return s.baseURL + path + "?token=" + url.QueryEscape(token)
+}
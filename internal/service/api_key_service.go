@@ -0,0 +1,168 @@
+package service
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyService struct {
+	apiKeyRepo *repository.APIKeyRepository
+	userRepo   *repository.UserRepository
+}
+
+func NewAPIKeyService(apiKeyRepo *repository.APIKeyRepository, userRepo *repository.UserRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// CreateAPIKey issues a new key. Personal keys belong to the caller, service
+// keys can only be issued by admins and belong to req.UserID (or the admin).
+// The requested scopes must be allowed for the role of the key owner.
+func (s *APIKeyService) CreateAPIKey(callerID uuid.UUID, callerRole string, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	kind := req.Kind
+	if kind == "" {
+		kind = models.APIKeyKindPersonal
+	}
+
+	ownerID := callerID
+	if kind == models.APIKeyKindService {
+		if callerRole != models.RoleAdmin {
+			return nil, fmt.Errorf("only admins can create service keys")
+		}
+		if req.UserID != nil {
+			ownerID = *req.UserID
+		}
+	} else if req.UserID != nil && *req.UserID != callerID {
+		return nil, fmt.Errorf("personal keys can only be created for yourself")
+	}
+
+	owner, err := s.userRepo.GetUserByID(ownerID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	for _, scope := range req.Scopes {
+		if !hasScope(models.RoleScopes[owner.Role], scope) {
+			return nil, fmt.Errorf("scope %s is not allowed for role %s", scope, owner.Role)
+		}
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+
+	plain, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.APIKey{
+		UserID:    ownerID,
+		CreatedBy: &callerID,
+		Name:      req.Name,
+		Kind:      kind,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if key.ExpiresAt != nil {
+		expiresAt := key.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.apiKeyRepo.CreateAPIKey(key); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateAPIKeyResponse{APIKey: *key, Key: plain}, nil
+}
+
+// GetAPIKeys lists the keys a user owns or has created
+func (s *APIKeyService) GetAPIKeys(userID uuid.UUID) (*models.APIKeyListResponse, error) {
+	keys, err := s.apiKeyRepo.GetAPIKeysForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APIKeyListResponse{APIKeys: keys, Total: len(keys)}, nil
+}
+
+// RevokeAPIKey revokes a key owned or created by the caller. Admins can revoke any key.
+func (s *APIKeyService) RevokeAPIKey(callerID uuid.UUID, callerRole string, id uuid.UUID) error {
+	key, err := s.apiKeyRepo.GetAPIKeyByID(id)
+	if err != nil {
+		return err
+	}
+
+	isCreator := key.CreatedBy != nil && *key.CreatedBy == callerID
+	if callerRole != models.RoleAdmin && key.UserID != callerID && !isCreator {
+		return models.ErrAPIKeyNotFound
+	}
+
+	return s.apiKeyRepo.RevokeAPIKey(id)
+}
+
+// AuthenticateAPIKey resolves a presented key to the user it acts for
+func (s *APIKeyService) AuthenticateAPIKey(plain string) (*models.APIKeyPrincipal, error) {
+	prefix, ok := auth.ParseAPIKey(plain)
+	if !ok {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(auth.HashToken(plain))) != 1 {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetUserByID(key.UserID.String())
+	if err != nil || user.SuspendedAt != nil {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepo.TouchAPIKey(key.ID); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	// Scopes cannot outgrow the owner's current role
+	scopes := []string{}
+	for _, scope := range key.Scopes {
+		if hasScope(models.RoleScopes[user.Role], scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &models.APIKeyPrincipal{
+		KeyID:  key.ID,
+		UserID: user.ID,
+		Email:  user.Email,
+		Role:   user.Role,
+		Scopes: scopes,
+	}, nil
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+)
+
+// CodecRolloutRunner runs the rollout and rollback jobs of codec versions.
+// Every target is updated at most once: its state is stored after each
+// profile, so a job retried after an outage continues with the profiles still
+// pending. The script a profile had before is stored before it is replaced.
+type CodecRolloutRunner struct {
+	store      interfaces.CodecRolloutStore
+	chirpStack interfaces.ChirpStackClient
+}
+
+// NewCodecRolloutRunner creates the runner. chirpStack is nil when the
+// ChirpStack integration is disabled.
+func NewCodecRolloutRunner(store interfaces.CodecRolloutStore, chirpStack interfaces.ChirpStackClient) *CodecRolloutRunner {
+	return &CodecRolloutRunner{store: store, chirpStack: chirpStack}
+}
+
+// RunRolloutJob pushes the rollout's version to its pending targets. When a
+// target fails and the rollout asked for it, the updated targets are rolled
+// back right away.
+func (r *CodecRolloutRunner) RunRolloutJob(job *models.Job) error {
+	rollout, err := r.loadRollout(job)
+	if err != nil {
+		return err
+	}
+
+	switch rollout.Status {
+	case models.RolloutRunning:
+	case models.RolloutRollingBack:
+		// An earlier attempt failed while rolling back
+		return r.rollback(rollout)
+	default:
+		return nil
+	}
+
+	version, err := r.store.GetCodecVersionByID(rollout.CodecVersionID)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i := range rollout.Targets {
+		target := &rollout.Targets[i]
+		if target.Status == models.RolloutTargetPending {
+			if err := r.push(target, version.Script); err != nil {
+				return err
+			}
+		}
+		if target.Status == models.RolloutTargetFailed {
+			failed++
+		}
+	}
+
+	if failed == 0 {
+		return r.store.SetRolloutStatus(rollout.ID, models.RolloutSucceeded)
+	}
+	if !rollout.RollbackOnFailure {
+		return r.store.SetRolloutStatus(rollout.ID, models.RolloutFailed)
+	}
+
+	fmt.Printf("Warning: Codec rollout %s failed on %d device profiles, rolling back\n", rollout.ID, failed)
+	if err := r.store.SetCodecVersionStatus(version.ID, models.CodecVersionRolledBack); err != nil {
+		return err
+	}
+	if err := r.store.SetRolloutStatus(rollout.ID, models.RolloutRollingBack); err != nil {
+		return err
+	}
+	return r.rollback(rollout)
+}
+
+// RunRollbackJob restores the scripts the rollout replaced
+func (r *CodecRolloutRunner) RunRollbackJob(job *models.Job) error {
+	rollout, err := r.loadRollout(job)
+	if err != nil {
+		return err
+	}
+	if rollout.Status != models.RolloutRollingBack {
+		return nil
+	}
+	return r.rollback(rollout)
+}
+
+func (r *CodecRolloutRunner) loadRollout(job *models.Job) (*models.CodecRollout, error) {
+	if r.chirpStack == nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrJobPermanent, models.ErrChirpStackDisabled)
+	}
+
+	var payload models.CodecRolloutPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("%w: invalid payload: %v", models.ErrJobPermanent, err)
+	}
+
+	rollout, err := r.store.GetRollout(payload.RolloutID)
+	if errors.Is(err, models.ErrRolloutNotFound) {
+		return nil, fmt.Errorf("%w: %v", models.ErrJobPermanent, err)
+	}
+	return rollout, err
+}
+
+// push sets the script of one target's device profile
+func (r *CodecRolloutRunner) push(target *models.CodecRolloutTarget, script string) error {
+	profile, err := r.chirpStack.GetDeviceProfile(target.DeviceProfileID)
+	if err != nil {
+		return r.settle(target, models.RolloutTargetUpdated, err)
+	}
+
+	// A retry finds the new script in place of the one to keep
+	if target.PreviousScript == nil {
+		runtime, previous := profile.PayloadCodecRuntime, profile.PayloadCodecScript
+		target.PreviousRuntime, target.PreviousScript = &runtime, &previous
+		if err := r.store.UpdateRolloutTarget(target); err != nil {
+			return err
+		}
+	}
+
+	profile.PayloadCodecRuntime = "JS"
+	profile.PayloadCodecScript = script
+	return r.settle(target, models.RolloutTargetUpdated, r.chirpStack.UpdateDeviceProfile(target.DeviceProfileID, *profile))
+}
+
+// rollback restores the updated targets and finishes the rollout
+func (r *CodecRolloutRunner) rollback(rollout *models.CodecRollout) error {
+	for i := range rollout.Targets {
+		target := &rollout.Targets[i]
+		if target.Status != models.RolloutTargetUpdated || target.PreviousScript == nil {
+			continue
+		}
+
+		profile, err := r.chirpStack.GetDeviceProfile(target.DeviceProfileID)
+		switch {
+		case errors.Is(err, chirpstack.ErrNotFound):
+			// Nothing left to restore
+			err = nil
+		case err == nil:
+			profile.PayloadCodecRuntime = *target.PreviousRuntime
+			profile.PayloadCodecScript = *target.PreviousScript
+			err = r.chirpStack.UpdateDeviceProfile(target.DeviceProfileID, *profile)
+		}
+		if err != nil {
+			err = fmt.Errorf("rollback failed: %w", err)
+		}
+		if err := r.settle(target, models.RolloutTargetRolledBack, err); err != nil {
+			return err
+		}
+	}
+
+	return r.store.SetRolloutStatus(rollout.ID, models.RolloutRolledBack)
+}
+
+// settle stores the outcome of a ChirpStack call on a target. Outages are
+// returned so the job is retried, other errors fail the target.
+func (r *CodecRolloutRunner) settle(target *models.CodecRolloutTarget, status string, err error) error {
+	if errors.Is(err, chirpstack.ErrUnavailable) {
+		return err
+	}
+
+	target.Status = status
+	target.Error = nil
+	if err != nil {
+		message := err.Error()
+		target.Status = models.RolloutTargetFailed
+		target.Error = &message
+	}
+	return r.store.UpdateRolloutTarget(target)
+}
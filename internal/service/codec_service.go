@@ -0,0 +1,151 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// decodeUplinkFunc matches the entry point ChirpStack calls on JS codecs
+var decodeUplinkFunc = regexp.MustCompile(`function\s+decodeUplink\s*\(`)
+
+// CodecService manages payload codecs. Publishing a version rolls it out to
+// the ChirpStack device profiles using the codec in a background job.
+type CodecService struct {
+	codecRepo  *repository.CodecRepository
+	chirpStack interfaces.ChirpStackClient
+}
+
+// NewCodecService creates the service. chirpStack is nil when the ChirpStack
+// integration is disabled.
+func NewCodecService(codecRepo *repository.CodecRepository, chirpStack interfaces.ChirpStackClient) *CodecService {
+	return &CodecService{
+		codecRepo:  codecRepo,
+		chirpStack: chirpStack,
+	}
+}
+
+// EnsureDefaultCodec creates the default codec from the built-in Lnode script
+// on a fresh database
+func (s *CodecService) EnsureDefaultCodec() error {
+	description := "Lnode payload codec of the default device profiles"
+	codec := &models.Codec{Name: "lnode", Description: &description}
+
+	created, err := s.codecRepo.EnsureDefaultCodec(codec, chirpstack.DefaultProfileTemplate().CodecScript, "Built-in Lnode codec")
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Printf("Created default codec %s from the built-in script\n", codec.ID)
+	}
+	return nil
+}
+
+func (s *CodecService) CreateCodec(req *models.CreateCodecRequest) (*models.Codec, error) {
+	codec := &models.Codec{Name: req.Name, Description: req.Description}
+	if err := s.codecRepo.CreateCodec(codec); err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+func (s *CodecService) GetCodecs() ([]models.Codec, error) {
+	return s.codecRepo.GetCodecs()
+}
+
+// GetCodecVersions returns a codec with all its versions
+func (s *CodecService) GetCodecVersions(codecID uuid.UUID) (*models.CodecVersionListResponse, error) {
+	codec, err := s.codecRepo.GetCodecByID(codecID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.codecRepo.GetCodecVersions(codecID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CodecVersionListResponse{Codec: *codec, Versions: versions}, nil
+}
+
+// CreateCodecVersion adds a draft. Drafts reach device profiles once published.
+func (s *CodecService) CreateCodecVersion(codecID, userID uuid.UUID, req *models.CreateCodecVersionRequest) (*models.CodecVersion, error) {
+	if !decodeUplinkFunc.MatchString(req.Script) {
+		return nil, models.ErrInvalidCodecScript
+	}
+
+	version := &models.CodecVersion{
+		CodecID:   codecID,
+		Script:    req.Script,
+		Changelog: req.Changelog,
+		AuthorID:  &userID,
+	}
+	if err := s.codecRepo.CreateCodecVersion(version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+func (s *CodecService) GetCodecVersion(codecID uuid.UUID, version int) (*models.CodecVersion, error) {
+	return s.codecRepo.GetCodecVersion(codecID, version)
+}
+
+// PublishCodecVersion publishes a draft and queues its rollout to the device
+// profiles of every tenant using the codec. Only one rollout of a codec runs
+// at a time.
+func (s *CodecService) PublishCodecVersion(codecID uuid.UUID, number int, userID uuid.UUID, req *models.PublishCodecVersionRequest) (*models.CodecRollout, error) {
+	if s.chirpStack == nil {
+		return nil, models.ErrChirpStackDisabled
+	}
+
+	codec, err := s.codecRepo.GetCodecByID(codecID)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.codecRepo.GetCodecVersion(codecID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	rollout := &models.CodecRollout{RollbackOnFailure: req.RollbackOnFailure, StartedBy: &userID}
+	job := &models.Job{Type: models.JobCodecRollout}
+	if err := s.codecRepo.PublishCodecVersion(codec, version, rollout, job); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
+
+func (s *CodecService) GetCodecRollouts(codecID uuid.UUID) ([]models.CodecRollout, error) {
+	if _, err := s.codecRepo.GetCodecByID(codecID); err != nil {
+		return nil, err
+	}
+	return s.codecRepo.GetCodecRollouts(codecID)
+}
+
+func (s *CodecService) GetRollout(id uuid.UUID) (*models.CodecRollout, error) {
+	return s.codecRepo.GetRollout(id)
+}
+
+// RollbackRollout queues restoring the scripts a finished rollout replaced.
+// Its version stops being handed to new device profiles.
+func (s *CodecService) RollbackRollout(id uuid.UUID) (*models.CodecRollout, error) {
+	if s.chirpStack == nil {
+		return nil, models.ErrChirpStackDisabled
+	}
+
+	rollout, err := s.codecRepo.GetRollout(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.codecRepo.StartRollback(rollout, &models.Job{Type: models.JobCodecRollback}); err != nil {
+		return nil, err
+	}
+	return rollout, nil
+}
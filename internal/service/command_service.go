@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"math"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/lnode"
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CommandService sends commands to the lamps through the ChirpStack device
+// queue and keeps a record of them. Their status follows the txack and ack
+// events of the integration.
+type CommandService struct {
+	devices  interfaces.DeviceControl
+	commands interfaces.CommandStore
+	queue    interfaces.CommandQueue
+	protocol lnode.CommandSet
+}
+
+// NewCommandService creates the service. queue is nil when the ChirpStack
+// integration is disabled, protocol is the port and opcodes of the commands.
+func NewCommandService(devices interfaces.DeviceControl, commands interfaces.CommandStore, queue interfaces.CommandQueue, protocol lnode.CommandSet) *CommandService {
+	return &CommandService{devices: devices, commands: commands, queue: queue, protocol: protocol}
+}
+
+// SendCommand encodes a command, records it and enqueues it for the device.
+// Sending takes the member role in the device's organization.
+func (s *CommandService) SendCommand(deviceID, userID uuid.UUID, role string, req *models.SendCommandRequest) (*models.DeviceCommand, error) {
+	device, err := s.devices.GetDeviceForUpdate(deviceID, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := EncodeCommand(s.protocol, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.queue == nil {
+		return nil, models.ErrChirpStackDisabled
+	}
+	if !device.ChirpStackDeviceCreated {
+		return nil, models.ErrDeviceNotProvisioned
+	}
+
+	command := &models.DeviceCommand{
+		DeviceID:  device.ID,
+		Type:      req.Type,
+		Data:      data,
+		FPort:     s.protocol.FPort,
+		Confirmed: req.Confirmed,
+		SentBy:    &userID,
+	}
+	switch req.Type {
+	case models.CommandSetDimming:
+		command.Level = req.Level
+	case models.CommandSwitch:
+		command.On = req.On
+	}
+
+	// The command is recorded before it is enqueued, a txack arriving before
+	// its queue item is stored is applied along with it
+	if err := s.commands.CreateCommand(command); err != nil {
+		return nil, err
+	}
+
+	queueItemID, err := s.queue.EnqueueDownlink(models.ChirpStackQueueItem{
+		DevEUI:    device.DevEUI,
+		Confirmed: req.Confirmed,
+		FPort:     s.protocol.FPort,
+		Data:      data,
+	})
+	if err != nil {
+		if err := s.commands.DeleteCommand(command.ID); err != nil {
+			fmt.Printf("Warning: Failed to delete command %s that wasn't enqueued: %v\n", command.ID, err)
+		}
+		return nil, err
+	}
+
+	if err := s.commands.SetCommandQueueItem(command, queueItemID); err != nil {
+		return nil, fmt.Errorf("command was enqueued as %s but not recorded: %w", queueItemID, err)
+	}
+
+	return command, nil
+}
+
+// GetDeviceCommands lists the commands sent to a device the user can see
+func (s *CommandService) GetDeviceCommands(deviceID, userID uuid.UUID, role string, page, pageSize int) (*models.DeviceCommandListResponse, error) {
+	if _, err := s.devices.GetDeviceByID(deviceID, userID, role); err != nil {
+		return nil, err
+	}
+
+	commands, total, err := s.commands.GetDeviceCommands(deviceID, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DeviceCommandListResponse{
+		Commands:   commands,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(pageSize))),
+	}, nil
+}
+
+// EncodeCommand returns the frame of a command with the opcodes of protocol
+func EncodeCommand(protocol lnode.CommandSet, req *models.SendCommandRequest) ([]byte, error) {
+	switch req.Type {
+	case models.CommandSetDimming:
+		if req.Level == nil {
+			return nil, fmt.Errorf("%w: set_dimming needs a level", models.ErrInvalidCommand)
+		}
+		data, err := protocol.DimmingCommand(*req.Level)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", models.ErrInvalidCommand, err)
+		}
+		return data, nil
+	case models.CommandSwitch:
+		if req.On == nil {
+			return nil, fmt.Errorf("%w: switch needs on", models.ErrInvalidCommand)
+		}
+		return protocol.SwitchCommand(*req.On), nil
+	case models.CommandRequestStatus:
+		return protocol.StatusRequestCommand(), nil
+	case models.CommandRequestGPS:
+		return protocol.GPSRequestCommand(), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", models.ErrInvalidCommand, req.Type)
+	}
+}
@@ -1,9 +1,13 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"strings"
 
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
 	"go-auth-api/internal/models"
 	"go-auth-api/internal/repository"
 
@@ -11,25 +15,40 @@ import (
 )
 
 type DeviceService struct {
-	deviceRepo        *repository.DeviceRepository
-	userRepo          *repository.UserRepository
-	chirpStackService *ChirpStackService
+	deviceRepo *repository.DeviceRepository
+	userRepo   *repository.UserRepository
+	orgService *OrganizationService
+	chirpStack interfaces.ChirpStackClient
+	codecs     interfaces.CodecScripts
 }
 
-func NewDeviceService(deviceRepo *repository.DeviceRepository, userRepo *repository.UserRepository, chirpStackService *ChirpStackService) *DeviceService {
+// NewDeviceService creates the service. chirpStack is nil when the ChirpStack
+// integration is disabled, codecs supplies the codecs device versions attach.
+func NewDeviceService(deviceRepo *repository.DeviceRepository, userRepo *repository.UserRepository, orgService *OrganizationService, chirpStack interfaces.ChirpStackClient, codecs interfaces.CodecScripts) *DeviceService {
 	return &DeviceService{
-		deviceRepo:        deviceRepo,
-		userRepo:          userRepo,
-		chirpStackService: chirpStackService,
+		deviceRepo: deviceRepo,
+		userRepo:   userRepo,
+		orgService: orgService,
+		chirpStack: chirpStack,
+		codecs:     codecs,
 	}
 }
 
 // Device Version methods
 func (s *DeviceService) CreateDeviceVersion(req *models.CreateDeviceVersionRequest) (*models.DeviceVersion, error) {
 	version := &models.DeviceVersion{
-		Name:        req.Name,
-		Version:     req.Version,
-		Description: req.Description,
+		Name:            req.Name,
+		Version:         req.Version,
+		Description:     req.Description,
+		ProfileTemplate: req.ProfileTemplate,
+		CodecID:         req.CodecID,
+	}
+
+	if err := s.validateCodec(version.CodecID); err != nil {
+		return nil, err
+	}
+	if err := validateProfileTemplate(version.ProfileTemplate, version.CodecID != nil); err != nil {
+		return nil, err
 	}
 
 	err := s.deviceRepo.CreateDeviceVersion(version)
@@ -61,10 +80,65 @@ func (s *DeviceService) GetDeviceVersions(page, pageSize int) (*models.DeviceVer
 	}, nil
 }
 
+// UpdateDeviceVersion updates a device version. A new profile template or
+// codec is used for the tenants that create the version's profile from then
+// on, profiles already created get a codec with its next rollout.
 func (s *DeviceService) UpdateDeviceVersion(id uuid.UUID, req *models.UpdateDeviceVersionRequest) error {
+	if err := s.validateCodec(req.CodecID); err != nil {
+		return err
+	}
+
+	withCodec := req.CodecID != nil
+	if req.ProfileTemplate != nil && !withCodec {
+		version, err := s.deviceRepo.GetDeviceVersionByID(id)
+		if err != nil {
+			return err
+		}
+		withCodec = version.CodecID != nil
+	}
+	if err := validateProfileTemplate(req.ProfileTemplate, withCodec); err != nil {
+		return err
+	}
 	return s.deviceRepo.UpdateDeviceVersion(id, req)
 }
 
+// validateCodec checks that a codec attached to a device version has a
+// published version to create device profiles with
+func (s *DeviceService) validateCodec(codecID *uuid.UUID) error {
+	if codecID == nil {
+		return nil
+	}
+	codec, err := s.codecs.GetCodecByID(*codecID)
+	if errors.Is(err, models.ErrCodecNotFound) {
+		return fmt.Errorf("%w: %v", models.ErrInvalidProfileTemplate, err)
+	}
+	if err != nil {
+		return err
+	}
+	if codec.PublishedVersion == nil {
+		return fmt.Errorf("%w: codec %s has no published version", models.ErrInvalidProfileTemplate, codec.Name)
+	}
+	return nil
+}
+
+// validateProfileTemplate checks what the request bindings can't. Templates
+// of versions with a codec get the codec's script.
+func validateProfileTemplate(t *models.DeviceProfileTemplate, withCodec bool) error {
+	if t == nil {
+		return nil
+	}
+	if err := chirpstack.ValidateProfileTemplate(*t); err != nil {
+		return fmt.Errorf("%w: %v", models.ErrInvalidProfileTemplate, err)
+	}
+	if t.CodecRuntime == "JS" && strings.TrimSpace(t.CodecScript) == "" && !withCodec {
+		return fmt.Errorf("%w: the JS codec runtime needs codec_script", models.ErrInvalidProfileTemplate)
+	}
+	if t.CodecRuntime != "JS" && t.CodecScript != "" {
+		return fmt.Errorf("%w: codec_script is only used by the JS codec runtime", models.ErrInvalidProfileTemplate)
+	}
+	return nil
+}
+
 func (s *DeviceService) DeleteDeviceVersion(id uuid.UUID) error {
 	return s.deviceRepo.DeleteDeviceVersion(id)
 }
@@ -72,11 +146,24 @@ func (s *DeviceService) DeleteDeviceVersion(id uuid.UUID) error {
 // Allowed Device methods
 func (s *DeviceService) CreateAllowedDevice(req *models.CreateAllowedDeviceRequest) (*models.AllowedDevice, error) {
 	device := &models.AllowedDevice{
-		DevEUI:      req.DevEUI,
-		NwkKey:      req.NwkKey,
-		AppKey:      req.AppKey,
-		AddrKey:     req.AddrKey,
-		Description: req.Description,
+		DevEUI:         req.DevEUI,
+		ActivationMode: req.ActivationMode,
+		JoinEUI:        req.JoinEUI,
+		NwkKey:         req.NwkKey,
+		AppKey:         req.AppKey,
+		AddrKey:        req.AddrKey,
+		Description:    req.Description,
+	}
+	if device.ActivationMode == "" {
+		device.ActivationMode = models.ActivationABP
+	}
+	// LoRaWAN 1.0 OTAA devices have a single root key
+	if device.ActivationMode == models.ActivationOTAA && device.NwkKey == "" {
+		device.NwkKey = device.AppKey
+	}
+
+	if err := validateActivation(device); err != nil {
+		return nil, err
 	}
 
 	err := s.deviceRepo.CreateAllowedDevice(device)
@@ -109,124 +196,404 @@ func (s *DeviceService) GetAllowedDevices(page, pageSize int) (*models.AllowedDe
 }
 
 func (s *DeviceService) UpdateAllowedDevice(devEUI string, req *models.UpdateAllowedDeviceRequest) error {
+	// Check the activation settings the device ends up with
+	if req.ActivationMode != nil || req.JoinEUI != nil || req.AddrKey != nil {
+		device, err := s.deviceRepo.GetAllowedDeviceByDevEUI(devEUI)
+		if err != nil {
+			return err
+		}
+		if req.ActivationMode != nil {
+			device.ActivationMode = *req.ActivationMode
+		}
+		if req.JoinEUI != nil {
+			device.JoinEUI = req.JoinEUI
+		}
+		if req.AddrKey != nil {
+			device.AddrKey = *req.AddrKey
+		}
+		if err := validateActivation(device); err != nil {
+			return err
+		}
+	}
+
 	return s.deviceRepo.UpdateAllowedDevice(devEUI, req)
 }
 
+// validateActivation checks that an allowed device has what its activation
+// mode needs
+func validateActivation(device *models.AllowedDevice) error {
+	switch device.ActivationMode {
+	case models.ActivationABP:
+		if device.AddrKey == "" || device.NwkKey == "" {
+			return fmt.Errorf("%w: ABP devices need addr_key and nwk_key", models.ErrInvalidActivation)
+		}
+	case models.ActivationOTAA:
+		if device.JoinEUI == nil {
+			return fmt.Errorf("%w: OTAA devices need join_eui", models.ErrInvalidActivation)
+		}
+	default:
+		return fmt.Errorf("%w: unknown activation mode %q", models.ErrInvalidActivation, device.ActivationMode)
+	}
+	return nil
+}
+
 func (s *DeviceService) DeleteAllowedDevice(devEUI string) error {
 	return s.deviceRepo.DeleteAllowedDevice(devEUI)
 }
 
 // Device methods
-func (s *DeviceService) CreateDevice(userID uuid.UUID, req *models.CreateDeviceRequest) (*models.Device, error) {
-	// Check if user exists and has ChirpStack data
+func (s *DeviceService) CreateDevice(userID uuid.UUID, role string, req *models.CreateDeviceRequest) (*models.Device, error) {
 	user, err := s.userRepo.GetUserByID(userID.String())
 	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
-	if user.ApplicationID == nil || user.DeviceProfileID == nil {
-		return nil, fmt.Errorf("user does not have ChirpStack application or device profile")
+	if user.EmailVerifiedAt == nil {
+		return nil, models.ErrEmailNotVerified
+	}
+
+	// Devices are added to an organization the user can manage devices in
+	org, err := s.orgService.DeviceOrganization(req.OrganizationID, userID, role)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if devEUI exists in allowed devices
-	allowedDevice, err := s.deviceRepo.GetAllowedDeviceByDevEUI(req.DevEUI)
+	_, err = s.deviceRepo.GetAllowedDeviceByDevEUI(req.DevEUI)
 	if err != nil {
 		return nil, fmt.Errorf("device with devEUI %s not found in allowed devices", req.DevEUI)
 	}
 
 	// Check if version exists
-	_, err = s.deviceRepo.GetDeviceVersionByID(req.VersionID)
+	version, err := s.deviceRepo.GetDeviceVersionByID(req.VersionID)
 	if err != nil {
 		return nil, fmt.Errorf("device version not found: %w", err)
 	}
 
+	// Hardware built for one region can't join the network of another
+	if t := version.ProfileTemplate; t != nil && t.Region != "" && t.Region != org.Region {
+		return nil, fmt.Errorf("%w: %s %s is built for %s, the organization uses %s",
+			models.ErrRegionMismatch, version.Name, version.Version, t.Region, org.Region)
+	}
+
 	// Create device in database
 	device := &models.Device{
-		UserID:      userID,
-		VersionID:   req.VersionID,
-		Name:        req.Name,
-		DevEUI:      req.DevEUI,
-		Description: req.Description,
+		OrganizationID: org.ID,
+		UserID:         &userID,
+		VersionID:      req.VersionID,
+		Name:           req.Name,
+		DevEUI:         req.DevEUI,
+		Description:    req.Description,
 	}
 
-	err = s.deviceRepo.CreateDevice(device)
+	// The device is created in ChirpStack by a background job, queued
+	// together with the device row
+	var job *models.Job
+	if s.chirpStack != nil {
+		job = &models.Job{Type: models.JobProvisionDevice}
+	}
+
+	err = s.deviceRepo.CreateDevice(device, job)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create device: %w", err)
 	}
 
-	// Create device in ChirpStack if service is enabled
-	if s.chirpStackService != nil && s.chirpStackService.IsEnabled() {
-		err = s.createChirpStackDevice(device, user, allowedDevice)
-		if err != nil {
-			fmt.Printf("Warning: Failed to create ChirpStack device: %v\n", err)
+	// Get the created device with version info
+	created, err := s.deviceRepo.GetDeviceByID(device.ID)
+	if err != nil {
+		return nil, err
+	}
+	if job != nil {
+		created.ProvisioningJobID = &job.ID
+	}
+	return created, nil
+}
+
+// RunProvisioningJob handles JobProvisionDevice: it provisions the
+// organization if needed, then creates and activates the device in
+// ChirpStack. Steps recorded as done on the device are skipped on retries.
+func (s *DeviceService) RunProvisioningJob(job *models.Job) error {
+	if job.DeviceID == nil {
+		return fmt.Errorf("%w: job has no device", models.ErrJobPermanent)
+	}
+
+	device, err := s.deviceRepo.GetDeviceByID(*job.DeviceID)
+	if errors.Is(err, models.ErrDeviceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.chirpStack == nil || device.ChirpStackDeviceActivated {
+		return nil
+	}
+
+	org, err := s.orgService.GetOrganizationByID(device.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if err := s.orgService.EnsureResources(org); err != nil {
+		if org.ProvisioningStatus == models.ProvisioningFailed {
+			return fmt.Errorf("%w: %v", models.ErrJobPermanent, err)
 		}
+		return err
 	}
 
-	// Get the created device with version info
-	return s.deviceRepo.GetDeviceByID(device.ID)
+	allowedDevice, err := s.deviceRepo.GetAllowedDeviceByDevEUI(device.DevEUI)
+	if err != nil {
+		return fmt.Errorf("%w: device with devEUI %s not found in allowed devices", models.ErrJobPermanent, device.DevEUI)
+	}
+
+	err = s.createChirpStackDevice(device, org, allowedDevice)
+	if errors.Is(err, chirpstack.ErrBadRequest) {
+		return fmt.Errorf("%w: %v", models.ErrJobPermanent, err)
+	}
+	return err
 }
 
-func (s *DeviceService) createChirpStackDevice(device *models.Device, user *models.User, allowedDevice *models.AllowedDevice) error {
-	// Create device in ChirpStack
-	createReq := models.ChirpStackCreateDeviceRequest{
-		Device: models.ChirpStackDeviceInfo{
-			ApplicationID:   *user.ApplicationID,
+func (s *DeviceService) createChirpStackDevice(device *models.Device, org *models.Organization, allowedDevice *models.AllowedDevice) error {
+	otaa := allowedDevice.ActivationMode == models.ActivationOTAA
+	joinEUI := "0000000000000000"
+	if otaa && allowedDevice.JoinEUI != nil {
+		joinEUI = *allowedDevice.JoinEUI
+	}
+
+	if !device.ChirpStackDeviceCreated {
+		profileID, err := s.deviceProfileID(device, org, allowedDevice.ActivationMode)
+		if err != nil {
+			return err
+		}
+
+		// Create device in ChirpStack. It already exists when an earlier
+		// attempt got this far without recording it.
+		err = s.chirpStack.CreateDevice(models.ChirpStackDeviceInfo{
+			ApplicationID:   *org.ApplicationID,
 			Description:     device.Name,
 			DevEUI:          device.DevEUI,
-			DeviceProfileID: *user.DeviceProfileID,
+			DeviceProfileID: profileID,
 			IsDisabled:      false,
-			JoinEUI:         "0000000000000000",
+			JoinEUI:         joinEUI,
 			Name:            device.Name,
 			SkipFcntCheck:   true,
 			Tags:            make(map[string]string),
 			Variables:       make(map[string]string),
-		},
-	}
+		})
+		switch {
+		case err == nil:
+			fmt.Printf("ChirpStack device created: %s\n", device.DevEUI)
+		case errors.Is(err, chirpstack.ErrConflict):
+			if err := s.adoptChirpStackDevice(device, org); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
 
-	responseBody, err := s.chirpStackService.makeRequest("POST", "/devices", createReq)
-	if err != nil {
-		return fmt.Errorf("failed to create ChirpStack device: %w", err)
+		if err := s.deviceRepo.UpdateDeviceChirpStackStatus(device.ID, true, false); err != nil {
+			return fmt.Errorf("failed to update device ChirpStack status: %w", err)
+		}
 	}
 
-	fmt.Printf("ChirpStack device created: %s\n", string(responseBody))
+	if otaa {
+		return s.setChirpStackRootKeys(device, allowedDevice)
+	}
 
 	// Activate device in ChirpStack
-	activateReq := models.ChirpStackActivateDeviceRequest{
-		DeviceActivation: models.ChirpStackDeviceActivation{
-			AFCntDown:   0,
-			AppSKey:     allowedDevice.AppKey,
-			DevAddr:     allowedDevice.AddrKey,
-			FCntUp:      0,
-			FNwkSIntKey: allowedDevice.NwkKey,
-			NFCntDown:   0,
-			NwkSEncKey:  allowedDevice.NwkKey,
-			SNwkSIntKey: allowedDevice.NwkKey,
-		},
-	}
-
-	activateURL := fmt.Sprintf("/devices/%s/activate", device.DevEUI)
-	responseBody, err = s.chirpStackService.makeRequest("POST", activateURL, activateReq)
+	err := s.chirpStack.ActivateDevice(device.DevEUI, models.ChirpStackDeviceActivation{
+		AFCntDown:   0,
+		AppSKey:     allowedDevice.AppKey,
+		DevAddr:     allowedDevice.AddrKey,
+		FCntUp:      0,
+		FNwkSIntKey: allowedDevice.NwkKey,
+		NFCntDown:   0,
+		NwkSEncKey:  allowedDevice.NwkKey,
+		SNwkSIntKey: allowedDevice.NwkKey,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to activate ChirpStack device: %w", err)
+		return err
 	}
 
-	fmt.Printf("ChirpStack device activated: %s\n", string(responseBody))
+	fmt.Printf("ChirpStack device activated: %s\n", device.DevEUI)
 
 	// Update device status in database
-	err = s.deviceRepo.UpdateDeviceChirpStackStatus(device.ID, true, true)
+	if err := s.deviceRepo.UpdateDeviceChirpStackStatus(device.ID, true, true); err != nil {
+		return fmt.Errorf("failed to update device ChirpStack status: %w", err)
+	}
+
+	return nil
+}
+
+// adoptChirpStackDevice takes over a device that already exists in
+// ChirpStack. DevEUIs are unique across ChirpStack, so the device is only
+// adopted when it is in the organization's own application.
+func (s *DeviceService) adoptChirpStackDevice(device *models.Device, org *models.Organization) error {
+	existing, err := s.chirpStack.GetDevice(device.DevEUI)
 	if err != nil {
-		fmt.Printf("Warning: Failed to update device ChirpStack status: %v\n", err)
+		return fmt.Errorf("failed to get existing ChirpStack device: %w", err)
+	}
+	if existing.ApplicationID != *org.ApplicationID {
+		return fmt.Errorf("%w: device %s already exists in another ChirpStack application", models.ErrJobPermanent, device.DevEUI)
 	}
 
+	fmt.Printf("ChirpStack device adopted: %s\n", device.DevEUI)
 	return nil
 }
 
-func (s *DeviceService) GetDeviceByID(id uuid.UUID) (*models.Device, error) {
-	return s.deviceRepo.GetDeviceByID(id)
+// deviceProfileID returns the device profile a device is created with. Devices
+// of a version with a profile template or codec get the profile made from it,
+// which is created in the tenant with the first such device. Other devices use
+// the organization's default profiles.
+func (s *DeviceService) deviceProfileID(device *models.Device, org *models.Organization, mode string) (string, error) {
+	version, err := s.deviceRepo.GetDeviceVersionByID(device.VersionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get device version: %w", err)
+	}
+
+	if version.ProfileTemplate == nil && version.CodecID == nil {
+		profileID := org.DeviceProfileID
+		if mode == models.ActivationOTAA {
+			profileID = org.OTAADeviceProfileID
+		}
+		if profileID == nil {
+			return "", fmt.Errorf("%w: organization has no %s device profile", models.ErrJobPermanent, mode)
+		}
+		return *profileID, nil
+	}
+
+	profileID, err := s.deviceRepo.GetOrganizationDeviceProfile(org.ID, version.ID, mode)
+	if err != nil {
+		return "", fmt.Errorf("failed to get device profile: %w", err)
+	}
+	if profileID != "" {
+		return profileID, nil
+	}
+
+	template, err := s.profileTemplate(version)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s %s (%s)", version.Name, version.Version, strings.ToUpper(mode))
+	profileID, err = s.chirpStack.CreateDeviceProfile(
+		chirpstack.DeviceProfileFromTemplate(*org.TenantID, name, template, org.Region, mode == models.ActivationOTAA))
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("ChirpStack device profile %q created for organization %s\n", name, org.ID)
+
+	recorded, err := s.deviceRepo.AddOrganizationDeviceProfile(&models.OrganizationDeviceProfile{
+		OrganizationID:  org.ID,
+		DeviceVersionID: version.ID,
+		ActivationMode:  mode,
+		DeviceProfileID: profileID,
+	})
+	if err != nil || recorded != profileID {
+		// Not recorded, or another worker got there first
+		if delErr := s.chirpStack.DeleteDeviceProfile(profileID); delErr != nil {
+			fmt.Printf("Warning: Failed to delete unused device profile %s: %v\n", profileID, delErr)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to record device profile: %w", err)
+	}
+
+	return recorded, nil
 }
 
+// profileTemplate returns the template of a version's device profiles. The
+// latest published version of the version's codec replaces the template's
+// codec, versions with only a codec use the default template.
+func (s *DeviceService) profileTemplate(version *models.DeviceVersion) (models.DeviceProfileTemplate, error) {
+	template := chirpstack.DefaultProfileTemplate()
+	if version.ProfileTemplate != nil {
+		template = *version.ProfileTemplate
+	}
+	if version.CodecID == nil {
+		return template, nil
+	}
+
+	codecVersion, err := s.codecs.GetPublishedCodecVersion(*version.CodecID)
+	if errors.Is(err, models.ErrCodecVersionNotFound) {
+		// All versions rolled back, keep the template's script
+		if template.CodecRuntime == "JS" && template.CodecScript == "" {
+			template.CodecScript = chirpstack.DefaultProfileTemplate().CodecScript
+		}
+		return template, nil
+	}
+	if err != nil {
+		return template, fmt.Errorf("failed to get codec: %w", err)
+	}
+
+	template.CodecRuntime = "JS"
+	template.CodecScript = codecVersion.Script
+	return template, nil
+}
+
+// setChirpStackRootKeys stores the root keys of an OTAA device, which then
+// gets its session when it joins. ChirpStack expects the AppKey of a LoRaWAN
+// 1.0.x device in nwkKey.
+func (s *DeviceService) setChirpStackRootKeys(device *models.Device, allowedDevice *models.AllowedDevice) error {
+	err := s.chirpStack.CreateDeviceKeys(device.DevEUI, OTAARootKeys(allowedDevice))
+	if err != nil && !errors.Is(err, chirpstack.ErrConflict) {
+		return err
+	}
+
+	fmt.Printf("ChirpStack device keys set: %s\n", device.DevEUI)
+
+	if err := s.deviceRepo.UpdateDeviceChirpStackStatus(device.ID, true, true); err != nil {
+		return fmt.Errorf("failed to update device ChirpStack status: %w", err)
+	}
+
+	return nil
+}
+
+// OTAARootKeys returns the ChirpStack root keys of an OTAA device. Lnode
+// devices speak LoRaWAN 1.0.3, where the AppKey is the only root key.
+func OTAARootKeys(allowedDevice *models.AllowedDevice) models.ChirpStackDeviceKeys {
+	return models.ChirpStackDeviceKeys{
+		NwkKey: allowedDevice.AppKey,
+		AppKey: allowedDevice.AppKey,
+	}
+}
+
+// getDeviceForUser loads a device on behalf of a user. Admins can access any
+// device, everyone else needs at least the min role in the device's
+// organization. Devices of other organizations return ErrDeviceNotFound.
+func (s *DeviceService) getDeviceForUser(id, userID uuid.UUID, role, min string) (*models.Device, error) {
+	device, err := s.deviceRepo.GetDeviceByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == models.RoleAdmin {
+		return device, nil
+	}
+
+	if _, _, err := s.orgService.authorize(device.OrganizationID, userID, role, min); err != nil {
+		if errors.Is(err, models.ErrOrganizationNotFound) {
+			return nil, models.ErrDeviceNotFound
+		}
+		return nil, err
+	}
+
+	return device, nil
+}
+
+func (s *DeviceService) GetDeviceByID(id, userID uuid.UUID, role string) (*models.Device, error) {
+	return s.getDeviceForUser(id, userID, role, models.OrgRoleViewer)
+}
+
+// GetDeviceForUpdate loads a device the user may change or control, which
+// takes the member role in its organization
+func (s *DeviceService) GetDeviceForUpdate(id, userID uuid.UUID, role string) (*models.Device, error) {
+	return s.getDeviceForUser(id, userID, role, models.OrgRoleMember)
+}
+
+// GetDevicesByUserID lists the devices of all organizations the user belongs to
 func (s *DeviceService) GetDevicesByUserID(userID uuid.UUID, page, pageSize int) (*models.DeviceListResponse, error) {
-	devices, total, err := s.deviceRepo.GetDevicesByUserID(userID, page, pageSize)
+	devices, total, err := s.deviceRepo.GetDevicesForMember(userID, page, pageSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user devices: %w", err)
 	}
@@ -259,7 +626,12 @@ func (s *DeviceService) GetAllDevices(page, pageSize int) (*models.DeviceListRes
 	}, nil
 }
 
-func (s *DeviceService) UpdateDevice(id uuid.UUID, req *models.UpdateDeviceRequest) error {
+func (s *DeviceService) UpdateDevice(id, userID uuid.UUID, role string, req *models.UpdateDeviceRequest) error {
+	// Check organization access
+	if _, err := s.getDeviceForUser(id, userID, role, models.OrgRoleMember); err != nil {
+		return err
+	}
+
 	// Check if version exists if version_id is being updated
 	if req.VersionID != nil {
 		_, err := s.deviceRepo.GetDeviceVersionByID(*req.VersionID)
@@ -271,17 +643,18 @@ func (s *DeviceService) UpdateDevice(id uuid.UUID, req *models.UpdateDeviceReque
 	return s.deviceRepo.UpdateDevice(id, req)
 }
 
-func (s *DeviceService) DeleteDevice(id uuid.UUID) error {
+func (s *DeviceService) DeleteDevice(id, userID uuid.UUID, role string) error {
 	// Get device info before deleting
-	device, err := s.deviceRepo.GetDeviceByID(id)
+	device, err := s.getDeviceForUser(id, userID, role, models.OrgRoleMember)
 	if err != nil {
-		return fmt.Errorf("device not found: %w", err)
+		return err
 	}
 
-	// Delete device from ChirpStack if service is enabled and device was created in ChirpStack
-	if s.chirpStackService != nil && s.chirpStackService.IsEnabled() && device.ChirpStackDeviceCreated {
-		err = s.chirpStackService.DeleteDevice(device.DevEUI)
-		if err != nil {
+	// Delete device from ChirpStack if it was created there. A device that is
+	// already gone from ChirpStack needs no cleanup.
+	if s.chirpStack != nil && device.ChirpStackDeviceCreated {
+		err = s.chirpStack.DeleteDevice(device.DevEUI)
+		if err != nil && !errors.Is(err, chirpstack.ErrNotFound) {
 			fmt.Printf("Warning: Failed to delete ChirpStack device: %v\n", err)
 			// Continue with database deletion even if ChirpStack deletion fails
 		}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/lnode"
+	"go-auth-api/internal/models"
+)
+
+// IntegrationService ingests the events ChirpStack sends for the devices of
+// an organization. Events are matched to their organization by tenant, or by
+// application when they come over MQTT, and to the local device by DevEUI.
+type IntegrationService struct {
+	orgs      interfaces.IntegrationOrganizations
+	devices   interfaces.IntegrationDevices
+	telemetry interfaces.IntegrationTelemetry
+	commands  interfaces.IntegrationCommands
+}
+
+func NewIntegrationService(orgs interfaces.IntegrationOrganizations, devices interfaces.IntegrationDevices, telemetry interfaces.IntegrationTelemetry, commands interfaces.IntegrationCommands) *IntegrationService {
+	return &IntegrationService{orgs: orgs, devices: devices, telemetry: telemetry, commands: commands}
+}
+
+// HandleWebhook processes an event posted by the HTTP integration of an
+// organization's application, encoded as JSON or protobuf as its content
+// type tells. secret must be the one registered with the integration of the
+// tenant the event names.
+func (s *IntegrationService) HandleWebhook(eventType, secret, contentType string, body []byte) error {
+	encoding, err := chirpstack.EventEncoding(contentType)
+	if err != nil {
+		return err
+	}
+	event, err := chirpstack.DecodeEvent(eventType, encoding, body)
+	if err != nil {
+		return err
+	}
+
+	org, err := s.orgs.GetOrganizationByTenantID(event.DeviceInfo.TenantID)
+	if errors.Is(err, models.ErrOrganizationNotFound) {
+		return models.ErrIntegrationUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	if org.IntegrationSecret == nil || secret == "" ||
+		subtle.ConstantTimeCompare([]byte(secret), []byte(*org.IntegrationSecret)) != 1 {
+		return models.ErrIntegrationUnauthorized
+	}
+
+	return s.process(org, eventType, event)
+}
+
+// HandleApplicationEvent processes an event the MQTT integration published
+// for applicationID. The broker is trusted in place of a secret, the event
+// is accepted when the application belongs to an organization.
+func (s *IntegrationService) HandleApplicationEvent(applicationID, eventType, encoding string, body []byte) error {
+	event, err := chirpstack.DecodeEvent(eventType, encoding, body)
+	if err != nil {
+		return err
+	}
+
+	org, err := s.orgs.GetOrganizationByApplicationID(applicationID)
+	if errors.Is(err, models.ErrOrganizationNotFound) {
+		return models.ErrDeviceNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.process(org, eventType, event)
+}
+
+// process applies an authenticated event of org
+func (s *IntegrationService) process(org *models.Organization, eventType string, event *models.IntegrationEvent) error {
+	device, err := s.devices.GetDeviceByDevEUI(event.DeviceInfo.DevEUI)
+	if err != nil {
+		return err
+	}
+	// The DevEUI may since have been registered by another organization
+	if device.OrganizationID != org.ID {
+		return models.ErrDeviceNotFound
+	}
+
+	at := event.OccurredAt()
+	switch eventType {
+	case models.EventUp, models.EventJoin, models.EventStatus:
+		if err := s.devices.UpdateDeviceLastSeen(device.ID, at); err != nil {
+			return fmt.Errorf("failed to update last seen time: %w", err)
+		}
+	case models.EventLog:
+		if event.Level == "ERROR" {
+			fmt.Printf("Warning: ChirpStack reported an error for device %s: %s (%s)\n", device.DevEUI, event.Description, event.Code)
+		}
+	case models.EventTxAck, models.EventAck:
+		if event.QueueItemID != "" {
+			if err := s.commands.UpdateCommandStatus(device.ID, event.QueueItemID, commandStatus(eventType, event)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if eventType == models.EventUp {
+		if values := TelemetryValues(event); len(values) > 0 {
+			sample := &models.TelemetrySample{DeviceID: device.ID, Time: at, Values: values}
+			if err := s.telemetry.InsertTelemetry(sample); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// commandStatus is the status of a command after its txack or ack event
+func commandStatus(eventType string, event *models.IntegrationEvent) string {
+	switch {
+	case eventType == models.EventTxAck:
+		return models.CommandSent
+	case event.Acknowledged:
+		return models.CommandAcknowledged
+	default:
+		return models.CommandNotAcknowledged
+	}
+}
+
+// TelemetryValues returns the lamp measurements of an uplink. They are read
+// from the object decoded by the device profile's codec, or from the frame
+// itself when the codec didn't run.
+func TelemetryValues(event *models.IntegrationEvent) map[string]float64 {
+	object := map[string]interface{}{}
+	if len(event.Object) > 0 {
+		if err := json.Unmarshal(event.Object, &object); err != nil {
+			return nil
+		}
+	} else if len(event.Data) > 0 {
+		uplink, err := lnode.Decode(event.Data)
+		if err != nil {
+			return nil
+		}
+		object = uplink.Object()
+	}
+
+	values := map[string]float64{}
+	for _, field := range models.TelemetryFields {
+		switch v := object[field].(type) {
+		case float64:
+			values[field] = v
+		case int64:
+			values[field] = float64(v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Retry schedule of failed jobs: jobRetryBase after the first failure,
+// doubling up to jobRetryMax
+const (
+	jobRetryBase = 30 * time.Second
+	jobRetryMax  = time.Hour
+	// jobLease is how long a worker owns a claimed job. A job still running
+	// after that is assumed lost and handed to another worker.
+	jobLease = 5 * time.Minute
+)
+
+// JobHandler runs one job. A returned error schedules a retry, errors
+// wrapping models.ErrJobPermanent move the job to the dead letters at once.
+type JobHandler func(job *models.Job) error
+
+// JobQueue runs the background jobs stored in Postgres with a pool of worker
+// goroutines. Jobs are claimed with SKIP LOCKED, so several instances can
+// share the queue.
+type JobQueue struct {
+	store      interfaces.JobStore
+	orgService *OrganizationService
+	handlers   map[string]JobHandler
+}
+
+func NewJobQueue(store interfaces.JobStore, orgService *OrganizationService) *JobQueue {
+	return &JobQueue{
+		store:      store,
+		orgService: orgService,
+		handlers:   make(map[string]JobHandler),
+	}
+}
+
+// Register sets the handler of a job type. Handlers are registered before Start.
+func (q *JobQueue) Register(jobType string, handler JobHandler) {
+	q.handlers[jobType] = handler
+}
+
+// JobRetryDelay returns how long a job waits after its attempt-th failure
+func JobRetryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := time.Duration(float64(jobRetryBase) * math.Pow(2, float64(attempt-1)))
+	if delay <= 0 || delay > jobRetryMax {
+		delay = jobRetryMax
+	}
+	return delay
+}
+
+// Start runs workers goroutines that poll for due jobs every pollInterval
+// while the queue is empty
+func (q *JobQueue) Start(workers int, pollInterval time.Duration) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				processed, err := q.RunOnce()
+				if err != nil {
+					fmt.Printf("Warning: Job queue: %v\n", err)
+				}
+				if !processed || err != nil {
+					time.Sleep(pollInterval)
+				}
+			}
+		}()
+	}
+}
+
+// RunOnce claims and runs the next due job. It reports whether there was one.
+func (q *JobQueue) RunOnce() (bool, error) {
+	job, err := q.store.ClaimNext(jobLease)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	err = q.run(job)
+	switch {
+	case err == nil:
+		err = q.store.Complete(job.ID, job.Attempts)
+	case errors.Is(err, models.ErrJobPermanent) || job.Attempts >= job.MaxAttempts:
+		fmt.Printf("Warning: Job %s (%s) failed for good after %d attempts: %v\n", job.ID, job.Type, job.Attempts, err)
+		err = q.store.Bury(job.ID, job.Attempts, err.Error())
+	default:
+		err = q.store.Reschedule(job.ID, job.Attempts, time.Now().UTC().Add(JobRetryDelay(job.Attempts)), err.Error())
+	}
+
+	// The job ran past its lease and another worker owns it now, so its
+	// outcome is left to that worker
+	if errors.Is(err, models.ErrJobLeaseLost) {
+		return true, fmt.Errorf("job %s (%s) attempt %d: %w", job.ID, job.Type, job.Attempts, err)
+	}
+	return true, err
+}
+
+// run calls the job's handler, turning a panic into a failed attempt
+func (q *JobQueue) run(job *models.Job) (err error) {
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown job type %q", models.ErrJobPermanent, job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(job)
+}
+
+// GetJob returns a job of an organization the user belongs to
+func (q *JobQueue) GetJob(id, userID uuid.UUID, role string) (*models.Job, error) {
+	job, err := q.store.GetJobByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == models.RoleAdmin {
+		return job, nil
+	}
+
+	if job.OrganizationID == nil {
+		return nil, models.ErrJobNotFound
+	}
+	if _, _, err := q.orgService.authorize(*job.OrganizationID, userID, role, models.OrgRoleViewer); err != nil {
+		if errors.Is(err, models.ErrOrganizationNotFound) {
+			return nil, models.ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetOrganizationJobs lists the jobs of an organization and its devices
+func (q *JobQueue) GetOrganizationJobs(orgID, userID uuid.UUID, role string, page, pageSize int) (*models.JobListResponse, error) {
+	if _, _, err := q.orgService.authorize(orgID, userID, role, models.OrgRoleViewer); err != nil {
+		return nil, err
+	}
+
+	page, pageSize = jobPage(page, pageSize)
+	jobs, total, err := q.store.GetOrganizationJobs(orgID, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobList(jobs, total, page, pageSize), nil
+}
+
+// GetJobs lists all jobs, optionally only those in one status
+func (q *JobQueue) GetJobs(status string, page, pageSize int) (*models.JobListResponse, error) {
+	page, pageSize = jobPage(page, pageSize)
+	jobs, total, err := q.store.GetJobs(status, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobList(jobs, total, page, pageSize), nil
+}
+
+// RetryJob puts a dead job back in the queue with a fresh set of attempts
+func (q *JobQueue) RetryJob(id uuid.UUID) error {
+	return q.store.Requeue(id)
+}
+
+func jobPage(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+func jobList(jobs []models.Job, total, page, pageSize int) *models.JobListResponse {
+	return &models.JobListResponse{
+		Jobs:       jobs,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(pageSize))),
+	}
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Login throttling policy. After accountDelayAfter consecutive failures an
+// account has to wait between attempts, starting at one second and doubling
+// up to maxDelay. Reaching the lock threshold locks the account.
+//
+// Failures per client address are counted within ipWindow. From ipDelayAfter
+// failures on the address gets the same progressive delay and at ipBlockAfter
+// it is blocked for ipWindow. Address counters are kept in memory per instance.
+const (
+	accountDelayAfter = 3
+	ipDelayAfter      = 10
+	ipBlockAfter      = 50
+	ipWindow          = 15 * time.Minute
+	maxDelay          = 30 * time.Second
+	// Address counters are swept once the map grows beyond this size
+	ipSweepThreshold = 10000
+)
+
+type ipFailures struct {
+	count        int
+	first        time.Time
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// LoginGuard protects the login endpoint against brute-force attacks by
+// counting failures per account and per client address, and records security
+// events.
+type LoginGuard struct {
+	securityRepo  interfaces.SecurityStore
+	userRepo      *repository.UserRepository
+	lockThreshold int
+	lockDuration  time.Duration
+
+	mu  sync.Mutex
+	ips map[string]*ipFailures
+}
+
+func NewLoginGuard(securityRepo interfaces.SecurityStore, userRepo *repository.UserRepository, lockThreshold int, lockDuration time.Duration) *LoginGuard {
+	return &LoginGuard{
+		securityRepo:  securityRepo,
+		userRepo:      userRepo,
+		lockThreshold: lockThreshold,
+		lockDuration:  lockDuration,
+		ips:           make(map[string]*ipFailures),
+	}
+}
+
+// progressiveDelay returns how long to wait after failures, with delays
+// starting once threshold is reached
+func progressiveDelay(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	exp := failures - threshold
+	if exp > 10 {
+		return maxDelay
+	}
+	delay := time.Duration(math.Pow(2, float64(exp))) * time.Second
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// CheckIP refuses an attempt from an address that failed too often recently
+func (g *LoginGuard) CheckIP(ip string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	state, ok := g.ips[ip]
+	if !ok {
+		return nil
+	}
+
+	if now.Before(state.blockedUntil) {
+		return &models.LoginThrottledError{RetryAfter: state.blockedUntil.Sub(now)}
+	}
+
+	if now.Sub(state.first) > ipWindow {
+		delete(g.ips, ip)
+		return nil
+	}
+
+	if wait := state.last.Add(progressiveDelay(state.count, ipDelayAfter)).Sub(now); wait > 0 {
+		return &models.LoginThrottledError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// CheckAccount refuses an attempt for a locked account or one that has to wait
+// after recent failures
+func (g *LoginGuard) CheckAccount(user *models.User) error {
+	now := time.Now()
+
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return &models.LoginThrottledError{RetryAfter: user.LockedUntil.Sub(now), Locked: true}
+	}
+
+	if user.LastFailedLogin != nil {
+		wait := user.LastFailedLogin.Add(progressiveDelay(user.FailedLogins, accountDelayAfter)).Sub(now)
+		if wait > 0 {
+			return &models.LoginThrottledError{RetryAfter: wait}
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed attempt against the address and, when the
+// email belongs to an account, the account
+func (g *LoginGuard) RecordFailure(user *models.User, req *models.LoginRequest) {
+	g.recordEvent(user, req, models.EventLoginFailure, "")
+
+	if g.recordIPFailure(req.IPAddress) {
+		g.recordEvent(nil, req, models.EventIPBlocked, fmt.Sprintf("blocked for %s", ipWindow))
+	}
+
+	if user == nil {
+		return
+	}
+
+	_, lockedUntil, err := g.securityRepo.RecordFailedLogin(user.ID, g.lockThreshold, time.Now().UTC().Add(g.lockDuration))
+	if err != nil {
+		fmt.Printf("Warning: Failed to record failed login for user %s: %v\n", user.Email, err)
+		return
+	}
+
+	// The account was not locked before this attempt, so a lock in the future is new
+	if lockedUntil != nil && time.Now().Before(*lockedUntil) {
+		g.recordEvent(user, req, models.EventAccountLocked, fmt.Sprintf("locked for %s", g.lockDuration))
+	}
+}
+
+// recordIPFailure counts a failure for an address and reports whether the
+// address got blocked by it
+func (g *LoginGuard) recordIPFailure(ip string) bool {
+	if ip == "" {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	if len(g.ips) > ipSweepThreshold {
+		for key, state := range g.ips {
+			if now.Sub(state.first) > ipWindow && now.After(state.blockedUntil) {
+				delete(g.ips, key)
+			}
+		}
+	}
+
+	state, ok := g.ips[ip]
+	if !ok || now.Sub(state.first) > ipWindow {
+		state = &ipFailures{first: now}
+		g.ips[ip] = state
+	}
+
+	state.count++
+	state.last = now
+
+	if state.count >= ipBlockAfter {
+		state.blockedUntil = now.Add(ipWindow)
+		state.count = 0
+		state.first = now
+		return true
+	}
+
+	return false
+}
+
+// RecordSuccess clears the failure count of the account. It is called once
+// every factor of the login has been verified.
+func (g *LoginGuard) RecordSuccess(user *models.User, req *models.LoginRequest) {
+	g.recordEvent(user, req, models.EventLoginSuccess, "")
+
+	if user.FailedLogins == 0 && user.LockedUntil == nil {
+		return
+	}
+
+	if err := g.securityRepo.ResetFailedLogins(user.ID); err != nil {
+		fmt.Printf("Warning: Failed to reset failed logins for user %s: %v\n", user.Email, err)
+	}
+}
+
+// UnlockUser lets an admin lift a lockout before it expires
+func (g *LoginGuard) UnlockUser(id string, adminID uuid.UUID) error {
+	user, err := g.userRepo.GetUserByID(id)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := g.securityRepo.ResetFailedLogins(user.ID); err != nil {
+		return err
+	}
+
+	g.recordEvent(user, &models.LoginRequest{Email: user.Email}, models.EventAccountUnlocked, "unlocked by "+adminID.String())
+	return nil
+}
+
+// GetSecurityEvents returns the most recent security events, optionally only
+// for one user
+func (g *LoginGuard) GetSecurityEvents(userID *uuid.UUID, page, pageSize int) (*models.SecurityEventListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	events, total, err := g.securityRepo.GetSecurityEvents(userID, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SecurityEventListResponse{
+		Events:     events,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(pageSize))),
+	}, nil
+}
+
+func (g *LoginGuard) recordEvent(user *models.User, req *models.LoginRequest, eventType, details string) {
+	event := &models.SecurityEvent{
+		EventType: eventType,
+		Email:     req.Email,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+		Details:   details,
+	}
+	if user != nil {
+		event.UserID = &user.ID
+	}
+
+	if err := g.securityRepo.CreateSecurityEvent(event); err != nil {
+		fmt.Printf("Warning: Failed to record security event %s: %v\n", eventType, err)
+	}
+}
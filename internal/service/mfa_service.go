@@ -0,0 +1,350 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// How long the user has to enter the code after the password step
+	mfaChallengeTTL = 5 * time.Minute
+	// How long role policies are cached before they are read again
+	rolePolicyCacheTTL = 30 * time.Second
+	recoveryCodeCount  = 10
+)
+
+// MFAService manages TOTP enrollment, the second login step and the per-role
+// policies that make two-factor authentication mandatory.
+type MFAService struct {
+	userRepo      *repository.UserRepository
+	mfaRepo       *repository.MFARepository
+	userTokenRepo *repository.UserTokenRepository
+	tokenService  *TokenService
+	loginGuard    *LoginGuard
+	hasher        *auth.PasswordHasher
+	issuer        string
+
+	mu         sync.RWMutex
+	requireMFA map[string]bool
+	loadedAt   time.Time
+}
+
+func NewMFAService(userRepo *repository.UserRepository, mfaRepo *repository.MFARepository, userTokenRepo *repository.UserTokenRepository, tokenService *TokenService, loginGuard *LoginGuard, hasher *auth.PasswordHasher, issuer string) *MFAService {
+	return &MFAService{
+		userRepo:      userRepo,
+		mfaRepo:       mfaRepo,
+		userTokenRepo: userTokenRepo,
+		tokenService:  tokenService,
+		loginGuard:    loginGuard,
+		hasher:        hasher,
+		issuer:        issuer,
+	}
+}
+
+// Enroll creates a new TOTP secret. TOTP is only enabled once a code for the
+// secret has been confirmed.
+func (s *MFAService) Enroll(userID uuid.UUID) (*models.TOTPEnrollResponse, error) {
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, models.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SetPendingTOTPSecret(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm enables TOTP after checking the first code and returns the recovery codes
+func (s *MFAService) Confirm(userID uuid.UUID, code string) (*models.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, models.ErrMFAAlreadyEnabled
+	}
+
+	secret, lastStep, err := s.mfaRepo.GetTOTPSecret(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := auth.ValidateTOTP(secret, code, time.Now(), lastStep)
+	if !ok {
+		return nil, models.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.EnableTOTP(userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable turns TOTP off. It needs both the password and a current code.
+// Wrong passwords and codes count as failed logins, so that a stolen access
+// token can't be used to guess the password.
+func (s *MFAService) Disable(userID uuid.UUID, req *models.DisableTOTPRequest) error {
+	if err := s.loginGuard.CheckIP(req.IPAddress); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := s.loginGuard.CheckAccount(user); err != nil {
+		return err
+	}
+
+	login := &models.LoginRequest{Email: user.Email, IPAddress: req.IPAddress, UserAgent: req.UserAgent}
+	valid, err := s.hasher.Check(req.Password, user.PasswordHash)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		s.loginGuard.RecordFailure(user, login)
+		return fmt.Errorf("invalid credentials")
+	}
+
+	if err := s.verifyCode(user, req.Code); err != nil {
+		if errors.Is(err, models.ErrInvalidMFACode) {
+			s.loginGuard.RecordFailure(user, login)
+		}
+		return err
+	}
+
+	return s.mfaRepo.DisableTOTP(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of a user
+func (s *MFAService) RegenerateRecoveryCodes(userID uuid.UUID, code string) (*models.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if err := s.verifyCode(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// ResetUserMFA lets an admin turn off TOTP for a user who lost their device.
+// All sessions of the user are ended.
+func (s *MFAService) ResetUserMFA(id string) error {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := s.mfaRepo.DisableTOTP(user.ID); err != nil {
+		return err
+	}
+
+	return s.tokenService.LogoutAll(user.ID)
+}
+
+// StartChallenge returns the token that links the password step of a login to
+// the OTP step
+func (s *MFAService) StartChallenge(userID uuid.UUID) (string, error) {
+	return createUserToken(s.userTokenRepo, userID, models.UserTokenMFAChallenge, mfaChallengeTTL)
+}
+
+// CompleteLogin finishes a login with a TOTP or recovery code. The challenge
+// token can only be used once, a wrong code means starting over. Wrong codes
+// count as failed logins of the account and the client address, and only a
+// correct code clears the failures.
+func (s *MFAService) CompleteLogin(req *models.MFALoginRequest) (*models.AuthResponse, error) {
+	if err := s.loginGuard.CheckIP(req.IPAddress); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.userTokenRepo.ConsumeUserToken(auth.HashToken(req.MFAToken), models.UserTokenMFAChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil || user.SuspendedAt != nil {
+		return nil, models.ErrInvalidUserToken
+	}
+
+	if err := s.loginGuard.CheckAccount(user); err != nil {
+		return nil, err
+	}
+
+	login := &models.LoginRequest{Email: user.Email, IPAddress: req.IPAddress, UserAgent: req.UserAgent}
+	if err := s.verifyCode(user, req.Code); err != nil {
+		if errors.Is(err, models.ErrInvalidMFACode) {
+			s.loginGuard.RecordFailure(user, login)
+		}
+		return nil, err
+	}
+
+	s.loginGuard.RecordSuccess(user, login)
+
+	tokens, err := s.tokenService.IssueTokens(user, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return &models.AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	}, nil
+}
+
+// verifyCode accepts a TOTP code or an unused recovery code
+func (s *MFAService) verifyCode(user *models.User, code string) error {
+	if user.TOTPEnabledAt == nil {
+		return models.ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != auth.TOTPDigits {
+		ok, err := s.mfaRepo.UseRecoveryCode(user.ID, auth.HashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return models.ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	secret, lastStep, err := s.mfaRepo.GetTOTPSecret(user.ID)
+	if err != nil {
+		return err
+	}
+
+	step, ok := auth.ValidateTOTP(secret, code, time.Now(), lastStep)
+	if !ok {
+		return models.ErrInvalidMFACode
+	}
+
+	// Guards against two requests racing with the same code
+	advanced, err := s.mfaRepo.AdvanceTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !advanced {
+		return models.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// RequiresMFA reports whether users of role must log in with a second factor
+func (s *MFAService) RequiresMFA(role string) bool {
+	s.mu.RLock()
+	fresh := s.requireMFA != nil && time.Since(s.loadedAt) < rolePolicyCacheTTL
+	required := s.requireMFA[role]
+	s.mu.RUnlock()
+
+	if fresh {
+		return required
+	}
+
+	if err := s.loadPolicies(); err != nil {
+		// Keep using the last known policies
+		fmt.Printf("Warning: Failed to load role policies: %v\n", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.requireMFA[role]
+}
+
+func (s *MFAService) loadPolicies() error {
+	policies, err := s.mfaRepo.GetRolePolicies()
+	if err != nil {
+		return err
+	}
+
+	requireMFA := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		requireMFA[policy.Role] = policy.RequireMFA
+	}
+
+	s.mu.Lock()
+	s.requireMFA = requireMFA
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// GetRolePolicies returns the policy of every role
+func (s *MFAService) GetRolePolicies() ([]models.RolePolicy, error) {
+	return s.mfaRepo.GetRolePolicies()
+}
+
+// SetRolePolicy makes two-factor authentication mandatory for a role or not
+func (s *MFAService) SetRolePolicy(role string, requireMFA bool) error {
+	if !models.IsValidRole(role) {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	if err := s.mfaRepo.SetRolePolicy(role, requireMFA); err != nil {
+		return err
+	}
+
+	return s.loadPolicies()
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
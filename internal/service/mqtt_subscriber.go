@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttTimeout bounds the wait for the broker to answer a (un)subscribe
+const mqttTimeout = 10 * time.Second
+
+// subscribeFailure is the SUBACK return code of a refused subscription
+const subscribeFailure = 0x80
+
+// MQTTOptions configures the connection to the broker of the ChirpStack MQTT
+// integration
+type MQTTOptions struct {
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// Encoding of the events, JSON unless the integration has json=false
+	Encoding string
+	// RefreshInterval is how often the applications to watch are reloaded,
+	// a minute when zero
+	RefreshInterval time.Duration
+}
+
+// MQTTSubscriber receives the events the ChirpStack MQTT integration
+// publishes for the applications of all organizations and hands them to the
+// same pipeline as the HTTP webhook. The session is persistent and events
+// are taken at QoS 1, so the broker keeps what arrives while the server is
+// away.
+type MQTTSubscriber struct {
+	apps     interfaces.IntegrationApplications
+	events   interfaces.IntegrationServiceInterface
+	encoding string
+	refresh  time.Duration
+	client   mqtt.Client
+	stop     chan struct{}
+
+	// mu guards subscribed, the applications subscribed to on the current
+	// connection
+	mu         sync.Mutex
+	subscribed map[string]bool
+}
+
+func NewMQTTSubscriber(options MQTTOptions, apps interfaces.IntegrationApplications, events interfaces.IntegrationServiceInterface) *MQTTSubscriber {
+	s := &MQTTSubscriber{
+		apps:       apps,
+		events:     events,
+		encoding:   options.Encoding,
+		refresh:    options.RefreshInterval,
+		stop:       make(chan struct{}),
+		subscribed: map[string]bool{},
+	}
+	if s.refresh <= 0 {
+		s.refresh = time.Minute
+	}
+
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute).
+		// Events are acknowledged once handled, the broker redelivers the
+		// ones in flight when the server stops
+		SetAutoAckDisabled(true).
+		// Events queued by the session arrive before the subscriptions are
+		// made again
+		SetDefaultPublishHandler(s.handleMessage).
+		SetOnConnectHandler(func(mqtt.Client) {
+			s.subscribe(true)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			fmt.Printf("Warning: Lost connection to the MQTT broker: %v\n", err)
+		})
+	s.client = mqtt.NewClient(clientOptions)
+
+	return s
+}
+
+// Start connects to the broker, retrying until it is reachable, and picks up
+// the applications of newly provisioned organizations every refresh interval
+func (s *MQTTSubscriber) Start() {
+	s.client.Connect()
+
+	go func() {
+		ticker := time.NewTicker(s.refresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if s.client.IsConnectionOpen() {
+					s.subscribe(false)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop disconnects from the broker, which keeps the session
+func (s *MQTTSubscriber) Stop() {
+	close(s.stop)
+	s.client.Disconnect(250)
+}
+
+// subscribe subscribes to the events of the applications not subscribed to
+// yet and unsubscribes from the ones no organization has anymore. After a
+// reconnect all applications are subscribed again, in case the broker lost
+// the session.
+func (s *MQTTSubscriber) subscribe(reconnected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reconnected {
+		s.subscribed = map[string]bool{}
+	}
+
+	ids, err := s.apps.GetApplicationIDs()
+	if err != nil {
+		fmt.Printf("Warning: Failed to get the applications to subscribe to: %v\n", err)
+		return
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	filters := map[string]byte{}
+	for _, id := range ids {
+		wanted[id] = true
+		if !s.subscribed[id] {
+			filters[eventTopic(id)] = 1
+		}
+	}
+
+	if len(filters) > 0 {
+		token := s.client.SubscribeMultiple(filters, s.handleMessage)
+		if err := waitMQTT(token); err != nil {
+			fmt.Printf("Warning: Failed to subscribe to %d applications: %v\n", len(filters), err)
+		} else {
+			for topic, qos := range token.(*mqtt.SubscribeToken).Result() {
+				if qos == subscribeFailure {
+					fmt.Printf("Warning: MQTT broker refused the subscription to %s\n", topic)
+					continue
+				}
+				if id, _, ok := parseEventTopic(topic); ok {
+					s.subscribed[id] = true
+				}
+			}
+		}
+	}
+
+	var stale []string
+	for id := range s.subscribed {
+		if !wanted[id] {
+			stale = append(stale, eventTopic(id))
+			delete(s.subscribed, id)
+		}
+	}
+	if len(stale) > 0 {
+		if err := waitMQTT(s.client.Unsubscribe(stale...)); err != nil {
+			fmt.Printf("Warning: Failed to unsubscribe from %d applications: %v\n", len(stale), err)
+		}
+	}
+}
+
+// handleMessage passes an event to the integration service. An event is
+// acknowledged once processed, or when processing it can never succeed: it
+// doesn't decode or its device isn't known here. Other failures leave it
+// unacknowledged, so the broker sends it again when the session resumes.
+func (s *MQTTSubscriber) handleMessage(_ mqtt.Client, msg mqtt.Message) {
+	applicationID, eventType, ok := parseEventTopic(msg.Topic())
+	if !ok || !models.IsValidIntegrationEvent(eventType) {
+		msg.Ack()
+		return
+	}
+
+	err := s.events.HandleApplicationEvent(applicationID, eventType, s.encoding, msg.Payload())
+	switch {
+	// Devices removed here but still in ChirpStack are reported by the reconciler
+	case err == nil, errors.Is(err, models.ErrDeviceNotFound):
+	case errors.Is(err, models.ErrInvalidEvent), errors.Is(err, models.ErrUnsupportedEncoding):
+		fmt.Printf("Warning: Dropping MQTT event on %s: %v\n", msg.Topic(), err)
+	default:
+		fmt.Printf("Warning: Failed to process MQTT event on %s, leaving it for redelivery: %v\n", msg.Topic(), err)
+		return
+	}
+	msg.Ack()
+}
+
+// eventTopic is the topic the MQTT integration publishes the events of an
+// application's devices on
+func eventTopic(applicationID string) string {
+	return "application/" + applicationID + "/device/+/event/+"
+}
+
+// parseEventTopic returns the application and event type of a topic of the
+// form application/<id>/device/<DevEUI>/event/<type>
+func parseEventTopic(topic string) (applicationID, eventType string, ok bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 6 || parts[0] != "application" || parts[2] != "device" || parts[4] != "event" {
+		return "", "", false
+	}
+	return parts[1], parts[5], parts[1] != ""
+}
+
+func waitMQTT(token mqtt.Token) error {
+	if !token.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("timed out after %s", mqttTimeout)
+	}
+	return token.Error()
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/config"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/mailer"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// invitationTTL is how long an organization invitation can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+// OrganizationService manages organizations, their members and invitations.
+// Organizations own the ChirpStack tenant that their devices are created in.
+type OrganizationService struct {
+	orgRepo    interfaces.OrganizationStore
+	userRepo   *repository.UserRepository
+	chirpStack interfaces.ChirpStackClient
+	saga       *ProvisioningSaga
+	mailer     mailer.Mailer
+	baseURL    string
+	// region of organizations created without one
+	defaultRegion string
+	// endpoint ChirpStack posts integration events to and their encoding
+	integrationURL      string
+	integrationEncoding string
+}
+
+// NewOrganizationService creates the service. chirpStack is nil when the
+// ChirpStack integration is disabled, codecs supplies the codec of the default
+// device profiles.
+func NewOrganizationService(cfg *config.Config, orgRepo interfaces.OrganizationStore, userRepo *repository.UserRepository, chirpStack interfaces.ChirpStackClient, codecs interfaces.CodecScripts, m mailer.Mailer) *OrganizationService {
+	return &OrganizationService{
+		orgRepo:    orgRepo,
+		userRepo:   userRepo,
+		chirpStack: chirpStack,
+		saga:       NewProvisioningSaga(chirpStack, orgRepo, codecs),
+		mailer:     m,
+		baseURL:    cfg.AppBaseURL,
+
+		defaultRegion:       cfg.DefaultRegion,
+		integrationURL:      cfg.IntegrationURL,
+		integrationEncoding: cfg.EventEncoding,
+	}
+}
+
+// authorize returns the organization if the user holds at least the min role
+// in it. Global admins act as owners of every organization. Users outside the
+// organization get ErrOrganizationNotFound so they can't probe for IDs.
+func (s *OrganizationService) authorize(orgID, userID uuid.UUID, role, min string) (*models.Organization, string, error) {
+	org, err := s.orgRepo.GetOrganizationByID(orgID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if role == models.RoleAdmin {
+		return org, models.OrgRoleOwner, nil
+	}
+
+	memberRole, err := s.orgRepo.GetMemberRole(orgID, userID)
+	if errors.Is(err, models.ErrMemberNotFound) {
+		return nil, "", models.ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !models.OrgRoleAtLeast(memberRole, min) {
+		return nil, "", models.ErrOrgPermissionDenied
+	}
+
+	return org, memberRole, nil
+}
+
+// CreateOrganization creates an organization owned by the user and queues the
+// provisioning of its ChirpStack resources
+func (s *OrganizationService) CreateOrganization(userID uuid.UUID, req *models.CreateOrganizationRequest) (*models.Organization, error) {
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return nil, models.ErrEmailNotVerified
+	}
+
+	org := &models.Organization{Name: req.Name, Region: req.Region}
+	if org.Region == "" {
+		org.Region = s.defaultRegion
+	}
+	if _, ok := chirpstack.LookupRegion(org.Region); !ok {
+		return nil, fmt.Errorf("%w: %s", models.ErrUnknownRegion, org.Region)
+	}
+
+	job := s.provisioningJob()
+	if err := s.orgRepo.CreateOrganization(org, userID, job); err != nil {
+		return nil, err
+	}
+	org.Role = models.OrgRoleOwner
+	if job != nil {
+		org.ProvisioningJobID = &job.ID
+	}
+
+	return org, nil
+}
+
+// EnsurePersonalOrganization gives a user without any membership an
+// organization of their own, so a newly verified user can add devices
+func (s *OrganizationService) EnsurePersonalOrganization(user *models.User) (*models.Organization, error) {
+	orgs, err := s.orgRepo.GetOrganizationsForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) > 0 {
+		return &orgs[0], nil
+	}
+
+	org := &models.Organization{Name: user.FullName, Region: s.defaultRegion}
+	if err := s.orgRepo.CreateOrganization(org, user.ID, s.provisioningJob()); err != nil {
+		return nil, err
+	}
+	org.Role = models.OrgRoleOwner
+
+	return org, nil
+}
+
+// EnsureResources provisions the ChirpStack tenant, application and device
+// profiles of an organization if it doesn't have them yet. On failure org
+// still reflects what was provisioned.
+func (s *OrganizationService) EnsureResources(org *models.Organization) error {
+	if org.ProvisioningStatus == models.ProvisioningProvisioned || s.chirpStack == nil {
+		return nil
+	}
+
+	stored, err := s.saga.Provision(org.ID)
+	if stored != nil {
+		org.TenantID = stored.TenantID
+		org.ApplicationID = stored.ApplicationID
+		org.DeviceProfileID = stored.DeviceProfileID
+		org.OTAADeviceProfileID = stored.OTAADeviceProfileID
+		org.ProvisioningStatus = stored.ProvisioningStatus
+		org.ProvisioningError = stored.ProvisioningError
+	}
+	return err
+}
+
+// EnqueueIntegrationUpdates queues the registration of the HTTP integration
+// for provisioned organizations whose integration doesn't post to the
+// configured endpoint in the configured encoding, such as organizations
+// provisioned before the integration existed
+func (s *OrganizationService) EnqueueIntegrationUpdates() (int, error) {
+	if s.chirpStack == nil || s.integrationURL == "" {
+		return 0, nil
+	}
+	return s.orgRepo.EnqueueIntegrationUpdates(s.integrationURL, s.integrationEncoding)
+}
+
+// provisioningJob is the job enqueued with a new organization, nil when the
+// ChirpStack integration is disabled
+func (s *OrganizationService) provisioningJob() *models.Job {
+	if s.chirpStack == nil {
+		return nil
+	}
+	return &models.Job{Type: models.JobProvisionOrganization}
+}
+
+// RunProvisioningJob handles JobProvisionOrganization: it provisions the
+// organization and registers the HTTP integration of its application.
+// Provisioning that ChirpStack rejected is not retried.
+func (s *OrganizationService) RunProvisioningJob(job *models.Job) error {
+	if job.OrganizationID == nil {
+		return fmt.Errorf("%w: job has no organization", models.ErrJobPermanent)
+	}
+
+	org, err := s.orgRepo.GetOrganizationByID(*job.OrganizationID)
+	if errors.Is(err, models.ErrOrganizationNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.EnsureResources(org); err != nil {
+		if org.ProvisioningStatus == models.ProvisioningFailed {
+			return fmt.Errorf("%w: %v", models.ErrJobPermanent, err)
+		}
+		return err
+	}
+
+	// Devices don't wait for the integration, so it is only registered here
+	return s.saga.RegisterIntegration(org, s.integrationURL, s.integrationEncoding)
+}
+
+// DeviceOrganization resolves the organization a user adds a device to:
+// orgID when given, otherwise the user's oldest membership
+func (s *OrganizationService) DeviceOrganization(orgID *uuid.UUID, userID uuid.UUID, role string) (*models.Organization, error) {
+	if orgID != nil {
+		org, _, err := s.authorize(*orgID, userID, role, models.OrgRoleMember)
+		return org, err
+	}
+
+	orgs, err := s.orgRepo.GetOrganizationsForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) == 0 {
+		return nil, models.ErrOrganizationNotFound
+	}
+	if !models.OrgRoleAtLeast(orgs[0].Role, models.OrgRoleMember) {
+		return nil, models.ErrOrgPermissionDenied
+	}
+
+	return &orgs[0], nil
+}
+
+// GetOrganizationByID returns an organization without checking membership,
+// for background jobs acting on behalf of the system
+func (s *OrganizationService) GetOrganizationByID(id uuid.UUID) (*models.Organization, error) {
+	return s.orgRepo.GetOrganizationByID(id)
+}
+
+func (s *OrganizationService) GetOrganizations(userID uuid.UUID) ([]models.Organization, error) {
+	return s.orgRepo.GetOrganizationsForUser(userID)
+}
+
+func (s *OrganizationService) GetOrganization(id, userID uuid.UUID, role string) (*models.Organization, error) {
+	org, memberRole, err := s.authorize(id, userID, role, models.OrgRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	org.Role = memberRole
+	return org, nil
+}
+
+func (s *OrganizationService) UpdateOrganization(id, userID uuid.UUID, role string, req *models.UpdateOrganizationRequest) (*models.Organization, error) {
+	if _, _, err := s.authorize(id, userID, role, models.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := s.orgRepo.UpdateOrganizationName(id, req.Name); err != nil {
+		return nil, err
+	}
+
+	return s.GetOrganization(id, userID, role)
+}
+
+func (s *OrganizationService) GetMembers(id, userID uuid.UUID, role string) ([]models.OrganizationMember, error) {
+	if _, _, err := s.authorize(id, userID, role, models.OrgRoleViewer); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.GetMembers(id)
+}
+
+// UpdateMemberRole changes a member's role. Admins manage members below owner,
+// only owners can grant or take away the owner role, and the last owner can't
+// be demoted.
+func (s *OrganizationService) UpdateMemberRole(id, userID uuid.UUID, role string, memberID uuid.UUID, req *models.UpdateMemberRoleRequest) error {
+	_, actorRole, err := s.authorize(id, userID, role, models.OrgRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.orgRepo.GetMemberRole(id, memberID)
+	if err != nil {
+		return err
+	}
+
+	if (current == models.OrgRoleOwner || req.Role == models.OrgRoleOwner) && actorRole != models.OrgRoleOwner {
+		return models.ErrOrgPermissionDenied
+	}
+
+	// The store refuses to demote the last owner under a lock
+	return s.orgRepo.UpdateMemberRole(id, memberID, req.Role)
+}
+
+// RemoveMember removes a member from the organization. Members can always
+// leave on their own, removing others needs the admin role and removing an
+// owner needs the owner role. The last owner can't leave.
+func (s *OrganizationService) RemoveMember(id, userID uuid.UUID, role string, memberID uuid.UUID) error {
+	min := models.OrgRoleAdmin
+	if memberID == userID {
+		min = models.OrgRoleViewer
+	}
+
+	_, actorRole, err := s.authorize(id, userID, role, min)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.orgRepo.GetMemberRole(id, memberID)
+	if err != nil {
+		return err
+	}
+
+	if current == models.OrgRoleOwner && memberID != userID && actorRole != models.OrgRoleOwner {
+		return models.ErrOrgPermissionDenied
+	}
+
+	// The store refuses to remove the last owner under a lock
+	return s.orgRepo.RemoveMember(id, memberID)
+}
+
+// CreateInvitation mails an invitation to join the organization
+func (s *OrganizationService) CreateInvitation(id, userID uuid.UUID, role string, req *models.CreateInvitationRequest) (*models.OrganizationInvitation, error) {
+	org, actorRole, err := s.authorize(id, userID, role, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Role == models.OrgRoleOwner && actorRole != models.OrgRoleOwner {
+		return nil, models.ErrOrgPermissionDenied
+	}
+
+	plain, hash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	invitation := &models.OrganizationInvitation{
+		OrganizationID: id,
+		Email:          strings.ToLower(req.Email),
+		Role:           req.Role,
+		TokenHash:      hash,
+		InvitedBy:      &userID,
+		ExpiresAt:      time.Now().UTC().Add(invitationTTL),
+	}
+	if err := s.orgRepo.CreateInvitation(invitation); err != nil {
+		return nil, err
+	}
+
+	err = s.mailer.Send(&mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You have been invited to %s", org.Name),
+		Body: fmt.Sprintf("Hello,\n\nYou have been invited to join %s as %s. Sign in or create an account with this email address and open the link below to accept:\n\n%s\n\nThe invitation expires in %s.",
+			org.Name, invitation.Role, s.link("/accept-invitation", plain), invitationTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+func (s *OrganizationService) GetInvitations(id, userID uuid.UUID, role string) ([]models.OrganizationInvitation, error) {
+	if _, _, err := s.authorize(id, userID, role, models.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.GetPendingInvitations(id)
+}
+
+func (s *OrganizationService) RevokeInvitation(id, userID uuid.UUID, role string, invitationID uuid.UUID) error {
+	if _, _, err := s.authorize(id, userID, role, models.OrgRoleAdmin); err != nil {
+		return err
+	}
+	return s.orgRepo.DeleteInvitation(id, invitationID)
+}
+
+// AcceptInvitation adds the user to the organization the invitation is for.
+// The invitation must have been sent to the user's email address, which also
+// confirms that address.
+func (s *OrganizationService) AcceptInvitation(userID uuid.UUID, token string) (*models.Organization, error) {
+	user, err := s.userRepo.GetUserByID(userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	orgID, err := s.orgRepo.AcceptInvitation(auth.HashToken(token), user.ID, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err := s.userRepo.SetEmailVerified(user.ID.String()); err != nil {
+			fmt.Printf("Warning: Failed to mark email of %s verified: %v\n", user.Email, err)
+		}
+	}
+
+	return s.GetOrganization(orgID, userID, user.Role)
+}
+
+func (s *OrganizationService) link(path, token string) string {
+	return s.baseURL + path + "?token=" + url.QueryEscape(token)
+}
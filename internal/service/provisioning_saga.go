@@ -0,0 +1,278 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// provisioningStep creates one ChirpStack resource of an organization. field
+// points at the organization column recording the resource's ID, find looks
+// the resource up by its deterministic name.
+type provisioningStep struct {
+	name   string
+	field  func(org *models.Organization) **string
+	find   func(org *models.Organization) (string, error)
+	create func(org *models.Organization) (string, error)
+	remove func(id string) error
+}
+
+// ProvisioningSaga provisions the ChirpStack tenant, application and the ABP
+// and OTAA device profiles of an organization as a saga. Each created
+// resource is recorded on the organization before the next step starts, so a
+// retry resumes after the last recorded step instead of creating the
+// resources again. The device profiles get the latest published version of
+// the default codec.
+//
+// Resources have names that are unique within their parent, and each step
+// adopts an existing resource of that name instead of creating it. A create
+// that succeeded in ChirpStack but timed out for the saga therefore does not
+// leave a duplicate behind after the retry.
+//
+// When ChirpStack is unavailable the recorded resources are kept for the
+// retry. When it rejects a step the earlier steps are deleted again in
+// reverse order and the organization is marked failed. Recorded resources
+// always form a prefix of the steps: compensation stops at the first delete
+// that fails and leaves the rest recorded for the next attempt.
+type ProvisioningSaga struct {
+	chirpStack interfaces.ChirpStackClient
+	store      interfaces.ProvisioningStore
+	codecs     interfaces.CodecScripts
+	steps      []provisioningStep
+
+	// script is the default codec script of the current run, empty for the
+	// built-in one
+	script string
+
+	// Provisioning is rare, so one lock serializes all runs of an instance
+	mu sync.Mutex
+}
+
+// NewProvisioningSaga creates the saga. Without codecs the device profiles get
+// the built-in Lnode codec.
+func NewProvisioningSaga(chirpStack interfaces.ChirpStackClient, store interfaces.ProvisioningStore, codecs interfaces.CodecScripts) *ProvisioningSaga {
+	p := &ProvisioningSaga{
+		chirpStack: chirpStack,
+		store:      store,
+		codecs:     codecs,
+	}
+	p.steps = []provisioningStep{
+		{
+			name:  "tenant",
+			field: func(org *models.Organization) **string { return &org.TenantID },
+			find: func(org *models.Organization) (string, error) {
+				return chirpStack.FindTenant(chirpstack.TenantName(org.ID.String(), org.Name))
+			},
+			create: func(org *models.Organization) (string, error) {
+				tenant := chirpstack.DefaultTenant(org.Name)
+				tenant.Name = chirpstack.TenantName(org.ID.String(), org.Name)
+				return chirpStack.CreateTenant(tenant)
+			},
+			remove: func(id string) error { return chirpStack.DeleteTenant(id) },
+		},
+		{
+			name:  "application",
+			field: func(org *models.Organization) **string { return &org.ApplicationID },
+			find: func(org *models.Organization) (string, error) {
+				return chirpStack.FindApplication(*org.TenantID, chirpstack.DefaultApplicationName)
+			},
+			create: func(org *models.Organization) (string, error) {
+				return chirpStack.CreateApplication(chirpstack.DefaultApplication(*org.TenantID, chirpstack.DefaultApplicationName))
+			},
+			remove: func(id string) error { return chirpStack.DeleteApplication(id) },
+		},
+		{
+			name:  "device profile",
+			field: func(org *models.Organization) **string { return &org.DeviceProfileID },
+			find: func(org *models.Organization) (string, error) {
+				return chirpStack.FindDeviceProfile(*org.TenantID, chirpstack.DefaultDeviceProfile(*org.TenantID, org.Region).Name)
+			},
+			create: func(org *models.Organization) (string, error) {
+				return chirpStack.CreateDeviceProfile(p.withCodec(chirpstack.DefaultDeviceProfile(*org.TenantID, org.Region)))
+			},
+			remove: func(id string) error { return chirpStack.DeleteDeviceProfile(id) },
+		},
+		{
+			name:  "OTAA device profile",
+			field: func(org *models.Organization) **string { return &org.OTAADeviceProfileID },
+			find: func(org *models.Organization) (string, error) {
+				return chirpStack.FindDeviceProfile(*org.TenantID, chirpstack.DefaultOTAADeviceProfile(*org.TenantID, org.Region).Name)
+			},
+			create: func(org *models.Organization) (string, error) {
+				return chirpStack.CreateDeviceProfile(p.withCodec(chirpstack.DefaultOTAADeviceProfile(*org.TenantID, org.Region)))
+			},
+			remove: func(id string) error { return chirpStack.DeleteDeviceProfile(id) },
+		},
+	}
+	return p
+}
+
+// Provision runs the saga for an organization and returns its stored state
+// afterwards, which is also set when provisioning fails
+func (p *ProvisioningSaga) Provision(orgID uuid.UUID) (*models.Organization, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Work on the stored state, the caller's copy may be stale
+	org, err := p.store.GetOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org.ProvisioningStatus == models.ProvisioningProvisioned {
+		return org, nil
+	}
+
+	if p.script, err = p.defaultCodecScript(); err != nil {
+		return org, err
+	}
+
+	for i, step := range p.steps {
+		field := step.field(org)
+		if *field != nil {
+			continue
+		}
+
+		id, err := step.find(org)
+		if errors.Is(err, chirpstack.ErrNotFound) {
+			id, err = step.create(org)
+		}
+		if err != nil {
+			return org, p.fail(org, i, err)
+		}
+
+		*field = &id
+		org.ProvisioningStatus = models.ProvisioningPending
+		if err := p.store.UpdateOrganizationProvisioning(org); err != nil {
+			// An unrecorded resource would never be cleaned up
+			*field = nil
+			if rmErr := step.remove(id); rmErr != nil && !errors.Is(rmErr, chirpstack.ErrNotFound) {
+				fmt.Printf("Warning: Failed to delete unrecorded ChirpStack %s %s of organization %s: %v\n", step.name, id, org.ID, rmErr)
+			}
+			return org, fmt.Errorf("failed to record ChirpStack %s: %w", step.name, err)
+		}
+	}
+
+	org.ProvisioningStatus = models.ProvisioningProvisioned
+	org.ProvisioningError = nil
+	if err := p.store.UpdateOrganizationProvisioning(org); err != nil {
+		return org, fmt.Errorf("failed to store provisioning status: %w", err)
+	}
+
+	fmt.Printf("Successfully created ChirpStack resources for organization %s: TenantID=%s, ApplicationID=%s, DeviceProfileID=%s, OTAADeviceProfileID=%s\n",
+		org.ID, *org.TenantID, *org.ApplicationID, *org.DeviceProfileID, *org.OTAADeviceProfileID)
+	return org, nil
+}
+
+// RegisterIntegration makes ChirpStack post the events of a provisioned
+// organization's application to url in encoding. The organization keeps its
+// secret when the integration moves to another URL or encoding. Integrations
+// registered elsewhere are replaced.
+func (p *ProvisioningSaga) RegisterIntegration(org *models.Organization, url, encoding string) error {
+	if url == "" || org.ApplicationID == nil {
+		return nil
+	}
+	if org.IntegrationSecret != nil && equalString(org.IntegrationURL, url) && equalString(org.IntegrationEncoding, encoding) {
+		return nil
+	}
+
+	var secret string
+	if org.IntegrationSecret != nil {
+		secret = *org.IntegrationSecret
+	} else {
+		var err error
+		if secret, _, err = auth.GenerateOpaqueToken(); err != nil {
+			return fmt.Errorf("failed to generate integration secret: %w", err)
+		}
+	}
+
+	integration := chirpstack.DefaultHTTPIntegration(*org.ApplicationID, url, encoding, secret)
+	err := p.chirpStack.CreateHTTPIntegration(integration)
+	if errors.Is(err, chirpstack.ErrConflict) {
+		err = p.chirpStack.UpdateHTTPIntegration(integration)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to register ChirpStack HTTP integration: %w", err)
+	}
+
+	if err := p.store.UpdateOrganizationIntegration(org.ID, url, encoding, secret); err != nil {
+		return fmt.Errorf("failed to record ChirpStack HTTP integration: %w", err)
+	}
+	org.IntegrationURL = &url
+	org.IntegrationEncoding = &encoding
+	org.IntegrationSecret = &secret
+	return nil
+}
+
+func equalString(p *string, s string) bool {
+	return p != nil && *p == s
+}
+
+// defaultCodecScript returns the latest published script of the default
+// codec, or an empty string when there is none
+func (p *ProvisioningSaga) defaultCodecScript() (string, error) {
+	if p.codecs == nil {
+		return "", nil
+	}
+
+	codec, err := p.codecs.GetDefaultCodec()
+	if errors.Is(err, models.ErrCodecNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	version, err := p.codecs.GetPublishedCodecVersion(codec.ID)
+	if errors.Is(err, models.ErrCodecVersionNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return version.Script, nil
+}
+
+func (p *ProvisioningSaga) withCodec(profile models.ChirpStackDeviceProfile) models.ChirpStackDeviceProfile {
+	if p.script != "" {
+		profile.PayloadCodecRuntime = "JS"
+		profile.PayloadCodecScript = p.script
+	}
+	return profile
+}
+
+// fail handles an error of step failed. Outages keep the recorded steps for
+// the next attempt, anything else rolls them back.
+func (p *ProvisioningSaga) fail(org *models.Organization, failed int, cause error) error {
+	err := fmt.Errorf("failed to create ChirpStack %s: %w", p.steps[failed].name, cause)
+
+	if !errors.Is(cause, chirpstack.ErrUnavailable) {
+		org.ProvisioningStatus = models.ProvisioningFailed
+		for i := failed - 1; i >= 0; i-- {
+			step := p.steps[i]
+			field := step.field(org)
+			if *field == nil {
+				continue
+			}
+			if rmErr := step.remove(**field); rmErr != nil && !errors.Is(rmErr, chirpstack.ErrNotFound) {
+				err = fmt.Errorf("%w (rollback of %s %s failed: %v)", err, step.name, **field, rmErr)
+				break
+			}
+			*field = nil
+		}
+	}
+
+	message := err.Error()
+	org.ProvisioningError = &message
+	if storeErr := p.store.UpdateOrganizationProvisioning(org); storeErr != nil {
+		fmt.Printf("Warning: Failed to store provisioning state of organization %s: %v\n", org.ID, storeErr)
+	}
+
+	return err
+}
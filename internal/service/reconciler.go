@@ -0,0 +1,308 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// reconcilePageSize is the number of ChirpStack devices fetched per request
+const reconcilePageSize = 100
+
+// Reconciler compares the devices table with the devices in each
+// organization's ChirpStack application and reports the drift. With repair
+// on it fixes the flags of local rows, queues provisioning jobs to recreate
+// or reactivate devices and deletes ChirpStack devices nobody owns.
+type Reconciler struct {
+	orgRepo    interfaces.ReconcileOrganizations
+	deviceRepo interfaces.ReconcileDevices
+	jobRepo    interfaces.JobEnqueuer
+	chirpStack interfaces.ChirpStackClient
+
+	// running is held for the duration of a run
+	running sync.Mutex
+
+	mu   sync.Mutex
+	last *models.ReconcileReport
+}
+
+// NewReconciler creates the reconciler. chirpStack is nil when the ChirpStack
+// integration is disabled.
+func NewReconciler(orgRepo interfaces.ReconcileOrganizations, deviceRepo interfaces.ReconcileDevices, jobRepo interfaces.JobEnqueuer, chirpStack interfaces.ChirpStackClient) *Reconciler {
+	return &Reconciler{
+		orgRepo:    orgRepo,
+		deviceRepo: deviceRepo,
+		jobRepo:    jobRepo,
+		chirpStack: chirpStack,
+	}
+}
+
+// Start reconciles all organizations every interval
+func (r *Reconciler) Start(interval time.Duration, repair bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := r.Reconcile(&models.ReconcileRequest{Repair: repair})
+			if err != nil {
+				fmt.Printf("Warning: Device reconciliation failed: %v\n", err)
+				continue
+			}
+			if len(report.Drift) > 0 || len(report.Errors) > 0 {
+				fmt.Printf("Device reconciliation found %d differences in %d organizations (%d could not be checked)\n",
+					len(report.Drift), report.Organizations, len(report.Errors))
+			}
+		}
+	}()
+}
+
+// Reconcile checks one organization, or all provisioned organizations when
+// none is given. Only one run happens at a time.
+func (r *Reconciler) Reconcile(req *models.ReconcileRequest) (*models.ReconcileReport, error) {
+	if r.chirpStack == nil {
+		return nil, models.ErrChirpStackDisabled
+	}
+	if !r.running.TryLock() {
+		return nil, models.ErrReconcileRunning
+	}
+	defer r.running.Unlock()
+
+	var orgs []models.Organization
+	if req.OrganizationID != nil {
+		org, err := r.orgRepo.GetOrganizationByID(*req.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, *org)
+	} else {
+		var err error
+		if orgs, err = r.orgRepo.GetProvisionedOrganizations(); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &models.ReconcileReport{
+		StartedAt: time.Now().UTC(),
+		Repair:    req.Repair,
+		Drift:     []models.DeviceDrift{},
+		Errors:    map[string]string{},
+	}
+	for i := range orgs {
+		if err := r.reconcileOrganization(report, &orgs[i], req.Repair); err != nil {
+			report.Errors[orgs[i].ID.String()] = err.Error()
+		}
+	}
+	report.Organizations = len(orgs)
+	report.FinishedAt = time.Now().UTC()
+
+	r.mu.Lock()
+	r.last = report
+	r.mu.Unlock()
+
+	return report, nil
+}
+
+// LastReport returns the report of the most recent run
+func (r *Reconciler) LastReport() (*models.ReconcileReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.last == nil {
+		return nil, models.ErrNoReconcileRun
+	}
+	return r.last, nil
+}
+
+func (r *Reconciler) reconcileOrganization(report *models.ReconcileReport, org *models.Organization, repair bool) error {
+	if org.ProvisioningStatus != models.ProvisioningProvisioned || org.ApplicationID == nil {
+		return fmt.Errorf("organization is not provisioned")
+	}
+
+	remote, err := r.listApplicationDevices(*org.ApplicationID)
+	if err != nil {
+		return err
+	}
+
+	local, err := r.deviceRepo.GetOrganizationDevices(org.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get devices: %w", err)
+	}
+	report.Devices += len(local)
+
+	// Sessions are only looked up where the flags need checking
+	inChirpStack := make(map[string]bool, len(remote))
+	for _, item := range remote {
+		inChirpStack[normalizeDevEUI(item.DevEUI)] = true
+	}
+	activated := make(map[string]bool)
+	for _, device := range local {
+		eui := normalizeDevEUI(device.DevEUI)
+		if !inChirpStack[eui] || (device.ChirpStackDeviceCreated && !device.ChirpStackDeviceActivated) {
+			continue
+		}
+		ok, err := r.isActivated(device.DevEUI)
+		if err != nil {
+			return err
+		}
+		activated[eui] = ok
+	}
+
+	drift := CompareDevices(org.ID, local, remote, activated)
+	for i := range drift {
+		d := &drift[i]
+
+		// A ChirpStack device without a row here may belong to another
+		// organization. Orphans get deleted, so they are only repaired once the
+		// lookup has confirmed that no row exists.
+		if d.Kind == models.DriftOrphan {
+			owner, err := r.deviceRepo.GetDeviceByDevEUI(d.DevEUI)
+			switch {
+			case err == nil && owner.OrganizationID != org.ID:
+				d.Kind = models.DriftMisplaced
+				d.DeviceID = &owner.ID
+			case err != nil && !errors.Is(err, models.ErrDeviceNotFound):
+				d.Error = fmt.Sprintf("failed to look up device owner: %v", err)
+				continue
+			}
+		}
+
+		if repair {
+			if err := r.repair(d, activated); err != nil {
+				d.Error = err.Error()
+			} else {
+				d.Repaired = true
+			}
+		}
+	}
+
+	report.Drift = append(report.Drift, drift...)
+	return nil
+}
+
+// isActivated tells whether ChirpStack can talk to a device: ABP devices need
+// a session, OTAA devices need their root keys and get a session on join.
+// Devices no longer in the allowed devices are checked as ABP.
+func (r *Reconciler) isActivated(devEUI string) (bool, error) {
+	allowed, err := r.deviceRepo.GetAllowedDeviceByDevEUI(devEUI)
+	if err == nil && allowed.ActivationMode == models.ActivationOTAA {
+		_, err := r.chirpStack.GetDeviceKeys(devEUI)
+		if errors.Is(err, chirpstack.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	activation, err := r.chirpStack.GetDeviceActivation(devEUI)
+	if err != nil {
+		return false, err
+	}
+	return activation != nil, nil
+}
+
+// repair fixes one difference. Devices are recreated and reactivated by the
+// provisioning job, which skips the steps the flags mark as done.
+func (r *Reconciler) repair(d *models.DeviceDrift, activated map[string]bool) error {
+	switch d.Kind {
+	case models.DriftMissing:
+		return r.reprovision(d, false)
+	case models.DriftNotActivated:
+		return r.reprovision(d, true)
+	case models.DriftUnflagged:
+		if activated[normalizeDevEUI(d.DevEUI)] {
+			return r.deviceRepo.UpdateDeviceChirpStackStatus(*d.DeviceID, true, true)
+		}
+		return r.reprovision(d, true)
+	case models.DriftOrphan:
+		err := r.chirpStack.DeleteDevice(d.DevEUI)
+		if err != nil && !errors.Is(err, chirpstack.ErrNotFound) {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s devices are not repaired automatically", d.Kind)
+	}
+}
+
+func (r *Reconciler) reprovision(d *models.DeviceDrift, created bool) error {
+	if err := r.deviceRepo.UpdateDeviceChirpStackStatus(*d.DeviceID, created, false); err != nil {
+		return err
+	}
+	orgID := d.OrganizationID
+	return r.jobRepo.Enqueue(&models.Job{
+		Type:           models.JobProvisionDevice,
+		OrganizationID: &orgID,
+		DeviceID:       d.DeviceID,
+	})
+}
+
+func (r *Reconciler) listApplicationDevices(applicationID string) ([]models.ChirpStackDeviceListItem, error) {
+	var devices []models.ChirpStackDeviceListItem
+	for {
+		page, total, err := r.chirpStack.ListDevices(applicationID, reconcilePageSize, len(devices))
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, page...)
+		if len(page) == 0 || len(devices) >= total {
+			return devices, nil
+		}
+	}
+}
+
+// CompareDevices returns the drift between the local devices of an
+// organization and the devices in its ChirpStack application. activated
+// tells, by upper case DevEUI, whether a device present in ChirpStack has a
+// session.
+func CompareDevices(orgID uuid.UUID, local []models.Device, remote []models.ChirpStackDeviceListItem, activated map[string]bool) []models.DeviceDrift {
+	inChirpStack := make(map[string]bool, len(remote))
+	for _, item := range remote {
+		inChirpStack[normalizeDevEUI(item.DevEUI)] = true
+	}
+
+	drift := []models.DeviceDrift{}
+	known := make(map[string]bool, len(local))
+	for _, device := range local {
+		eui := normalizeDevEUI(device.DevEUI)
+		known[eui] = true
+
+		kind := ""
+		switch {
+		case !inChirpStack[eui]:
+			if device.ChirpStackDeviceCreated {
+				kind = models.DriftMissing
+			}
+		case !device.ChirpStackDeviceCreated:
+			kind = models.DriftUnflagged
+		case device.ChirpStackDeviceActivated && !activated[eui]:
+			kind = models.DriftNotActivated
+		}
+
+		if kind != "" {
+			id := device.ID
+			drift = append(drift, models.DeviceDrift{Kind: kind, OrganizationID: orgID, DeviceID: &id, DevEUI: device.DevEUI})
+		}
+	}
+
+	for _, item := range remote {
+		if !known[normalizeDevEUI(item.DevEUI)] {
+			drift = append(drift, models.DeviceDrift{Kind: models.DriftOrphan, OrganizationID: orgID, DevEUI: item.DevEUI})
+		}
+	}
+
+	return drift
+}
+
+// normalizeDevEUI makes DevEUIs comparable, ChirpStack returns them in lower
+// case while devices are registered in upper case
+func normalizeDevEUI(devEUI string) string {
+	return strings.ToUpper(devEUI)
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxTelemetryPoints is the most samples or buckets a query returns
+	MaxTelemetryPoints = 10000
+	// defaultTelemetryRange is the range queried when from is not given
+	defaultTelemetryRange = 24 * time.Hour
+)
+
+// TelemetryService answers telemetry queries for the devices a user can see
+// and keeps the monthly partitions of the telemetry table in place
+type TelemetryService struct {
+	devices   interfaces.DeviceAccess
+	telemetry interfaces.TelemetryStore
+}
+
+func NewTelemetryService(devices interfaces.DeviceAccess, telemetry interfaces.TelemetryStore) *TelemetryService {
+	return &TelemetryService{devices: devices, telemetry: telemetry}
+}
+
+// Start creates the partitions of this and next month now and once a day
+func (s *TelemetryService) Start() {
+	ensure := func() {
+		now := time.Now().UTC()
+		if err := s.telemetry.EnsureTelemetryPartitions(now, now.AddDate(0, 1, 0)); err != nil {
+			fmt.Printf("Warning: Failed to create telemetry partitions: %v\n", err)
+		}
+	}
+	ensure()
+
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ensure()
+		}
+	}()
+}
+
+// GetDeviceTelemetry returns the samples of a device in the queried range,
+// or their minimum, maximum and average per interval when one is given. The
+// range defaults to the last day, the fields to all of them.
+func (s *TelemetryService) GetDeviceTelemetry(deviceID, userID uuid.UUID, role string, query *models.TelemetryQuery) (*models.TelemetryResponse, error) {
+	if _, err := s.devices.GetDeviceByID(deviceID, userID, role); err != nil {
+		return nil, err
+	}
+
+	from, to, err := telemetryRange(query.From, query.To)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := telemetryFields(query.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.TelemetryResponse{DeviceID: deviceID, From: from, To: to, Fields: fields}
+
+	if query.Interval == "" {
+		points, err := s.telemetry.GetTelemetry(deviceID, from, to, fields, MaxTelemetryPoints+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(points) > MaxTelemetryPoints {
+			return nil, fmt.Errorf("%w: more than %d samples in range, give an interval", models.ErrInvalidTelemetryQuery, MaxTelemetryPoints)
+		}
+		response.Points = points
+		return response, nil
+	}
+
+	interval, err := time.ParseDuration(query.Interval)
+	if err != nil || interval < time.Second {
+		return nil, fmt.Errorf("%w: interval must be a duration of at least 1s", models.ErrInvalidTelemetryQuery)
+	}
+	if buckets := (to.Sub(from) + interval - 1) / interval; buckets > MaxTelemetryPoints {
+		return nil, fmt.Errorf("%w: more than %d intervals in range", models.ErrInvalidTelemetryQuery, MaxTelemetryPoints)
+	}
+
+	points, err := s.telemetry.GetTelemetryBuckets(deviceID, from, to, interval, fields)
+	if err != nil {
+		return nil, err
+	}
+	response.Interval = interval.String()
+	response.Points = points
+	return response, nil
+}
+
+// telemetryRange parses the from and to of a query, to defaults to now and
+// from to a day before to
+func telemetryRange(fromParam, toParam string) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if toParam != "" {
+		t, err := time.Parse(time.RFC3339, toParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be an RFC 3339 time", models.ErrInvalidTelemetryQuery)
+		}
+		to = t.UTC()
+	}
+
+	from := to.Add(-defaultTelemetryRange)
+	if fromParam != "" {
+		t, err := time.Parse(time.RFC3339, fromParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be an RFC 3339 time", models.ErrInvalidTelemetryQuery)
+		}
+		from = t.UTC()
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be before to", models.ErrInvalidTelemetryQuery)
+	}
+	return from, to, nil
+}
+
+// telemetryFields parses the comma separated fields of a query, all fields
+// when none are given
+func telemetryFields(param string) ([]string, error) {
+	if param == "" {
+		return models.TelemetryFields, nil
+	}
+
+	fields := []string{}
+	seen := map[string]bool{}
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if !models.IsValidTelemetryField(field) {
+			return nil, fmt.Errorf("%w: unknown field %q", models.ErrInvalidTelemetryQuery, field)
+		}
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
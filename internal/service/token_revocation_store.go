@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/interfaces"
+
+	"github.com/google/uuid"
+)
+
+// TokenRevocationStore keeps the access token denylist in memory so that
+// AuthMiddleware does not hit the database on every request. Revocations are
+// written through to Postgres and the cache is periodically reloaded to pick
+// up revocations made by other instances.
+type TokenRevocationStore struct {
+	repo           interfaces.TokenRevocationStorage
+	accessTokenTTL time.Duration
+
+	mu         sync.RWMutex
+	revoked    map[string]time.Time    // jti -> token expiry
+	userCutoff map[uuid.UUID]time.Time // user -> tokens issued up to are revoked
+}
+
+func NewTokenRevocationStore(repo interfaces.TokenRevocationStorage, accessTokenTTL time.Duration) *TokenRevocationStore {
+	return &TokenRevocationStore{
+		repo:           repo,
+		accessTokenTTL: accessTokenTTL,
+		revoked:        make(map[string]time.Time),
+		userCutoff:     make(map[uuid.UUID]time.Time),
+	}
+}
+
+// RevokeToken denylists a single access token
+func (s *TokenRevocationStore) RevokeToken(jti string, userID uuid.UUID, expiresAt time.Time) error {
+	if err := s.repo.RevokeAccessToken(jti, userID, expiresAt.UTC()); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revoked[jti] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeUserTokens rejects every access token issued to a user so far
+func (s *TokenRevocationStore) RevokeUserTokens(userID uuid.UUID) error {
+	// Token timestamps and the stored cutoff have microsecond precision.
+	// IsRevoked rejects tokens issued at or before the cutoff.
+	cutoff := time.Now().UTC().Truncate(time.Microsecond)
+
+	if err := s.repo.RevokeUserAccessTokens(userID, cutoff); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if cutoff.After(s.userCutoff[userID]) {
+		s.userCutoff[userID] = cutoff
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// IsRevoked reports whether the access token described by claims has been revoked
+func (s *TokenRevocationStore) IsRevoked(claims *auth.JWTClaims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.revoked[claims.ID]; ok {
+		return true
+	}
+
+	if cutoff, ok := s.userCutoff[claims.UserID]; ok {
+		if claims.IssuedAt == nil || !claims.IssuedAt.Time.After(cutoff) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reload replaces the cache with the current state of the database
+func (s *TokenRevocationStore) Reload() error {
+	revoked, err := s.repo.GetRevokedAccessTokens()
+	if err != nil {
+		return err
+	}
+
+	// Cutoffs older than the access token lifetime cannot match a valid token
+	since := time.Now().UTC().Add(-s.accessTokenTTL)
+	userCutoff, err := s.repo.GetUserRevocationsSince(since)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Keep local entries that may have been added while loading
+	now := time.Now()
+	for jti, expiresAt := range s.revoked {
+		if _, ok := revoked[jti]; !ok && expiresAt.After(now) {
+			revoked[jti] = expiresAt
+		}
+	}
+	for userID, cutoff := range s.userCutoff {
+		if cutoff.After(since) && cutoff.After(userCutoff[userID]) {
+			userCutoff[userID] = cutoff
+		}
+	}
+
+	s.revoked = revoked
+	s.userCutoff = userCutoff
+
+	return nil
+}
+
+// Start reloads the cache every interval and prunes expired revocations
+func (s *TokenRevocationStore) Start(interval time.Duration) {
+	if err := s.Reload(); err != nil {
+		fmt.Printf("Warning: Failed to load token revocations: %v\n", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := s.repo.DeleteExpiredRevocations(time.Now().UTC().Add(-s.accessTokenTTL)); err != nil {
+				fmt.Printf("Warning: Failed to prune token revocations: %v\n", err)
+			}
+			if err := s.Reload(); err != nil {
+				fmt.Printf("Warning: Failed to reload token revocations: %v\n", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+type TokenService struct {
+	jwtService       *auth.JWTService
+	refreshTokenRepo *repository.RefreshTokenRepository
+	userRepo         *repository.UserRepository
+	revocationStore  *TokenRevocationStore
+	refreshTokenTTL  time.Duration
+}
+
+func NewTokenService(jwtService *auth.JWTService, refreshTokenRepo *repository.RefreshTokenRepository, userRepo *repository.UserRepository, revocationStore *TokenRevocationStore, refreshTokenTTL time.Duration) *TokenService {
+	return &TokenService{
+		jwtService:       jwtService,
+		refreshTokenRepo: refreshTokenRepo,
+		userRepo:         userRepo,
+		revocationStore:  revocationStore,
+		refreshTokenTTL:  refreshTokenTTL,
+	}
+}
+
+// IssueTokens starts a new session for a user: a short-lived access token and
+// a refresh token belonging to a new token family. mfa records that the login
+// was completed with a second factor.
+func (s *TokenService) IssueTokens(user *models.User, mfa bool) (*models.TokenPair, error) {
+	return s.issueTokens(user, uuid.Nil, uuid.New(), mfa)
+}
+
+// issueTokens creates an access and refresh token pair. When currentID is set
+// the refresh token replaces that token within the same family.
+func (s *TokenService) issueTokens(user *models.User, currentID, familyID uuid.UUID, mfa bool) (*models.TokenPair, error) {
+	amr := []string{auth.AMRPassword}
+	if mfa {
+		amr = append(amr, auth.AMROTP)
+	}
+
+	accessToken, err := s.jwtService.GenerateToken(user.ID, user.Email, user.Role, amr...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	plain, hash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(s.refreshTokenTTL),
+		MFA:       mfa,
+	}
+
+	if currentID == uuid.Nil {
+		err = s.refreshTokenRepo.CreateRefreshToken(refreshToken)
+	} else {
+		err = s.refreshTokenRepo.RotateRefreshToken(currentID, refreshToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: plain,
+		ExpiresIn:    int64(s.jwtService.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token
+// is revoked (rotation). Presenting an already rotated token means it has
+// leaked, so the whole family is revoked and the user has to log in again.
+func (s *TokenService) Refresh(refreshToken string) (*models.AuthResponse, error) {
+	current, err := s.refreshTokenRepo.GetRefreshTokenByHash(auth.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	if current.RevokedAt != nil {
+		if current.ReplacedBy != nil {
+			if err := s.refreshTokenRepo.RevokeRefreshTokenFamily(current.FamilyID); err != nil {
+				return nil, err
+			}
+			return nil, models.ErrRefreshTokenReused
+		}
+		return nil, models.ErrInvalidRefreshToken
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return nil, models.ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetUserByID(current.UserID.String())
+	if err != nil || user.SuspendedAt != nil {
+		return nil, models.ErrInvalidRefreshToken
+	}
+
+	tokens, err := s.issueTokens(user, current.ID, current.FamilyID, current.MFA)
+	if errors.Is(err, models.ErrRefreshTokenReused) {
+		// Lost a race against another request rotating the same token
+		if err := s.refreshTokenRepo.RevokeRefreshTokenFamily(current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, models.ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	}, nil
+}
+
+// Logout ends the session the refresh token belongs to
+func (s *TokenService) Logout(refreshToken string) error {
+	current, err := s.refreshTokenRepo.GetRefreshTokenByHash(auth.HashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+
+	return s.refreshTokenRepo.RevokeRefreshTokenFamily(current.FamilyID)
+}
+
+// LogoutAll ends every session of a user by revoking all refresh tokens and
+// every access token issued so far
+func (s *TokenService) LogoutAll(userID uuid.UUID) error {
+	if err := s.refreshTokenRepo.RevokeUserRefreshTokens(userID); err != nil {
+		return err
+	}
+
+	return s.revocationStore.RevokeUserTokens(userID)
+}
+
+// RevokeAccessToken denylists a single access token, e.g. the one used to log out
+func (s *TokenService) RevokeAccessToken(jti string, userID uuid.UUID, expiresAt time.Time) error {
+	return s.revocationStore.RevokeToken(jti, userID, expiresAt)
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"sync"
 
 	"go-auth-api/internal/auth"
 	"go-auth-api/internal/models"
@@ -10,19 +12,39 @@ import (
 )
 
 type UserService struct {
-	userRepo          *repository.UserRepository
-	jwtService        *auth.JWTService
-	chirpStackService *ChirpStackService
+	userRepo       *repository.UserRepository
+	tokenService   *TokenService
+	accountService *AccountService
+	mfaService     *MFAService
+	loginGuard     *LoginGuard
+	hasher         *auth.PasswordHasher
 }
 
-func NewUserService(userRepo *repository.UserRepository, jwtService *auth.JWTService, chirpStackService *ChirpStackService) *UserService {
+func NewUserService(userRepo *repository.UserRepository, tokenService *TokenService, accountService *AccountService, mfaService *MFAService, loginGuard *LoginGuard, hasher *auth.PasswordHasher) *UserService {
 	return &UserService{
-		userRepo:          userRepo,
-		jwtService:        jwtService,
-		chirpStackService: chirpStackService,
+		userRepo:       userRepo,
+		tokenService:   tokenService,
+		accountService: accountService,
+		mfaService:     mfaService,
+		loginGuard:     loginGuard,
+		hasher:         hasher,
 	}
 }
 
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash returns a bcrypt hash to check passwords against when the
+// email is unknown, so response times don't reveal which accounts exist
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = auth.HashPassword("dummy-password")
+	})
+	return dummyHash
+}
+
 func (s *UserService) Register(req *models.RegisterRequest) (*models.AuthResponse, error) {
 	// Check if user already exists
 	existingUser, _ := s.userRepo.GetUserByEmail(req.Email)
@@ -31,7 +53,7 @@ func (s *UserService) Register(req *models.RegisterRequest) (*models.AuthRespons
 	}
 
 	// Hash password
-	hashedPassword, err := auth.HashPassword(req.Password)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -41,57 +63,90 @@ func (s *UserService) Register(req *models.RegisterRequest) (*models.AuthRespons
 		Email:        req.Email,
 		PasswordHash: hashedPassword,
 		FullName:     req.FullName,
+		Role:         models.RoleCustomer,
 	}
 
 	if err := s.userRepo.CreateUser(user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Generate JWT token
-	token, err := s.jwtService.GenerateToken(user.ID, user.Email)
+	// Generate access and refresh tokens
+	tokens, err := s.tokenService.IssueTokens(user, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	// Create ChirpStack resources if enabled
-	if s.chirpStackService != nil && s.chirpStackService.IsEnabled() {
-		chirpStackData, err := s.chirpStackService.CreateUserResources(user.ID.String(), user.Email)
-		if err != nil {
-			// Log error but don't fail user registration
-			fmt.Printf("Warning: Failed to create ChirpStack resources for user %s: %v\n", user.Email, err)
-		} else {
-			fmt.Printf("Successfully created ChirpStack resources for user %s: TenantID=%s, ApplicationID=%s, DeviceProfileID=%s\n",
-				user.Email, chirpStackData.TenantID, chirpStackData.ApplicationID, chirpStackData.DeviceProfileID)
-		}
+	// ChirpStack resources are created once the email address is verified
+	if err := s.accountService.SendVerificationEmail(user); err != nil {
+		// Log error but don't fail user registration, the email can be resent
+		fmt.Printf("Warning: Failed to send verification email to %s: %v\n", user.Email, err)
 	}
 
 	return &models.AuthResponse{
-		Token: token,
-		User:  *user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
 	}, nil
 }
 
 func (s *UserService) Login(req *models.LoginRequest) (*models.AuthResponse, error) {
+	// Refuse addresses with too many recent failures before spending bcrypt time
+	if err := s.loginGuard.CheckIP(req.IPAddress); err != nil {
+		return nil, err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetUserByEmail(req.Email)
 	if err != nil {
+		if _, err := s.hasher.Check(req.Password, dummyPasswordHash()); err != nil {
+			return nil, err
+		}
+		s.loginGuard.RecordFailure(nil, req)
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
+	if err := s.loginGuard.CheckAccount(user); err != nil {
+		return nil, err
+	}
+
 	// Check password
-	if !auth.CheckPasswordHash(req.Password, user.PasswordHash) {
+	valid, err := s.hasher.Check(req.Password, user.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		s.loginGuard.RecordFailure(user, req)
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
-	// Generate JWT token
-	token, err := s.jwtService.GenerateToken(user.ID, user.Email)
+	if user.SuspendedAt != nil {
+		return nil, fmt.Errorf("account is suspended")
+	}
+
+	// With two-factor authentication enabled the password only starts the
+	// login, failures are only cleared once the code has been checked
+	if user.TOTPEnabledAt != nil {
+		mfaToken, err := s.mfaService.StartChallenge(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.AuthResponse{MFARequired: true, MFAToken: mfaToken, User: *user}, nil
+	}
+
+	s.loginGuard.RecordSuccess(user, req)
+
+	// Generate access and refresh tokens
+	tokens, err := s.tokenService.IssueTokens(user, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
 	return &models.AuthResponse{
-		Token: token,
-		User:  *user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
 	}, nil
 }
 
@@ -147,6 +202,7 @@ func (s *UserService) UpdateUser(id string, req *models.UpdateUserRequest) (*mod
 			return nil, fmt.Errorf("email already exists")
 		}
 		updates["email"] = req.Email
+		updates["email_verified_at"] = nil
 	}
 
 	if req.FullName != "" {
@@ -154,7 +210,7 @@ func (s *UserService) UpdateUser(id string, req *models.UpdateUserRequest) (*mod
 	}
 
 	if req.Password != "" {
-		hashedPassword, err := auth.HashPassword(req.Password)
+		hashedPassword, err := s.hasher.Hash(req.Password)
 		if err != nil {
 			return nil, fmt.Errorf("failed to hash password: %w", err)
 		}
@@ -171,20 +227,91 @@ func (s *UserService) UpdateUser(id string, req *models.UpdateUserRequest) (*mod
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
+	// A password change ends all existing sessions
+	if _, ok := updates["password_hash"]; ok {
+		if err := s.tokenService.LogoutAll(existingUser.ID); err != nil {
+			return nil, fmt.Errorf("failed to revoke user tokens: %w", err)
+		}
+	}
+
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// A new email address has to be verified again
+	if _, ok := updates["email"]; ok {
+		if err := s.accountService.SendVerificationEmail(user); err != nil {
+			fmt.Printf("Warning: Failed to send verification email to %s: %v\n", user.Email, err)
+		}
+	}
+
 	// Return updated user
+	return user, nil
+}
+
+// UpdateUserRole changes the role of a user
+func (s *UserService) UpdateUserRole(id string, role string) (*models.User, error) {
+	if !models.IsValidRole(role) {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
+	// Check if user exists
+	_, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	err = s.userRepo.UpdateUser(id, map[string]interface{}{"role": role})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user role: %w", err)
+	}
+
 	return s.userRepo.GetUserByID(id)
 }
 
+// SuspendUser suspends a user account and revokes all of its tokens
+func (s *UserService) SuspendUser(id string) error {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := s.userRepo.SetUserSuspended(id, true); err != nil {
+		return err
+	}
+
+	if err := s.tokenService.LogoutAll(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	return nil
+}
+
+// ReactivateUser lifts the suspension of a user account
+func (s *UserService) ReactivateUser(id string) error {
+	return s.userRepo.SetUserSuspended(id, false)
+}
+
 // DeleteUser deletes a user by ID
 func (s *UserService) DeleteUser(id string) error {
 	// Check if user exists
-	_, err := s.userRepo.GetUserByID(id)
+	user, err := s.userRepo.GetUserByID(id)
 	if err != nil {
 		return fmt.Errorf("user not found")
 	}
 
-	// Delete user
-	err = s.userRepo.DeleteUser(id)
+	// Revoke tokens that are already out before the user disappears. Only
+	// owners of organizations that have another owner can be deleted.
+	err = s.userRepo.DeleteUser(id, func() error {
+		if err := s.tokenService.LogoutAll(user.ID); err != nil {
+			return fmt.Errorf("failed to revoke user tokens: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, models.ErrLastOwner) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -0,0 +1,11 @@
+-- Add role column to users
+-- Roles: admin (fleet and user management), operator (device inventory), customer (own account and devices)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT 'customer';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'operator', 'customer'));
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
+
+-- The first admin has to be promoted manually, e.g.:
+-- UPDATE users SET role = 'admin' WHERE email = 'admin@example.com';
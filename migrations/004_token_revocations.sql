@@ -0,0 +1,20 @@
+-- Access token denylist keyed by JWT ID (jti)
+-- Rows can be removed once the token they refer to has expired.
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
+
+-- Per-user cutoff: every access token issued before revoked_before is rejected.
+-- There is deliberately no foreign key so the cutoff survives user deletion.
+CREATE TABLE IF NOT EXISTS user_token_revocations (
+    user_id UUID PRIMARY KEY,
+    revoked_before TIMESTAMP NOT NULL
+);
+
+-- Account suspension
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP;
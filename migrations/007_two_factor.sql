@@ -0,0 +1,34 @@
+-- TOTP two-factor authentication
+-- totp_secret is set on enrollment, totp_enabled_at once the first code is confirmed.
+-- totp_last_step is the last accepted time step, codes from earlier steps are rejected.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- Single-use recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
+
+-- Sessions remember whether the login was completed with a second factor
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS mfa BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Login challenges between the password and the OTP step
+ALTER TABLE user_tokens DROP CONSTRAINT IF EXISTS user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('email_verification', 'password_reset', 'mfa_challenge'));
+
+-- Per-role security policies
+CREATE TABLE IF NOT EXISTS role_policies (
+    role VARCHAR(20) PRIMARY KEY CHECK (role IN ('admin', 'operator', 'customer')),
+    require_mfa BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO role_policies (role) VALUES ('admin'), ('operator'), ('customer')
+ON CONFLICT (role) DO NOTHING;
@@ -0,0 +1,69 @@
+-- Organizations own the ChirpStack tenant, application, device profile and devices
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    tenant_id VARCHAR(255),
+    application_id VARCHAR(255),
+    device_profile_id VARCHAR(255),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Member roles: owners manage everything including other owners, admins manage
+-- members and devices, members manage devices and viewers only read.
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+
+-- Invitations are accepted with a token mailed to the invited address,
+-- stored as a SHA-256 hash
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_organization_id ON organization_invitations(organization_id);
+
+-- Devices belong to an organization. user_id now records who added the device
+-- and is kept when that user leaves.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE devices ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS devices_user_id_fkey;
+ALTER TABLE devices ADD CONSTRAINT devices_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_devices_organization_id ON devices(organization_id);
+
+-- Move every user with ChirpStack resources or devices into a one-person
+-- organization that takes over the tenant. The tenant columns on users are
+-- no longer written and only kept for rollback.
+INSERT INTO organizations (name, tenant_id, application_id, device_profile_id, created_by, created_at)
+SELECT u.full_name, u.tenant_id, u.application_id, u.device_profile_id, u.id, u.created_at
+FROM users u
+WHERE (u.tenant_id IS NOT NULL OR EXISTS (SELECT 1 FROM devices d WHERE d.user_id = u.id))
+  AND NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = u.id);
+
+INSERT INTO organization_members (organization_id, user_id, role)
+SELECT o.id, o.created_by, 'owner'
+FROM organizations o
+WHERE o.created_by IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+UPDATE devices d
+SET organization_id = o.id
+FROM organizations o
+WHERE d.organization_id IS NULL AND o.created_by = d.user_id;
+
+ALTER TABLE devices ALTER COLUMN organization_id SET NOT NULL;
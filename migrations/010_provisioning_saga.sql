@@ -0,0 +1,9 @@
+-- ChirpStack provisioning runs as a saga: every created resource is recorded
+-- right away, so a retry resumes where the last attempt stopped and a failed
+-- attempt knows what to roll back
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS provisioning_status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (provisioning_status IN ('pending', 'provisioned', 'failed'));
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS provisioning_error TEXT;
+
+UPDATE organizations SET provisioning_status = 'provisioned'
+WHERE tenant_id IS NOT NULL AND application_id IS NOT NULL AND device_profile_id IS NOT NULL;
@@ -0,0 +1,41 @@
+-- Background jobs, written in the same transaction as the row they act on
+-- (transactional outbox) and picked up by worker goroutines. Failed jobs are
+-- retried with backoff until max_attempts, then kept as dead letters.
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'dead')),
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    device_id UUID REFERENCES devices(id) ON DELETE CASCADE,
+    payload JSONB NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 10,
+    run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_jobs_organization_id ON jobs(organization_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_device_id ON jobs(device_id);
+
+-- Organizations and devices that never got provisioned are picked up once,
+-- running the migration again doesn't queue them twice
+INSERT INTO jobs (type, organization_id)
+SELECT 'provision_organization', o.id FROM organizations o
+WHERE o.provisioning_status <> 'provisioned'
+  AND NOT EXISTS (
+      SELECT 1 FROM jobs j
+      WHERE j.type = 'provision_organization' AND j.organization_id = o.id AND j.status IN ('pending', 'running')
+  );
+
+INSERT INTO jobs (type, organization_id, device_id)
+SELECT 'provision_device', d.organization_id, d.id FROM devices d
+WHERE d.chirpstack_device_activated IS NOT TRUE
+  AND NOT EXISTS (
+      SELECT 1 FROM jobs j
+      WHERE j.type = 'provision_device' AND j.device_id = d.id AND j.status IN ('pending', 'running')
+  );
@@ -0,0 +1,25 @@
+-- Allowed devices join either by ABP with preset session keys or by OTAA with
+-- root keys and a JoinEUI. OTAA devices get their address when they join.
+ALTER TABLE allowed_devices ADD COLUMN IF NOT EXISTS activation_mode VARCHAR(4) NOT NULL DEFAULT 'abp'
+    CHECK (activation_mode IN ('abp', 'otaa'));
+ALTER TABLE allowed_devices ADD COLUMN IF NOT EXISTS join_eui VARCHAR(16);
+ALTER TABLE allowed_devices ALTER COLUMN addr_key DROP NOT NULL;
+ALTER TABLE allowed_devices DROP CONSTRAINT IF EXISTS allowed_devices_activation_check;
+ALTER TABLE allowed_devices ADD CONSTRAINT allowed_devices_activation_check CHECK (
+    (activation_mode = 'abp' AND addr_key IS NOT NULL) OR (activation_mode = 'otaa' AND join_eui IS NOT NULL)
+);
+
+-- Organizations get a second device profile for OTAA devices. Provisioned
+-- organizations resume their provisioning to create it.
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS otaa_device_profile_id VARCHAR(255);
+
+INSERT INTO jobs (type, organization_id)
+SELECT 'provision_organization', o.id FROM organizations o
+WHERE o.provisioning_status = 'provisioned' AND o.otaa_device_profile_id IS NULL
+  AND NOT EXISTS (
+      SELECT 1 FROM jobs j
+      WHERE j.type = 'provision_organization' AND j.organization_id = o.id AND j.status IN ('pending', 'running')
+  );
+
+UPDATE organizations SET provisioning_status = 'pending'
+WHERE provisioning_status = 'provisioned' AND otaa_device_profile_id IS NULL;
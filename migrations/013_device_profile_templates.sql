@@ -0,0 +1,16 @@
+-- Device versions carry the template of their ChirpStack device profile.
+-- Versions without one use the organization's default Lnode profiles.
+ALTER TABLE device_versions ADD COLUMN IF NOT EXISTS profile_template JSONB;
+
+-- Device profiles created from a template in an organization's tenant, one
+-- per device version and activation mode
+CREATE TABLE IF NOT EXISTS organization_device_profiles (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    device_version_id UUID NOT NULL REFERENCES device_versions(id) ON DELETE CASCADE,
+    activation_mode VARCHAR(4) NOT NULL CHECK (activation_mode IN ('abp', 'otaa')),
+    device_profile_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, device_version_id, activation_mode)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_device_profiles_version ON organization_device_profiles(device_version_id);
@@ -0,0 +1,3 @@
+-- Organizations pick the LoRaWAN region their ChirpStack profiles are created
+-- for. Existing organizations were provisioned for AS923_2.
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS region VARCHAR(20) NOT NULL DEFAULT 'AS923_2';
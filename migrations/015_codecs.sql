@@ -0,0 +1,61 @@
+-- Payload codecs are JavaScript decoders kept with their full version history.
+-- The default codec is the one of the organizations' default Lnode profiles,
+-- it is created from the built-in script on startup.
+CREATE TABLE IF NOT EXISTS codecs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_codecs_default ON codecs(is_default) WHERE is_default;
+
+-- Versions are numbered per codec. Drafts become published when they are
+-- rolled out and rolled_back when that rollout is undone; new device profiles
+-- get the latest published version.
+CREATE TABLE IF NOT EXISTS codec_versions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    codec_id UUID NOT NULL REFERENCES codecs(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    script TEXT NOT NULL,
+    changelog TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published', 'rolled_back')),
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP,
+    UNIQUE (codec_id, version)
+);
+
+ALTER TABLE device_versions ADD COLUMN IF NOT EXISTS codec_id UUID REFERENCES codecs(id) ON DELETE SET NULL;
+
+-- A rollout pushes a codec version to every device profile using the codec,
+-- one target per profile. Targets keep the script they replaced for rollback.
+CREATE TABLE IF NOT EXISTS codec_rollouts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    codec_id UUID NOT NULL REFERENCES codecs(id) ON DELETE CASCADE,
+    codec_version_id UUID NOT NULL REFERENCES codec_versions(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'running'
+        CHECK (status IN ('running', 'succeeded', 'failed', 'rolling_back', 'rolled_back')),
+    rollback_on_failure BOOLEAN NOT NULL DEFAULT FALSE,
+    started_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_codec_rollouts_codec_id ON codec_rollouts(codec_id);
+
+CREATE TABLE IF NOT EXISTS codec_rollout_targets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    rollout_id UUID NOT NULL REFERENCES codec_rollouts(id) ON DELETE CASCADE,
+    organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL,
+    device_profile_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'updated', 'failed', 'rolled_back')),
+    previous_runtime VARCHAR(20),
+    previous_script TEXT,
+    error TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (rollout_id, device_profile_id)
+);
@@ -0,0 +1,10 @@
+-- ChirpStack posts the events of an organization's application to the
+-- integration URL with the organization's secret as bearer token. Both are
+-- NULL until the integration is registered.
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS integration_secret VARCHAR(64);
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS integration_url TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_organizations_tenant_id ON organizations(tenant_id);
+
+-- Time of the last uplink, join or status event of a device
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
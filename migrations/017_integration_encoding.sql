@@ -0,0 +1,6 @@
+-- Encoding ChirpStack posts the integration events of an organization in.
+-- Integrations registered so far send JSON.
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS integration_encoding VARCHAR(10);
+
+UPDATE organizations SET integration_encoding = 'JSON'
+WHERE integration_url IS NOT NULL AND integration_encoding IS NULL;
@@ -0,0 +1,3 @@
+-- Events from the MQTT integration are matched to their organization by the
+-- application ID in the topic
+CREATE INDEX IF NOT EXISTS idx_organizations_application_id ON organizations(application_id);
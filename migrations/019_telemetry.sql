@@ -0,0 +1,19 @@
+-- Lamp measurements decoded from the uplinks of a device. The table is
+-- partitioned by month; the server creates the partitions of the current and
+-- next month, samples outside them land in the default partition and are
+-- moved out of it when their month's partition is created.
+CREATE TABLE IF NOT EXISTS device_telemetry (
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    time TIMESTAMP NOT NULL,
+    voltage DOUBLE PRECISION,
+    current DOUBLE PRECISION,
+    power DOUBLE PRECISION,
+    energy DOUBLE PRECISION,
+    pf DOUBLE PRECISION,
+    tilt DOUBLE PRECISION,
+    dimming SMALLINT,
+    status_lamp SMALLINT,
+    PRIMARY KEY (device_id, time)
+) PARTITION BY RANGE (time);
+
+CREATE TABLE IF NOT EXISTS device_telemetry_default PARTITION OF device_telemetry DEFAULT;
@@ -0,0 +1,21 @@
+-- Downlink commands sent to devices. queue_item_id is the ID of the item in
+-- the ChirpStack device queue, the ack and txack events carry it back.
+CREATE TABLE IF NOT EXISTS device_commands (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    type VARCHAR(32) NOT NULL,
+    level SMALLINT,
+    "on" BOOLEAN,
+    data BYTEA NOT NULL,
+    f_port SMALLINT NOT NULL,
+    confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    queue_item_id VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'sent', 'acknowledged', 'not_acknowledged')),
+    sent_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_commands_device_id ON device_commands(device_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_device_commands_queue_item_id ON device_commands(queue_item_id);
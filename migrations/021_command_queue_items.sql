@@ -0,0 +1,17 @@
+-- Commands are recorded as pending before they are enqueued in ChirpStack and
+-- get their queue item ID once ChirpStack returns it. A txack or ack for a
+-- queue item no command has yet is kept in device_command_events and applied
+-- when the command gets the queue item.
+ALTER TABLE device_commands ALTER COLUMN queue_item_id DROP NOT NULL;
+ALTER TABLE device_commands ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE device_commands DROP CONSTRAINT IF EXISTS device_commands_status_check;
+ALTER TABLE device_commands ADD CONSTRAINT device_commands_status_check
+    CHECK (status IN ('pending', 'queued', 'sent', 'acknowledged', 'not_acknowledged'));
+
+CREATE TABLE IF NOT EXISTS device_command_events (
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    queue_item_id VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (device_id, queue_item_id)
+);
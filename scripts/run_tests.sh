@@ -162,6 +162,32 @@ run_api_tests() {
         echo "Response: $LOGIN_RESPONSE"
         return 1
     fi
+
+    # The device inventory takes an admin and devices a verified email with
+    # an organization. The user is promoted in the database and verified with
+    # a token stored there, since the verification mail only goes to the log.
+    print_status "Promoting and verifying the test user..."
+    VERIFY_TOKEN="api-test-verification-${TIMESTAMP}"
+    VERIFY_HASH=$(printf '%s' "$VERIFY_TOKEN" | sha256sum | cut -d' ' -f1)
+    docker-compose exec -T postgres psql -U postgres -d auth_db -v ON_ERROR_STOP=1 -c \
+        "UPDATE users SET role = 'admin' WHERE email = '$TEST_EMAIL';
+         INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+         SELECT id, 'email_verification', '$VERIFY_HASH', CURRENT_TIMESTAMP + INTERVAL '1 hour' FROM users WHERE email = '$TEST_EMAIL';" > /dev/null
+    curl -s -X POST http://localhost:8080/api/v1/auth/verify-email \
+        -H "Content-Type: application/json" \
+        -d "{\"token\": \"$VERIFY_TOKEN\"}" > /dev/null
+    LOGIN_RESPONSE=$(curl -s -X POST http://localhost:8080/api/v1/auth/login \
+        -H "Content-Type: application/json" \
+        -d "{\"email\": \"$TEST_EMAIL\", \"password\": \"password123\"}")
+
+    if echo "$LOGIN_RESPONSE" | grep -q "token"; then
+        TOKEN=$(echo "$LOGIN_RESPONSE" | grep -o '"token":"[^"]*"' | cut -d'"' -f4)
+        print_success "Test user promoted and verified"
+    else
+        print_error "User login test failed"
+        echo "Response: $LOGIN_RESPONSE"
+        return 1
+    fi
     
     # Test protected route
     print_status "Testing protected route..."
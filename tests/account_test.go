@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-auth-api/internal/handlers"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/mailer"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock AccountService
+type MockAccountService struct {
+	mock.Mock
+}
+
+var _ interfaces.AccountServiceInterface = (*MockAccountService)(nil)
+
+func (m *MockAccountService) ResendVerification(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockAccountService) VerifyEmail(token string) (*models.User, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockAccountService) RequestPasswordReset(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func (m *MockAccountService) ResetPassword(token, password string) error {
+	args := m.Called(token, password)
+	return args.Error(0)
+}
+
+func setupAccountTestRouter() (*gin.Engine, *MockAccountService) {
+	gin.SetMode(gin.TestMode)
+
+	mockAccountService := &MockAccountService{}
+	accountHandler := handlers.NewAccountHandler(mockAccountService)
+
+	router := gin.New()
+	auth := router.Group("/api/v1/auth")
+	{
+		auth.POST("/verify-email", accountHandler.VerifyEmail)
+		auth.POST("/forgot-password", accountHandler.ForgotPassword)
+		auth.POST("/reset-password", accountHandler.ResetPassword)
+	}
+
+	return router, mockAccountService
+}
+
+func TestAccountFlows(t *testing.T) {
+	router, mockService := setupAccountTestRouter()
+
+	post := func(path string, body interface{}) int {
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Verify email", func(t *testing.T) {
+		mockService.On("VerifyEmail", "good-token").Return(&models.User{ID: uuid.New()}, nil).Once()
+		mockService.On("VerifyEmail", "used-token").Return(nil, models.ErrInvalidUserToken).Once()
+
+		assert.Equal(t, http.StatusOK, post("/api/v1/auth/verify-email", models.VerifyEmailRequest{Token: "good-token"}))
+		assert.Equal(t, http.StatusBadRequest, post("/api/v1/auth/verify-email", models.VerifyEmailRequest{Token: "used-token"}))
+	})
+
+	t.Run("Forgot password does not reveal unknown addresses", func(t *testing.T) {
+		mockService.On("RequestPasswordReset", "nobody@example.com").Return(nil).Once()
+
+		assert.Equal(t, http.StatusOK, post("/api/v1/auth/forgot-password", models.ForgotPasswordRequest{Email: "nobody@example.com"}))
+	})
+
+	t.Run("Forgot password hides mailer failures", func(t *testing.T) {
+		mockService.On("RequestPasswordReset", "test@example.com").Return(errors.New("smtp unavailable")).Once()
+
+		assert.Equal(t, http.StatusOK, post("/api/v1/auth/forgot-password", models.ForgotPasswordRequest{Email: "test@example.com"}))
+	})
+
+	t.Run("Reset password", func(t *testing.T) {
+		mockService.On("ResetPassword", "reset-token", "newpassword").Return(nil).Once()
+		mockService.On("ResetPassword", "expired-token", "newpassword").Return(models.ErrInvalidUserToken).Once()
+
+		assert.Equal(t, http.StatusOK, post("/api/v1/auth/reset-password", models.ResetPasswordRequest{Token: "reset-token", Password: "newpassword"}))
+		assert.Equal(t, http.StatusBadRequest, post("/api/v1/auth/reset-password", models.ResetPasswordRequest{Token: "expired-token", Password: "newpassword"}))
+		assert.Equal(t, http.StatusBadRequest, post("/api/v1/auth/reset-password", models.ResetPasswordRequest{Token: "reset-token", Password: "short"}))
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := mailer.NewWriterMailer(&buf)
+
+	err := m.Send(&mailer.Message{
+		To:      "test@example.com",
+		Subject: "Confirm your email address",
+		Body:    "http://localhost:8080/verify-email?token=abc",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "To: test@example.com")
+	assert.Contains(t, buf.String(), "Subject: Confirm your email address")
+	assert.Contains(t, buf.String(), "verify-email?token=abc")
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/handlers"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock TokenService
+type MockTokenService struct {
+	mock.Mock
+}
+
+// Implement TokenServiceInterface
+var _ interfaces.TokenServiceInterface = (*MockTokenService)(nil)
+
+func (m *MockTokenService) Refresh(refreshToken string) (*models.AuthResponse, error) {
+	args := m.Called(refreshToken)
+	return args.Get(0).(*models.AuthResponse), args.Error(1)
+}
+
+func (m *MockTokenService) Logout(refreshToken string) error {
+	args := m.Called(refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockTokenService) LogoutAll(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockTokenService) RevokeAccessToken(jti string, userID uuid.UUID, expiresAt time.Time) error {
+	args := m.Called(jti, userID, expiresAt)
+	return args.Error(0)
+}
+
+func setupAuthTestRouter() (*gin.Engine, *MockTokenService) {
+	gin.SetMode(gin.TestMode)
+
+	mockTokenService := &MockTokenService{}
+	authHandler := handlers.NewAuthHandler(mockTokenService)
+
+	router := gin.New()
+	auth := router.Group("/api/v1/auth")
+	{
+		auth.POST("/refresh", authHandler.Refresh)
+		auth.POST("/logout", authHandler.Logout)
+	}
+
+	return router, mockTokenService
+}
+
+func TestRefreshToken(t *testing.T) {
+	router, mockService := setupAuthTestRouter()
+
+	t.Run("Successful Refresh", func(t *testing.T) {
+		expectedResponse := &models.AuthResponse{
+			Token:        "new-access-token",
+			RefreshToken: "new-refresh-token",
+			ExpiresIn:    900,
+		}
+
+		mockService.On("Refresh", "valid-refresh-token").Return(expectedResponse, nil)
+
+		jsonBody, _ := json.Marshal(models.RefreshTokenRequest{RefreshToken: "valid-refresh-token"})
+		req, _ := http.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response models.AuthResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "new-access-token", response.Token)
+		assert.Equal(t, "new-refresh-token", response.RefreshToken)
+	})
+
+	t.Run("Reused Token", func(t *testing.T) {
+		mockService.On("Refresh", "rotated-refresh-token").Return((*models.AuthResponse)(nil), models.ErrRefreshTokenReused)
+
+		jsonBody, _ := json.Marshal(models.RefreshTokenRequest{RefreshToken: "rotated-refresh-token"})
+		req, _ := http.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Missing Token", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewBufferString(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestLogout(t *testing.T) {
+	router, mockService := setupAuthTestRouter()
+
+	mockService.On("Logout", "valid-refresh-token").Return(nil)
+
+	jsonBody, _ := json.Marshal(models.LogoutRequest{RefreshToken: "valid-refresh-token"})
+	req, _ := http.NewRequest("POST", "/api/v1/auth/logout", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ interfaces.ChirpStackClient = (*chirpstack.Client)(nil)
+
+// recordedRequest is what the fake ChirpStack server saw
+type recordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Auth   string
+	Body   map[string]interface{}
+}
+
+func newChirpStackServer(t *testing.T, status int, response string) (*chirpstack.Client, *recordedRequest) {
+	recorded := &recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded.Method = r.Method
+		recorded.Path = r.URL.Path
+		recorded.Query = r.URL.RawQuery
+		recorded.Auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		if len(body) > 0 {
+			require.NoError(t, json.Unmarshal(body, &recorded.Body))
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	return chirpstack.NewClient(server.URL+"/api", "test-token", chirpstack.Options{Timeout: 5 * time.Second, MaxRetries: -1}), recorded
+}
+
+func TestChirpStackClientRequests(t *testing.T) {
+	t.Run("Create tenant", func(t *testing.T) {
+		client, recorded := newChirpStackServer(t, http.StatusOK, `{"id":"tenant-1"}`)
+
+		id, err := client.CreateTenant(chirpstack.DefaultTenant("Acme"))
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-1", id)
+		assert.Equal(t, "POST", recorded.Method)
+		assert.Equal(t, "/api/tenants", recorded.Path)
+		assert.Equal(t, "Bearer test-token", recorded.Auth)
+		assert.Equal(t, "Acme", recorded.Body["tenant"].(map[string]interface{})["name"])
+	})
+
+	t.Run("Activate device", func(t *testing.T) {
+		client, recorded := newChirpStackServer(t, http.StatusOK, `{}`)
+
+		err := client.ActivateDevice("C5EABC521E8304EE", models.ChirpStackDeviceActivation{DevAddr: "01020304"})
+		require.NoError(t, err)
+		assert.Equal(t, "/api/devices/C5EABC521E8304EE/activate", recorded.Path)
+		assert.Equal(t, "01020304", recorded.Body["deviceActivation"].(map[string]interface{})["devAddr"])
+	})
+
+	t.Run("Device activation", func(t *testing.T) {
+		client, recorded := newChirpStackServer(t, http.StatusOK, `{"deviceActivation":{"devAddr":"01020304"}}`)
+
+		activation, err := client.GetDeviceActivation("C5EABC521E8304EE")
+		require.NoError(t, err)
+		require.NotNil(t, activation)
+		assert.Equal(t, "01020304", activation.DevAddr)
+		assert.Equal(t, "/api/devices/C5EABC521E8304EE/activation", recorded.Path)
+
+		client, _ = newChirpStackServer(t, http.StatusOK, `{}`)
+		activation, err = client.GetDeviceActivation("C5EABC521E8304EE")
+		require.NoError(t, err)
+		assert.Nil(t, activation)
+	})
+
+	t.Run("List devices", func(t *testing.T) {
+		client, recorded := newChirpStackServer(t, http.StatusOK,
+			`{"totalCount":2,"result":[{"devEui":"0000000000000001","name":"a"},{"devEui":"0000000000000002","name":"b"}]}`)
+
+		devices, total, err := client.ListDevices("app-1", 10, 20)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.Len(t, devices, 2)
+		assert.Equal(t, "GET", recorded.Method)
+		assert.Equal(t, "applicationId=app-1&limit=10&offset=20", recorded.Query)
+	})
+
+	t.Run("Find application by exact name", func(t *testing.T) {
+		client, recorded := newChirpStackServer(t, http.StatusOK,
+			`{"totalCount":2,"result":[{"id":"app-2","name":"Lnode old"},{"id":"app-1","name":"Lnode"}]}`)
+
+		id, err := client.FindApplication("tenant-1", "Lnode")
+		require.NoError(t, err)
+		assert.Equal(t, "app-1", id)
+		assert.Equal(t, "GET", recorded.Method)
+		assert.Equal(t, "/api/applications", recorded.Path)
+		assert.Equal(t, "limit=100&search=Lnode&tenantId=tenant-1", recorded.Query)
+
+		client, _ = newChirpStackServer(t, http.StatusOK, `{"totalCount":1,"result":[{"id":"app-2","name":"Lnode old"}]}`)
+		_, err = client.FindApplication("tenant-1", "Lnode")
+		assert.True(t, errors.Is(err, chirpstack.ErrNotFound))
+	})
+
+	t.Run("Enqueue downlink", func(t *testing.T) {
+		client, recorded := newChirpStackServer(t, http.StatusOK, `{"id":"queue-1"}`)
+
+		id, err := client.EnqueueDownlink(models.ChirpStackQueueItem{DevEUI: "C5EABC521E8304EE", FPort: 10, Data: []byte{0x01, 0x02}})
+		require.NoError(t, err)
+		assert.Equal(t, "queue-1", id)
+		assert.Equal(t, "/api/devices/C5EABC521E8304EE/queue", recorded.Path)
+		item := recorded.Body["queueItem"].(map[string]interface{})
+		assert.Equal(t, "AQI=", item["data"])
+		assert.EqualValues(t, 10, item["fPort"])
+	})
+
+	t.Run("HTTP integration", func(t *testing.T) {
+		client, recorded := newChirpStackServer(t, http.StatusOK, `{}`)
+
+		err := client.CreateHTTPIntegration(chirpstack.DefaultHTTPIntegration("app-1", "https://api.example.com/api/v1/integrations/chirpstack", chirpstack.EncodingJSON, "s3cret"))
+		require.NoError(t, err)
+		assert.Equal(t, "POST", recorded.Method)
+		assert.Equal(t, "/api/applications/app-1/integrations/http", recorded.Path)
+		integration := recorded.Body["integration"].(map[string]interface{})
+		assert.Equal(t, "JSON", integration["encoding"])
+		assert.Equal(t, "https://api.example.com/api/v1/integrations/chirpstack", integration["eventEndpointUrl"])
+		assert.Equal(t, "Bearer s3cret", integration["headers"].(map[string]interface{})["Authorization"])
+	})
+
+	t.Run("Device keys", func(t *testing.T) {
+		client, recorded := newChirpStackServer(t, http.StatusOK, `{"deviceKeys":{"nwkKey":"00","appKey":"11"}}`)
+
+		keys, err := client.GetDeviceKeys("C5EABC521E8304EE")
+		require.NoError(t, err)
+		assert.Equal(t, "11", keys.AppKey)
+		assert.Equal(t, "/api/devices/C5EABC521E8304EE/keys", recorded.Path)
+	})
+}
+
+func TestChirpStackClientErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"not found", http.StatusNotFound, `{"code":5,"message":"Object does not exist"}`, chirpstack.ErrNotFound},
+		{"conflict", http.StatusConflict, `{"code":6,"message":"Object already exists"}`, chirpstack.ErrConflict},
+		{"unauthorized", http.StatusUnauthorized, `{"code":16,"message":"invalid token"}`, chirpstack.ErrUnauthorized},
+		{"forbidden", http.StatusForbidden, `{}`, chirpstack.ErrUnauthorized},
+		{"bad request", http.StatusBadRequest, `bad`, chirpstack.ErrBadRequest},
+		{"server error", http.StatusBadGateway, ``, chirpstack.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newChirpStackServer(t, tt.status, tt.body)
+
+			err := client.DeleteDevice("C5EABC521E8304EE")
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tt.want), "got %v", err)
+
+			var apiErr *chirpstack.APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tt.status, apiErr.StatusCode)
+		})
+	}
+
+	t.Run("gateway message is extracted", func(t *testing.T) {
+		client, _ := newChirpStackServer(t, http.StatusNotFound, `{"code":5,"message":"Object does not exist"}`)
+
+		_, err := client.GetDevice("C5EABC521E8304EE")
+		assert.Contains(t, err.Error(), "Object does not exist")
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		client := chirpstack.NewClient("http://127.0.0.1:1/api", "test-token", chirpstack.Options{Timeout: time.Second, MaxRetries: -1})
+
+		_, err := client.CreateTenant(chirpstack.DefaultTenant("Acme"))
+		assert.True(t, errors.Is(err, chirpstack.ErrUnavailable))
+	})
+}
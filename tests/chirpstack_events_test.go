@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/models"
+
+	"github.com/chirpstack/chirpstack/api/go/v4/integration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// eventFixtures are the events in testdata/chirpstack_events with the
+// message of the ChirpStack integration API they are encoded as. Each has a
+// JSON and a protobuf encoding of the same event and a golden file both must
+// decode to. With -update the protobuf encoding is made from the JSON one.
+var eventFixtures = map[string]func() proto.Message{
+	models.EventUp:       func() proto.Message { return &integration.UplinkEvent{} },
+	models.EventJoin:     func() proto.Message { return &integration.JoinEvent{} },
+	models.EventAck:      func() proto.Message { return &integration.AckEvent{} },
+	models.EventTxAck:    func() proto.Message { return &integration.TxAckEvent{} },
+	models.EventStatus:   func() proto.Message { return &integration.StatusEvent{} },
+	models.EventLog:      func() proto.Message { return &integration.LogEvent{} },
+	models.EventLocation: func() proto.Message { return &integration.LocationEvent{} },
+}
+
+func readEventFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "chirpstack_events", name))
+	require.NoError(t, err)
+	return b
+}
+
+// writeProtobufFixture encodes the JSON fixture of an event as protobuf
+func writeProtobufFixture(t *testing.T, eventType string, newMessage func() proto.Message) {
+	t.Helper()
+	msg := newMessage()
+	require.NoError(t, protojson.Unmarshal(readEventFixture(t, eventType+".json"), msg))
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join("testdata", "chirpstack_events", eventType+".pb"), b, 0o644))
+}
+
+func TestChirpStackEventGolden(t *testing.T) {
+	for eventType, newMessage := range eventFixtures {
+		eventType, newMessage := eventType, newMessage
+		t.Run(eventType, func(t *testing.T) {
+			golden := filepath.Join("testdata", "chirpstack_events", eventType+".golden.json")
+			if *updateGolden {
+				writeProtobufFixture(t, eventType, newMessage)
+			}
+
+			fromJSON, err := chirpstack.DecodeEvent(eventType, chirpstack.EncodingJSON, readEventFixture(t, eventType+".json"))
+			require.NoError(t, err)
+			got, err := json.MarshalIndent(fromJSON, "", "  ")
+			require.NoError(t, err)
+			got = append(got, '\n')
+
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(golden, got, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got), "JSON")
+
+			fromProtobuf, err := chirpstack.DecodeEvent(eventType, chirpstack.EncodingProtobuf, readEventFixture(t, eventType+".pb"))
+			require.NoError(t, err)
+			got, err = json.MarshalIndent(fromProtobuf, "", "  ")
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got)+"\n", "protobuf")
+		})
+	}
+}
+
+func TestChirpStackEventDecoding(t *testing.T) {
+	t.Run("Encoding follows the content type", func(t *testing.T) {
+		for contentType, want := range map[string]string{
+			"":                                chirpstack.EncodingJSON,
+			"application/json":                chirpstack.EncodingJSON,
+			"application/json; charset=utf-8": chirpstack.EncodingJSON,
+			"application/octet-stream":        chirpstack.EncodingProtobuf,
+			"application/x-protobuf":          chirpstack.EncodingProtobuf,
+		} {
+			encoding, err := chirpstack.EventEncoding(contentType)
+			require.NoError(t, err, contentType)
+			assert.Equal(t, want, encoding, contentType)
+		}
+
+		_, err := chirpstack.EventEncoding("text/plain")
+		assert.ErrorIs(t, err, models.ErrUnsupportedEncoding)
+	})
+
+	t.Run("Malformed events are invalid", func(t *testing.T) {
+		_, err := chirpstack.DecodeEvent(models.EventUp, chirpstack.EncodingJSON, []byte(`{"deviceInfo":`))
+		assert.ErrorIs(t, err, models.ErrInvalidEvent)
+
+		uplink := readEventFixture(t, "up.pb")
+		_, err = chirpstack.DecodeEvent(models.EventUp, chirpstack.EncodingProtobuf, uplink[:len(uplink)-3])
+		assert.ErrorIs(t, err, models.ErrInvalidEvent)
+
+		// A deduplication ID that isn't UTF-8
+		_, err = chirpstack.DecodeEvent(models.EventJoin, chirpstack.EncodingProtobuf, []byte{0x0A, 0x01, 0xFF})
+		assert.ErrorIs(t, err, models.ErrInvalidEvent)
+
+		_, err = chirpstack.DecodeEvent("integration", chirpstack.EncodingProtobuf, uplink)
+		assert.ErrorIs(t, err, models.ErrInvalidEvent)
+	})
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyChirpStack answers with the given status codes in order and 200 once
+// they run out
+func flakyChirpStack(t *testing.T, statuses ...int) (string, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n <= len(statuses) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte(`{"id":"ok"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/api", &calls
+}
+
+func fastRetries() chirpstack.Options {
+	return chirpstack.Options{Timeout: time.Second, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+}
+
+func TestChirpStackRetries(t *testing.T) {
+	t.Run("Idempotent calls are retried", func(t *testing.T) {
+		url, calls := flakyChirpStack(t, http.StatusBadGateway, http.StatusServiceUnavailable)
+		client := chirpstack.NewClient(url, "token", fastRetries())
+
+		_, err := client.GetTenant("tenant-1")
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, atomic.LoadInt32(calls))
+	})
+
+	t.Run("Retries give up after MaxRetries", func(t *testing.T) {
+		url, calls := flakyChirpStack(t, 500, 500, 500, 500, 500)
+		opts := fastRetries()
+		opts.MaxRetries = 2
+		client := chirpstack.NewClient(url, "token", opts)
+
+		err := client.DeleteDevice("C5EABC521E8304EE")
+		assert.True(t, errors.Is(err, chirpstack.ErrUnavailable))
+		assert.EqualValues(t, 3, atomic.LoadInt32(calls))
+	})
+
+	t.Run("Creates are not repeated after a server error", func(t *testing.T) {
+		url, calls := flakyChirpStack(t, http.StatusInternalServerError)
+		client := chirpstack.NewClient(url, "token", fastRetries())
+
+		_, err := client.CreateTenant(chirpstack.DefaultTenant("Acme"))
+		assert.Error(t, err)
+		assert.EqualValues(t, 1, atomic.LoadInt32(calls))
+	})
+
+	t.Run("Creates are retried when rejected unprocessed", func(t *testing.T) {
+		url, calls := flakyChirpStack(t, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+		client := chirpstack.NewClient(url, "token", fastRetries())
+
+		_, err := client.CreateTenant(chirpstack.DefaultTenant("Acme"))
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, atomic.LoadInt32(calls))
+	})
+
+	t.Run("Client errors are not retried", func(t *testing.T) {
+		url, calls := flakyChirpStack(t, http.StatusNotFound)
+		client := chirpstack.NewClient(url, "token", fastRetries())
+
+		_, err := client.GetDevice("C5EABC521E8304EE")
+		assert.True(t, errors.Is(err, chirpstack.ErrNotFound))
+		assert.EqualValues(t, 1, atomic.LoadInt32(calls))
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	d, ok := chirpstack.ParseRetryAfter("7", now)
+	assert.True(t, ok)
+	assert.Equal(t, 7*time.Second, d)
+
+	d, ok = chirpstack.ParseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, d)
+
+	d, ok = chirpstack.ParseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+
+	_, ok = chirpstack.ParseRetryAfter("soon", now)
+	assert.False(t, ok)
+	_, ok = chirpstack.ParseRetryAfter("", now)
+	assert.False(t, ok)
+}
+
+func TestChirpStackCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := chirpstack.NewClient(server.URL+"/api", "token", chirpstack.Options{
+		Timeout:          time.Second,
+		MaxRetries:       -1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  50 * time.Millisecond,
+	})
+
+	// Two failures in a row open the circuit
+	assert.Error(t, client.DeleteDevice("C5EABC521E8304EE"))
+	assert.Equal(t, chirpstack.CircuitClosed, client.CircuitStatus().State)
+	assert.Error(t, client.DeleteDevice("C5EABC521E8304EE"))
+	assert.Equal(t, chirpstack.CircuitOpen, client.CircuitStatus().State)
+	assert.Equal(t, 2, client.CircuitStatus().ConsecutiveFailures)
+
+	// Open circuit fails fast without calling ChirpStack
+	err := client.DeleteDevice("C5EABC521E8304EE")
+	assert.True(t, errors.Is(err, chirpstack.ErrCircuitOpen))
+	assert.True(t, errors.Is(err, chirpstack.ErrUnavailable))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	// After the cooldown a failed probe opens it again
+	time.Sleep(60 * time.Millisecond)
+	assert.Error(t, client.DeleteDevice("C5EABC521E8304EE"))
+	assert.Equal(t, chirpstack.CircuitOpen, client.CircuitStatus().State)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// and a successful probe closes it
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, client.DeleteDevice("C5EABC521E8304EE"))
+	assert.Equal(t, chirpstack.CircuitClosed, client.CircuitStatus().State)
+	assert.Equal(t, 0, client.CircuitStatus().ConsecutiveFailures)
+}
+
+type staticCircuit struct {
+	status chirpstack.BreakerStatus
+}
+
+func (s staticCircuit) CircuitStatus() chirpstack.BreakerStatus {
+	return s.status
+}
+
+func TestHealthEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	get := func(handler *handlers.HealthHandler) map[string]interface{} {
+		router := gin.New()
+		router.GET("/health", handler.Health)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/health", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	body := get(handlers.NewHealthHandler(nil))
+	assert.Equal(t, "ok", body["status"])
+	assert.Equal(t, false, body["chirpstack"].(map[string]interface{})["enabled"])
+
+	body = get(handlers.NewHealthHandler(staticCircuit{chirpstack.BreakerStatus{State: chirpstack.CircuitClosed}}))
+	assert.Equal(t, "ok", body["status"])
+
+	body = get(handlers.NewHealthHandler(staticCircuit{chirpstack.BreakerStatus{State: chirpstack.CircuitOpen, ConsecutiveFailures: 5}}))
+	assert.Equal(t, "degraded", body["status"])
+	circuit := body["chirpstack"].(map[string]interface{})["circuit"].(map[string]interface{})
+	assert.Equal(t, chirpstack.CircuitOpen, circuit["state"])
+}
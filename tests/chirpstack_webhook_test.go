@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/handlers"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryIntegrationStore holds the organizations and devices events are
+// matched to. The MQTT subscriber uses it from its own goroutines.
+type memoryIntegrationStore struct {
+	mu       sync.Mutex
+	orgs     []models.Organization
+	devices  []models.Device
+	lastSeen map[uuid.UUID]time.Time
+	samples  []models.TelemetrySample
+	// commands holds the last status of each queue item
+	commands map[string]string
+	// lastSeenErr fails updates of the last seen time
+	lastSeenErr error
+}
+
+func (m *memoryIntegrationStore) findOrganization(match func(org *models.Organization) bool) (*models.Organization, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.orgs {
+		if match(&m.orgs[i]) {
+			org := m.orgs[i]
+			return &org, nil
+		}
+	}
+	return nil, models.ErrOrganizationNotFound
+}
+
+func (m *memoryIntegrationStore) GetOrganizationByTenantID(tenantID string) (*models.Organization, error) {
+	return m.findOrganization(func(org *models.Organization) bool {
+		return org.TenantID != nil && *org.TenantID == tenantID
+	})
+}
+
+func (m *memoryIntegrationStore) GetOrganizationByApplicationID(applicationID string) (*models.Organization, error) {
+	return m.findOrganization(func(org *models.Organization) bool {
+		return org.ApplicationID != nil && *org.ApplicationID == applicationID
+	})
+}
+
+func (m *memoryIntegrationStore) GetApplicationIDs() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := []string{}
+	for _, org := range m.orgs {
+		if org.ApplicationID != nil {
+			ids = append(ids, *org.ApplicationID)
+		}
+	}
+	return ids, nil
+}
+
+func (m *memoryIntegrationStore) GetDeviceByDevEUI(devEUI string) (*models.Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.devices {
+		if strings.EqualFold(m.devices[i].DevEUI, devEUI) {
+			device := m.devices[i]
+			return &device, nil
+		}
+	}
+	return nil, models.ErrDeviceNotFound
+}
+
+func (m *memoryIntegrationStore) UpdateDeviceLastSeen(id uuid.UUID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lastSeenErr != nil {
+		return m.lastSeenErr
+	}
+	m.lastSeen[id] = at
+	return nil
+}
+
+func (m *memoryIntegrationStore) InsertTelemetry(sample *models.TelemetrySample) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.samples = append(m.samples, *sample)
+	return nil
+}
+
+func (m *memoryIntegrationStore) UpdateCommandStatus(_ uuid.UUID, queueItemID, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.commands[queueItemID] = status
+	return nil
+}
+
+func (m *memoryIntegrationStore) lastSeenOf(id uuid.UUID) (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	at, ok := m.lastSeen[id]
+	return at, ok
+}
+
+// newIntegrationStore returns a store with two organizations. The first owns
+// application app-1, has the secret s3cret and device C5EABC521E8304EE.
+func newIntegrationStore() *memoryIntegrationStore {
+	tenant, otherTenant, application, secret := "tenant-1", "tenant-2", "app-1", "s3cret"
+	acme := models.Organization{ID: uuid.New(), TenantID: &tenant, ApplicationID: &application, IntegrationSecret: &secret}
+	other := models.Organization{ID: uuid.New(), TenantID: &otherTenant}
+	return &memoryIntegrationStore{
+		orgs: []models.Organization{acme, other},
+		devices: []models.Device{
+			{ID: uuid.New(), OrganizationID: acme.ID, DevEUI: "C5EABC521E8304EE"},
+			{ID: uuid.New(), OrganizationID: other.ID, DevEUI: "0000000000000002"},
+		},
+		lastSeen: map[uuid.UUID]time.Time{},
+		commands: map[string]string{},
+	}
+}
+
+func newWebhookRouter() (*gin.Engine, *memoryIntegrationStore) {
+	store := newIntegrationStore()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := handlers.NewIntegrationHandler(service.NewIntegrationService(store, store, store, store))
+	router.POST("/integrations/chirpstack", handler.HandleChirpStackEvent)
+	return router, store
+}
+
+func postEvent(router *gin.Engine, event, auth, body string) *httptest.ResponseRecorder {
+	return postEncodedEvent(router, event, auth, "application/json", []byte(body))
+}
+
+func postEncodedEvent(router *gin.Engine, event, auth, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/integrations/chirpstack?event="+event, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+const uplinkEvent = `{
+	"deduplicationId": "3ac7e3c4-4401-4b8d-9386-a5c902f9202d",
+	"time": "2026-10-16T08:30:00Z",
+	"deviceInfo": {"tenantId": "tenant-1", "applicationId": "app-1", "deviceName": "Lamp 1", "devEui": "c5eabc521e8304ee"},
+	"devAddr": "01020304",
+	"fCnt": 12,
+	"fPort": 2,
+	"data": "AQI=",
+	"object": {"Dimming": 80},
+	"rxInfo": [{"gatewayId": "0016c001ff10a235", "rssi": -57, "snr": 10.5}]
+}`
+
+func TestChirpStackWebhook(t *testing.T) {
+	t.Run("Uplink updates the device's last seen time", func(t *testing.T) {
+		router, store := newWebhookRouter()
+
+		w := postEvent(router, models.EventUp, "Bearer s3cret", uplinkEvent)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC), store.lastSeen[store.devices[0].ID])
+	})
+
+	t.Run("Events without a last seen time are accepted", func(t *testing.T) {
+		router, store := newWebhookRouter()
+
+		w := postEvent(router, models.EventTxAck, "Bearer s3cret", `{"deviceInfo": {"tenantId": "tenant-1", "devEui": "C5EABC521E8304EE"}, "fCntDown": 3}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, store.lastSeen)
+	})
+
+	t.Run("Wrong or missing secret is rejected", func(t *testing.T) {
+		router, store := newWebhookRouter()
+
+		assert.Equal(t, http.StatusUnauthorized, postEvent(router, models.EventUp, "Bearer wrong", uplinkEvent).Code)
+		assert.Equal(t, http.StatusUnauthorized, postEvent(router, models.EventUp, "", uplinkEvent).Code)
+		// Organizations without a registered integration accept no secret
+		other := strings.Replace(uplinkEvent, "tenant-1", "tenant-2", 1)
+		assert.Equal(t, http.StatusUnauthorized, postEvent(router, models.EventUp, "Bearer ", other).Code)
+		unknown := strings.Replace(uplinkEvent, "tenant-1", "tenant-9", 1)
+		assert.Equal(t, http.StatusUnauthorized, postEvent(router, models.EventUp, "Bearer s3cret", unknown).Code)
+		assert.Empty(t, store.lastSeen)
+	})
+
+	t.Run("Devices of other organizations are not found", func(t *testing.T) {
+		router, store := newWebhookRouter()
+
+		foreign := strings.Replace(uplinkEvent, "c5eabc521e8304ee", "0000000000000002", 1)
+		assert.Equal(t, http.StatusNotFound, postEvent(router, models.EventUp, "Bearer s3cret", foreign).Code)
+		unknown := strings.Replace(uplinkEvent, "c5eabc521e8304ee", "0000000000000009", 1)
+		assert.Equal(t, http.StatusNotFound, postEvent(router, models.EventUp, "Bearer s3cret", unknown).Code)
+		assert.Empty(t, store.lastSeen)
+	})
+
+	t.Run("Protobuf events are decoded", func(t *testing.T) {
+		router, store := newWebhookRouter()
+		tenant := "52f14cd4-c6f1-4fbd-8f87-4025e1d49242"
+		store.orgs[0].TenantID = &tenant
+
+		w := postEncodedEvent(router, models.EventUp, "Bearer s3cret", "application/octet-stream", readEventFixture(t, "up.pb"))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, time.Date(2026, 10, 16, 8, 30, 0, 123456789, time.UTC), store.lastSeen[store.devices[0].ID])
+
+		w = postEncodedEvent(router, models.EventUp, "Bearer s3cret", "text/plain", readEventFixture(t, "up.pb"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		w = postEncodedEvent(router, models.EventUp, "Bearer s3cret", "application/octet-stream", []byte("{}"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Oversized bodies are rejected before decoding", func(t *testing.T) {
+		router, store := newWebhookRouter()
+
+		padded := strings.Replace(uplinkEvent, `"fCnt": 12,`, `"fCnt": 12, "padding": "`+strings.Repeat("x", 1<<20)+`",`, 1)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, postEvent(router, models.EventUp, "Bearer s3cret", padded).Code)
+		assert.Empty(t, store.lastSeen)
+	})
+
+	t.Run("Unknown event types are rejected", func(t *testing.T) {
+		router, _ := newWebhookRouter()
+
+		assert.Equal(t, http.StatusBadRequest, postEvent(router, "integration", "Bearer s3cret", uplinkEvent).Code)
+		assert.Equal(t, http.StatusBadRequest, postEvent(router, "", "Bearer s3cret", uplinkEvent).Code)
+	})
+}
@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProfiles is a ChirpStack client that only reads and updates device
+// profiles, with errors injected per profile
+type fakeProfiles struct {
+	interfaces.ChirpStackClient
+
+	profiles  map[string]models.ChirpStackDeviceProfile
+	updateErr map[string]error
+	updates   int
+}
+
+func newFakeProfiles(ids ...string) *fakeProfiles {
+	f := &fakeProfiles{
+		profiles:  map[string]models.ChirpStackDeviceProfile{},
+		updateErr: map[string]error{},
+	}
+	for _, id := range ids {
+		f.profiles[id] = models.ChirpStackDeviceProfile{Name: id, PayloadCodecRuntime: "JS", PayloadCodecScript: "old " + id}
+	}
+	return f
+}
+
+func (f *fakeProfiles) GetDeviceProfile(id string) (*models.ChirpStackDeviceProfile, error) {
+	profile, ok := f.profiles[id]
+	if !ok {
+		return nil, &chirpstack.APIError{StatusCode: 404}
+	}
+	return &profile, nil
+}
+
+func (f *fakeProfiles) UpdateDeviceProfile(id string, profile models.ChirpStackDeviceProfile) error {
+	if err := f.updateErr[id]; err != nil {
+		return err
+	}
+	f.updates++
+	f.profiles[id] = profile
+	return nil
+}
+
+// memoryRolloutStore keeps one rollout in memory
+type memoryRolloutStore struct {
+	rollout models.CodecRollout
+	version models.CodecVersion
+}
+
+func newMemoryRolloutStore(rollbackOnFailure bool, profileIDs ...string) *memoryRolloutStore {
+	store := &memoryRolloutStore{
+		version: models.CodecVersion{ID: uuid.New(), Version: 2, Script: "new", Status: models.CodecVersionPublished},
+	}
+	store.rollout = models.CodecRollout{
+		ID:                uuid.New(),
+		CodecVersionID:    store.version.ID,
+		Status:            models.RolloutRunning,
+		RollbackOnFailure: rollbackOnFailure,
+	}
+	for _, id := range profileIDs {
+		store.rollout.Targets = append(store.rollout.Targets, models.CodecRolloutTarget{
+			ID:              uuid.New(),
+			RolloutID:       store.rollout.ID,
+			DeviceProfileID: id,
+			Status:          models.RolloutTargetPending,
+		})
+	}
+	return store
+}
+
+func (m *memoryRolloutStore) GetRollout(id uuid.UUID) (*models.CodecRollout, error) {
+	if id != m.rollout.ID {
+		return nil, models.ErrRolloutNotFound
+	}
+	rollout := m.rollout
+	rollout.Targets = append([]models.CodecRolloutTarget(nil), m.rollout.Targets...)
+	return &rollout, nil
+}
+
+func (m *memoryRolloutStore) GetCodecVersionByID(id uuid.UUID) (*models.CodecVersion, error) {
+	if id != m.version.ID {
+		return nil, models.ErrCodecVersionNotFound
+	}
+	version := m.version
+	return &version, nil
+}
+
+func (m *memoryRolloutStore) UpdateRolloutTarget(target *models.CodecRolloutTarget) error {
+	for i := range m.rollout.Targets {
+		if m.rollout.Targets[i].ID == target.ID {
+			m.rollout.Targets[i] = *target
+		}
+	}
+	return nil
+}
+
+func (m *memoryRolloutStore) SetRolloutStatus(id uuid.UUID, status string) error {
+	m.rollout.Status = status
+	return nil
+}
+
+func (m *memoryRolloutStore) SetCodecVersionStatus(id uuid.UUID, status string) error {
+	m.version.Status = status
+	return nil
+}
+
+func (m *memoryRolloutStore) target(profileID string) models.CodecRolloutTarget {
+	for _, target := range m.rollout.Targets {
+		if target.DeviceProfileID == profileID {
+			return target
+		}
+	}
+	return models.CodecRolloutTarget{}
+}
+
+// memoryCodecScripts holds the default codec with one published version
+type memoryCodecScripts struct {
+	codec   models.Codec
+	version models.CodecVersion
+}
+
+func newMemoryCodecScripts(script string) *memoryCodecScripts {
+	published := 1
+	codec := models.Codec{ID: uuid.New(), Name: "lnode", IsDefault: true, PublishedVersion: &published}
+	return &memoryCodecScripts{
+		codec:   codec,
+		version: models.CodecVersion{ID: uuid.New(), CodecID: codec.ID, Version: 1, Script: script, Status: models.CodecVersionPublished},
+	}
+}
+
+func (m *memoryCodecScripts) GetCodecByID(id uuid.UUID) (*models.Codec, error) {
+	if id != m.codec.ID {
+		return nil, models.ErrCodecNotFound
+	}
+	codec := m.codec
+	return &codec, nil
+}
+
+func (m *memoryCodecScripts) GetDefaultCodec() (*models.Codec, error) {
+	return m.GetCodecByID(m.codec.ID)
+}
+
+func (m *memoryCodecScripts) GetPublishedCodecVersion(codecID uuid.UUID) (*models.CodecVersion, error) {
+	if codecID != m.codec.ID {
+		return nil, models.ErrCodecVersionNotFound
+	}
+	version := m.version
+	return &version, nil
+}
+
+func rolloutJob(t *testing.T, jobType string, rolloutID uuid.UUID) *models.Job {
+	payload, err := json.Marshal(models.CodecRolloutPayload{RolloutID: rolloutID})
+	require.NoError(t, err)
+	return &models.Job{Type: jobType, Payload: payload}
+}
+
+func TestCodecRollout(t *testing.T) {
+	t.Run("Updates every profile", func(t *testing.T) {
+		client := newFakeProfiles("p1", "p2")
+		store := newMemoryRolloutStore(false, "p1", "p2")
+		runner := service.NewCodecRolloutRunner(store, client)
+
+		require.NoError(t, runner.RunRolloutJob(rolloutJob(t, models.JobCodecRollout, store.rollout.ID)))
+		assert.Equal(t, models.RolloutSucceeded, store.rollout.Status)
+		assert.Equal(t, "new", client.profiles["p1"].PayloadCodecScript)
+		assert.Equal(t, "new", client.profiles["p2"].PayloadCodecScript)
+		assert.Equal(t, "old p1", *store.target("p1").PreviousScript)
+		assert.Equal(t, models.RolloutTargetUpdated, store.target("p2").Status)
+
+		// Finished rollouts are left alone
+		require.NoError(t, runner.RunRolloutJob(rolloutJob(t, models.JobCodecRollout, store.rollout.ID)))
+		assert.Equal(t, 2, client.updates)
+	})
+
+	t.Run("Rejected profiles fail the rollout", func(t *testing.T) {
+		client := newFakeProfiles("p1")
+		store := newMemoryRolloutStore(false, "p1", "missing")
+		runner := service.NewCodecRolloutRunner(store, client)
+
+		require.NoError(t, runner.RunRolloutJob(rolloutJob(t, models.JobCodecRollout, store.rollout.ID)))
+		assert.Equal(t, models.RolloutFailed, store.rollout.Status)
+		assert.Equal(t, models.RolloutTargetUpdated, store.target("p1").Status)
+		assert.Equal(t, models.RolloutTargetFailed, store.target("missing").Status)
+		assert.NotNil(t, store.target("missing").Error)
+		assert.Equal(t, models.CodecVersionPublished, store.version.Status)
+	})
+
+	t.Run("Outages are retried where they stopped", func(t *testing.T) {
+		client := newFakeProfiles("p1", "p2")
+		client.updateErr["p2"] = chirpstack.ErrCircuitOpen
+		store := newMemoryRolloutStore(false, "p1", "p2")
+		runner := service.NewCodecRolloutRunner(store, client)
+
+		err := runner.RunRolloutJob(rolloutJob(t, models.JobCodecRollout, store.rollout.ID))
+		assert.ErrorIs(t, err, chirpstack.ErrUnavailable)
+		assert.Equal(t, models.RolloutRunning, store.rollout.Status)
+		assert.Equal(t, models.RolloutTargetPending, store.target("p2").Status)
+		assert.Equal(t, "old p2", *store.target("p2").PreviousScript)
+
+		delete(client.updateErr, "p2")
+		require.NoError(t, runner.RunRolloutJob(rolloutJob(t, models.JobCodecRollout, store.rollout.ID)))
+		assert.Equal(t, models.RolloutSucceeded, store.rollout.Status)
+		assert.Equal(t, 2, client.updates)
+	})
+
+	t.Run("Failures roll back when asked to", func(t *testing.T) {
+		client := newFakeProfiles("p1", "p2")
+		client.updateErr["p2"] = &chirpstack.APIError{StatusCode: 400, Message: "invalid script"}
+		store := newMemoryRolloutStore(true, "p1", "p2")
+		runner := service.NewCodecRolloutRunner(store, client)
+
+		require.NoError(t, runner.RunRolloutJob(rolloutJob(t, models.JobCodecRollout, store.rollout.ID)))
+		assert.Equal(t, models.RolloutRolledBack, store.rollout.Status)
+		assert.Equal(t, models.CodecVersionRolledBack, store.version.Status)
+		assert.Equal(t, "old p1", client.profiles["p1"].PayloadCodecScript)
+		assert.Equal(t, models.RolloutTargetRolledBack, store.target("p1").Status)
+		assert.Equal(t, models.RolloutTargetFailed, store.target("p2").Status)
+	})
+
+	t.Run("Rollback restores the previous scripts", func(t *testing.T) {
+		client := newFakeProfiles("p1", "p2")
+		client.profiles["p2"] = models.ChirpStackDeviceProfile{Name: "p2", PayloadCodecRuntime: "CAYENNE_LPP"}
+		store := newMemoryRolloutStore(false, "p1", "p2")
+		runner := service.NewCodecRolloutRunner(store, client)
+
+		require.NoError(t, runner.RunRolloutJob(rolloutJob(t, models.JobCodecRollout, store.rollout.ID)))
+		assert.Equal(t, "JS", client.profiles["p2"].PayloadCodecRuntime)
+
+		// Only rollouts the service moved to rolling_back are rolled back
+		require.NoError(t, runner.RunRollbackJob(rolloutJob(t, models.JobCodecRollback, store.rollout.ID)))
+		assert.Equal(t, models.RolloutSucceeded, store.rollout.Status)
+
+		store.rollout.Status = models.RolloutRollingBack
+		require.NoError(t, runner.RunRollbackJob(rolloutJob(t, models.JobCodecRollback, store.rollout.ID)))
+		assert.Equal(t, models.RolloutRolledBack, store.rollout.Status)
+		assert.Equal(t, "old p1", client.profiles["p1"].PayloadCodecScript)
+		assert.Equal(t, "CAYENNE_LPP", client.profiles["p2"].PayloadCodecRuntime)
+		assert.Empty(t, client.profiles["p2"].PayloadCodecScript)
+	})
+
+	t.Run("Unknown rollouts are not retried", func(t *testing.T) {
+		runner := service.NewCodecRolloutRunner(newMemoryRolloutStore(false), newFakeProfiles())
+
+		err := runner.RunRolloutJob(rolloutJob(t, models.JobCodecRollout, uuid.New()))
+		assert.ErrorIs(t, err, models.ErrJobPermanent)
+	})
+}
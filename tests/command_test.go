@@ -0,0 +1,354 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/handlers"
+	"go-auth-api/internal/lnode"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryDeviceControl holds one device its members can control and its
+// viewers only see
+type memoryDeviceControl struct {
+	device  models.Device
+	members map[uuid.UUID]bool
+	viewers map[uuid.UUID]bool
+}
+
+func (m *memoryDeviceControl) GetDeviceByID(id, userID uuid.UUID, role string) (*models.Device, error) {
+	if id != m.device.ID || !(role == models.RoleAdmin || m.members[userID] || m.viewers[userID]) {
+		return nil, models.ErrDeviceNotFound
+	}
+	device := m.device
+	return &device, nil
+}
+
+func (m *memoryDeviceControl) GetDeviceForUpdate(id, userID uuid.UUID, role string) (*models.Device, error) {
+	device, err := m.GetDeviceByID(id, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if role != models.RoleAdmin && !m.members[userID] {
+		return nil, models.ErrOrgPermissionDenied
+	}
+	return device, nil
+}
+
+// memoryCommandStore records commands like CommandRepository, keeping the
+// statuses of queue items no command has yet in events
+type memoryCommandStore struct {
+	commands []models.DeviceCommand
+	events   map[string]string
+}
+
+func (m *memoryCommandStore) CreateCommand(command *models.DeviceCommand) error {
+	command.ID = uuid.New()
+	command.Status = models.CommandPending
+	m.commands = append(m.commands, *command)
+	return nil
+}
+
+func (m *memoryCommandStore) SetCommandQueueItem(command *models.DeviceCommand, queueItemID string) error {
+	command.QueueItemID = &queueItemID
+	command.Status = models.CommandQueued
+	if status, ok := m.events[queueItemID]; ok {
+		command.Status = status
+		delete(m.events, queueItemID)
+	}
+	for i := range m.commands {
+		if m.commands[i].ID == command.ID {
+			m.commands[i] = *command
+			return nil
+		}
+	}
+	return errors.New("command not found")
+}
+
+func (m *memoryCommandStore) DeleteCommand(id uuid.UUID) error {
+	for i := range m.commands {
+		if m.commands[i].ID == id {
+			m.commands = append(m.commands[:i], m.commands[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *memoryCommandStore) UpdateCommandStatus(_ uuid.UUID, queueItemID, status string) error {
+	for i := range m.commands {
+		if id := m.commands[i].QueueItemID; id != nil && *id == queueItemID {
+			m.commands[i].Status = status
+			return nil
+		}
+	}
+	if m.events == nil {
+		m.events = map[string]string{}
+	}
+	m.events[queueItemID] = status
+	return nil
+}
+
+func (m *memoryCommandStore) GetDeviceCommands(deviceID uuid.UUID, page, pageSize int) ([]models.DeviceCommand, int, error) {
+	commands := []models.DeviceCommand{}
+	for _, command := range m.commands {
+		if command.DeviceID == deviceID {
+			commands = append(commands, command)
+		}
+	}
+	return commands, len(commands), nil
+}
+
+// fakeCommandQueue records the enqueued downlinks, failing with err when set.
+// enqueued is called before the queue item ID is returned.
+type fakeCommandQueue struct {
+	items    []models.ChirpStackQueueItem
+	err      error
+	enqueued func(queueItemID string)
+}
+
+func (q *fakeCommandQueue) EnqueueDownlink(item models.ChirpStackQueueItem) (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	q.items = append(q.items, item)
+	if q.enqueued != nil {
+		q.enqueued("queue-item-1")
+	}
+	return "queue-item-1", nil
+}
+
+func TestEncodeCommand(t *testing.T) {
+	level := func(v int) *int { return &v }
+	on := func(v bool) *bool { return &v }
+
+	for _, tc := range []struct {
+		name string
+		req  models.SendCommandRequest
+		want []byte
+	}{
+		{"Dimming", models.SendCommandRequest{Type: models.CommandSetDimming, Level: level(75)}, []byte{0x10, 75}},
+		{"Full brightness", models.SendCommandRequest{Type: models.CommandSetDimming, Level: level(100)}, []byte{0x10, 100}},
+		{"Switch on", models.SendCommandRequest{Type: models.CommandSwitch, On: on(true)}, []byte{0x11, 1}},
+		{"Switch off", models.SendCommandRequest{Type: models.CommandSwitch, On: on(false)}, []byte{0x11, 0}},
+		{"Status request", models.SendCommandRequest{Type: models.CommandRequestStatus}, []byte{0x20}},
+		{"GPS request", models.SendCommandRequest{Type: models.CommandRequestGPS}, []byte{0x21}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := service.EncodeCommand(lnode.DefaultCommandSet, &tc.req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, data)
+		})
+	}
+
+	t.Run("Invalid commands are rejected", func(t *testing.T) {
+		for _, req := range []models.SendCommandRequest{
+			{Type: models.CommandSetDimming},
+			{Type: models.CommandSetDimming, Level: level(101)},
+			{Type: models.CommandSetDimming, Level: level(-1)},
+			{Type: models.CommandSwitch},
+			{Type: "reboot"},
+		} {
+			_, err := service.EncodeCommand(lnode.DefaultCommandSet, &req)
+			assert.ErrorIs(t, err, models.ErrInvalidCommand, "%+v", req)
+		}
+
+		_, err := lnode.DefaultCommandSet.DimmingCommand(101)
+		assert.ErrorIs(t, err, lnode.ErrValueRange)
+	})
+
+	t.Run("Configured opcodes are used", func(t *testing.T) {
+		protocol := lnode.CommandSet{FPort: 20, Dimming: 0x01, Switch: 0x02, StatusRequest: 0x03, GPSRequest: 0x04}
+		require.NoError(t, protocol.Validate())
+
+		data, err := service.EncodeCommand(protocol, &models.SendCommandRequest{Type: models.CommandSetDimming, Level: level(75)})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0x01, 75}, data)
+		data, err = service.EncodeCommand(protocol, &models.SendCommandRequest{Type: models.CommandRequestGPS})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0x04}, data)
+	})
+
+	t.Run("Command sets are validated", func(t *testing.T) {
+		assert.NoError(t, lnode.DefaultCommandSet.Validate())
+
+		protocol := lnode.DefaultCommandSet
+		protocol.FPort = 0
+		assert.Error(t, protocol.Validate())
+		protocol.FPort = 224
+		assert.Error(t, protocol.Validate())
+
+		protocol = lnode.DefaultCommandSet
+		protocol.GPSRequest = protocol.StatusRequest
+		assert.Error(t, protocol.Validate())
+	})
+}
+
+func TestCommandService(t *testing.T) {
+	member, viewer := uuid.New(), uuid.New()
+	protocol := lnode.CommandSet{FPort: 20, Dimming: 0x01, Switch: 0x02, StatusRequest: 0x03, GPSRequest: 0x04}
+	newService := func() (*service.CommandService, *memoryDeviceControl, *memoryCommandStore, *fakeCommandQueue) {
+		devices := &memoryDeviceControl{
+			device:  models.Device{ID: uuid.New(), DevEUI: "C5EABC521E8304EE", ChirpStackDeviceCreated: true},
+			members: map[uuid.UUID]bool{member: true},
+			viewers: map[uuid.UUID]bool{viewer: true},
+		}
+		store, queue := &memoryCommandStore{}, &fakeCommandQueue{}
+		return service.NewCommandService(devices, store, queue, protocol), devices, store, queue
+	}
+	dim := 40
+	dimming := &models.SendCommandRequest{Type: models.CommandSetDimming, Level: &dim, Confirmed: true}
+
+	t.Run("Commands are enqueued and recorded", func(t *testing.T) {
+		commands, devices, store, queue := newService()
+
+		command, err := commands.SendCommand(devices.device.ID, member, models.RoleCustomer, dimming)
+		require.NoError(t, err)
+
+		require.Len(t, queue.items, 1)
+		assert.Equal(t, models.ChirpStackQueueItem{
+			DevEUI: "C5EABC521E8304EE", Confirmed: true, FPort: 20, Data: []byte{0x01, 40},
+		}, queue.items[0])
+
+		require.NotNil(t, command.QueueItemID)
+		assert.Equal(t, "queue-item-1", *command.QueueItemID)
+		assert.Equal(t, models.CommandQueued, command.Status)
+		assert.Equal(t, 20, command.FPort)
+		assert.True(t, command.Confirmed)
+		assert.Equal(t, 40, *command.Level)
+		assert.Nil(t, command.On)
+		assert.Equal(t, member, *command.SentBy)
+
+		list, err := commands.GetDeviceCommands(devices.device.ID, viewer, models.RoleCustomer, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, list.Total)
+		assert.Equal(t, store.commands, list.Commands)
+	})
+
+	t.Run("Viewers can't send commands", func(t *testing.T) {
+		commands, devices, store, queue := newService()
+
+		_, err := commands.SendCommand(devices.device.ID, viewer, models.RoleCustomer, dimming)
+		assert.ErrorIs(t, err, models.ErrOrgPermissionDenied)
+		_, err = commands.SendCommand(devices.device.ID, uuid.New(), models.RoleCustomer, dimming)
+		assert.ErrorIs(t, err, models.ErrDeviceNotFound)
+		_, err = commands.GetDeviceCommands(devices.device.ID, uuid.New(), models.RoleCustomer, 1, 10)
+		assert.ErrorIs(t, err, models.ErrDeviceNotFound)
+
+		assert.Empty(t, queue.items)
+		assert.Empty(t, store.commands)
+	})
+
+	t.Run("Commands need a device provisioned in ChirpStack", func(t *testing.T) {
+		commands, devices, store, queue := newService()
+		devices.device.ChirpStackDeviceCreated = false
+
+		_, err := commands.SendCommand(devices.device.ID, member, models.RoleCustomer, dimming)
+		assert.ErrorIs(t, err, models.ErrDeviceNotProvisioned)
+
+		disabled := service.NewCommandService(devices, store, nil, protocol)
+		_, err = disabled.SendCommand(devices.device.ID, member, models.RoleCustomer, dimming)
+		assert.ErrorIs(t, err, models.ErrChirpStackDisabled)
+
+		assert.Empty(t, queue.items)
+		assert.Empty(t, store.commands)
+	})
+
+	t.Run("Failed enqueues aren't recorded", func(t *testing.T) {
+		commands, devices, store, queue := newService()
+		queue.err = chirpstack.ErrCircuitOpen
+
+		_, err := commands.SendCommand(devices.device.ID, member, models.RoleCustomer, dimming)
+		assert.ErrorIs(t, err, chirpstack.ErrUnavailable)
+		assert.Empty(t, store.commands)
+	})
+
+	t.Run("A txack before the queue item is recorded isn't lost", func(t *testing.T) {
+		commands, devices, store, queue := newService()
+		queue.enqueued = func(queueItemID string) {
+			require.Len(t, store.commands, 1)
+			assert.Equal(t, models.CommandPending, store.commands[0].Status)
+			require.NoError(t, store.UpdateCommandStatus(devices.device.ID, queueItemID, models.CommandSent))
+		}
+
+		command, err := commands.SendCommand(devices.device.ID, member, models.RoleCustomer, dimming)
+		require.NoError(t, err)
+		assert.Equal(t, models.CommandSent, command.Status)
+		assert.Equal(t, models.CommandSent, store.commands[0].Status)
+		assert.Empty(t, store.events)
+	})
+
+	t.Run("Handler maps the errors", func(t *testing.T) {
+		commands, devices, _, queue := newService()
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		handler := handlers.NewCommandHandler(commands)
+		setUser := func(c *gin.Context) {
+			c.Set("user_id", member)
+			c.Set("user_role", models.RoleCustomer)
+		}
+		router.POST("/devices/:id/commands", setUser, handler.SendCommand)
+		router.GET("/devices/:id/commands", setUser, handler.GetDeviceCommands)
+
+		send := func(id uuid.UUID, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/devices/"+id.String()+"/commands", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		w := send(devices.device.ID, `{"type": "switch", "on": true}`)
+		require.Equal(t, http.StatusAccepted, w.Code)
+		var command models.DeviceCommand
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &command))
+		require.NotNil(t, command.QueueItemID)
+		assert.Equal(t, "queue-item-1", *command.QueueItemID)
+		assert.True(t, bytes.Equal([]byte{0x02, 1}, command.Data))
+		assert.False(t, command.Confirmed)
+
+		assert.Equal(t, http.StatusBadRequest, send(devices.device.ID, `{"type": "reboot"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, send(devices.device.ID, `{"type": "set_dimming", "level": 120}`).Code)
+		assert.Equal(t, http.StatusNotFound, send(uuid.New(), `{"type": "request_gps"}`).Code)
+
+		queue.err = chirpstack.ErrCircuitOpen
+		assert.Equal(t, http.StatusServiceUnavailable, send(devices.device.ID, `{"type": "request_status"}`).Code)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/devices/"+devices.device.ID.String()+"/commands", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var list models.DeviceCommandListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		assert.Equal(t, 1, list.Total)
+	})
+
+	t.Run("Ack events update the command status", func(t *testing.T) {
+		router, store := newWebhookRouter()
+		deviceInfo := `"deviceInfo": {"tenantId": "tenant-1", "applicationId": "app-1", "devEui": "c5eabc521e8304ee"}`
+
+		txack := `{` + deviceInfo + `, "queueItemId": "queue-item-1", "downlinkId": 7, "fCntDown": 3}`
+		require.Equal(t, http.StatusOK, postEvent(router, models.EventTxAck, "Bearer s3cret", txack).Code)
+		assert.Equal(t, models.CommandSent, store.commands["queue-item-1"])
+
+		ack := `{` + deviceInfo + `, "queueItemId": "queue-item-1", "acknowledged": true, "fCntDown": 3}`
+		require.Equal(t, http.StatusOK, postEvent(router, models.EventAck, "Bearer s3cret", ack).Code)
+		assert.Equal(t, models.CommandAcknowledged, store.commands["queue-item-1"])
+
+		nack := `{` + deviceInfo + `, "queueItemId": "queue-item-2", "fCntDown": 4}`
+		require.Equal(t, http.StatusOK, postEvent(router, models.EventAck, "Bearer s3cret", nack).Code)
+		assert.Equal(t, models.CommandNotAcknowledged, store.commands["queue-item-2"])
+	})
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"testing"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceProfileFromTemplate(t *testing.T) {
+	t.Run("Default template is the Lnode profile", func(t *testing.T) {
+		profile := chirpstack.DefaultDeviceProfile("tenant-1", "AS923_2")
+
+		assert.Equal(t, "tenant-1", profile.TenantID)
+		assert.Equal(t, "RAK_ABP", profile.Name)
+		assert.Equal(t, "AS923_2", profile.Region)
+		assert.Equal(t, "as923_2", profile.RegionConfigID)
+		assert.Equal(t, 921400000, profile.AbpRx2Freq)
+		assert.Equal(t, 2, profile.AbpRx2Dr)
+		assert.Equal(t, 1, profile.AbpRx1Delay)
+		assert.Equal(t, 3600, profile.UplinkInterval)
+		assert.True(t, profile.SupportsClassC)
+		assert.False(t, profile.SupportsOtaa)
+		assert.Contains(t, profile.PayloadCodecScript, "function decodeUplink")
+		assert.Contains(t, profile.Measurements, "Energy")
+
+		assert.True(t, chirpstack.DefaultOTAADeviceProfile("tenant-1", "AS923_2").SupportsOtaa)
+	})
+
+	t.Run("Profiles follow the organization's region", func(t *testing.T) {
+		profile := chirpstack.DefaultDeviceProfile("tenant-1", "US915")
+
+		assert.Equal(t, "US915", profile.Region)
+		assert.Equal(t, "us915_0", profile.RegionConfigID)
+		assert.Equal(t, 923300000, profile.AbpRx2Freq)
+		assert.Equal(t, 8, profile.AbpRx2Dr)
+	})
+
+	t.Run("Template settings are applied", func(t *testing.T) {
+		profile := chirpstack.DeviceProfileFromTemplate("tenant-1", "RAK3172 v1.0 (OTAA)", models.DeviceProfileTemplate{
+			Region:            "EU868",
+			MacVersion:        "LORAWAN_1_0_4",
+			RegParamsRevision: "RP002_1_0_3",
+			DeviceClass:       models.DeviceClassA,
+			UplinkInterval:    600,
+			CodecRuntime:      "CAYENNE_LPP",
+		}, "AS923_2", true)
+
+		assert.Equal(t, "RAK3172 v1.0 (OTAA)", profile.Name)
+		// The template's own region wins over the organization's
+		assert.Equal(t, "EU868", profile.Region)
+		assert.Equal(t, "eu868", profile.RegionConfigID)
+		assert.Equal(t, 869525000, profile.AbpRx2Freq)
+		assert.Equal(t, 0, profile.AbpRx2Dr)
+		assert.Equal(t, "LORAWAN_1_0_4", profile.MacVersion)
+		assert.Equal(t, 600, profile.UplinkInterval)
+		assert.Equal(t, "CAYENNE_LPP", profile.PayloadCodecRuntime)
+		assert.Empty(t, profile.PayloadCodecScript)
+		assert.NotNil(t, profile.Measurements)
+		assert.False(t, profile.SupportsClassB)
+		assert.False(t, profile.SupportsClassC)
+		assert.True(t, profile.SupportsOtaa)
+	})
+}
+
+func TestValidateProfileTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template models.DeviceProfileTemplate
+		wantErr  string
+	}{
+		{name: "Region-less template", template: models.DeviceProfileTemplate{}},
+		{name: "RX2 within the band", template: models.DeviceProfileTemplate{Region: "EU868", AbpRx2Freq: 869525000, AbpRx2Dr: 3}},
+		{name: "Unknown region", template: models.DeviceProfileTemplate{Region: "EU999"}, wantErr: "unknown region"},
+		{name: "RX2 outside the band", template: models.DeviceProfileTemplate{Region: "EU868", AbpRx2Freq: 923300000}, wantErr: "outside the EU868 band"},
+		{name: "Uplink-only data rate", template: models.DeviceProfileTemplate{Region: "US915", AbpRx2Dr: 3}, wantErr: "not a US915 downlink data rate"},
+		{name: "RX2 without a region", template: models.DeviceProfileTemplate{AbpRx2Freq: 869525000}, wantErr: "need a region"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := chirpstack.ValidateProfileTemplate(tt.template)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"go-auth-api/internal/handlers"
 	"go-auth-api/internal/interfaces"
 	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -77,13 +78,13 @@ func (m *MockDeviceService) DeleteAllowedDevice(devEUI string) error {
 	return args.Error(0)
 }
 
-func (m *MockDeviceService) CreateDevice(userID uuid.UUID, req *models.CreateDeviceRequest) (*models.Device, error) {
-	args := m.Called(userID, req)
+func (m *MockDeviceService) CreateDevice(userID uuid.UUID, role string, req *models.CreateDeviceRequest) (*models.Device, error) {
+	args := m.Called(userID, role, req)
 	return args.Get(0).(*models.Device), args.Error(1)
 }
 
-func (m *MockDeviceService) GetDeviceByID(id uuid.UUID) (*models.Device, error) {
-	args := m.Called(id)
+func (m *MockDeviceService) GetDeviceByID(id, userID uuid.UUID, role string) (*models.Device, error) {
+	args := m.Called(id, userID, role)
 	return args.Get(0).(*models.Device), args.Error(1)
 }
 
@@ -97,13 +98,13 @@ func (m *MockDeviceService) GetAllDevices(page, pageSize int) (*models.DeviceLis
 	return args.Get(0).(*models.DeviceListResponse), args.Error(1)
 }
 
-func (m *MockDeviceService) UpdateDevice(id uuid.UUID, req *models.UpdateDeviceRequest) error {
-	args := m.Called(id, req)
+func (m *MockDeviceService) UpdateDevice(id, userID uuid.UUID, role string, req *models.UpdateDeviceRequest) error {
+	args := m.Called(id, userID, role, req)
 	return args.Error(0)
 }
 
-func (m *MockDeviceService) DeleteDevice(id uuid.UUID) error {
-	args := m.Called(id)
+func (m *MockDeviceService) DeleteDevice(id, userID uuid.UUID, role string) error {
+	args := m.Called(id, userID, role)
 	return args.Error(0)
 }
 
@@ -188,6 +189,21 @@ func TestCreateDeviceVersion(t *testing.T) {
 
 		mockService.AssertExpectations(t)
 	})
+
+	t.Run("Invalid Profile Template", func(t *testing.T) {
+		body := `{"name": "RAK3172", "version": "v1.0", "profile_template": {
+			"region": "EU868", "mac_version": "LORAWAN_1_0_3", "reg_params_revision": "A",
+			"device_class": "D", "uplink_interval": 600, "codec_runtime": "NONE"}}`
+
+		req, _ := http.NewRequest("POST", "/api/v1/devices/versions", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "DeviceClass")
+	})
 }
 
 func TestGetDeviceVersions(t *testing.T) {
@@ -268,6 +284,39 @@ func TestCreateAllowedDevice(t *testing.T) {
 
 		mockService.AssertExpectations(t)
 	})
+
+	t.Run("Invalid Activation Settings", func(t *testing.T) {
+		router, mockService := setupDeviceTestRouter()
+		mockService.On("CreateAllowedDevice", mock.AnythingOfType("*models.CreateAllowedDeviceRequest")).
+			Return((*models.AllowedDevice)(nil), fmt.Errorf("%w: OTAA devices need join_eui", models.ErrInvalidActivation))
+
+		reqBody := models.CreateAllowedDeviceRequest{
+			DevEUI:         "C5EABC521E8304EF",
+			ActivationMode: models.ActivationOTAA,
+			AppKey:         "97784F3B7F2A57EECF19F10E625081E0",
+		}
+
+		jsonBody, _ := json.Marshal(reqBody)
+		req, _ := http.NewRequest("POST", "/api/v1/devices/allowed", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestOTAARootKeys(t *testing.T) {
+	keys := service.OTAARootKeys(&models.AllowedDevice{
+		ActivationMode: models.ActivationOTAA,
+		AppKey:         "97784F3B7F2A57EECF19F10E625081E0",
+	})
+
+	// LoRaWAN 1.0.x devices have the AppKey as their only root key
+	assert.Equal(t, "97784F3B7F2A57EECF19F10E625081E0", keys.NwkKey)
+	assert.Equal(t, "97784F3B7F2A57EECF19F10E625081E0", keys.AppKey)
 }
 
 func TestCreateDevice(t *testing.T) {
@@ -280,7 +329,8 @@ func TestCreateDevice(t *testing.T) {
 
 		expectedDevice := &models.Device{
 			ID:                        deviceID,
-			UserID:                    userID,
+			OrganizationID:            uuid.New(),
+			UserID:                    &userID,
 			VersionID:                 versionID,
 			Name:                      "My Device",
 			DevEUI:                    "C5EABC521E8304EE",
@@ -292,7 +342,7 @@ func TestCreateDevice(t *testing.T) {
 			UpdatedAt:                 time.Now(),
 		}
 
-		mockService.On("CreateDevice", mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*models.CreateDeviceRequest")).Return(expectedDevice, nil)
+		mockService.On("CreateDevice", mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("string"), mock.AnythingOfType("*models.CreateDeviceRequest")).Return(expectedDevice, nil)
 
 		reqBody := models.CreateDeviceRequest{
 			VersionID:   versionID,
@@ -327,7 +377,7 @@ func TestDeleteDeviceWithChirpStack(t *testing.T) {
 	t.Run("Successful Deletion with ChirpStack", func(t *testing.T) {
 		deviceID := uuid.New()
 
-		mockService.On("DeleteDevice", deviceID).Return(nil)
+		mockService.On("DeleteDevice", deviceID, mock.AnythingOfType("uuid.UUID"), mock.Anything).Return(nil)
 
 		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/devices/%s", deviceID), nil)
 		w := httptest.NewRecorder()
@@ -343,3 +393,33 @@ func TestDeleteDeviceWithChirpStack(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 }
+
+func TestDeviceOwnership(t *testing.T) {
+	router, mockService := setupDeviceTestRouter()
+
+	t.Run("Foreign device returns 404", func(t *testing.T) {
+		deviceID := uuid.New()
+
+		mockService.On("GetDeviceByID", deviceID, mock.AnythingOfType("uuid.UUID"), mock.Anything).Return((*models.Device)(nil), models.ErrDeviceNotFound)
+		mockService.On("UpdateDevice", deviceID, mock.AnythingOfType("uuid.UUID"), mock.Anything, mock.AnythingOfType("*models.UpdateDeviceRequest")).Return(models.ErrDeviceNotFound)
+		mockService.On("DeleteDevice", deviceID, mock.AnythingOfType("uuid.UUID"), mock.Anything).Return(models.ErrDeviceNotFound)
+
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/devices/%s", deviceID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/v1/devices/%s", deviceID), bytes.NewBufferString(`{"name":"Renamed"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/v1/devices/%s", deviceID), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		mockService.AssertExpectations(t)
+	})
+}
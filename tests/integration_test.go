@@ -2,22 +2,28 @@ package tests
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 
+	"go-auth-api/internal/auth"
 	"go-auth-api/internal/models"
 
+	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/suite"
 )
 
 type IntegrationTestSuite struct {
 	suite.Suite
-	baseURL string
-	token   string
-	userID  string
+	baseURL    string
+	db         *sql.DB
+	adminToken string
+	token      string
+	userID     string
 }
 
 func (suite *IntegrationTestSuite) SetupSuite() {
@@ -26,8 +32,25 @@ func (suite *IntegrationTestSuite) SetupSuite() {
 	// Wait for service to be ready
 	suite.waitForService()
 
-	// Register and login a test user
-	suite.registerAndLogin()
+	// Accounts are seeded through the database of the docker-compose setup
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		getTestEnv("DB_HOST", "localhost"), getTestEnv("DB_PORT", "5432"), getTestEnv("DB_USER", "postgres"),
+		getTestEnv("DB_PASSWORD", "password123"), getTestEnv("DB_NAME", "auth_db"))
+	db, err := sql.Open("postgres", dsn)
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.Ping())
+	suite.db = db
+
+	// An admin manages the device inventory, a verified customer owns the
+	// devices in the organization created on verification
+	suite.adminToken, _ = suite.registerAndLogin("integration_admin", models.RoleAdmin)
+	suite.token, suite.userID = suite.registerAndLogin("integration_test", models.RoleCustomer)
+}
+
+func (suite *IntegrationTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		suite.db.Close()
+	}
 }
 
 func (suite *IntegrationTestSuite) waitForService() {
@@ -46,10 +69,12 @@ func (suite *IntegrationTestSuite) waitForService() {
 	suite.T().Fatal("Service not ready after 60 seconds")
 }
 
-func (suite *IntegrationTestSuite) registerAndLogin() {
+// registerAndLogin registers a user with the role, verifies the email
+// address and returns an access token and the user ID
+func (suite *IntegrationTestSuite) registerAndLogin(prefix, role string) (string, string) {
 	// Register user
 	registerReq := models.RegisterRequest{
-		Email:    fmt.Sprintf("integration_test_%d@example.com", time.Now().Unix()),
+		Email:    fmt.Sprintf("%s_%d@example.com", prefix, time.Now().UnixNano()),
 		Password: "password123",
 		FullName: "Integration Test User",
 	}
@@ -63,12 +88,69 @@ func (suite *IntegrationTestSuite) registerAndLogin() {
 	err = json.NewDecoder(resp.Body).Decode(&authResp)
 	suite.Require().NoError(err)
 	resp.Body.Close()
+	userID := authResp.User.ID.String()
+
+	// The first admin is promoted in the database, like in a new deployment
+	_, err = suite.db.Exec(`UPDATE users SET role = $1 WHERE id = $2`, role, userID)
+	suite.Require().NoError(err)
+
+	// The verification mail is not readable here, so a token of our own is
+	// stored and verified through the API, which creates the organization
+	token := fmt.Sprintf("integration-verification-%s", userID)
+	_, err = suite.db.Exec(`
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + INTERVAL '1 hour')`,
+		userID, models.UserTokenEmailVerification, auth.HashToken(token))
+	suite.Require().NoError(err)
+
+	jsonBody, _ = json.Marshal(models.VerifyEmailRequest{Token: token})
+	resp, err = http.Post(suite.baseURL+"/auth/verify-email", "application/json", bytes.NewBuffer(jsonBody))
+	suite.Require().NoError(err)
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	// Log in again for a token with the new role
+	jsonBody, _ = json.Marshal(models.LoginRequest{Email: registerReq.Email, Password: registerReq.Password})
+	resp, err = http.Post(suite.baseURL+"/auth/login", "application/json", bytes.NewBuffer(jsonBody))
+	suite.Require().NoError(err)
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+	err = json.NewDecoder(resp.Body).Decode(&authResp)
+	suite.Require().NoError(err)
+	resp.Body.Close()
 
-	suite.token = authResp.Token
-	suite.userID = authResp.User.ID.String()
+	return authResp.Token, userID
+}
+
+// waitForJob polls a background job until it succeeded or died
+func (suite *IntegrationTestSuite) waitForJob(id string) models.Job {
+	var job models.Job
+	for i := 0; i < 30; i++ {
+		resp, err := suite.makeAuthenticatedRequest("GET", "/jobs/"+id, nil)
+		suite.Require().NoError(err)
+		suite.Require().Equal(http.StatusOK, resp.StatusCode)
+		err = json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		suite.Require().NoError(err)
+
+		if job.Status == models.JobSucceeded || job.Status == models.JobDead {
+			return job
+		}
+		time.Sleep(time.Second)
+	}
+	suite.T().Fatalf("Job %s not finished after 30 seconds", id)
+	return job
 }
 
 func (suite *IntegrationTestSuite) makeAuthenticatedRequest(method, endpoint string, body interface{}) (*http.Response, error) {
+	return suite.makeRequest(suite.token, method, endpoint, body)
+}
+
+// makeAdminRequest sends a request as the admin, for the device inventory
+func (suite *IntegrationTestSuite) makeAdminRequest(method, endpoint string, body interface{}) (*http.Response, error) {
+	return suite.makeRequest(suite.adminToken, method, endpoint, body)
+}
+
+func (suite *IntegrationTestSuite) makeRequest(token, method, endpoint string, body interface{}) (*http.Response, error) {
 	var reqBody *bytes.Buffer
 	if body != nil {
 		jsonBody, _ := json.Marshal(body)
@@ -83,7 +165,7 @@ func (suite *IntegrationTestSuite) makeAuthenticatedRequest(method, endpoint str
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+suite.token)
+	req.Header.Set("Authorization", "Bearer "+token)
 
 	client := &http.Client{}
 	return client.Do(req)
@@ -97,7 +179,7 @@ func (suite *IntegrationTestSuite) TestCompleteDeviceWorkflow() {
 		Description: stringPtr("RAK7200 LoRaWAN Tracker v1.0"),
 	}
 
-	resp, err := suite.makeAuthenticatedRequest("POST", "/devices/versions", versionReq)
+	resp, err := suite.makeAdminRequest("POST", "/devices/versions", versionReq)
 	suite.Require().NoError(err)
 	suite.Equal(http.StatusCreated, resp.StatusCode)
 
@@ -118,7 +200,7 @@ func (suite *IntegrationTestSuite) TestCompleteDeviceWorkflow() {
 		Description: stringPtr("Integration test device"),
 	}
 
-	resp, err = suite.makeAuthenticatedRequest("POST", "/devices/allowed", allowedReq)
+	resp, err = suite.makeAdminRequest("POST", "/devices/allowed", allowedReq)
 	suite.Require().NoError(err)
 	suite.Equal(http.StatusCreated, resp.StatusCode)
 
@@ -148,6 +230,18 @@ func (suite *IntegrationTestSuite) TestCompleteDeviceWorkflow() {
 
 	suite.Equal("My Integration Test Device", device.Name)
 	suite.Equal("C5EABC521E8304EE", device.DevEUI)
+
+	// The device is provisioned in ChirpStack by a background job
+	suite.Require().NotNil(device.ProvisioningJobID)
+	job := suite.waitForJob(device.ProvisioningJobID.String())
+	suite.Equal(models.JobSucceeded, job.Status)
+
+	resp, err = suite.makeAuthenticatedRequest("GET", "/devices/"+device.ID.String(), nil)
+	suite.Require().NoError(err)
+	err = json.NewDecoder(resp.Body).Decode(&device)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+
 	suite.True(device.ChirpStackDeviceCreated)
 	suite.True(device.ChirpStackDeviceActivated)
 
@@ -213,7 +307,7 @@ func (suite *IntegrationTestSuite) TestDeviceVersionManagement() {
 	createdVersions := make([]models.DeviceVersion, 0, len(versions))
 
 	for _, versionReq := range versions {
-		resp, err := suite.makeAuthenticatedRequest("POST", "/devices/versions", versionReq)
+		resp, err := suite.makeAdminRequest("POST", "/devices/versions", versionReq)
 		suite.Require().NoError(err)
 		suite.Equal(http.StatusCreated, resp.StatusCode)
 
@@ -242,13 +336,13 @@ func (suite *IntegrationTestSuite) TestDeviceVersionManagement() {
 		Description: stringPtr("Updated description"),
 	}
 
-	resp, err = suite.makeAuthenticatedRequest("PUT", fmt.Sprintf("/devices/versions/%s", createdVersions[0].ID), updateReq)
+	resp, err = suite.makeAdminRequest("PUT", fmt.Sprintf("/devices/versions/%s", createdVersions[0].ID), updateReq)
 	suite.Require().NoError(err)
 	suite.Equal(http.StatusOK, resp.StatusCode)
 	resp.Body.Close()
 
 	// Delete a version
-	resp, err = suite.makeAuthenticatedRequest("DELETE", fmt.Sprintf("/devices/versions/%s", createdVersions[2].ID), nil)
+	resp, err = suite.makeAdminRequest("DELETE", fmt.Sprintf("/devices/versions/%s", createdVersions[2].ID), nil)
 	suite.Require().NoError(err)
 	suite.Equal(http.StatusOK, resp.StatusCode)
 	resp.Body.Close()
@@ -266,15 +360,25 @@ func (suite *IntegrationTestSuite) TestUserProfile() {
 	resp.Body.Close()
 
 	suite.Equal(suite.userID, user.ID.String())
-	suite.NotNil(user.TenantID)
-	suite.NotNil(user.ApplicationID)
-	suite.NotNil(user.DeviceProfileID)
+
+	// ChirpStack resources belong to the user's organizations
+	resp, err = suite.makeAuthenticatedRequest("GET", "/organizations", nil)
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
 }
 
 func stringPtr(s string) *string {
 	return &s
 }
 
+func getTestEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 func TestIntegrationSuite(t *testing.T) {
 	suite.Run(t, new(IntegrationTestSuite))
 }
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryJobStore is a single-threaded job store for driving the queue
+type memoryJobStore struct {
+	jobs map[uuid.UUID]*models.Job
+}
+
+func newMemoryJobStore(jobs ...*models.Job) *memoryJobStore {
+	store := &memoryJobStore{jobs: map[uuid.UUID]*models.Job{}}
+	for _, job := range jobs {
+		job.ID = uuid.New()
+		job.Status = models.JobPending
+		if job.MaxAttempts == 0 {
+			job.MaxAttempts = models.DefaultJobMaxAttempts
+		}
+		store.jobs[job.ID] = job
+	}
+	return store
+}
+
+func (m *memoryJobStore) ClaimNext(time.Duration) (*models.Job, error) {
+	for _, job := range m.jobs {
+		if job.Status == models.JobPending && !job.RunAt.After(time.Now()) {
+			job.Status = models.JobRunning
+			job.Attempts++
+			claimed := *job
+			return &claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryJobStore) finish(id uuid.UUID, attempt int, status string, runAt time.Time, lastError string) error {
+	job, ok := m.jobs[id]
+	if !ok || job.Status != models.JobRunning || job.Attempts != attempt {
+		return models.ErrJobLeaseLost
+	}
+	job.Status = status
+	job.RunAt = runAt
+	job.LastError = nil
+	if lastError != "" {
+		job.LastError = &lastError
+	}
+	return nil
+}
+
+func (m *memoryJobStore) Complete(id uuid.UUID, attempt int) error {
+	return m.finish(id, attempt, models.JobSucceeded, time.Time{}, "")
+}
+
+func (m *memoryJobStore) Reschedule(id uuid.UUID, attempt int, runAt time.Time, lastError string) error {
+	return m.finish(id, attempt, models.JobPending, runAt, lastError)
+}
+
+func (m *memoryJobStore) Bury(id uuid.UUID, attempt int, lastError string) error {
+	return m.finish(id, attempt, models.JobDead, time.Time{}, lastError)
+}
+
+func (m *memoryJobStore) Requeue(id uuid.UUID) error {
+	job, ok := m.jobs[id]
+	if !ok {
+		return models.ErrJobNotFound
+	}
+	if job.Status != models.JobDead {
+		return models.ErrJobNotDead
+	}
+	job.Status = models.JobPending
+	job.Attempts = 0
+	return nil
+}
+
+func (m *memoryJobStore) GetJobByID(id uuid.UUID) (*models.Job, error) {
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, models.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (m *memoryJobStore) GetOrganizationJobs(uuid.UUID, int, int) ([]models.Job, int, error) {
+	return nil, 0, nil
+}
+
+func (m *memoryJobStore) GetJobs(string, int, int) ([]models.Job, int, error) {
+	return nil, 0, nil
+}
+
+func TestJobRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, service.JobRetryDelay(1))
+	assert.Equal(t, time.Minute, service.JobRetryDelay(2))
+	assert.Equal(t, 8*time.Minute, service.JobRetryDelay(5))
+	assert.Equal(t, time.Hour, service.JobRetryDelay(8))
+	assert.Equal(t, time.Hour, service.JobRetryDelay(100))
+}
+
+func TestJobQueue(t *testing.T) {
+	t.Run("Successful job is completed", func(t *testing.T) {
+		job := &models.Job{Type: models.JobProvisionDevice}
+		store := newMemoryJobStore(job)
+		queue := service.NewJobQueue(store, nil)
+
+		var ran int
+		queue.Register(models.JobProvisionDevice, func(*models.Job) error {
+			ran++
+			return nil
+		})
+
+		processed, err := queue.RunOnce()
+		require.NoError(t, err)
+		assert.True(t, processed)
+		assert.Equal(t, 1, ran)
+		assert.Equal(t, models.JobSucceeded, job.Status)
+
+		processed, err = queue.RunOnce()
+		require.NoError(t, err)
+		assert.False(t, processed)
+	})
+
+	t.Run("Failed job is rescheduled with backoff", func(t *testing.T) {
+		job := &models.Job{Type: models.JobProvisionDevice}
+		store := newMemoryJobStore(job)
+		queue := service.NewJobQueue(store, nil)
+		queue.Register(models.JobProvisionDevice, func(*models.Job) error {
+			return errors.New("chirpstack: unavailable")
+		})
+
+		before := time.Now()
+		_, err := queue.RunOnce()
+		require.NoError(t, err)
+		assert.Equal(t, models.JobPending, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+		assert.Equal(t, "chirpstack: unavailable", *job.LastError)
+		assert.WithinDuration(t, before.Add(service.JobRetryDelay(1)), job.RunAt, 5*time.Second)
+
+		// Not due yet
+		processed, err := queue.RunOnce()
+		require.NoError(t, err)
+		assert.False(t, processed)
+	})
+
+	t.Run("Job goes to the dead letters after max attempts", func(t *testing.T) {
+		job := &models.Job{Type: models.JobProvisionDevice, MaxAttempts: 3}
+		store := newMemoryJobStore(job)
+		queue := service.NewJobQueue(store, nil)
+		queue.Register(models.JobProvisionDevice, func(*models.Job) error {
+			return errors.New("still failing")
+		})
+
+		for i := 0; i < 3; i++ {
+			job.RunAt = time.Time{}
+			_, err := queue.RunOnce()
+			require.NoError(t, err)
+		}
+		assert.Equal(t, models.JobDead, job.Status)
+		assert.Equal(t, 3, job.Attempts)
+
+		// Admins can give it another round
+		require.NoError(t, queue.RetryJob(job.ID))
+		assert.Equal(t, models.JobPending, job.Status)
+		assert.Equal(t, 0, job.Attempts)
+		assert.ErrorIs(t, queue.RetryJob(job.ID), models.ErrJobNotDead)
+	})
+
+	t.Run("Permanent failures are not retried", func(t *testing.T) {
+		job := &models.Job{Type: models.JobProvisionDevice}
+		store := newMemoryJobStore(job)
+		queue := service.NewJobQueue(store, nil)
+		queue.Register(models.JobProvisionDevice, func(*models.Job) error {
+			return fmt.Errorf("%w: device not in allowed devices", models.ErrJobPermanent)
+		})
+
+		_, err := queue.RunOnce()
+		require.NoError(t, err)
+		assert.Equal(t, models.JobDead, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+	})
+
+	t.Run("Unknown types and panics fail the attempt", func(t *testing.T) {
+		unknown := &models.Job{Type: "unknown"}
+		store := newMemoryJobStore(unknown)
+		queue := service.NewJobQueue(store, nil)
+
+		_, err := queue.RunOnce()
+		require.NoError(t, err)
+		assert.Equal(t, models.JobDead, unknown.Status)
+
+		panicking := &models.Job{Type: models.JobProvisionOrganization}
+		store = newMemoryJobStore(panicking)
+		queue = service.NewJobQueue(store, nil)
+		queue.Register(models.JobProvisionOrganization, func(*models.Job) error {
+			panic("boom")
+		})
+
+		_, err = queue.RunOnce()
+		require.NoError(t, err)
+		assert.Equal(t, models.JobPending, panicking.Status)
+		assert.Contains(t, *panicking.LastError, "boom")
+	})
+}
+
+func TestJobQueueLostLease(t *testing.T) {
+	job := &models.Job{Type: models.JobProvisionDevice}
+	store := newMemoryJobStore(job)
+	queue := service.NewJobQueue(store, nil)
+
+	// The handler outlives its lease and another worker claims the job
+	queue.Register(models.JobProvisionDevice, func(*models.Job) error {
+		job.Attempts++
+		return errors.New("chirpstack: unavailable")
+	})
+
+	processed, err := queue.RunOnce()
+	assert.True(t, processed)
+	assert.ErrorIs(t, err, models.ErrJobLeaseLost)
+	assert.Equal(t, models.JobRunning, job.Status)
+	assert.Nil(t, job.LastError)
+}
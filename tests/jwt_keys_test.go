@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRSAKey(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "rsa.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func writeEd25519Key(t *testing.T) (privatePath, publicPath string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	privatePath = filepath.Join(dir, "ed25519.pem")
+	publicPath = filepath.Join(dir, "ed25519.pub.pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600))
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0600))
+	return privatePath, publicPath
+}
+
+func TestAsymmetricJWT(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("RS256", func(t *testing.T) {
+		key, err := auth.LoadSigningKey("rsa-1", writeRSAKey(t))
+		require.NoError(t, err)
+		assert.Equal(t, "RS256", key.Method.Alg())
+
+		jwtService := auth.NewJWTServiceWithKeys(key, nil, 15*time.Minute)
+		token, err := jwtService.GenerateToken(userID, "test@example.com", models.RoleCustomer)
+		require.NoError(t, err)
+
+		claims, err := jwtService.ValidateToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, userID, claims.UserID)
+
+		jwks := jwtService.JWKS()
+		require.Len(t, jwks.Keys, 1)
+		assert.Equal(t, "RSA", jwks.Keys[0].KeyType)
+		assert.Equal(t, "rsa-1", jwks.Keys[0].KeyID)
+		assert.NotEmpty(t, jwks.Keys[0].N)
+		assert.Equal(t, "AQAB", jwks.Keys[0].E)
+	})
+
+	t.Run("EdDSA", func(t *testing.T) {
+		privatePath, _ := writeEd25519Key(t)
+		key, err := auth.LoadSigningKey("ed-1", privatePath)
+		require.NoError(t, err)
+		assert.Equal(t, "EdDSA", key.Method.Alg())
+
+		jwtService := auth.NewJWTServiceWithKeys(key, nil, 15*time.Minute)
+		token, err := jwtService.GenerateToken(userID, "test@example.com", models.RoleCustomer)
+		require.NoError(t, err)
+
+		_, err = jwtService.ValidateToken(token)
+		require.NoError(t, err)
+
+		jwks := jwtService.JWKS()
+		require.Len(t, jwks.Keys, 1)
+		assert.Equal(t, "OKP", jwks.Keys[0].KeyType)
+		assert.Equal(t, "Ed25519", jwks.Keys[0].Curve)
+	})
+
+	t.Run("Key rotation", func(t *testing.T) {
+		oldPrivate, oldPublic := writeEd25519Key(t)
+		oldKey, err := auth.LoadSigningKey("old", oldPrivate)
+		require.NoError(t, err)
+		newKey, err := auth.LoadSigningKey("new", writeRSAKey(t))
+		require.NoError(t, err)
+
+		oldService := auth.NewJWTServiceWithKeys(oldKey, nil, 15*time.Minute)
+		oldToken, err := oldService.GenerateToken(userID, "test@example.com", models.RoleCustomer)
+		require.NoError(t, err)
+
+		// Without the old public key the token is rejected
+		rotated := auth.NewJWTServiceWithKeys(newKey, nil, 15*time.Minute)
+		_, err = rotated.ValidateToken(oldToken)
+		assert.Error(t, err)
+
+		verificationKeys, err := auth.LoadVerificationKeys("old=" + oldPublic)
+		require.NoError(t, err)
+		rotated = auth.NewJWTServiceWithKeys(newKey, verificationKeys, 15*time.Minute)
+
+		_, err = rotated.ValidateToken(oldToken)
+		assert.NoError(t, err)
+		assert.Len(t, rotated.JWKS().Keys, 2)
+	})
+
+	t.Run("Shared secret tokens are rejected", func(t *testing.T) {
+		key, err := auth.LoadSigningKey("rsa-1", writeRSAKey(t))
+		require.NoError(t, err)
+
+		hmacToken, err := auth.NewJWTService("test-secret-key", 15*time.Minute).GenerateToken(userID, "test@example.com", models.RoleAdmin)
+		require.NoError(t, err)
+
+		_, err = auth.NewJWTServiceWithKeys(key, nil, 15*time.Minute).ValidateToken(hmacToken)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"go-auth-api/internal/lnode"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
+
+// lnodeFrames are decoded by the Go package and by the generated JavaScript
+var lnodeFrames = []struct {
+	name  string
+	frame []byte
+	want  *lnode.Uplink
+	err   error
+}{
+	{
+		name: "Power metrics",
+		frame: []byte{0x01, 80, 1,
+			0x01, 0x02, 0x03, 0x04, // Energy
+			0xE6, 0x59, // voltage 230.14
+			0x2C, 0x01, // current 3.00
+			0x5F, 0x00, // PF 0.95
+			0x10, 0x27, // Power 100.00
+			0x32, 0x00, // Tilt 0.50
+		},
+		want: &lnode.Uplink{Header: lnode.HeaderPower, Power: &lnode.PowerFrame{
+			Dimming:    80,
+			StatusLamp: 1,
+			// Every byte counts with its own weight, not all shifted by 8
+			Energy:  float64(0x04030201) / 100,
+			Voltage: 230.14,
+			Current: 3,
+			PF:      0.95,
+			Power:   100,
+			Tilt:    0.5,
+		}},
+	},
+	{
+		name:  "Energy uses all 32 bits",
+		frame: []byte{0x01, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		want:  &lnode.Uplink{Header: lnode.HeaderPower, Power: &lnode.PowerFrame{Energy: 42949672.95}},
+	},
+	{
+		name:  "GPS in the southern and western hemispheres",
+		frame: []byte{0x02, 0x9C, 0x39, 0xE4, 0xFE, 0x84, 0x84, 0xA3, 0xF9, 12},
+		want:  &lnode.Uplink{Header: lnode.HeaderGPS, GPS: &lnode.GPSFrame{Lat: -18.597476, Lng: -106.724220, Alt: 12}},
+	},
+	{
+		name:  "Timestamp",
+		frame: []byte{0x03, 0x80, 0x5D, 0x4A, 0x68},
+		want:  &lnode.Uplink{Header: lnode.HeaderTimestamp, Timestamp: &lnode.TimestampFrame{Timestamp: 0x684A5D80}},
+	},
+	{
+		name:  "Status without ID",
+		frame: []byte{0x04, 0x0A, 0x00},
+		want:  &lnode.Uplink{Header: lnode.HeaderStatus, Status: &lnode.StatusFrame{StatusCode: 10}},
+	},
+	{
+		name:  "Status with ID",
+		frame: []byte{0x04, 0x33, 0x00, 0x78, 0x56, 0x34, 0x12},
+		want:  &lnode.Uplink{Header: lnode.HeaderStatus, Status: &lnode.StatusFrame{StatusCode: 51, ID: uint32Ptr(0x12345678)}},
+	},
+	{
+		name:  "Trailing bytes are ignored",
+		frame: []byte{0x03, 1, 0, 0, 0, 0xFF},
+		want:  &lnode.Uplink{Header: lnode.HeaderTimestamp, Timestamp: &lnode.TimestampFrame{Timestamp: 1}},
+	},
+	{name: "Empty frame", frame: nil, err: lnode.ErrEmptyFrame},
+	{name: "Unknown header", frame: []byte{0x09, 1, 2}, err: lnode.ErrUnknownHeader},
+	{name: "Short power frame", frame: []byte{0x01, 80, 1, 0x01}, err: lnode.ErrShortFrame},
+	{name: "Status code 50 without ID", frame: []byte{0x04, 0x32, 0x00, 0x01}, err: lnode.ErrShortFrame},
+}
+
+func TestLnodeDecode(t *testing.T) {
+	for _, tt := range lnodeFrames {
+		t.Run(tt.name, func(t *testing.T) {
+			uplink, err := lnode.Decode(tt.frame)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want.Power != nil {
+				require.NotNil(t, uplink.Power)
+				assert.InDelta(t, tt.want.Power.Energy, uplink.Power.Energy, 1e-9)
+				assert.InDelta(t, tt.want.Power.Voltage, uplink.Power.Voltage, 1e-9)
+				assert.InDelta(t, tt.want.Power.Tilt, uplink.Power.Tilt, 1e-9)
+				uplink.Power.Energy, uplink.Power.Voltage, uplink.Power.Tilt = tt.want.Power.Energy, tt.want.Power.Voltage, tt.want.Power.Tilt
+			}
+			assert.Equal(t, tt.want, uplink)
+		})
+	}
+}
+
+func TestLnodeEncode(t *testing.T) {
+	t.Run("Encodes what it decodes", func(t *testing.T) {
+		frame := []byte{0x01, 80, 1, 0x01, 0x02, 0x03, 0x04, 0xE6, 0x59, 0x2C, 0x01, 0x5F, 0x00, 0x10, 0x27, 0x32, 0x00}
+		uplink, err := lnode.Decode(frame)
+		require.NoError(t, err)
+
+		encoded, err := lnode.Encode(uplink)
+		require.NoError(t, err)
+		assert.Equal(t, frame, encoded)
+	})
+
+	t.Run("Rounds to the frame resolution", func(t *testing.T) {
+		encoded, err := lnode.Encode(&lnode.Uplink{Header: lnode.HeaderGPS, GPS: &lnode.GPSFrame{Lat: 10.7769231, Lng: 106.7009, Alt: 5}})
+		require.NoError(t, err)
+
+		uplink, err := lnode.Decode(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, 10.776923, uplink.GPS.Lat)
+		assert.Equal(t, 106.7009, uplink.GPS.Lng)
+	})
+
+	tests := []struct {
+		name   string
+		uplink *lnode.Uplink
+		err    error
+	}{
+		{"Negative voltage", &lnode.Uplink{Header: lnode.HeaderPower, Power: &lnode.PowerFrame{Voltage: -1}}, lnode.ErrValueRange},
+		{"Voltage over 16 bits", &lnode.Uplink{Header: lnode.HeaderPower, Power: &lnode.PowerFrame{Voltage: 655.36}}, lnode.ErrValueRange},
+		{"Latitude over 32 bits", &lnode.Uplink{Header: lnode.HeaderGPS, GPS: &lnode.GPSFrame{Lat: 2147.483648}}, lnode.ErrValueRange},
+		{"ID with a code that has none", &lnode.Uplink{Header: lnode.HeaderStatus, Status: &lnode.StatusFrame{StatusCode: 10, ID: uint32Ptr(1)}}, lnode.ErrValueRange},
+		{"Code that needs an ID", &lnode.Uplink{Header: lnode.HeaderStatus, Status: &lnode.StatusFrame{StatusCode: 52}}, lnode.ErrMissingValue},
+		{"Header without its frame", &lnode.Uplink{Header: lnode.HeaderTimestamp}, lnode.ErrMissingValue},
+		{"Unknown header", &lnode.Uplink{Header: 7}, lnode.ErrUnknownHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := lnode.Encode(tt.uplink)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestLnodeObject(t *testing.T) {
+	uplink, err := lnode.Decode([]byte{0x04, 0x33, 0x00, 0x78, 0x56, 0x34, 0x12})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"header_device": 4,
+		"status_code":   int64(51),
+		"ID":            int64(0x12345678),
+	}, uplink.Object())
+}
+
+// jsList returns the elements of an exported JavaScript array
+func jsList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+// jsNumber converts the numbers of Object() and of exported JavaScript values
+func jsNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return math.NaN()
+}
+
+func TestLnodeJavaScript(t *testing.T) {
+	script := lnode.JavaScript()
+
+	vm := goja.New()
+	_, err := vm.RunString(script)
+	require.NoError(t, err)
+	decodeUplink, ok := goja.AssertFunction(vm.Get("decodeUplink"))
+	require.True(t, ok)
+
+	// The codec ChirpStack runs has to agree with the decoder used here
+	for _, tt := range lnodeFrames {
+		t.Run(tt.name, func(t *testing.T) {
+			bytes := make([]interface{}, len(tt.frame))
+			for i, b := range tt.frame {
+				bytes[i] = int(b)
+			}
+			value, err := decodeUplink(goja.Undefined(), vm.ToValue(map[string]interface{}{"bytes": bytes, "fPort": 1}))
+			require.NoError(t, err)
+			result := value.Export().(map[string]interface{})
+
+			if tt.err != nil {
+				// Unknown headers are passed on with a warning
+				assert.NotEmpty(t, append(jsList(result["errors"]), jsList(result["warnings"])...))
+				return
+			}
+
+			require.Empty(t, jsList(result["errors"]))
+			uplink, err := lnode.Decode(tt.frame)
+			require.NoError(t, err)
+			want := uplink.Object()
+
+			data := result["data"].(map[string]interface{})
+			require.Len(t, data, len(want))
+			for key, value := range want {
+				require.Contains(t, data, key)
+				assert.InDelta(t, jsNumber(value), jsNumber(data[key]), 1e-9, key)
+			}
+		})
+	}
+
+	assert.Contains(t, script, "function decodeUplink(input)")
+	for _, spec := range lnode.Spec {
+		for _, f := range append(append([]lnode.Field(nil), spec.Fields...), spec.Optional...) {
+			assert.Contains(t, script, "data."+f.Name+" = ")
+		}
+	}
+	assert.Contains(t, script, "data.Energy = readUint(bytes, 3, 4) / 100;")
+	assert.Contains(t, script, "data.lat = readInt(bytes, 1, 4) / 1000000;")
+	assert.Contains(t, script, "[50, 51, 52, 53].indexOf(data.status_code)")
+}
+
+// FuzzLnodeDecode checks that any frame decodes without panicking and that
+// decoded frames encode back to the same bytes
+func FuzzLnodeDecode(f *testing.F) {
+	f.Add([]byte{0x01, 80, 1, 0x01, 0x02, 0x03, 0x04, 0xE6, 0x59, 0x2C, 0x01, 0x5F, 0x00, 0x10, 0x27, 0x32, 0x00})
+	f.Add([]byte{0x02, 0x9C, 0x39, 0xE4, 0xFE, 0x84, 0x84, 0xA3, 0xF9, 12})
+	f.Add([]byte{0x03, 0x80, 0x5D, 0x4A, 0x68})
+	f.Add([]byte{0x04, 0x33, 0x00, 0x78, 0x56, 0x34, 0x12})
+	f.Add([]byte{0x04, 0x0A, 0x00})
+	f.Add([]byte{0x09})
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		uplink, err := lnode.Decode(frame)
+		if err != nil {
+			return
+		}
+
+		encoded, err := lnode.Encode(uplink)
+		if err != nil {
+			t.Fatalf("decoded frame %x does not encode: %v", frame, err)
+		}
+		if !bytes.HasPrefix(frame, encoded) {
+			t.Fatalf("frame %x encodes to %x", frame, encoded)
+		}
+	})
+}
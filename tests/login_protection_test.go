@@ -0,0 +1,294 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/handlers"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPasswordHasherPool(t *testing.T) {
+	hash, err := auth.HashPassword("password123")
+	assert.NoError(t, err)
+
+	t.Run("Checks passwords", func(t *testing.T) {
+		hasher := auth.NewPasswordHasher(2, time.Second)
+		ok, err := hasher.Check("password123", hash)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = hasher.Check("wrong", hash)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Rejects work when all workers stay busy", func(t *testing.T) {
+		hasher := auth.NewPasswordHasher(1, time.Millisecond)
+
+		var wg sync.WaitGroup
+		results := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := hasher.Check("password123", hash)
+				results <- err
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		busy := 0
+		for err := range results {
+			if err != nil {
+				assert.ErrorIs(t, err, auth.ErrHasherBusy)
+				busy++
+			}
+		}
+		assert.Greater(t, busy, 0)
+		assert.Less(t, busy, 4)
+	})
+}
+
+func TestLoginGuardAccount(t *testing.T) {
+	guard := service.NewLoginGuard(nil, nil, 10, 15*time.Minute)
+	now := time.Now()
+
+	t.Run("A few failures are not throttled", func(t *testing.T) {
+		user := &models.User{FailedLogins: 2, LastFailedLogin: &now}
+		assert.NoError(t, guard.CheckAccount(user))
+	})
+
+	t.Run("Repeated failures add a delay", func(t *testing.T) {
+		user := &models.User{FailedLogins: 5, LastFailedLogin: &now}
+		err := guard.CheckAccount(user)
+
+		var throttled *models.LoginThrottledError
+		assert.ErrorAs(t, err, &throttled)
+		assert.ErrorIs(t, err, models.ErrLoginThrottled)
+		assert.False(t, throttled.Locked)
+		assert.InDelta(t, 4*time.Second, throttled.RetryAfter, float64(time.Second))
+	})
+
+	t.Run("Delay has passed", func(t *testing.T) {
+		earlier := now.Add(-time.Minute)
+		user := &models.User{FailedLogins: 9, LastFailedLogin: &earlier}
+		assert.NoError(t, guard.CheckAccount(user))
+	})
+
+	t.Run("Locked account", func(t *testing.T) {
+		lockedUntil := now.Add(10 * time.Minute)
+		err := guard.CheckAccount(&models.User{LockedUntil: &lockedUntil})
+
+		var throttled *models.LoginThrottledError
+		assert.ErrorAs(t, err, &throttled)
+		assert.True(t, throttled.Locked)
+	})
+
+	t.Run("Expired lock", func(t *testing.T) {
+		lockedUntil := now.Add(-time.Second)
+		assert.NoError(t, guard.CheckAccount(&models.User{LockedUntil: &lockedUntil}))
+	})
+}
+
+func TestLoginThrottledResponse(t *testing.T) {
+	router, mockService := setupTestRouter()
+
+	mockService.On("Login", mock.MatchedBy(func(req *models.LoginRequest) bool {
+		return req.Email == "locked@example.com" && req.IPAddress != ""
+	})).Return((*models.AuthResponse)(nil), &models.LoginThrottledError{RetryAfter: 90 * time.Second, Locked: true})
+
+	jsonBody, _ := json.Marshal(models.LoginRequest{Email: "locked@example.com", Password: "password123"})
+	req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "203.0.113.7:4321"
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "90", w.Header().Get("Retry-After"))
+	mockService.AssertExpectations(t)
+}
+
+// memorySecurityStore is an in-memory SecurityStore
+type memorySecurityStore struct {
+	failures map[uuid.UUID]int
+	events   []models.SecurityEvent
+}
+
+func newMemorySecurityStore() *memorySecurityStore {
+	return &memorySecurityStore{failures: map[uuid.UUID]int{}}
+}
+
+func (m *memorySecurityStore) RecordFailedLogin(userID uuid.UUID, lockAfter int, lockedUntil time.Time) (int, *time.Time, error) {
+	m.failures[userID]++
+	if m.failures[userID] >= lockAfter {
+		return m.failures[userID], &lockedUntil, nil
+	}
+	return m.failures[userID], nil, nil
+}
+
+func (m *memorySecurityStore) ResetFailedLogins(userID uuid.UUID) error {
+	delete(m.failures, userID)
+	return nil
+}
+
+func (m *memorySecurityStore) CreateSecurityEvent(event *models.SecurityEvent) error {
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *memorySecurityStore) GetSecurityEvents(userID *uuid.UUID, page, pageSize int) ([]models.SecurityEvent, int, error) {
+	return m.events, len(m.events), nil
+}
+
+func TestLoginGuardRecordsFailures(t *testing.T) {
+	store := newMemorySecurityStore()
+	guard := service.NewLoginGuard(store, nil, 3, 15*time.Minute)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com"}
+	req := &models.LoginRequest{Email: user.Email, IPAddress: "203.0.113.7"}
+
+	guard.RecordFailure(user, req)
+	guard.RecordFailure(user, req)
+	assert.Equal(t, 2, store.failures[user.ID])
+
+	guard.RecordFailure(user, req)
+	assert.Equal(t, models.EventAccountLocked, store.events[len(store.events)-1].EventType)
+
+	user.FailedLogins = 3
+	guard.RecordSuccess(user, req)
+	assert.Zero(t, store.failures[user.ID])
+}
+
+// guardedUserService fails every login through a LoginGuard, like
+// UserService does for a wrong password
+type guardedUserService struct {
+	MockUserService
+	guard *service.LoginGuard
+}
+
+func (s *guardedUserService) Login(req *models.LoginRequest) (*models.AuthResponse, error) {
+	if err := s.guard.CheckIP(req.IPAddress); err != nil {
+		return nil, err
+	}
+	s.guard.RecordFailure(nil, req)
+	return nil, fmt.Errorf("invalid credentials")
+}
+
+func TestLoginIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newMemorySecurityStore()
+	userService := &guardedUserService{guard: service.NewLoginGuard(store, nil, 10, 15*time.Minute)}
+	userHandler := handlers.NewUserHandler(userService)
+
+	// Same setup as the server without TRUSTED_PROXIES
+	router := gin.New()
+	assert.NoError(t, router.SetTrustedProxies(nil))
+	router.POST("/api/v1/auth/login", userHandler.Login)
+
+	login := func(forwardedFor string) int {
+		jsonBody, _ := json.Marshal(models.LoginRequest{Email: "victim@example.com", Password: "guess"})
+		req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = "203.0.113.7:4321"
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Every attempt claims a fresh address, but they all count against the peer
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login(fmt.Sprintf("198.51.100.%d", i)))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, login("198.51.100.99"))
+
+	for _, event := range store.events {
+		assert.Equal(t, "203.0.113.7", event.IPAddress)
+	}
+}
+
+// MockMFAService only implements the second login step and disabling
+type MockMFAService struct {
+	interfaces.MFAServiceInterface
+	mock.Mock
+}
+
+func (m *MockMFAService) Disable(userID uuid.UUID, req *models.DisableTOTPRequest) error {
+	args := m.Called(userID, req)
+	return args.Error(0)
+}
+
+func (m *MockMFAService) CompleteLogin(req *models.MFALoginRequest) (*models.AuthResponse, error) {
+	args := m.Called(req)
+	return args.Get(0).(*models.AuthResponse), args.Error(1)
+}
+
+func TestMFALoginThrottledResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockMFAService{}
+	router := gin.New()
+	router.POST("/api/v1/auth/login/2fa", handlers.NewMFAHandler(mockService).CompleteLogin)
+
+	mockService.On("CompleteLogin", mock.MatchedBy(func(req *models.MFALoginRequest) bool {
+		return req.MFAToken == "challenge" && req.IPAddress == "203.0.113.7"
+	})).Return((*models.AuthResponse)(nil), &models.LoginThrottledError{RetryAfter: 8 * time.Second})
+
+	jsonBody, _ := json.Marshal(models.MFALoginRequest{MFAToken: "challenge", Code: "000000"})
+	req, _ := http.NewRequest("POST", "/api/v1/auth/login/2fa", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "203.0.113.7:4321"
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "8", w.Header().Get("Retry-After"))
+	mockService.AssertExpectations(t)
+}
+
+func TestMFADisableThrottledResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New()
+	mockService := &MockMFAService{}
+	router := gin.New()
+	router.POST("/api/v1/auth/2fa/disable", func(c *gin.Context) {
+		c.Set("user_id", userID)
+	}, handlers.NewMFAHandler(mockService).Disable)
+
+	mockService.On("Disable", userID, mock.MatchedBy(func(req *models.DisableTOTPRequest) bool {
+		return req.Password == "guess" && req.IPAddress == "203.0.113.7"
+	})).Return(&models.LoginThrottledError{RetryAfter: 4 * time.Second})
+
+	jsonBody, _ := json.Marshal(models.DisableTOTPRequest{Password: "guess", Code: "000000"})
+	req, _ := http.NewRequest("POST", "/api/v1/auth/2fa/disable", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "203.0.113.7:4321"
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "4", w.Header().Get("Retry-After"))
+	mockService.AssertExpectations(t)
+}
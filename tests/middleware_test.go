@@ -0,0 +1,300 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/middleware"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRoleTestRouter(jwtService *auth.JWTService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) }
+
+	api := router.Group("/api/v1")
+	api.Use(middleware.AuthMiddleware(jwtService, nil, nil))
+	{
+		api.GET("/devices/all", middleware.RequireRole(models.RoleAdmin), ok)
+		api.POST("/devices/allowed", middleware.RequireRole(models.RoleAdmin, models.RoleOperator), ok)
+		api.PUT("/users/:id", middleware.RequireSelfOrRole("id", models.RoleAdmin), ok)
+	}
+
+	return router
+}
+
+func TestRoleMiddleware(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret-key", 15*time.Minute)
+	router := setupRoleTestRouter(jwtService)
+
+	tokenFor := func(userID uuid.UUID, role string) string {
+		token, err := jwtService.GenerateToken(userID, "test@example.com", role)
+		assert.NoError(t, err)
+		return token
+	}
+
+	do := func(method, path, token string) int {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	customerID := uuid.New()
+	customer := tokenFor(customerID, models.RoleCustomer)
+	operator := tokenFor(uuid.New(), models.RoleOperator)
+	admin := tokenFor(uuid.New(), models.RoleAdmin)
+
+	t.Run("Fleet endpoints are admin only", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do("GET", "/api/v1/devices/all", customer))
+		assert.Equal(t, http.StatusForbidden, do("GET", "/api/v1/devices/all", operator))
+		assert.Equal(t, http.StatusOK, do("GET", "/api/v1/devices/all", admin))
+	})
+
+	t.Run("Inventory endpoints allow admins and operators", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do("POST", "/api/v1/devices/allowed", customer))
+		assert.Equal(t, http.StatusOK, do("POST", "/api/v1/devices/allowed", operator))
+		assert.Equal(t, http.StatusOK, do("POST", "/api/v1/devices/allowed", admin))
+	})
+
+	t.Run("Customers can only manage their own account", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do("PUT", "/api/v1/users/"+customerID.String(), customer))
+		assert.Equal(t, http.StatusForbidden, do("PUT", "/api/v1/users/"+uuid.New().String(), customer))
+		assert.Equal(t, http.StatusOK, do("PUT", "/api/v1/users/"+customerID.String(), admin))
+	})
+
+	t.Run("Missing token is rejected", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/v1/devices/all", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// denylist is a TokenRevocationChecker backed by a set of JWT IDs
+type denylist map[string]bool
+
+func (d denylist) IsRevoked(claims *auth.JWTClaims) bool {
+	return d[claims.ID]
+}
+
+func TestAuthMiddlewareRevocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jwtService := auth.NewJWTService("test-secret-key", 15*time.Minute)
+	revoked := denylist{}
+
+	router := gin.New()
+	router.GET("/profile", middleware.AuthMiddleware(jwtService, revoked, nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"token_id": c.GetString("token_id")})
+	})
+
+	token, err := jwtService.GenerateToken(uuid.New(), "test@example.com", models.RoleCustomer)
+	assert.NoError(t, err)
+
+	claims, err := jwtService.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims.ID)
+
+	do := func() int {
+		req, _ := http.NewRequest("GET", "/profile", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do())
+
+	revoked[claims.ID] = true
+	assert.Equal(t, http.StatusUnauthorized, do())
+}
+
+// memoryRevocations is an in-memory TokenRevocationStorage
+type memoryRevocations struct {
+	tokens map[string]time.Time
+	users  map[uuid.UUID]time.Time
+}
+
+func newMemoryRevocations() *memoryRevocations {
+	return &memoryRevocations{tokens: map[string]time.Time{}, users: map[uuid.UUID]time.Time{}}
+}
+
+func (m *memoryRevocations) RevokeAccessToken(jti string, userID uuid.UUID, expiresAt time.Time) error {
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+func (m *memoryRevocations) RevokeUserAccessTokens(userID uuid.UUID, revokedBefore time.Time) error {
+	m.users[userID] = revokedBefore
+	return nil
+}
+
+func (m *memoryRevocations) GetRevokedAccessTokens() (map[string]time.Time, error) {
+	tokens := make(map[string]time.Time)
+	for jti, expiresAt := range m.tokens {
+		tokens[jti] = expiresAt
+	}
+	return tokens, nil
+}
+
+func (m *memoryRevocations) GetUserRevocationsSince(since time.Time) (map[uuid.UUID]time.Time, error) {
+	users := make(map[uuid.UUID]time.Time)
+	for userID, cutoff := range m.users {
+		if cutoff.After(since) {
+			users[userID] = cutoff
+		}
+	}
+	return users, nil
+}
+
+func (m *memoryRevocations) DeleteExpiredRevocations(olderThan time.Time) error {
+	return nil
+}
+
+func TestTokenRevocationStoreUserCutoff(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret-key", 15*time.Minute)
+	userID := uuid.New()
+
+	claimsFor := func(userID uuid.UUID) *auth.JWTClaims {
+		token, err := jwtService.GenerateToken(userID, "test@example.com", models.RoleCustomer)
+		assert.NoError(t, err)
+		claims, err := jwtService.ValidateToken(token)
+		assert.NoError(t, err)
+		return claims
+	}
+
+	t.Run("Tokens carry sub-second issue times", func(t *testing.T) {
+		before := time.Now().Truncate(time.Microsecond)
+		claims := claimsFor(userID)
+		after := time.Now()
+
+		assert.False(t, claims.IssuedAt.Time.Before(before.Add(-time.Microsecond)), "iat %s before %s", claims.IssuedAt.Time, before)
+		assert.False(t, claims.IssuedAt.Time.After(after))
+	})
+
+	t.Run("Tokens issued before the revocation are rejected, later ones accepted", func(t *testing.T) {
+		repo := newMemoryRevocations()
+		store := service.NewTokenRevocationStore(repo, 15*time.Minute)
+
+		revoked := claimsFor(userID)
+		assert.NoError(t, store.RevokeUserTokens(userID))
+		cutoff := repo.users[userID]
+
+		assert.True(t, store.IsRevoked(revoked))
+
+		// A login in the same second after the revocation
+		later := *revoked
+		later.IssuedAt = jwt.NewNumericDate(cutoff.Add(time.Microsecond))
+		assert.False(t, store.IsRevoked(&later))
+
+		later.IssuedAt = jwt.NewNumericDate(cutoff)
+		assert.True(t, store.IsRevoked(&later))
+	})
+
+	t.Run("Cutoff survives a reload", func(t *testing.T) {
+		repo := newMemoryRevocations()
+		store := service.NewTokenRevocationStore(repo, 15*time.Minute)
+
+		claims := claimsFor(userID)
+		assert.NoError(t, repo.RevokeUserAccessTokens(userID, claims.IssuedAt.Time))
+		assert.NoError(t, store.Reload())
+
+		assert.True(t, store.IsRevoked(claims))
+	})
+
+	t.Run("Tokens of other users and later tokens are accepted", func(t *testing.T) {
+		repo := newMemoryRevocations()
+		store := service.NewTokenRevocationStore(repo, 15*time.Minute)
+
+		claims := claimsFor(userID)
+		assert.NoError(t, repo.RevokeUserAccessTokens(userID, claims.IssuedAt.Time.Add(-time.Second)))
+		assert.NoError(t, store.Reload())
+
+		assert.False(t, store.IsRevoked(claims))
+		assert.False(t, store.IsRevoked(claimsFor(uuid.New())))
+	})
+}
+
+// staticAPIKeys is an APIKeyAuthenticator backed by a map of keys
+type staticAPIKeys map[string]*models.APIKeyPrincipal
+
+func (k staticAPIKeys) AuthenticateAPIKey(key string) (*models.APIKeyPrincipal, error) {
+	principal, ok := k[key]
+	if !ok {
+		return nil, models.ErrInvalidAPIKey
+	}
+	return principal, nil
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jwtService := auth.NewJWTService("test-secret-key", 15*time.Minute)
+	readOnly, _, _, err := auth.GenerateAPIKey()
+	assert.NoError(t, err)
+	apiKeys := staticAPIKeys{
+		readOnly: {
+			KeyID:  uuid.New(),
+			UserID: uuid.New(),
+			Role:   models.RoleCustomer,
+			Scopes: []string{models.ScopeDevicesRead},
+		},
+	}
+
+	router := gin.New()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) }
+	api := router.Group("/api/v1")
+	api.Use(middleware.AuthMiddleware(jwtService, nil, apiKeys))
+	{
+		api.GET("/devices/my", middleware.RequireScope(models.ScopeDevicesRead), ok)
+		api.POST("/devices", middleware.RequireScope(models.ScopeDevicesWrite), ok)
+		api.GET("/user/profile", middleware.RejectAPIKeys(), ok)
+	}
+
+	do := func(method, path string, header, value string) int {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Key with scope is allowed", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do("GET", "/api/v1/devices/my", "X-API-Key", readOnly))
+		assert.Equal(t, http.StatusOK, do("GET", "/api/v1/devices/my", "Authorization", "Bearer "+readOnly))
+	})
+
+	t.Run("Key without scope is forbidden", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do("POST", "/api/v1/devices", "X-API-Key", readOnly))
+	})
+
+	t.Run("Keys cannot reach account endpoints", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do("GET", "/api/v1/user/profile", "X-API-Key", readOnly))
+	})
+
+	t.Run("Unknown key is rejected", func(t *testing.T) {
+		unknown, _, _, err := auth.GenerateAPIKey()
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, do("GET", "/api/v1/devices/my", "X-API-Key", unknown))
+	})
+
+	t.Run("Access tokens are not limited by scopes", func(t *testing.T) {
+		token, err := jwtService.GenerateToken(uuid.New(), "test@example.com", models.RoleCustomer)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, do("POST", "/api/v1/devices", "Authorization", "Bearer "+token))
+		assert.Equal(t, http.StatusOK, do("GET", "/api/v1/user/profile", "Authorization", "Bearer "+token))
+	})
+}
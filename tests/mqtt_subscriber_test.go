@@ -0,0 +1,262 @@
+package tests
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBroker is an MQTT broker stand-in for one client. It records the
+// connects and subscriptions it sees and publishes QoS 1 messages whose acks
+// the test can wait for.
+type fakeBroker struct {
+	listener   net.Listener
+	connects   chan *packets.ConnectPacket
+	subscribes chan []string
+	acks       chan uint16
+
+	mu       sync.Mutex
+	conn     net.Conn
+	sessions map[string]bool
+	nextID   uint16
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	b := &fakeBroker{
+		listener:   listener,
+		connects:   make(chan *packets.ConnectPacket, 16),
+		subscribes: make(chan []string, 16),
+		acks:       make(chan uint16, 16),
+		sessions:   map[string]bool{},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		b.drop()
+	})
+	return b
+}
+
+func (b *fakeBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+
+	p, err := packets.ReadPacket(conn)
+	if err != nil {
+		return
+	}
+	connect, ok := p.(*packets.ConnectPacket)
+	if !ok {
+		return
+	}
+
+	b.mu.Lock()
+	connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+	connack.SessionPresent = !connect.CleanSession && b.sessions[connect.ClientIdentifier]
+	b.sessions[connect.ClientIdentifier] = !connect.CleanSession
+	b.conn = conn
+	err = connack.Write(conn)
+	b.mu.Unlock()
+	if err != nil {
+		return
+	}
+	b.connects <- connect
+
+	for {
+		p, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		var reply packets.ControlPacket
+		switch p := p.(type) {
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			suback.ReturnCodes = p.Qoss
+			reply = suback
+			b.subscribes <- p.Topics
+		case *packets.UnsubscribePacket:
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = p.MessageID
+			reply = unsuback
+		case *packets.PingreqPacket:
+			reply = packets.NewControlPacket(packets.Pingresp)
+		case *packets.PubackPacket:
+			b.acks <- p.MessageID
+		case *packets.DisconnectPacket:
+			return
+		}
+
+		if reply != nil {
+			b.mu.Lock()
+			err = reply.Write(conn)
+			b.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// publish sends a QoS 1 message to the client and returns its message ID
+func (b *fakeBroker) publish(t *testing.T, topic string, payload []byte) uint16 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.Qos = 1
+	publish.TopicName = topic
+	publish.MessageID = b.nextID
+	publish.Payload = payload
+	require.NoError(t, publish.Write(b.conn))
+	return b.nextID
+}
+
+// drop closes the client's connection as a broker restart would
+func (b *fakeBroker) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn != nil {
+		b.conn.Close()
+	}
+}
+
+func receive[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the MQTT client")
+	}
+	var zero T
+	return zero
+}
+
+func newSubscriber(t *testing.T, broker *fakeBroker, store *memoryIntegrationStore) *service.MQTTSubscriber {
+	subscriber := service.NewMQTTSubscriber(service.MQTTOptions{
+		Broker:          broker.url(),
+		ClientID:        "go-auth-api-test",
+		Encoding:        chirpstack.EncodingJSON,
+		RefreshInterval: 50 * time.Millisecond,
+	}, store, service.NewIntegrationService(store, store, store, store))
+	subscriber.Start()
+	t.Cleanup(subscriber.Stop)
+	return subscriber
+}
+
+const uplinkTopic = "application/app-1/device/c5eabc521e8304ee/event/up"
+
+func TestMQTTSubscriber(t *testing.T) {
+	t.Run("Events of the stored applications are processed and acknowledged", func(t *testing.T) {
+		broker, store := newFakeBroker(t), newIntegrationStore()
+		newSubscriber(t, broker, store)
+
+		connect := receive(t, broker.connects)
+		assert.False(t, connect.CleanSession)
+		assert.Equal(t, "go-auth-api-test", connect.ClientIdentifier)
+		assert.Equal(t, []string{"application/app-1/device/+/event/+"}, receive(t, broker.subscribes))
+
+		id := broker.publish(t, uplinkTopic, []byte(uplinkEvent))
+		assert.Equal(t, id, receive(t, broker.acks))
+		at, ok := store.lastSeenOf(store.devices[0].ID)
+		require.True(t, ok)
+		assert.Equal(t, time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC), at)
+	})
+
+	t.Run("Reconnects resume the session and subscribe again", func(t *testing.T) {
+		broker, store := newFakeBroker(t), newIntegrationStore()
+		newSubscriber(t, broker, store)
+		receive(t, broker.connects)
+		receive(t, broker.subscribes)
+
+		broker.drop()
+		connect := receive(t, broker.connects)
+		assert.False(t, connect.CleanSession)
+		assert.Equal(t, []string{"application/app-1/device/+/event/+"}, receive(t, broker.subscribes))
+
+		id := broker.publish(t, uplinkTopic, []byte(uplinkEvent))
+		assert.Equal(t, id, receive(t, broker.acks))
+		_, ok := store.lastSeenOf(store.devices[0].ID)
+		assert.True(t, ok)
+	})
+
+	t.Run("Applications of new organizations are picked up", func(t *testing.T) {
+		broker, store := newFakeBroker(t), newIntegrationStore()
+		newSubscriber(t, broker, store)
+		receive(t, broker.connects)
+		receive(t, broker.subscribes)
+
+		application := "app-2"
+		store.mu.Lock()
+		store.orgs = append(store.orgs, models.Organization{ID: uuid.New(), ApplicationID: &application})
+		store.mu.Unlock()
+
+		assert.Equal(t, []string{"application/app-2/device/+/event/+"}, receive(t, broker.subscribes))
+	})
+
+	t.Run("Events that can't be processed are acknowledged", func(t *testing.T) {
+		broker, store := newFakeBroker(t), newIntegrationStore()
+		newSubscriber(t, broker, store)
+		receive(t, broker.connects)
+		receive(t, broker.subscribes)
+
+		for _, message := range []struct{ topic, payload string }{
+			{strings.Replace(uplinkTopic, "app-1", "app-9", 1), uplinkEvent},
+			{uplinkTopic, `{"deviceInfo":`},
+			{strings.Replace(uplinkTopic, "c5eabc521e8304ee", "0000000000000002", 1), strings.Replace(uplinkEvent, "c5eabc521e8304ee", "0000000000000002", 1)},
+			{"application/app-1/device/c5eabc521e8304ee/event/integration", uplinkEvent},
+		} {
+			id := broker.publish(t, message.topic, []byte(message.payload))
+			assert.Equal(t, id, receive(t, broker.acks), message.topic)
+		}
+
+		_, ok := store.lastSeenOf(store.devices[0].ID)
+		assert.False(t, ok)
+		_, ok = store.lastSeenOf(store.devices[1].ID)
+		assert.False(t, ok)
+	})
+
+	t.Run("Events that fail for now are left for redelivery", func(t *testing.T) {
+		broker, store := newFakeBroker(t), newIntegrationStore()
+		store.lastSeenErr = errors.New("database is unavailable")
+		newSubscriber(t, broker, store)
+		receive(t, broker.connects)
+		receive(t, broker.subscribes)
+
+		// Messages are handled in order, so the malformed event's ack is the
+		// first one unless the failed event was acknowledged
+		broker.publish(t, uplinkTopic, []byte(uplinkEvent))
+		malformed := broker.publish(t, uplinkTopic, []byte(`{"deviceInfo":`))
+		assert.Equal(t, malformed, receive(t, broker.acks))
+	})
+}
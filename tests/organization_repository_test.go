@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selectConnector is a database that answers every query with one row holding
+// a value for each column of the SELECT list. Columns without a value are
+// NULL. Scanning into a different number of destinations fails, which catches
+// SELECT lists and Scan calls that drifted apart.
+type selectConnector struct {
+	values map[string]driver.Value
+}
+
+func (c *selectConnector) Connect(context.Context) (driver.Conn, error) { return &selectConn{c}, nil }
+func (c *selectConnector) Driver() driver.Driver                        { return nil }
+
+type selectConn struct{ c *selectConnector }
+
+func (c *selectConn) Prepare(query string) (driver.Stmt, error) { return &selectStmt{c.c, query}, nil }
+func (c *selectConn) Close() error                              { return nil }
+func (c *selectConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type selectStmt struct {
+	c     *selectConnector
+	query string
+}
+
+func (s *selectStmt) Close() error  { return nil }
+func (s *selectStmt) NumInput() int { return -1 }
+
+func (s *selectStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("only queries are supported")
+}
+
+func (s *selectStmt) Query([]driver.Value) (driver.Rows, error) {
+	start := strings.Index(s.query, "SELECT") + len("SELECT")
+	end := strings.Index(s.query, "FROM")
+	if start < len("SELECT") || end < start {
+		return nil, errors.New("not a SELECT")
+	}
+
+	columns := []string{}
+	for _, column := range strings.Split(s.query[start:end], ",") {
+		column = strings.TrimSpace(column)
+		columns = append(columns, column[strings.LastIndex(column, ".")+1:])
+	}
+	return &selectRows{c: s.c, columns: columns}, nil
+}
+
+type selectRows struct {
+	c       *selectConnector
+	columns []string
+	done    bool
+}
+
+func (r *selectRows) Columns() []string { return r.columns }
+func (r *selectRows) Close() error      { return nil }
+
+func (r *selectRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	for i, column := range r.columns {
+		dest[i] = r.c.values[column]
+	}
+	return nil
+}
+
+func TestOrganizationRepositoryScans(t *testing.T) {
+	id := uuid.New()
+	now := time.Now().UTC()
+	db := sql.OpenDB(&selectConnector{values: map[string]driver.Value{
+		"id":                   id.String(),
+		"name":                 "Acme",
+		"region":               "AS923_2",
+		"application_id":       "app-1",
+		"provisioning_status":  models.ProvisioningProvisioned,
+		"integration_encoding": "PROTOBUF",
+		"created_at":           now,
+		"updated_at":           now,
+		"role":                 models.OrgRoleOwner,
+	}})
+	defer db.Close()
+	repo := repository.NewOrganizationRepository(db)
+
+	check := func(t *testing.T, org *models.Organization) {
+		assert.Equal(t, id, org.ID)
+		assert.Equal(t, "Acme", org.Name)
+		require.NotNil(t, org.ApplicationID)
+		assert.Equal(t, "app-1", *org.ApplicationID)
+		require.NotNil(t, org.IntegrationEncoding)
+		assert.Equal(t, "PROTOBUF", *org.IntegrationEncoding)
+		assert.Nil(t, org.TenantID)
+	}
+
+	t.Run("Organization by ID", func(t *testing.T) {
+		org, err := repo.GetOrganizationByID(id)
+		require.NoError(t, err)
+		check(t, org)
+	})
+
+	t.Run("Organizations of a user", func(t *testing.T) {
+		orgs, err := repo.GetOrganizationsForUser(uuid.New())
+		require.NoError(t, err)
+		require.Len(t, orgs, 1)
+		check(t, &orgs[0])
+		assert.Equal(t, models.OrgRoleOwner, orgs[0].Role)
+	})
+
+	t.Run("Provisioned organizations", func(t *testing.T) {
+		orgs, err := repo.GetProvisionedOrganizations()
+		require.NoError(t, err)
+		require.Len(t, orgs, 1)
+		check(t, &orgs[0])
+	})
+}
@@ -0,0 +1,303 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-auth-api/internal/config"
+	"go-auth-api/internal/handlers"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock OrganizationService
+type MockOrganizationService struct {
+	mock.Mock
+}
+
+var _ interfaces.OrganizationServiceInterface = (*MockOrganizationService)(nil)
+
+func (m *MockOrganizationService) CreateOrganization(userID uuid.UUID, req *models.CreateOrganizationRequest) (*models.Organization, error) {
+	args := m.Called(userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationService) GetOrganizations(userID uuid.UUID) ([]models.Organization, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationService) GetOrganization(id, userID uuid.UUID, role string) (*models.Organization, error) {
+	args := m.Called(id, userID, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationService) UpdateOrganization(id, userID uuid.UUID, role string, req *models.UpdateOrganizationRequest) (*models.Organization, error) {
+	args := m.Called(id, userID, role, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationService) GetMembers(id, userID uuid.UUID, role string) ([]models.OrganizationMember, error) {
+	args := m.Called(id, userID, role)
+	return args.Get(0).([]models.OrganizationMember), args.Error(1)
+}
+
+func (m *MockOrganizationService) UpdateMemberRole(id, userID uuid.UUID, role string, memberID uuid.UUID, req *models.UpdateMemberRoleRequest) error {
+	args := m.Called(id, userID, role, memberID, req)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) RemoveMember(id, userID uuid.UUID, role string, memberID uuid.UUID) error {
+	args := m.Called(id, userID, role, memberID)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) CreateInvitation(id, userID uuid.UUID, role string, req *models.CreateInvitationRequest) (*models.OrganizationInvitation, error) {
+	args := m.Called(id, userID, role, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OrganizationInvitation), args.Error(1)
+}
+
+func (m *MockOrganizationService) GetInvitations(id, userID uuid.UUID, role string) ([]models.OrganizationInvitation, error) {
+	args := m.Called(id, userID, role)
+	return args.Get(0).([]models.OrganizationInvitation), args.Error(1)
+}
+
+func (m *MockOrganizationService) RevokeInvitation(id, userID uuid.UUID, role string, invitationID uuid.UUID) error {
+	args := m.Called(id, userID, role, invitationID)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) AcceptInvitation(userID uuid.UUID, token string) (*models.Organization, error) {
+	args := m.Called(userID, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Organization), args.Error(1)
+}
+
+func setupOrganizationTestRouter(userID uuid.UUID) (*gin.Engine, *MockOrganizationService) {
+	gin.SetMode(gin.TestMode)
+
+	mockOrgService := &MockOrganizationService{}
+	orgHandler := handlers.NewOrganizationHandler(mockOrgService)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("user_role", models.RoleCustomer)
+		c.Next()
+	})
+
+	orgs := router.Group("/api/v1/organizations")
+	{
+		orgs.POST("", orgHandler.CreateOrganization)
+		orgs.POST("/invitations/accept", orgHandler.AcceptInvitation)
+		orgs.GET("/:id", orgHandler.GetOrganization)
+		orgs.PUT("/:id/members/:userId", orgHandler.UpdateMemberRole)
+		orgs.DELETE("/:id/members/:userId", orgHandler.RemoveMember)
+		orgs.POST("/:id/invitations", orgHandler.CreateInvitation)
+	}
+
+	return router, mockOrgService
+}
+
+func TestOrgRoles(t *testing.T) {
+	assert.True(t, models.OrgRoleAtLeast(models.OrgRoleOwner, models.OrgRoleAdmin))
+	assert.True(t, models.OrgRoleAtLeast(models.OrgRoleMember, models.OrgRoleMember))
+	assert.True(t, models.OrgRoleAtLeast(models.OrgRoleMember, models.OrgRoleViewer))
+	assert.False(t, models.OrgRoleAtLeast(models.OrgRoleViewer, models.OrgRoleMember))
+	assert.False(t, models.OrgRoleAtLeast(models.OrgRoleAdmin, models.OrgRoleOwner))
+	assert.False(t, models.OrgRoleAtLeast("unknown", models.OrgRoleViewer))
+	assert.False(t, models.IsValidOrgRole(models.RoleCustomer))
+}
+
+func TestOrganizationHandlers(t *testing.T) {
+	userID := uuid.New()
+	orgID := uuid.New()
+	memberID := uuid.New()
+	router, mockService := setupOrganizationTestRouter(userID)
+
+	send := func(method, path string, body interface{}) int {
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Create requires verified email", func(t *testing.T) {
+		mockService.On("CreateOrganization", userID, mock.AnythingOfType("*models.CreateOrganizationRequest")).
+			Return(nil, models.ErrEmailNotVerified).Once()
+
+		code := send("POST", "/api/v1/organizations", models.CreateOrganizationRequest{Name: "Acme"})
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("Non-members get not found", func(t *testing.T) {
+		mockService.On("GetOrganization", orgID, userID, models.RoleCustomer).
+			Return(nil, models.ErrOrganizationNotFound).Once()
+
+		code := send("GET", "/api/v1/organizations/"+orgID.String(), nil)
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("Invalid member role is rejected", func(t *testing.T) {
+		code := send("PUT", "/api/v1/organizations/"+orgID.String()+"/members/"+memberID.String(), gin.H{"role": "superuser"})
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Admins can't promote to owner", func(t *testing.T) {
+		mockService.On("UpdateMemberRole", orgID, userID, models.RoleCustomer, memberID, mock.AnythingOfType("*models.UpdateMemberRoleRequest")).
+			Return(models.ErrOrgPermissionDenied).Once()
+
+		code := send("PUT", "/api/v1/organizations/"+orgID.String()+"/members/"+memberID.String(), models.UpdateMemberRoleRequest{Role: models.OrgRoleOwner})
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("Last owner can't leave", func(t *testing.T) {
+		mockService.On("RemoveMember", orgID, userID, models.RoleCustomer, userID).
+			Return(models.ErrLastOwner).Once()
+
+		code := send("DELETE", "/api/v1/organizations/"+orgID.String()+"/members/"+userID.String(), nil)
+		assert.Equal(t, http.StatusConflict, code)
+	})
+
+	t.Run("Invite member", func(t *testing.T) {
+		mockService.On("CreateInvitation", orgID, userID, models.RoleCustomer, mock.AnythingOfType("*models.CreateInvitationRequest")).
+			Return(&models.OrganizationInvitation{ID: uuid.New(), OrganizationID: orgID, Email: "new@example.com", Role: models.OrgRoleMember}, nil).Once()
+
+		code := send("POST", "/api/v1/organizations/"+orgID.String()+"/invitations", models.CreateInvitationRequest{Email: "new@example.com", Role: models.OrgRoleMember})
+		assert.Equal(t, http.StatusCreated, code)
+	})
+
+	t.Run("Accept invitation", func(t *testing.T) {
+		mockService.On("AcceptInvitation", userID, "bad-token").Return(nil, models.ErrInvalidInvitation).Once()
+		mockService.On("AcceptInvitation", userID, "good-token").
+			Return(&models.Organization{ID: orgID, Name: "Acme", Role: models.OrgRoleMember}, nil).Once()
+
+		assert.Equal(t, http.StatusBadRequest, send("POST", "/api/v1/organizations/invitations/accept", models.AcceptInvitationRequest{Token: "bad-token"}))
+		assert.Equal(t, http.StatusOK, send("POST", "/api/v1/organizations/invitations/accept", models.AcceptInvitationRequest{Token: "good-token"}))
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+// memoryOrganizations is an OrganizationStore for member changes only. Like
+// the repository it refuses to demote or remove the last owner.
+type memoryOrganizations struct {
+	interfaces.OrganizationStore
+	org     *models.Organization
+	members map[uuid.UUID]string
+}
+
+func (m *memoryOrganizations) GetOrganizationByID(id uuid.UUID) (*models.Organization, error) {
+	if id != m.org.ID {
+		return nil, models.ErrOrganizationNotFound
+	}
+	org := *m.org
+	return &org, nil
+}
+
+func (m *memoryOrganizations) GetMemberRole(orgID, userID uuid.UUID) (string, error) {
+	role, ok := m.members[userID]
+	if !ok {
+		return "", models.ErrMemberNotFound
+	}
+	return role, nil
+}
+
+func (m *memoryOrganizations) GetMembers(orgID uuid.UUID) ([]models.OrganizationMember, error) {
+	members := []models.OrganizationMember{}
+	for userID, role := range m.members {
+		members = append(members, models.OrganizationMember{OrganizationID: orgID, UserID: userID, Role: role})
+	}
+	return members, nil
+}
+
+func (m *memoryOrganizations) UpdateMemberRole(orgID, userID uuid.UUID, role string) error {
+	if m.members[userID] == models.OrgRoleOwner && role != models.OrgRoleOwner && m.owners() <= 1 {
+		return models.ErrLastOwner
+	}
+	m.members[userID] = role
+	return nil
+}
+
+func (m *memoryOrganizations) RemoveMember(orgID, userID uuid.UUID) error {
+	if m.members[userID] == models.OrgRoleOwner && m.owners() <= 1 {
+		return models.ErrLastOwner
+	}
+	delete(m.members, userID)
+	return nil
+}
+
+func (m *memoryOrganizations) owners() int {
+	owners := 0
+	for _, role := range m.members {
+		if role == models.OrgRoleOwner {
+			owners++
+		}
+	}
+	return owners
+}
+
+func TestOrganizationKeepsAnOwner(t *testing.T) {
+	ownerID, otherID := uuid.New(), uuid.New()
+
+	setup := func() (*service.OrganizationService, *memoryOrganizations) {
+		store := &memoryOrganizations{
+			org:     &models.Organization{ID: uuid.New(), Name: "Acme"},
+			members: map[uuid.UUID]string{ownerID: models.OrgRoleOwner, otherID: models.OrgRoleAdmin},
+		}
+		return service.NewOrganizationService(&config.Config{}, store, nil, nil, nil, nil), store
+	}
+
+	t.Run("Last owner can't demote themselves", func(t *testing.T) {
+		orgService, store := setup()
+		err := orgService.UpdateMemberRole(store.org.ID, ownerID, models.RoleCustomer, ownerID, &models.UpdateMemberRoleRequest{Role: models.OrgRoleAdmin})
+		assert.ErrorIs(t, err, models.ErrLastOwner)
+		assert.Equal(t, models.OrgRoleOwner, store.members[ownerID])
+	})
+
+	t.Run("Last owner can't leave", func(t *testing.T) {
+		orgService, store := setup()
+		err := orgService.RemoveMember(store.org.ID, ownerID, models.RoleCustomer, ownerID)
+		assert.ErrorIs(t, err, models.ErrLastOwner)
+		assert.Contains(t, store.members, ownerID)
+	})
+
+	t.Run("Site admins can't remove the last owner either", func(t *testing.T) {
+		orgService, store := setup()
+		err := orgService.RemoveMember(store.org.ID, uuid.New(), models.RoleAdmin, ownerID)
+		assert.ErrorIs(t, err, models.ErrLastOwner)
+	})
+
+	t.Run("An owner can step down once there is another owner", func(t *testing.T) {
+		orgService, store := setup()
+		assert.NoError(t, orgService.UpdateMemberRole(store.org.ID, ownerID, models.RoleCustomer, otherID, &models.UpdateMemberRoleRequest{Role: models.OrgRoleOwner}))
+		assert.NoError(t, orgService.UpdateMemberRole(store.org.ID, ownerID, models.RoleCustomer, ownerID, &models.UpdateMemberRoleRequest{Role: models.OrgRoleMember}))
+		assert.NoError(t, orgService.RemoveMember(store.org.ID, ownerID, models.RoleCustomer, ownerID))
+		assert.Equal(t, map[uuid.UUID]string{otherID: models.OrgRoleOwner}, store.members)
+	})
+}
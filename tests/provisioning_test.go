@@ -0,0 +1,386 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go-auth-api/internal/chirpstack"
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvisioning is a ChirpStack client that only knows tenants,
+// applications and device profiles, with errors injected per call
+type fakeProvisioning struct {
+	interfaces.ChirpStackClient
+
+	resources map[string]bool
+	// resource IDs by kind, parent and name
+	names     map[string]string
+	created   map[string]int
+	createErr map[string]error
+	deleteErr map[string]error
+	// kinds whose next create succeeds but reports a timeout
+	lostReply map[string]bool
+	profiles  []models.ChirpStackDeviceProfile
+	// HTTP integrations by application ID
+	integrations map[string]models.ChirpStackHTTPIntegration
+}
+
+func newFakeProvisioning() *fakeProvisioning {
+	return &fakeProvisioning{
+		resources: map[string]bool{},
+		names:     map[string]string{},
+		created:   map[string]int{},
+		createErr: map[string]error{},
+		deleteErr: map[string]error{},
+		lostReply: map[string]bool{},
+
+		integrations: map[string]models.ChirpStackHTTPIntegration{},
+	}
+}
+
+func (f *fakeProvisioning) create(kind, parent, name string) (string, error) {
+	if err := f.createErr[kind]; err != nil {
+		return "", err
+	}
+	f.created[kind]++
+	id := fmt.Sprintf("%s-%d", kind, f.created[kind])
+	f.resources[id] = true
+	f.names[kind+"/"+parent+"/"+name] = id
+
+	if f.lostReply[kind] {
+		delete(f.lostReply, kind)
+		return "", fmt.Errorf("%w: context deadline exceeded", chirpstack.ErrUnavailable)
+	}
+	return id, nil
+}
+
+func (f *fakeProvisioning) find(kind, parent, name string) (string, error) {
+	id, ok := f.names[kind+"/"+parent+"/"+name]
+	if !ok || !f.resources[id] {
+		return "", chirpstack.ErrNotFound
+	}
+	return id, nil
+}
+
+func (f *fakeProvisioning) remove(kind, id string) error {
+	if err := f.deleteErr[kind]; err != nil {
+		return err
+	}
+	if !f.resources[id] {
+		return &chirpstack.APIError{StatusCode: 404}
+	}
+	delete(f.resources, id)
+	return nil
+}
+
+func (f *fakeProvisioning) CreateTenant(tenant models.ChirpStackTenant) (string, error) {
+	return f.create("tenant", "", tenant.Name)
+}
+
+func (f *fakeProvisioning) FindTenant(name string) (string, error) {
+	return f.find("tenant", "", name)
+}
+
+func (f *fakeProvisioning) DeleteTenant(id string) error {
+	return f.remove("tenant", id)
+}
+
+func (f *fakeProvisioning) CreateApplication(app models.ChirpStackApplication) (string, error) {
+	return f.create("application", app.TenantID, app.Name)
+}
+
+func (f *fakeProvisioning) FindApplication(tenantID, name string) (string, error) {
+	return f.find("application", tenantID, name)
+}
+
+func (f *fakeProvisioning) DeleteApplication(id string) error {
+	return f.remove("application", id)
+}
+
+func (f *fakeProvisioning) CreateDeviceProfile(profile models.ChirpStackDeviceProfile) (string, error) {
+	f.profiles = append(f.profiles, profile)
+	return f.create("profile", profile.TenantID, profile.Name)
+}
+
+func (f *fakeProvisioning) FindDeviceProfile(tenantID, name string) (string, error) {
+	return f.find("profile", tenantID, name)
+}
+
+func (f *fakeProvisioning) DeleteDeviceProfile(id string) error {
+	return f.remove("profile", id)
+}
+
+func (f *fakeProvisioning) CreateHTTPIntegration(integration models.ChirpStackHTTPIntegration) error {
+	if err := f.createErr["integration"]; err != nil {
+		return err
+	}
+	if _, ok := f.integrations[integration.ApplicationID]; ok {
+		return &chirpstack.APIError{StatusCode: 409}
+	}
+	f.created["integration"]++
+	f.integrations[integration.ApplicationID] = integration
+	return nil
+}
+
+func (f *fakeProvisioning) UpdateHTTPIntegration(integration models.ChirpStackHTTPIntegration) error {
+	if _, ok := f.integrations[integration.ApplicationID]; !ok {
+		return &chirpstack.APIError{StatusCode: 404}
+	}
+	f.integrations[integration.ApplicationID] = integration
+	return nil
+}
+
+// memoryProvisioningStore keeps one organization in memory
+type memoryProvisioningStore struct {
+	org       models.Organization
+	updateErr error
+}
+
+func (m *memoryProvisioningStore) GetOrganizationByID(id uuid.UUID) (*models.Organization, error) {
+	if id != m.org.ID {
+		return nil, models.ErrOrganizationNotFound
+	}
+	org := m.org
+	return &org, nil
+}
+
+func (m *memoryProvisioningStore) UpdateOrganizationProvisioning(org *models.Organization) error {
+	if m.updateErr != nil {
+		return m.updateErr
+	}
+	m.org = *org
+	return nil
+}
+
+func (m *memoryProvisioningStore) UpdateOrganizationIntegration(id uuid.UUID, url, encoding, secret string) error {
+	if m.updateErr != nil {
+		return m.updateErr
+	}
+	m.org.IntegrationURL = &url
+	m.org.IntegrationEncoding = &encoding
+	m.org.IntegrationSecret = &secret
+	return nil
+}
+
+func newProvisioningSaga() (*service.ProvisioningSaga, *fakeProvisioning, *memoryProvisioningStore) {
+	client := newFakeProvisioning()
+	store := &memoryProvisioningStore{org: models.Organization{
+		ID:                 uuid.New(),
+		Name:               "Acme",
+		ProvisioningStatus: models.ProvisioningPending,
+	}}
+	return service.NewProvisioningSaga(client, store, nil), client, store
+}
+
+func TestProvisioningSaga(t *testing.T) {
+	t.Run("Provisions all resources", func(t *testing.T) {
+		saga, client, store := newProvisioningSaga()
+
+		org, err := saga.Provision(store.org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ProvisioningProvisioned, org.ProvisioningStatus)
+		assert.Equal(t, "tenant-1", *store.org.TenantID)
+		assert.Equal(t, "application-1", *store.org.ApplicationID)
+		assert.Equal(t, "profile-1", *store.org.DeviceProfileID)
+		assert.Equal(t, "profile-2", *store.org.OTAADeviceProfileID)
+		assert.Len(t, client.resources, 4)
+
+		// Provisioned organizations are left alone
+		_, err = saga.Provision(store.org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, client.created["tenant"])
+	})
+
+	t.Run("Device profiles get the default codec", func(t *testing.T) {
+		_, client, store := newProvisioningSaga()
+		codecs := newMemoryCodecScripts("decoder v2")
+		saga := service.NewProvisioningSaga(client, store, codecs)
+
+		_, err := saga.Provision(store.org.ID)
+		require.NoError(t, err)
+		require.Len(t, client.profiles, 2)
+		for _, profile := range client.profiles {
+			assert.Equal(t, "JS", profile.PayloadCodecRuntime)
+			assert.Equal(t, "decoder v2", profile.PayloadCodecScript)
+		}
+	})
+
+	t.Run("Rejected step rolls back earlier steps", func(t *testing.T) {
+		saga, client, store := newProvisioningSaga()
+		client.createErr["profile"] = &chirpstack.APIError{StatusCode: 400, Message: "invalid codec"}
+
+		org, err := saga.Provision(store.org.ID)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, chirpstack.ErrBadRequest))
+		assert.Equal(t, models.ProvisioningFailed, org.ProvisioningStatus)
+		assert.Nil(t, store.org.TenantID)
+		assert.Nil(t, store.org.ApplicationID)
+		assert.Contains(t, *store.org.ProvisioningError, "invalid codec")
+		assert.Empty(t, client.resources)
+	})
+
+	t.Run("Outage keeps progress and retry resumes", func(t *testing.T) {
+		saga, client, store := newProvisioningSaga()
+		client.createErr["application"] = fmt.Errorf("%w: connection refused", chirpstack.ErrUnavailable)
+
+		_, err := saga.Provision(store.org.ID)
+		require.Error(t, err)
+		assert.Equal(t, models.ProvisioningPending, store.org.ProvisioningStatus)
+		assert.Equal(t, "tenant-1", *store.org.TenantID)
+		assert.Nil(t, store.org.ApplicationID)
+
+		delete(client.createErr, "application")
+		org, err := saga.Provision(store.org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ProvisioningProvisioned, org.ProvisioningStatus)
+		assert.Nil(t, org.ProvisioningError)
+		assert.Equal(t, 1, client.created["tenant"])
+		assert.Equal(t, "tenant-1", *org.TenantID)
+		assert.Len(t, client.resources, 4)
+	})
+
+	t.Run("Create that timed out is adopted on retry", func(t *testing.T) {
+		for _, kind := range []string{"tenant", "application", "profile"} {
+			saga, client, store := newProvisioningSaga()
+			client.lostReply[kind] = true
+
+			_, err := saga.Provision(store.org.ID)
+			require.Error(t, err, kind)
+			assert.True(t, errors.Is(err, chirpstack.ErrUnavailable), kind)
+			assert.Equal(t, models.ProvisioningPending, store.org.ProvisioningStatus, kind)
+
+			org, err := saga.Provision(store.org.ID)
+			require.NoError(t, err, kind)
+			assert.Equal(t, models.ProvisioningProvisioned, org.ProvisioningStatus, kind)
+			assert.Equal(t, 1, client.created["tenant"], kind)
+			assert.Equal(t, 1, client.created["application"], kind)
+			assert.Equal(t, 2, client.created["profile"], kind)
+			assert.Len(t, client.resources, 4, kind)
+			assert.Equal(t, "tenant-1", *org.TenantID, kind)
+			assert.Equal(t, "application-1", *org.ApplicationID, kind)
+			assert.Equal(t, "profile-1", *org.DeviceProfileID, kind)
+			assert.Equal(t, "profile-2", *org.OTAADeviceProfileID, kind)
+		}
+	})
+
+	t.Run("Tenant name includes the organization ID", func(t *testing.T) {
+		saga, client, store := newProvisioningSaga()
+
+		_, err := saga.Provision(store.org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-1", client.names["tenant//Acme ("+store.org.ID.String()+")"])
+	})
+
+	t.Run("Failed rollback stays recorded", func(t *testing.T) {
+		saga, client, store := newProvisioningSaga()
+		client.createErr["profile"] = &chirpstack.APIError{StatusCode: 400}
+		client.deleteErr["application"] = fmt.Errorf("%w: timeout", chirpstack.ErrUnavailable)
+
+		_, err := saga.Provision(store.org.ID)
+		require.Error(t, err)
+		assert.Equal(t, models.ProvisioningFailed, store.org.ProvisioningStatus)
+		// The tenant is kept as long as its application is
+		assert.Equal(t, "tenant-1", *store.org.TenantID)
+		assert.Equal(t, "application-1", *store.org.ApplicationID)
+
+		// The next attempt resumes with the recorded resources
+		delete(client.createErr, "profile")
+		delete(client.deleteErr, "application")
+		_, err = saga.Provision(store.org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, client.created["tenant"])
+		assert.Equal(t, 1, client.created["application"])
+	})
+
+	t.Run("Unrecorded resource is deleted", func(t *testing.T) {
+		saga, client, store := newProvisioningSaga()
+		store.updateErr = errors.New("database is down")
+
+		_, err := saga.Provision(store.org.ID)
+		require.Error(t, err)
+		assert.Equal(t, 1, client.created["tenant"])
+		assert.Empty(t, client.resources)
+	})
+}
+
+func TestProvisioningSagaIntegration(t *testing.T) {
+	const endpoint = "https://api.example.com/api/v1/integrations/chirpstack"
+
+	provisioned := func(t *testing.T) (*service.ProvisioningSaga, *fakeProvisioning, *memoryProvisioningStore, *models.Organization) {
+		saga, client, store := newProvisioningSaga()
+		org, err := saga.Provision(store.org.ID)
+		require.NoError(t, err)
+		return saga, client, store, org
+	}
+
+	t.Run("Registers the integration with a new secret", func(t *testing.T) {
+		saga, client, store, org := provisioned(t)
+
+		require.NoError(t, saga.RegisterIntegration(org, endpoint, chirpstack.EncodingJSON))
+		integration := client.integrations["application-1"]
+		assert.Equal(t, endpoint, integration.EventEndpointURL)
+		require.NotNil(t, store.org.IntegrationSecret)
+		assert.Len(t, *store.org.IntegrationSecret, 43)
+		assert.Equal(t, "Bearer "+*store.org.IntegrationSecret, integration.Headers["Authorization"])
+		assert.Equal(t, endpoint, *store.org.IntegrationURL)
+
+		// Registered integrations are left alone
+		require.NoError(t, saga.RegisterIntegration(org, endpoint, chirpstack.EncodingJSON))
+		assert.Equal(t, 1, client.created["integration"])
+	})
+
+	t.Run("Moving the endpoint or encoding keeps the secret", func(t *testing.T) {
+		saga, client, store, org := provisioned(t)
+		require.NoError(t, saga.RegisterIntegration(org, endpoint, chirpstack.EncodingJSON))
+		secret := *store.org.IntegrationSecret
+
+		require.NoError(t, saga.RegisterIntegration(org, "https://new.example.com/hook", chirpstack.EncodingJSON))
+		assert.Equal(t, "https://new.example.com/hook", client.integrations["application-1"].EventEndpointURL)
+		assert.Equal(t, secret, *store.org.IntegrationSecret)
+		assert.Equal(t, "https://new.example.com/hook", *store.org.IntegrationURL)
+
+		// So does switching the encoding
+		require.NoError(t, saga.RegisterIntegration(org, "https://new.example.com/hook", chirpstack.EncodingProtobuf))
+		assert.Equal(t, "PROTOBUF", client.integrations["application-1"].Encoding)
+		assert.Equal(t, secret, *store.org.IntegrationSecret)
+		assert.Equal(t, chirpstack.EncodingProtobuf, *store.org.IntegrationEncoding)
+	})
+
+	t.Run("Integration created elsewhere is replaced", func(t *testing.T) {
+		saga, client, store, org := provisioned(t)
+		client.integrations["application-1"] = models.ChirpStackHTTPIntegration{ApplicationID: "application-1", EventEndpointURL: "https://old.example.com"}
+
+		require.NoError(t, saga.RegisterIntegration(org, endpoint, chirpstack.EncodingJSON))
+		assert.Equal(t, endpoint, client.integrations["application-1"].EventEndpointURL)
+		assert.NotNil(t, store.org.IntegrationSecret)
+	})
+
+	t.Run("Failure records nothing", func(t *testing.T) {
+		saga, client, store, org := provisioned(t)
+		client.createErr["integration"] = fmt.Errorf("%w: timeout", chirpstack.ErrUnavailable)
+
+		err := saga.RegisterIntegration(org, endpoint, chirpstack.EncodingJSON)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, chirpstack.ErrUnavailable))
+		assert.Nil(t, store.org.IntegrationSecret)
+		assert.Nil(t, store.org.IntegrationURL)
+	})
+
+	t.Run("Nothing is registered without an application or endpoint", func(t *testing.T) {
+		saga, client, store := newProvisioningSaga()
+		require.NoError(t, saga.RegisterIntegration(&store.org, endpoint, chirpstack.EncodingJSON))
+
+		org, err := saga.Provision(store.org.ID)
+		require.NoError(t, err)
+		require.NoError(t, saga.RegisterIntegration(org, "", chirpstack.EncodingJSON))
+		assert.Empty(t, client.integrations)
+	})
+}
@@ -0,0 +1,285 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go-auth-api/internal/interfaces"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareDevices(t *testing.T) {
+	orgID := uuid.New()
+	device := func(devEUI string, created, activated bool) models.Device {
+		return models.Device{
+			ID:                        uuid.New(),
+			OrganizationID:            orgID,
+			DevEUI:                    devEUI,
+			ChirpStackDeviceCreated:   created,
+			ChirpStackDeviceActivated: activated,
+		}
+	}
+	remote := func(devEUIs ...string) []models.ChirpStackDeviceListItem {
+		items := []models.ChirpStackDeviceListItem{}
+		for _, eui := range devEUIs {
+			items = append(items, models.ChirpStackDeviceListItem{DevEUI: eui})
+		}
+		return items
+	}
+
+	tests := []struct {
+		name      string
+		local     []models.Device
+		remote    []models.ChirpStackDeviceListItem
+		activated map[string]bool
+		want      []string
+	}{
+		{
+			name:      "in sync, DevEUI case ignored",
+			local:     []models.Device{device("C5EABC521E8304EE", true, true)},
+			remote:    remote("c5eabc521e8304ee"),
+			activated: map[string]bool{"C5EABC521E8304EE": true},
+			want:      []string{},
+		},
+		{
+			name:   "deleted in ChirpStack",
+			local:  []models.Device{device("C5EABC521E8304EE", true, true)},
+			remote: remote(),
+			want:   []string{models.DriftMissing},
+		},
+		{
+			name:   "not provisioned yet is no drift",
+			local:  []models.Device{device("C5EABC521E8304EE", false, false)},
+			remote: remote(),
+			want:   []string{},
+		},
+		{
+			name:   "created but never flagged",
+			local:  []models.Device{device("C5EABC521E8304EE", false, false)},
+			remote: remote("c5eabc521e8304ee"),
+			want:   []string{models.DriftUnflagged},
+		},
+		{
+			name:      "session lost",
+			local:     []models.Device{device("C5EABC521E8304EE", true, true)},
+			remote:    remote("c5eabc521e8304ee"),
+			activated: map[string]bool{"C5EABC521E8304EE": false},
+			want:      []string{models.DriftNotActivated},
+		},
+		{
+			name:   "ChirpStack device without a row",
+			local:  []models.Device{},
+			remote: remote("0000000000000001"),
+			want:   []string{models.DriftOrphan},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift := service.CompareDevices(orgID, tt.local, tt.remote, tt.activated)
+
+			kinds := []string{}
+			for _, d := range drift {
+				kinds = append(kinds, d.Kind)
+				assert.Equal(t, orgID, d.OrganizationID)
+				assert.Equal(t, d.Kind != models.DriftOrphan, d.DeviceID != nil)
+			}
+			assert.Equal(t, tt.want, kinds)
+		})
+	}
+}
+
+// fakeReconcileChirpStack is a ChirpStack application with ABP devices
+type fakeReconcileChirpStack struct {
+	interfaces.ChirpStackClient
+
+	devices  []models.ChirpStackDeviceListItem
+	sessions map[string]bool
+	deleted  []string
+}
+
+func (f *fakeReconcileChirpStack) ListDevices(applicationID string, limit, offset int) ([]models.ChirpStackDeviceListItem, int, error) {
+	if offset >= len(f.devices) {
+		return nil, len(f.devices), nil
+	}
+	return f.devices[offset:], len(f.devices), nil
+}
+
+func (f *fakeReconcileChirpStack) GetDeviceActivation(devEUI string) (*models.ChirpStackDeviceActivation, error) {
+	if !f.sessions[devEUI] {
+		return nil, nil
+	}
+	return &models.ChirpStackDeviceActivation{DevAddr: "01020304"}, nil
+}
+
+func (f *fakeReconcileChirpStack) DeleteDevice(devEUI string) error {
+	f.deleted = append(f.deleted, devEUI)
+	return nil
+}
+
+// memoryReconcileStore keeps organizations, devices and queued jobs in memory
+type memoryReconcileStore struct {
+	orgs      []models.Organization
+	devices   []models.Device
+	lookupErr error
+	jobs      []models.Job
+}
+
+func (m *memoryReconcileStore) GetOrganizationByID(id uuid.UUID) (*models.Organization, error) {
+	for i := range m.orgs {
+		if m.orgs[i].ID == id {
+			return &m.orgs[i], nil
+		}
+	}
+	return nil, models.ErrOrganizationNotFound
+}
+
+func (m *memoryReconcileStore) GetProvisionedOrganizations() ([]models.Organization, error) {
+	return m.orgs, nil
+}
+
+func (m *memoryReconcileStore) GetOrganizationDevices(orgID uuid.UUID) ([]models.Device, error) {
+	devices := []models.Device{}
+	for _, device := range m.devices {
+		if device.OrganizationID == orgID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (m *memoryReconcileStore) GetDeviceByDevEUI(devEUI string) (*models.Device, error) {
+	if m.lookupErr != nil {
+		return nil, m.lookupErr
+	}
+	for i := range m.devices {
+		if strings.EqualFold(m.devices[i].DevEUI, devEUI) {
+			return &m.devices[i], nil
+		}
+	}
+	return nil, models.ErrDeviceNotFound
+}
+
+func (m *memoryReconcileStore) GetAllowedDeviceByDevEUI(devEUI string) (*models.AllowedDevice, error) {
+	return nil, errors.New("allowed device not found")
+}
+
+func (m *memoryReconcileStore) UpdateDeviceChirpStackStatus(id uuid.UUID, created, activated bool) error {
+	for i := range m.devices {
+		if m.devices[i].ID == id {
+			m.devices[i].ChirpStackDeviceCreated = created
+			m.devices[i].ChirpStackDeviceActivated = activated
+			return nil
+		}
+	}
+	return models.ErrDeviceNotFound
+}
+
+func (m *memoryReconcileStore) Enqueue(job *models.Job) error {
+	m.jobs = append(m.jobs, *job)
+	return nil
+}
+
+func TestReconcilerRepair(t *testing.T) {
+	applicationID := "application-1"
+
+	setup := func() (*service.Reconciler, *memoryReconcileStore, *fakeReconcileChirpStack, uuid.UUID) {
+		org := models.Organization{ID: uuid.New(), ProvisioningStatus: models.ProvisioningProvisioned, ApplicationID: &applicationID}
+		store := &memoryReconcileStore{orgs: []models.Organization{org}}
+		client := &fakeReconcileChirpStack{sessions: map[string]bool{}}
+		return service.NewReconciler(store, store, store, client), store, client, org.ID
+	}
+	addDevice := func(store *memoryReconcileStore, orgID uuid.UUID, devEUI string, created, activated bool) uuid.UUID {
+		id := uuid.New()
+		store.devices = append(store.devices, models.Device{
+			ID: id, OrganizationID: orgID, DevEUI: devEUI,
+			ChirpStackDeviceCreated: created, ChirpStackDeviceActivated: activated,
+		})
+		return id
+	}
+
+	t.Run("Missing and deactivated devices are reprovisioned", func(t *testing.T) {
+		reconciler, store, client, orgID := setup()
+		missing := addDevice(store, orgID, "0000000000000001", true, true)
+		lost := addDevice(store, orgID, "0000000000000002", true, true)
+		client.devices = []models.ChirpStackDeviceListItem{{DevEUI: "0000000000000002"}}
+
+		report, err := reconciler.Reconcile(&models.ReconcileRequest{Repair: true})
+		require.NoError(t, err)
+		require.Len(t, report.Drift, 2)
+		for _, d := range report.Drift {
+			assert.True(t, d.Repaired, d.Kind)
+		}
+
+		assert.False(t, store.devices[0].ChirpStackDeviceCreated)
+		assert.True(t, store.devices[1].ChirpStackDeviceCreated)
+		assert.False(t, store.devices[1].ChirpStackDeviceActivated)
+		require.Len(t, store.jobs, 2)
+		assert.Equal(t, models.JobProvisionDevice, store.jobs[0].Type)
+		assert.Equal(t, missing, *store.jobs[0].DeviceID)
+		assert.Equal(t, lost, *store.jobs[1].DeviceID)
+		assert.Equal(t, orgID, *store.jobs[1].OrganizationID)
+	})
+
+	t.Run("Activated device is only flagged", func(t *testing.T) {
+		reconciler, store, client, orgID := setup()
+		addDevice(store, orgID, "0000000000000001", false, false)
+		client.devices = []models.ChirpStackDeviceListItem{{DevEUI: "0000000000000001"}}
+		client.sessions["0000000000000001"] = true
+
+		report, err := reconciler.Reconcile(&models.ReconcileRequest{Repair: true})
+		require.NoError(t, err)
+		require.Len(t, report.Drift, 1)
+		assert.Equal(t, models.DriftUnflagged, report.Drift[0].Kind)
+		assert.True(t, store.devices[0].ChirpStackDeviceCreated)
+		assert.True(t, store.devices[0].ChirpStackDeviceActivated)
+		assert.Empty(t, store.jobs)
+	})
+
+	t.Run("Orphans are deleted, other organizations' devices are kept", func(t *testing.T) {
+		reconciler, store, client, _ := setup()
+		addDevice(store, uuid.New(), "0000000000000002", true, true)
+		client.devices = []models.ChirpStackDeviceListItem{{DevEUI: "0000000000000001"}, {DevEUI: "0000000000000002"}}
+
+		report, err := reconciler.Reconcile(&models.ReconcileRequest{Repair: true})
+		require.NoError(t, err)
+		require.Len(t, report.Drift, 2)
+		assert.Equal(t, models.DriftOrphan, report.Drift[0].Kind)
+		assert.True(t, report.Drift[0].Repaired)
+		assert.Equal(t, models.DriftMisplaced, report.Drift[1].Kind)
+		assert.False(t, report.Drift[1].Repaired)
+		assert.Equal(t, []string{"0000000000000001"}, client.deleted)
+	})
+
+	t.Run("Orphan is kept when the owner lookup fails", func(t *testing.T) {
+		reconciler, store, client, _ := setup()
+		store.lookupErr = errors.New("connection reset")
+		client.devices = []models.ChirpStackDeviceListItem{{DevEUI: "0000000000000001"}}
+
+		report, err := reconciler.Reconcile(&models.ReconcileRequest{Repair: true})
+		require.NoError(t, err)
+		require.Len(t, report.Drift, 1)
+		assert.False(t, report.Drift[0].Repaired)
+		assert.Contains(t, report.Drift[0].Error, "connection reset")
+		assert.Empty(t, client.deleted)
+	})
+
+	t.Run("Nothing changes without repair", func(t *testing.T) {
+		reconciler, store, client, orgID := setup()
+		addDevice(store, orgID, "0000000000000001", true, true)
+		client.devices = []models.ChirpStackDeviceListItem{{DevEUI: "0000000000000002"}}
+
+		report, err := reconciler.Reconcile(&models.ReconcileRequest{})
+		require.NoError(t, err)
+		assert.Len(t, report.Drift, 2)
+		assert.True(t, store.devices[0].ChirpStackDeviceCreated)
+		assert.Empty(t, store.jobs)
+		assert.Empty(t, client.deleted)
+	})
+}
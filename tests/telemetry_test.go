@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/handlers"
+	"go-auth-api/internal/models"
+	"go-auth-api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryDeviceAccess lets users see the devices listed for them
+type memoryDeviceAccess map[uuid.UUID]uuid.UUID
+
+func (m memoryDeviceAccess) GetDeviceByID(id, userID uuid.UUID, role string) (*models.Device, error) {
+	if owner, ok := m[id]; ok && (owner == userID || role == models.RoleAdmin) {
+		return &models.Device{ID: id}, nil
+	}
+	return nil, models.ErrDeviceNotFound
+}
+
+// memoryTelemetryStore records the last query and returns points as given
+type memoryTelemetryStore struct {
+	points   []models.TelemetryPoint
+	from, to time.Time
+	interval time.Duration
+	fields   []string
+	queries  int
+}
+
+func (m *memoryTelemetryStore) GetTelemetry(_ uuid.UUID, from, to time.Time, fields []string, limit int) ([]models.TelemetryPoint, error) {
+	m.from, m.to, m.interval, m.fields = from, to, 0, fields
+	m.queries++
+	if len(m.points) > limit {
+		return m.points[:limit], nil
+	}
+	return m.points, nil
+}
+
+func (m *memoryTelemetryStore) GetTelemetryBuckets(_ uuid.UUID, from, to time.Time, interval time.Duration, fields []string) ([]models.TelemetryPoint, error) {
+	m.from, m.to, m.interval, m.fields = from, to, interval, fields
+	m.queries++
+	return m.points, nil
+}
+
+func (m *memoryTelemetryStore) EnsureTelemetryPartitions(time.Time, time.Time) error {
+	return nil
+}
+
+func TestTelemetryValues(t *testing.T) {
+	t.Run("Measurements come from the decoded object", func(t *testing.T) {
+		event := &models.IntegrationEvent{Object: json.RawMessage(`{"Dimming": 80, "voltage": 230.14, "header_device": 1, "lat": 21.02}`)}
+		assert.Equal(t, map[string]float64{"Dimming": 80, "voltage": 230.14}, service.TelemetryValues(event))
+	})
+
+	t.Run("Frames the codec didn't decode are decoded here", func(t *testing.T) {
+		frame := []byte{0x01, 80, 1, 0x01, 0x02, 0x03, 0x04, 0xE6, 0x59, 0x2C, 0x01, 0x5F, 0x00, 0x10, 0x27, 0x32, 0x00}
+		values := service.TelemetryValues(&models.IntegrationEvent{Data: frame})
+		assert.Equal(t, map[string]float64{
+			"Dimming": 80, "Status_lamp": 1, "Energy": 673059.85, "voltage": 230.14,
+			"current": 3, "PF": 0.95, "Power": 100, "Tilt": 0.5,
+		}, values)
+	})
+
+	t.Run("Other frames have no measurements", func(t *testing.T) {
+		assert.Empty(t, service.TelemetryValues(&models.IntegrationEvent{Object: json.RawMessage(`{"header_device": 2, "lat": 21.02}`)}))
+		assert.Empty(t, service.TelemetryValues(&models.IntegrationEvent{Data: []byte{0x7f}}))
+		assert.Empty(t, service.TelemetryValues(&models.IntegrationEvent{}))
+	})
+
+	t.Run("Uplinks store their measurements", func(t *testing.T) {
+		router, store := newWebhookRouter()
+
+		require.Equal(t, http.StatusOK, postEvent(router, models.EventUp, "Bearer s3cret", uplinkEvent).Code)
+		require.Len(t, store.samples, 1)
+		assert.Equal(t, store.devices[0].ID, store.samples[0].DeviceID)
+		assert.Equal(t, time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC), store.samples[0].Time)
+		assert.Equal(t, map[string]float64{"Dimming": 80}, store.samples[0].Values)
+
+		// Other events don't
+		require.Equal(t, http.StatusOK, postEvent(router, models.EventStatus, "Bearer s3cret", uplinkEvent).Code)
+		assert.Len(t, store.samples, 1)
+	})
+}
+
+func TestTelemetryService(t *testing.T) {
+	owner, stranger, deviceID := uuid.New(), uuid.New(), uuid.New()
+	newService := func() (*service.TelemetryService, *memoryTelemetryStore) {
+		store := &memoryTelemetryStore{points: []models.TelemetryPoint{}}
+		return service.NewTelemetryService(memoryDeviceAccess{deviceID: owner}, store), store
+	}
+
+	t.Run("Samples of the last day are returned by default", func(t *testing.T) {
+		telemetry, store := newService()
+
+		response, err := telemetry.GetDeviceTelemetry(deviceID, owner, models.RoleCustomer, &models.TelemetryQuery{})
+		require.NoError(t, err)
+		assert.Equal(t, models.TelemetryFields, response.Fields)
+		assert.Equal(t, 24*time.Hour, response.To.Sub(response.From))
+		assert.WithinDuration(t, time.Now(), response.To, 5*time.Second)
+		assert.Empty(t, response.Interval)
+		assert.Equal(t, time.Duration(0), store.interval)
+	})
+
+	t.Run("Intervals aggregate the samples", func(t *testing.T) {
+		telemetry, store := newService()
+
+		response, err := telemetry.GetDeviceTelemetry(deviceID, owner, models.RoleCustomer, &models.TelemetryQuery{
+			From: "2026-10-16T00:00:00Z", To: "2026-10-16T12:00:00+07:00", Fields: "Power, voltage,Power", Interval: "15m",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "15m0s", response.Interval)
+		assert.Equal(t, []string{"Power", "voltage"}, store.fields)
+		assert.Equal(t, 15*time.Minute, store.interval)
+		assert.Equal(t, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), store.from)
+		assert.Equal(t, time.Date(2026, 10, 16, 5, 0, 0, 0, time.UTC), store.to)
+	})
+
+	t.Run("Customers only see their own devices", func(t *testing.T) {
+		telemetry, store := newService()
+
+		_, err := telemetry.GetDeviceTelemetry(deviceID, stranger, models.RoleCustomer, &models.TelemetryQuery{})
+		assert.ErrorIs(t, err, models.ErrDeviceNotFound)
+		_, err = telemetry.GetDeviceTelemetry(uuid.New(), owner, models.RoleCustomer, &models.TelemetryQuery{})
+		assert.ErrorIs(t, err, models.ErrDeviceNotFound)
+		assert.Zero(t, store.queries)
+
+		_, err = telemetry.GetDeviceTelemetry(deviceID, stranger, models.RoleAdmin, &models.TelemetryQuery{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid queries are rejected", func(t *testing.T) {
+		telemetry, store := newService()
+
+		for _, query := range []models.TelemetryQuery{
+			{Fields: "voltage,lat"},
+			{From: "yesterday"},
+			{To: "2026-10-16"},
+			{From: "2026-10-16T00:00:00Z", To: "2026-10-16T00:00:00Z"},
+			{Interval: "fast"},
+			{Interval: "500ms"},
+			// 86400 one second buckets in a day
+			{Interval: "1s"},
+		} {
+			_, err := telemetry.GetDeviceTelemetry(deviceID, owner, models.RoleCustomer, &query)
+			assert.ErrorIs(t, err, models.ErrInvalidTelemetryQuery, "%+v", query)
+		}
+		assert.Zero(t, store.queries)
+
+		store.points = make([]models.TelemetryPoint, service.MaxTelemetryPoints+1)
+		_, err := telemetry.GetDeviceTelemetry(deviceID, owner, models.RoleCustomer, &models.TelemetryQuery{})
+		assert.ErrorIs(t, err, models.ErrInvalidTelemetryQuery)
+	})
+
+	t.Run("Handler maps the errors", func(t *testing.T) {
+		telemetry, _ := newService()
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/devices/:id/telemetry", func(c *gin.Context) {
+			c.Set("user_id", owner)
+			c.Set("user_role", models.RoleCustomer)
+		}, handlers.NewTelemetryHandler(telemetry).GetDeviceTelemetry)
+
+		get := func(path string) int {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			return w.Code
+		}
+		assert.Equal(t, http.StatusOK, get("/devices/"+deviceID.String()+"/telemetry?fields=Dimming&interval=1h"))
+		assert.Equal(t, http.StatusBadRequest, get("/devices/"+deviceID.String()+"/telemetry?fields=gps"))
+		assert.Equal(t, http.StatusBadRequest, get("/devices/lamp-1/telemetry"))
+		assert.Equal(t, http.StatusNotFound, get("/devices/"+uuid.NewString()+"/telemetry"))
+	})
+}
@@ -0,0 +1,4 @@
+
+$53e82c3c-9a4b-4a1c-8b91-d3c5ee7d7e2a
+$52f14cd4-c6f1-4fbd-8f87-4025e1d49242Acme$17c82e96-be03-4f38-aef3-f83d48582d97"Lnode*$14855bf7-d10d-4aee-b618-ebfcb64dc7ad2RAK_ABP:Lamp 1Bc5eabc521e8304eeJ
+sitehanoiP"$4b4d0a5c-1d3e-4d3b-a0a9-cdfdc7c3e0e1(0
\ No newline at end of file
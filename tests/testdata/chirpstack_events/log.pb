@@ -0,0 +1,5 @@
+
+_
+$52f14cd4-c6f1-4fbd-8f87-4025e1d49242Acme$17c82e96-be03-4f38-aef3-f83d48582d97"Lnode*$14855bf7-d10d-4aee-b618-ebfcb64dc7ad2RAK_ABP:Lamp 1Bc5eabc521e8304eeJ
+sitehanoiP *Error: lnode: frame too short28
+deduplication_id$3ac7e3c4-4401-4b8d-9386-a5c902f9202d
\ No newline at end of file
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-auth-api/internal/auth"
+	"go-auth-api/internal/middleware"
+	"go-auth-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Secret from the RFC 6238 test vectors ("12345678901234567890" in base32)
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// Last six digits of the SHA1 vectors in RFC 6238 appendix B
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, v := range vectors {
+		code, err := auth.TOTPCode(rfcTOTPSecret, auth.TOTPStep(time.Unix(v.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, v.code, code, "time %d", v.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := auth.TOTPStep(now)
+
+	t.Run("Current code is accepted", func(t *testing.T) {
+		matched, ok := auth.ValidateTOTP(rfcTOTPSecret, "081804", now, 0)
+		assert.True(t, ok)
+		assert.Equal(t, step, matched)
+	})
+
+	t.Run("Previous period is accepted for clock drift", func(t *testing.T) {
+		previous, _ := auth.TOTPCode(rfcTOTPSecret, step-1)
+		_, ok := auth.ValidateTOTP(rfcTOTPSecret, previous, now, 0)
+		assert.True(t, ok)
+	})
+
+	t.Run("Old code is rejected", func(t *testing.T) {
+		old, _ := auth.TOTPCode(rfcTOTPSecret, step-5)
+		_, ok := auth.ValidateTOTP(rfcTOTPSecret, old, now, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("Used code cannot be replayed", func(t *testing.T) {
+		_, ok := auth.ValidateTOTP(rfcTOTPSecret, "081804", now, step)
+		assert.False(t, ok)
+	})
+
+	t.Run("Malformed code is rejected", func(t *testing.T) {
+		_, ok := auth.ValidateTOTP(rfcTOTPSecret, "81804", now, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	assert.NoError(t, err)
+
+	uri, err := url.Parse(auth.TOTPProvisioningURI("Go Auth API", "operator@example.com", secret))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Go Auth API:operator@example.com", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "Go Auth API", uri.Query().Get("issuer"))
+
+	codes, err := auth.GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	assert.Equal(t, auth.HashRecoveryCode(codes[0]), auth.HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+}
+
+// staticPolicy is an MFAPolicyChecker backed by a map of roles
+type staticPolicy map[string]bool
+
+func (p staticPolicy) RequiresMFA(role string) bool {
+	return p[role]
+}
+
+func TestRequireMFA(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jwtService := auth.NewJWTService("test-secret-key", 15*time.Minute)
+	router := gin.New()
+	router.GET("/devices/allowed",
+		middleware.AuthMiddleware(jwtService, nil, nil),
+		middleware.RequireMFA(staticPolicy{models.RoleOperator: true}),
+		func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+
+	do := func(role string, amr ...string) int {
+		token, err := jwtService.GenerateToken(uuid.New(), "test@example.com", role, amr...)
+		assert.NoError(t, err)
+
+		req, _ := http.NewRequest("GET", "/devices/allowed", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, do(models.RoleOperator, auth.AMRPassword))
+	assert.Equal(t, http.StatusOK, do(models.RoleOperator, auth.AMRPassword, auth.AMROTP))
+	assert.Equal(t, http.StatusOK, do(models.RoleCustomer, auth.AMRPassword))
+}
@@ -3,9 +3,11 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"go-auth-api/internal/auth"
 	"go-auth-api/internal/handlers"
@@ -51,6 +53,21 @@ func (m *MockUserService) UpdateUser(id string, req *models.UpdateUserRequest) (
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+func (m *MockUserService) UpdateUserRole(id string, role string) (*models.User, error) {
+	args := m.Called(id, role)
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) SuspendUser(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ReactivateUser(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
 func (m *MockUserService) DeleteUser(id string) error {
 	args := m.Called(id)
 	return args.Error(0)
@@ -173,6 +190,27 @@ func TestUserLogin(t *testing.T) {
 	})
 }
 
+func TestDeleteUserKeepsOrganizationOwners(t *testing.T) {
+	router, mockService := setupTestRouter()
+	userHandler := handlers.NewUserHandler(mockService)
+	router.DELETE("/api/v1/users/:id", userHandler.DeleteUser)
+
+	soleOwner, coOwner := uuid.New().String(), uuid.New().String()
+	mockService.On("DeleteUser", soleOwner).Return(fmt.Errorf("%w, the user is its only owner", models.ErrLastOwner))
+	mockService.On("DeleteUser", coOwner).Return(nil)
+
+	deleteUser := func(id string) int {
+		req, _ := http.NewRequest("DELETE", "/api/v1/users/"+id, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusConflict, deleteUser(soleOwner))
+	assert.Equal(t, http.StatusOK, deleteUser(coOwner))
+	mockService.AssertExpectations(t)
+}
+
 func TestPasswordHashing(t *testing.T) {
 	password := "testpassword123"
 
@@ -192,12 +230,12 @@ func TestPasswordHashing(t *testing.T) {
 }
 
 func TestJWTService(t *testing.T) {
-	jwtService := auth.NewJWTService("test-secret-key")
+	jwtService := auth.NewJWTService("test-secret-key", 15*time.Minute)
 	userID := uuid.New()
 	email := "test@example.com"
 
 	// Test token generation
-	token, err := jwtService.GenerateToken(userID, email)
+	token, err := jwtService.GenerateToken(userID, email, models.RoleCustomer)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
@@ -206,6 +244,7 @@ func TestJWTService(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, userID, claims.UserID)
 	assert.Equal(t, email, claims.Email)
+	assert.Equal(t, models.RoleCustomer, claims.Role)
 
 	// Test invalid token
 	_, err = jwtService.ValidateToken("invalid-token")